@@ -0,0 +1,92 @@
+package uploader
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	AckModeManual = "manual"
+	AckModeAuto   = "auto"
+
+	DefaultAckMode = AckModeManual
+)
+
+// subscribeOptions returns the subscribe options for the configured ack mode.
+// In auto mode the server is acked when the handler returns, so failures are
+// logged but never redelivered.
+func (u *Uploader) subscribeOptions() []nats.SubOpt {
+
+	opts := u.consumerOptions()
+
+	if u.ackMode != AckModeAuto {
+		opts = append(opts, nats.ManualAck())
+	}
+
+	return opts
+}
+
+func (u *Uploader) ack(m *nats.Msg) {
+
+	if u.ackMode == AckModeAuto {
+		return
+	}
+
+	m.Ack()
+}
+
+func (u *Uploader) nak(m *nats.Msg) {
+
+	if u.ackMode == AckModeAuto {
+		return
+	}
+
+	m.Nak()
+}
+
+func (u *Uploader) nakWithDelay(m *nats.Msg, delay time.Duration) {
+
+	if u.ackMode == AckModeAuto {
+		return
+	}
+
+	m.NakWithDelay(delay)
+}
+
+func (u *Uploader) term(m *nats.Msg) {
+
+	if u.ackMode == AckModeAuto {
+		return
+	}
+
+	m.Term()
+}
+
+// isPermanent reports whether redelivering the message can't succeed, so it
+// should be Term'd instead of Nak'd.
+func isPermanent(err error) bool {
+
+	permanent := []error{
+		ErrArchiveCollision,
+		ErrInvalidPayload,
+		ErrPayloadTooLarge,
+		ErrSubjectToken,
+		ErrReindexChanged,
+		ErrNoMetadata,
+		ErrSourceMissing,
+		ErrNameTooLong,
+		ErrArchiveDir,
+		ErrCompletionNoStream,
+		ErrURLClientStatus,
+	}
+
+	for _, target := range permanent {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
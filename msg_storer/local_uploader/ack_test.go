@@ -0,0 +1,20 @@
+package uploader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeOptionsByAckMode(t *testing.T) {
+	u := newTestUploader(t)
+
+	u.ackMode = AckModeManual
+	manual := u.subscribeOptions()
+
+	u.ackMode = AckModeAuto
+	auto := u.subscribeOptions()
+
+	// manual mode adds nats.ManualAck()
+	assert.Len(t, manual, len(auto)+1)
+}
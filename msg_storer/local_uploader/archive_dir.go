@@ -0,0 +1,148 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultDirMode  = 0750
+	DefaultDirUmask = "027"
+)
+
+var (
+	ErrArchiveDir = errors.New("Cannot create archive directory (permission?).")
+	ErrIndexDir   = errors.New("Cannot create index directory (permission?).")
+)
+
+// parseUmask parses an octal umask like 027.
+func parseUmask(value string) (fs.FileMode, error) {
+
+	umask, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || umask > 0777 {
+		return 0, fmt.Errorf("invalid dir_umask: %s", value)
+	}
+
+	return fs.FileMode(umask), nil
+}
+
+// mkdirArchiveDir creates the archive directory. A permission error won't go
+// away by redelivering, so it is reported as ErrArchiveDir to Term the message,
+// unless fixing the permissions of the existing directories lets it succeed.
+func (u *Uploader) mkdirArchiveDir(dir string) error {
+
+	if err := u.mkdirAll(dir); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%w (%s): %w", ErrArchiveDir, dir, err)
+		}
+		return err
+	}
+
+	u.enforceDirMode(dir)
+
+	return nil
+}
+
+// mkdirIndexDir creates the directory of the index file, which isn't created
+// by the move once the index lives apart from the archive.
+func (u *Uploader) mkdirIndexDir(dir string) error {
+
+	if err := u.mkdirAll(dir); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%w (%s): %w", ErrIndexDir, dir, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// mkdirAll creates the directory, retrying once after fixing the permissions
+// of the existing directories when fix_dir_permissions is set.
+func (u *Uploader) mkdirAll(dir string) error {
+
+	fsys := u.fileSystem()
+
+	err := fsys.MkdirAll(dir, DefaultDirMode)
+	if err == nil || !errors.Is(err, os.ErrPermission) || !u.fixDirPermissions {
+		return err
+	}
+
+	if ferr := u.fixPermissions(dir); ferr != nil {
+		u.logger.Warn("Failed to fix directory permissions",
+			zap.String("dir", dir),
+			zap.Error(ferr),
+		)
+		return err
+	}
+
+	return fsys.MkdirAll(dir, DefaultDirMode)
+}
+
+// fixPermissions sets the mode allowed by the umask on the existing directories
+// between the archivestore and dir.
+func (u *Uploader) fixPermissions(dir string) error {
+
+	fsys := u.fileSystem()
+	mode := fs.ModePerm &^ u.dirUmask
+
+	root := path.Clean(u.archivestore)
+	if !strings.HasPrefix(path.Clean(dir), root+"/") {
+		root = path.Clean(dir)
+	}
+	rel := strings.TrimPrefix(path.Clean(dir), root)
+
+	p := root
+	for _, component := range append([]string{""}, strings.Split(strings.Trim(rel, "/"), "/")...) {
+		p = path.Join(p, component)
+
+		fi, err := fsys.Stat(p)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !fi.IsDir() || fi.Mode().Perm() == mode {
+			continue
+		}
+
+		if err := fsys.Chmod(p, mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enforceDirMode converges the existing directories of the archive tree to
+// the mode allowed by the umask when enforce_dir_mode is set, as MkdirAll
+// leaves existing directories alone. Failures are only logged.
+func (u *Uploader) enforceDirMode(dir string) {
+
+	if !u.enforceDirModes {
+		return
+	}
+
+	if _, done := u.enforcedDirs.Load(dir); done {
+		return
+	}
+
+	if err := u.fixPermissions(dir); err != nil {
+		u.logger.Warn("Failed to enforce archive directory mode",
+			zap.String("dir", dir),
+			zap.Error(err),
+		)
+		return
+	}
+
+	u.enforcedDirs.Store(dir, true)
+}
@@ -0,0 +1,217 @@
+package uploader
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+// deniedFileSystem fails to create directories below denied with EACCES until
+// the mode of its parent is fixed.
+type deniedFileSystem struct {
+	osFileSystem
+	denied string
+	fixed  bool
+}
+
+func (f *deniedFileSystem) MkdirAll(p string, perm fs.FileMode) error {
+
+	if !f.fixed && strings.HasPrefix(p, f.denied) {
+		// the tree is created up to the permission boundary
+		if err := os.MkdirAll(path.Dir(f.denied), perm); err != nil {
+			return err
+		}
+		return &fs.PathError{Op: "mkdir", Path: f.denied, Err: syscall.EACCES}
+	}
+
+	return f.osFileSystem.MkdirAll(p, perm)
+}
+
+func (f *deniedFileSystem) Chmod(name string, mode fs.FileMode) error {
+
+	if name == path.Dir(f.denied) {
+		f.fixed = true
+	}
+
+	return f.osFileSystem.Chmod(name, mode)
+}
+
+func TestMkdirArchiveDirPermission(t *testing.T) {
+	u := newTestUploader(t)
+	u.recordFailures = true
+	u.fs = &deniedFileSystem{denied: path.Join(u.archivestore, "100/300")}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	_, err := u.Archive("1", filename)
+	assert.True(t, errors.Is(err, ErrArchiveDir))
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.True(t, isPermanent(err))
+	assert.Equal(t, ErrorClassPermission, classifyError(err))
+
+	// the message is Term'd instead of Nak'd forever
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	})
+
+	records, err := ReadFailures(u.failureLogFilename(filename))
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Contains(t, records[0].Error, "Cannot create archive directory")
+	}
+
+	_, err = os.Stat(filename)
+	assert.Nil(t, err)
+}
+
+func TestMkdirArchiveDirFixPermissions(t *testing.T) {
+	u := newTestUploader(t)
+	u.fixDirPermissions = true
+	u.dirUmask = 022
+	u.fs = &deniedFileSystem{denied: path.Join(u.archivestore, "100/300")}
+
+	// a directory on the way lacks the write permission
+	if err := os.MkdirAll(path.Join(u.archivestore, "100"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path.Join(u.archivestore, "100"), 0500); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	fi, err := os.Stat(path.Join(u.archivestore, "100"))
+	assert.Nil(t, err)
+	assert.Equal(t, fs.FileMode(0755), fi.Mode().Perm())
+}
+
+func TestMkdirArchiveDirTransient(t *testing.T) {
+	u := newTestUploader(t)
+
+	// a file in the way isn't a permission error
+	if err := os.MkdirAll(u.archivestore, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(u.archivestore, "100"), []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := u.mkdirArchiveDir(path.Join(u.archivestore, "100/300"))
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrArchiveDir))
+}
+
+func TestParseUmask(t *testing.T) {
+	umask, err := parseUmask(DefaultDirUmask)
+	assert.Nil(t, err)
+	assert.Equal(t, fs.FileMode(027), umask)
+
+	_, err = parseUmask("999")
+	assert.NotNil(t, err)
+
+	_, err = parseUmask("1777")
+	assert.NotNil(t, err)
+}
+
+// chmodFailingFileSystem fails every Chmod.
+type chmodFailingFileSystem struct {
+	osFileSystem
+}
+
+func (chmodFailingFileSystem) Chmod(name string, mode fs.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: syscall.EPERM}
+}
+
+func TestEnforceDirMode(t *testing.T) {
+	u := newTestUploader(t)
+	u.dirUmask = 027
+	u.enforceDirModes = true
+
+	// pre-existing directories with the wrong mode
+	dir := path.Join(u.archivestore, "100/100")
+	assert.Nil(t, os.MkdirAll(dir, 0700))
+	assert.Nil(t, os.Chmod(path.Join(u.archivestore, "100"), 0777))
+	assert.Nil(t, os.Chmod(dir, 0700))
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	for _, p := range []string{u.archivestore, path.Join(u.archivestore, "100"), dir} {
+		fi, err := os.Stat(p)
+		assert.Nil(t, err)
+		assert.Equal(t, fs.FileMode(0750), fi.Mode().Perm(), p)
+	}
+}
+
+func TestEnforceDirModeDisabled(t *testing.T) {
+	u := newTestUploader(t)
+	u.dirUmask = 027
+
+	dir := path.Join(u.archivestore, "100/100")
+	assert.Nil(t, os.MkdirAll(dir, 0700))
+	assert.Nil(t, os.Chmod(dir, 0700))
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	fi, err := os.Stat(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, fs.FileMode(0700), fi.Mode().Perm())
+}
+
+func TestEnforceDirModeFailure(t *testing.T) {
+	u := newTestUploader(t)
+	u.fs = chmodFailingFileSystem{}
+	u.dirUmask = 027
+	u.enforceDirModes = true
+
+	assert.Nil(t, os.MkdirAll(path.Join(u.archivestore, "100/100"), 0700))
+
+	// only logged
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+}
+
+func TestMkdirIndexDir(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := path.Join(u.datastore, "100/missing/MSG_1.db")
+
+	err := u.updateIndex(filename, path.Join(u.archivestore, "100/missing/MSG_1.db"), "1")
+	assert.Nil(t, err)
+
+	fi, err := os.Stat(path.Join(u.datastore, "100/missing"))
+	if assert.Nil(t, err) {
+		assert.True(t, fi.IsDir())
+	}
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/missing/MSG_1.db"), entry.ArchiveName)
+}
+
+func TestMkdirIndexDirPermission(t *testing.T) {
+	u := newTestUploader(t)
+	u.fs = &deniedFileSystem{denied: path.Join(u.datastore, "100/denied")}
+
+	filename := path.Join(u.datastore, "100/denied/MSG_1.db")
+
+	err := u.updateIndex(filename, path.Join(u.archivestore, "100/denied/MSG_1.db"), "1")
+	assert.True(t, errors.Is(err, ErrIndexDir))
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.Equal(t, ErrorClassPermission, classifyError(err))
+}
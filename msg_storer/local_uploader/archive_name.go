@@ -0,0 +1,23 @@
+package uploader
+
+import (
+	"path"
+)
+
+const (
+	DefaultArchivePrefix = ""
+	DefaultArchiveSuffix = ""
+)
+
+// decorateArchiveName applies the prefix and suffix to the base name of the
+// archive file, followed by the extension of the compression.
+func (u *Uploader) decorateArchiveName(archiveName string) string {
+
+	if u.archivePrefix == "" && u.archiveSuffix == "" && u.compression == "" {
+		return archiveName
+	}
+
+	dir, base := path.Split(archiveName)
+
+	return dir + u.archivePrefix + base + u.archiveSuffix + compressionExt(u.compression)
+}
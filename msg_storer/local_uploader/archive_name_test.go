@@ -0,0 +1,69 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchivePrefix(t *testing.T) {
+	u := newTestUploader(t)
+	u.archivePrefix = "old_"
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/300/old_MSG_1.db"), archiveName)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+}
+
+func TestArchiveSuffix(t *testing.T) {
+	u := newTestUploader(t)
+	u.archiveSuffix = ".archived"
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/300/MSG_1.db.archived"), archiveName)
+
+	_, err = os.Stat(archiveName)
+	assert.Nil(t, err)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+}
+
+func TestArchiveSuffixWithCompression(t *testing.T) {
+	u := newTestUploader(t)
+	u.archivePrefix = "old_"
+	u.archiveSuffix = ".archived"
+	u.compression = CompressionGzip
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/300/old_MSG_1.db.archived.gz"), archiveName)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+	assert.Equal(t, CompressionGzip, entry.Compression)
+}
+
+func TestDecorateArchiveName(t *testing.T) {
+	u := newTestUploader(t)
+
+	assert.Equal(t, "/archivestore/MSG_1.db", u.decorateArchiveName("/archivestore/MSG_1.db"))
+
+	u.compression = CompressionGzip
+	assert.Equal(t, "/archivestore/MSG_1.db.gz", u.decorateArchiveName("/archivestore/MSG_1.db"))
+}
@@ -0,0 +1,39 @@
+package uploader
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchivePathFor(t *testing.T) {
+	u := newTestUploader(t)
+	u.partitionByHost = true
+	u.partitionByDate = "2006/01"
+	u.archivePrefix = "old_"
+	u.archiveSuffix = ".archived"
+	u.compression = CompressionGzip
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	predicted, err := u.ArchivePathFor(filename)
+	assert.Nil(t, err)
+
+	// nothing is created
+	_, err = os.Stat(u.archivestore)
+	assert.True(t, os.IsNotExist(err))
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, predicted)
+}
+
+func TestArchivePathForSamePath(t *testing.T) {
+	u := newTestUploader(t)
+	u.archivestore = u.datastore
+
+	_, err := u.ArchivePathFor(u.datastore + "/100/300/MSG_1.db")
+	assert.True(t, errors.Is(err, ErrSameArchivePath))
+}
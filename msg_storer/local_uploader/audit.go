@@ -0,0 +1,192 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultAuditInterval  = 0
+	DefaultAuditBatchSize = 1000
+	DefaultAuditRate      = 100
+	DefaultAuditFileMode  = ""
+	DefaultAuditOwner     = -1
+	DefaultAuditFix       = false
+	DefaultAuditEvents    = false
+
+	ActionAudit = "audit"
+)
+
+var (
+	ErrAuditDeviation = errors.New("Archive path deviates from the expected owner or mode.")
+
+	errAuditBatchDone = errors.New("audit batch done")
+)
+
+// parseFileMode parses an octal mode like 0640, an empty mode isn't audited.
+func parseFileMode(value string) (fs.FileMode, error) {
+
+	if value == "" {
+		return 0, nil
+	}
+
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("invalid audit_file_mode: %s", value)
+	}
+
+	return fs.FileMode(mode), nil
+}
+
+// auditBatch checks the next audit_batch_size paths of the archivestore
+// against the expected owner and mode, at most audit_rate paths per second.
+// Like the background verification, the walk continues where the previous
+// batch stopped and starts over after the last path.
+func (u *Uploader) auditBatch(ctx context.Context) error {
+
+	var interval time.Duration
+	if u.auditRate > 0 {
+		interval = time.Second / time.Duration(u.auditRate)
+	}
+
+	start := u.auditCursor
+	pos := 0
+	checked := 0
+
+	err := filepath.WalkDir(u.archivestore, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if pos < start {
+			pos++
+			return nil
+		}
+
+		if checked >= u.auditBatchSize {
+			return errAuditBatchDone
+		}
+
+		if checked > 0 && interval > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+
+		pos++
+		checked++
+
+		u.auditPath(p, d)
+
+		return nil
+	})
+	if errors.Is(err, errAuditBatchDone) {
+		u.auditCursor = pos
+		return nil
+	}
+
+	// start over with the next batch
+	u.auditCursor = 0
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// auditPath reports the deviations of the path, and corrects them when
+// audit_fix is set. Symlinks are skipped.
+func (u *Uploader) auditPath(p string, d fs.DirEntry) {
+
+	if d.Type()&fs.ModeSymlink != 0 {
+		return
+	}
+
+	fi, err := d.Info()
+	if err != nil {
+		return
+	}
+
+	u.counters.audited.Add(1)
+
+	mode := u.auditFileMode
+	if fi.IsDir() {
+		mode = fs.ModePerm &^ u.dirUmask
+	}
+
+	reasons := make([]string, 0)
+	if mode != 0 && fi.Mode().Perm() != mode {
+		reasons = append(reasons, fmt.Sprintf("mode %#o != %#o", fi.Mode().Perm(), mode))
+	}
+
+	uid, gid, ok := fileOwner(fi)
+	if ok && u.auditUID >= 0 && uid != u.auditUID {
+		reasons = append(reasons, fmt.Sprintf("uid %d != %d", uid, u.auditUID))
+	}
+	if ok && u.auditGID >= 0 && gid != u.auditGID {
+		reasons = append(reasons, fmt.Sprintf("gid %d != %d", gid, u.auditGID))
+	}
+
+	if len(reasons) == 0 {
+		return
+	}
+
+	u.counters.auditDeviations.Add(1)
+
+	err = fmt.Errorf("%w (%s): %s", ErrAuditDeviation, p, strings.Join(reasons, ", "))
+	u.logger.Warn(err.Error(),
+		zap.String("path", p),
+		zap.Strings("reasons", reasons),
+	)
+
+	if u.auditEvents {
+		u.publishFailure(nil, "", p, ActionAudit, err)
+	}
+
+	if !u.auditFix {
+		return
+	}
+
+	if err := u.fixAudited(p, fi, mode); err != nil {
+		u.logger.Error("Failed to correct archive path",
+			zap.String("path", p),
+			zap.Error(err),
+		)
+		return
+	}
+
+	u.counters.auditFixed.Add(1)
+}
+
+func (u *Uploader) fixAudited(p string, fi fs.FileInfo, mode fs.FileMode) error {
+
+	if mode != 0 && fi.Mode().Perm() != mode {
+		if err := u.fileSystem().Chmod(p, mode); err != nil {
+			return err
+		}
+	}
+
+	if u.auditUID >= 0 || u.auditGID >= 0 {
+		return os.Lchown(p, u.auditUID, u.auditGID)
+	}
+
+	return nil
+}
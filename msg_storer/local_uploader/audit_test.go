@@ -0,0 +1,102 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuditUploader(t *testing.T) *Uploader {
+	u := newTestUploader(t)
+	u.dirUmask = 0027
+	u.auditBatchSize = DefaultAuditBatchSize
+	u.auditUID = DefaultAuditOwner
+	u.auditGID = DefaultAuditOwner
+
+	assert.Nil(t, os.MkdirAll(path.Join(u.archivestore, "100/200"), 0750))
+	assert.Nil(t, os.WriteFile(path.Join(u.archivestore, "100/200/MSG_1.db"), []byte("data"), 0640))
+
+	// changed by an external process
+	assert.Nil(t, os.Chmod(path.Join(u.archivestore, "100"), 0777))
+
+	return u
+}
+
+func TestAudit(t *testing.T) {
+	u := newAuditUploader(t)
+
+	assert.Nil(t, u.auditBatch(context.Background()))
+
+	stats := u.Stats()
+	assert.Equal(t, uint64(4), stats.Audited)
+	assert.Equal(t, uint64(1), stats.AuditDeviations)
+	assert.Equal(t, uint64(0), stats.AuditFixed)
+
+	// reported only
+	fi, err := os.Stat(path.Join(u.archivestore, "100"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0777), fi.Mode().Perm())
+}
+
+func TestAuditFix(t *testing.T) {
+	u := newAuditUploader(t)
+	u.auditFix = true
+	u.auditFileMode = 0600
+
+	assert.Nil(t, u.auditBatch(context.Background()))
+
+	stats := u.Stats()
+	assert.Equal(t, uint64(2), stats.AuditDeviations)
+	assert.Equal(t, uint64(2), stats.AuditFixed)
+
+	fi, err := os.Stat(path.Join(u.archivestore, "100"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+
+	fi, err = os.Stat(path.Join(u.archivestore, "100/200/MSG_1.db"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	// converged
+	assert.Nil(t, u.auditBatch(context.Background()))
+	assert.Equal(t, uint64(2), u.Stats().AuditDeviations)
+}
+
+func TestAuditOwner(t *testing.T) {
+	u := newAuditUploader(t)
+	assert.Nil(t, os.Chmod(path.Join(u.archivestore, "100"), 0750))
+	u.auditUID = os.Getuid() + 1
+
+	assert.Nil(t, u.auditBatch(context.Background()))
+	assert.Equal(t, uint64(4), u.Stats().AuditDeviations)
+}
+
+func TestAuditBatch(t *testing.T) {
+	u := newAuditUploader(t)
+	u.auditBatchSize = 3
+
+	assert.Nil(t, u.auditBatch(context.Background()))
+	assert.Equal(t, 3, u.auditCursor)
+	assert.Equal(t, uint64(3), u.Stats().Audited)
+
+	// the rest, then over again
+	assert.Nil(t, u.auditBatch(context.Background()))
+	assert.Equal(t, 0, u.auditCursor)
+	assert.Equal(t, uint64(4), u.Stats().Audited)
+}
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := parseFileMode("0640")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), mode)
+
+	mode, err = parseFileMode("")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0), mode)
+
+	_, err = parseFileMode("rw-r-----")
+	assert.NotNil(t, err)
+}
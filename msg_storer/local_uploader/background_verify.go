@@ -0,0 +1,122 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultVerifyInterval  = 0
+	DefaultVerifyBatchSize = 100
+	DefaultVerifyRate      = 10
+	DefaultVerifyEvents    = false
+
+	ActionVerify = "verify"
+)
+
+var (
+	ErrVerifyFailed = errors.New("Archive file doesn't match its index entry.")
+
+	errVerifyBatchDone = errors.New("verify batch done")
+)
+
+// verifyBatch checks the next verify_batch_size index entries against their
+// archive files, at most verify_rate entries per second so that it doesn't
+// compete with ingestion for IO. The walk continues where the previous batch
+// stopped and starts over after the last entry.
+func (u *Uploader) verifyBatch(ctx context.Context) error {
+
+	var interval time.Duration
+	if u.verifyRate > 0 {
+		interval = time.Second / time.Duration(u.verifyRate)
+	}
+
+	start := u.verifyCursor
+	pos := 0
+	checked := 0
+
+	files, err := u.indexFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	visit := func(entry Entry) error {
+
+		if pos < start {
+			pos++
+			return nil
+		}
+
+		if checked >= u.verifyBatchSize {
+			return errVerifyBatchDone
+		}
+
+		if checked > 0 && interval > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+
+		pos++
+		checked++
+
+		u.verifyEntry(entry)
+
+		return nil
+	}
+
+	for _, indexFilename := range files {
+		if err = u.eachIndexEntry(ctx, indexFilename, visit); err != nil {
+			break
+		}
+	}
+	if errors.Is(err, errVerifyBatchDone) {
+		u.verifyCursor = pos
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// start over with the next batch
+	u.verifyCursor = 0
+
+	return nil
+}
+
+func (u *Uploader) verifyEntry(entry Entry) {
+
+	reason, err := checkEntry(entry)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return
+	}
+
+	u.counters.verified.Add(1)
+
+	if reason == "" {
+		return
+	}
+
+	u.counters.verifyFailures.Add(1)
+
+	err = fmt.Errorf("%w (seq: %s, archiveName: %s): %s", ErrVerifyFailed, entry.Seq, entry.ArchiveName, reason)
+	u.logger.Warn(err.Error(),
+		zap.String("seq", entry.Seq),
+		zap.String("archiveName", entry.ArchiveName),
+		zap.String("reason", reason),
+	)
+
+	if u.verifyEvents {
+		u.publishFailure(nil, entry.Seq, entry.Filename, ActionVerify, err)
+	}
+}
@@ -0,0 +1,116 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareVerify(t *testing.T, u *Uploader, count int) []string {
+	u.checksum = true
+
+	archiveNames := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		filename := createTestFile(t, u, fmt.Sprintf("100/100/MSG_%d.db", i), fmt.Sprintf("data-%d", i))
+		archiveName, err := u.Archive(fmt.Sprint(i), filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archiveNames = append(archiveNames, archiveName)
+	}
+
+	return archiveNames
+}
+
+func TestVerifyBatch(t *testing.T) {
+	u := newTestUploader(t)
+	u.verifyBatchSize = 2
+	u.verifyRate = 1000
+
+	archiveNames := prepareVerify(t, u, 3)
+
+	// same size, different content
+	assert.Nil(t, os.WriteFile(archiveNames[2], []byte("data-x"), 0644))
+
+	// the first batch only covers the first entries
+	assert.Nil(t, u.verifyBatch(context.Background()))
+	assert.Equal(t, uint64(2), u.Stats().Verified)
+	assert.Equal(t, uint64(0), u.Stats().VerifyFailures)
+
+	// the next batch continues with the corrupt entry
+	assert.Nil(t, u.verifyBatch(context.Background()))
+	assert.Equal(t, uint64(3), u.Stats().Verified)
+	assert.Equal(t, uint64(1), u.Stats().VerifyFailures)
+
+	// and the walk starts over
+	assert.Equal(t, 0, u.verifyCursor)
+	assert.Nil(t, u.verifyBatch(context.Background()))
+	assert.Equal(t, uint64(5), u.Stats().Verified)
+}
+
+func TestVerifyBatchRateLimit(t *testing.T) {
+	u := newTestUploader(t)
+	u.verifyBatchSize = 10
+	u.verifyRate = 20
+
+	prepareVerify(t, u, 3)
+
+	begin := time.Now()
+	assert.Nil(t, u.verifyBatch(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(begin), 2*time.Second/20)
+
+	// a cancelled context stops the walk
+	u.verifyRate = 1
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, u.verifyBatch(ctx), context.DeadlineExceeded)
+}
+
+func (s *TestSuite) TestVerifyEvents() {
+	u := s.uploader
+	datastore, archivestore := u.datastore, u.archivestore
+	defer func() {
+		u.datastore, u.archivestore = datastore, archivestore
+		u.errorSubject = DefaultErrorSubject
+		u.verifyEvents = DefaultVerifyEvents
+		u.checksum = false
+	}()
+
+	root := s.T().TempDir()
+	u.datastore = path.Join(root, "datastore")
+	u.archivestore = path.Join(root, "archivestore")
+	u.errorSubject = "uploader-verify.errors"
+	u.verifyEvents = true
+	u.verifyBatchSize = DefaultVerifyBatchSize
+	u.verifyRate = 1000
+
+	archiveNames := prepareVerify(s.T(), u, 1)
+	s.Nil(os.Remove(archiveNames[0]))
+
+	sub, err := u.params.NATSConnector.GetConnection().SubscribeSync(u.errorSubject)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer sub.Unsubscribe()
+
+	s.Nil(u.verifyBatch(context.Background()))
+
+	m, err := sub.NextMsg(time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	var event FailureEvent
+	s.Nil(json.Unmarshal(m.Data, &event))
+	s.Equal("1", event.Seq)
+	s.Equal(ActionVerify, event.Action)
+	s.Equal(ErrorClassVerify, event.ErrorClass)
+}
@@ -0,0 +1,148 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	PartialFailureAllOrNothing = "all_or_nothing"
+	PartialFailureBestEffort   = "best_effort"
+
+	DefaultPartialFailureMode = PartialFailureAllOrNothing
+)
+
+func validatePartialFailureMode(mode string) error {
+
+	switch mode {
+	case PartialFailureAllOrNothing, PartialFailureBestEffort:
+		return nil
+	}
+
+	return fmt.Errorf("invalid partial_failure_mode: %s", mode)
+}
+
+// parseJobs parses a payload of newline separated seq:filename pairs.
+// Empty lines are ignored.
+func parseJobs(m *nats.Msg) ([]*job, error) {
+
+	jobs := make([]*job, 0)
+
+	for _, line := range strings.Split(string(m.Data), "\n") {
+
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		j, err := parseJob(&nats.Msg{Subject: m.Subject, Data: []byte(line)})
+		if err != nil {
+			return nil, err
+		}
+		j.msg = m
+
+		jobs = append(jobs, j)
+	}
+
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("%w (%s)", ErrInvalidPayload, string(m.Data))
+	}
+
+	return jobs, nil
+}
+
+type batchFailure struct {
+	job *job
+	err error
+}
+
+// handleBatch archives every file of a multiline payload. The message is only
+// acked once all files are archived, or with best_effort once any file is,
+// the others being recorded as failures. Files archived by an earlier
+// delivery are skipped, so a redelivery only retries the files which failed.
+func (u *Uploader) handleBatch(ctx context.Context, m *nats.Msg) error {
+
+	jobs, err := parseJobs(m)
+	if err != nil {
+		u.logger.Error(err.Error())
+		u.term(m)
+		u.publishFailure(m, "", string(m.Data), ActionTerm, err)
+		return err
+	}
+
+	failures := make([]batchFailure, 0)
+	permanent := true
+	for _, j := range jobs {
+		j.ctx = ctx
+		j.seq = u.seqFormat.normalize(j.seq)
+
+		archiveName, err := u.archiveOnce(j)
+		if err == nil {
+			err = u.publishCompletionTraced(ctx, j.seq, j.filename, archiveName)
+		}
+
+		if err != nil && isReadOnly(err) {
+			u.enterDegraded(err)
+			u.nakWithDelay(m, u.degradedNakDelay)
+			return err
+		}
+
+		if err != nil {
+			u.logger.Error(err.Error())
+			u.counters.failed.Add(1)
+			failures = append(failures, batchFailure{job: j, err: err})
+			permanent = permanent && isPermanent(err)
+		}
+	}
+
+	if len(failures) == 0 {
+		u.ack(m)
+		return nil
+	}
+
+	// Term only when redelivering can't help any of the failed files
+	action := ActionNak
+	switch {
+	case u.partialFailureMode == PartialFailureBestEffort && len(failures) < len(jobs):
+		action = ActionAck
+		u.ack(m)
+	case permanent:
+		action = ActionTerm
+		u.term(m)
+	default:
+		u.nak(m)
+	}
+
+	errs := make([]error, 0, len(failures))
+	for _, f := range failures {
+		if action != ActionNak {
+			u.recordFailure(f.job, f.err)
+		}
+		u.publishFailure(m, f.job.seq, f.job.filename, action, f.err)
+		errs = append(errs, f.err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// archiveOnce archives the file of the job unless it was already archived.
+func (u *Uploader) archiveOnce(j *job) (string, error) {
+
+	if entry, ok := u.archivedEntry(j); ok {
+		u.emitSkipped(j, entry.ArchiveName)
+		return entry.ArchiveName, nil
+	}
+
+	if u.sourceWait > 0 {
+		u.waitForSource(cleanFilename(j.filename))
+	}
+
+	archiveName, err := u.archive(j)
+	u.emitArchive(j, archiveName, err)
+
+	return archiveName, err
+}
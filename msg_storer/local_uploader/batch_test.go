@@ -0,0 +1,161 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func batchMsg(lines ...string) *nats.Msg {
+	return &nats.Msg{
+		Subject: "test",
+		Data:    []byte(strings.Join(lines, "\n")),
+	}
+}
+
+func TestBatchAllSucceed(t *testing.T) {
+	u := newTestUploader(t)
+	u.multilinePayload = true
+
+	a := createTestFile(t, u, "100/300/MSG_1.db", "one")
+	b := createTestFile(t, u, "100/300/MSG_2.db", "two")
+	c := createTestFile(t, u, "100/300/MSG_3.db", "three")
+
+	err := u.handleBatch(context.Background(), batchMsg("1:"+a, "2:"+b, "", "3:"+c, ""))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), u.counters.archived.Load())
+
+	// each file gets its own entry
+	entries, err := NewIndexReader(u.indexFilename(a)).List()
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "1", entries[0].Seq)
+		assert.Equal(t, "2", entries[1].Seq)
+		assert.Equal(t, "3", entries[2].Seq)
+	}
+}
+
+func TestBatchFailMidList(t *testing.T) {
+	u := newTestUploader(t)
+	u.multilinePayload = true
+
+	a := createTestFile(t, u, "100/300/MSG_1.db", "one")
+	b := path.Join(u.datastore, "100/300/MSG_2.db")
+	c := createTestFile(t, u, "100/300/MSG_3.db", "three")
+	m := batchMsg("1:"+a, "2:"+b, "3:"+c)
+
+	// the missing file Naks the message, the others are archived
+	err := u.handleBatch(context.Background(), m)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.False(t, isPermanent(err))
+	assert.Equal(t, uint64(2), u.counters.archived.Load())
+
+	// the redelivery only archives the file which failed
+	createTestFile(t, u, "100/300/MSG_2.db", "two")
+
+	err = u.handleBatch(context.Background(), m)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), u.counters.archived.Load())
+
+	entries, err := NewIndexReader(u.indexFilename(a)).List()
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "1", entries[0].Seq)
+		assert.Equal(t, "3", entries[1].Seq)
+		assert.Equal(t, "2", entries[2].Seq)
+	}
+}
+
+func TestBatchPermanentFailure(t *testing.T) {
+	u := newTestUploader(t)
+	u.multilinePayload = true
+	u.maxNameLength = 16
+	u.recordFailures = true
+
+	a := createTestFile(t, u, "100/300/MSG_1.db", "one")
+	b := createTestFile(t, u, "100/300/"+strings.Repeat("b", 20), "two")
+
+	err := u.handleBatch(context.Background(), batchMsg("1:"+a, "2:"+b))
+	assert.True(t, errors.Is(err, ErrNameTooLong))
+
+	records, err := ReadFailures(u.failureLogFilename(a))
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "2", records[0].Seq)
+	}
+}
+
+func TestBatchPartialFailureMode(t *testing.T) {
+	for _, mode := range []string{PartialFailureAllOrNothing, PartialFailureBestEffort} {
+		u := newTestUploader(t)
+		u.multilinePayload = true
+		u.recordFailures = true
+		u.partialFailureMode = mode
+
+		a := createTestFile(t, u, "100/300/MSG_1.db", "one")
+		b := path.Join(u.datastore, "100/300/MSG_2.db")
+		m := batchMsg("1:"+a, "2:"+b)
+
+		err := u.handleBatch(context.Background(), m)
+		assert.True(t, errors.Is(err, os.ErrNotExist), mode)
+		assert.Equal(t, uint64(1), u.counters.archived.Load(), mode)
+
+		// best_effort acks and gives up the missing file, all_or_nothing Naks
+		records, err := ReadFailures(u.failureLogFilename(a))
+		assert.Nil(t, err)
+		if mode == PartialFailureBestEffort {
+			if assert.Len(t, records, 1, mode) {
+				assert.Equal(t, "2", records[0].Seq)
+				assert.Equal(t, ErrorClassNotFound, records[0].ErrorClass)
+			}
+		} else {
+			assert.Empty(t, records, mode)
+		}
+
+		// a redelivery skips the archived file
+		err = u.handleBatch(context.Background(), m)
+		assert.True(t, errors.Is(err, os.ErrNotExist), mode)
+		assert.Equal(t, uint64(1), u.counters.archived.Load(), mode)
+
+		entries, err := NewIndexReader(u.indexFilename(a)).List()
+		assert.Nil(t, err)
+		assert.Len(t, entries, 1, mode)
+	}
+}
+
+func TestBatchBestEffortAllFail(t *testing.T) {
+	u := newTestUploader(t)
+	u.multilinePayload = true
+	u.recordFailures = true
+	u.partialFailureMode = PartialFailureBestEffort
+
+	a := path.Join(u.datastore, "100/300/MSG_1.db")
+	b := path.Join(u.datastore, "100/300/MSG_2.db")
+
+	// nothing archived, so the message is Nak'd like all_or_nothing
+	err := u.handleBatch(context.Background(), batchMsg("1:"+a, "2:"+b))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.NoFileExists(t, u.failureLogFilename(a))
+}
+
+func TestParseJobs(t *testing.T) {
+	jobs, err := parseJobs(batchMsg("1:a", "", "2:b\r"))
+	assert.Nil(t, err)
+	if assert.Len(t, jobs, 2) {
+		assert.Equal(t, "a", jobs[0].filename)
+		assert.Equal(t, "b", jobs[1].filename)
+		assert.NotNil(t, jobs[0].msg)
+	}
+
+	_, err = parseJobs(batchMsg("1:a", "b"))
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+
+	_, err = parseJobs(batchMsg("", ""))
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+}
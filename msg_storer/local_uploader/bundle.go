@@ -0,0 +1,284 @@
+package uploader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+const (
+	BundleFormat  = "whisper-index-bundle"
+	BundleVersion = 1
+)
+
+var (
+	ErrInvalidBundle = errors.New("Invalid index bundle.")
+)
+
+// BundleHeader is the first line of an index bundle. The checksum covers the
+// entry lines following it.
+type BundleHeader struct {
+	Format       string    `json:"format"`
+	Version      int       `json:"version"`
+	Scope        string    `json:"scope"`
+	Datastore    string    `json:"datastore"`
+	Archivestore string    `json:"archivestore"`
+	Indexes      int       `json:"indexes"`
+	Entries      int       `json:"entries"`
+	Checksum     string    `json:"sha256"`
+	Created      time.Time `json:"created"`
+}
+
+// bundleEntry is an index entry of a bundle, one JSON object per line.
+type bundleEntry struct {
+	Index       string    `json:"index"`
+	Seq         string    `json:"seq"`
+	ArchiveName string    `json:"archive_name"`
+	Size        int64     `json:"size,omitempty"`
+	Checksum    string    `json:"sha256,omitempty"`
+	Filename    string    `json:"src,omitempty"`
+	RawFilename string    `json:"raw,omitempty"`
+	Time        time.Time `json:"ts"`
+	Compression string    `json:"z,omitempty"`
+	Evicted     time.Time `json:"evicted"`
+	Deleted     time.Time `json:"deleted"`
+	Counter     uint64    `json:"n,omitempty"`
+
+	Labels  map[string]string `json:"labels,omitempty"`
+	Logical string            `json:"logical,omitempty"`
+	URL     string            `json:"url,omitempty"`
+}
+
+func newBundleEntry(index string, entry Entry) bundleEntry {
+	return bundleEntry{
+		Index:       index,
+		Seq:         entry.Seq,
+		ArchiveName: entry.ArchiveName,
+		Size:        entry.Size,
+		Checksum:    entry.Checksum,
+		Filename:    entry.Filename,
+		RawFilename: entry.RawFilename,
+		Time:        entry.Time,
+		Compression: entry.Compression,
+		Evicted:     entry.Evicted,
+		Deleted:     entry.Deleted,
+		Counter:     entry.Counter,
+		Labels:      entry.Labels,
+		Logical:     entry.Logical,
+		URL:         entry.URL,
+	}
+}
+
+func (e bundleEntry) entry() Entry {
+	return Entry{
+		Seq:         e.Seq,
+		ArchiveName: e.ArchiveName,
+		Size:        e.Size,
+		Checksum:    e.Checksum,
+		Filename:    e.Filename,
+		RawFilename: e.RawFilename,
+		Time:        e.Time,
+		Compression: e.Compression,
+		Evicted:     e.Evicted,
+		Deleted:     e.Deleted,
+		Counter:     e.Counter,
+		Labels:      e.Labels,
+		Logical:     e.Logical,
+		URL:         e.URL,
+	}
+}
+
+// indexFiles returns the paths of all indexes.
+func (u *Uploader) indexFiles(ctx context.Context) ([]string, error) {
+
+	if u.sqlIndex != nil {
+		return u.sqlIndex.indexNames(ctx)
+	}
+
+	files := make([]string, 0)
+	err := u.eachIndexFile(ctx, func(indexFilename string) error {
+		files = append(files, indexFilename)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// ExportIndex writes every index entry, including rotated shards and
+// tombstones, to a bundle which ImportIndex restores, e.g. in another
+// environment. The bundle starts with a BundleHeader line followed by one
+// JSON entry per line.
+func (u *Uploader) ExportIndex(w io.Writer) error {
+
+	files, err := u.indexFiles(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// the header carries the checksum, so the entries are encoded first
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+
+	header := BundleHeader{
+		Format:       BundleFormat,
+		Version:      BundleVersion,
+		Scope:        u.scope,
+		Datastore:    path.Clean(u.datastore),
+		Archivestore: path.Clean(u.archivestore),
+		Indexes:      len(files),
+		Created:      time.Now(),
+	}
+
+	for _, indexFilename := range files {
+		entries, err := u.indexReader(indexFilename).List()
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := enc.Encode(newBundleEntry(indexFilename, entry)); err != nil {
+				return err
+			}
+			header.Entries++
+		}
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	header.Checksum = hex.EncodeToString(sum[:])
+
+	if err := json.NewEncoder(w).Encode(header); err != nil {
+		return err
+	}
+
+	_, err = body.WriteTo(w)
+
+	return err
+}
+
+// ImportIndex appends the entries of a bundle written by ExportIndex to the
+// indexes. Index paths, archive names and source files below the datastore
+// and the archivestore of the bundle are moved below the ones of this
+// uploader. The bundle is verified before anything is written, and entries
+// already indexed are skipped, so an import can be repeated.
+func (u *Uploader) ImportIndex(r io.Reader) error {
+
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(r)
+
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+
+	var header BundleHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+
+	if header.Format != BundleFormat || header.Version != BundleVersion {
+		return fmt.Errorf("%w (format: %s, version: %d)", ErrInvalidBundle, header.Format, header.Version)
+	}
+
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != header.Checksum {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidBundle)
+	}
+
+	entries := make([]bundleEntry, 0, header.Entries)
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for dec.More() {
+		var e bundleEntry
+		if err := dec.Decode(&e); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) != header.Entries {
+		return fmt.Errorf("%w: %d of %d entries", ErrInvalidBundle, len(entries), header.Entries)
+	}
+
+	datastore := path.Clean(u.datastore)
+	archivestore := path.Clean(u.archivestore)
+
+	// archive names already indexed, per index file
+	indexed := make(map[string]map[string]bool)
+
+	for _, e := range entries {
+		indexFilename := rebasePath(e.Index, header.Datastore, datastore)
+
+		entry := e.entry()
+		entry.ArchiveName = rebasePath(entry.ArchiveName, header.Archivestore, archivestore)
+		entry.Filename = rebasePath(entry.Filename, header.Datastore, datastore)
+		entry.RawFilename = rebasePath(entry.RawFilename, header.Datastore, datastore)
+
+		names, ok := indexed[indexFilename]
+		if !ok {
+			names, err = u.indexedEntries(indexFilename)
+			if err != nil {
+				return err
+			}
+			indexed[indexFilename] = names
+		}
+
+		key := entry.Seq + ":" + entry.ArchiveName
+		if names[key] {
+			continue
+		}
+
+		if err := u.importEntry(indexFilename, entry); err != nil {
+			return err
+		}
+		names[key] = true
+	}
+
+	return nil
+}
+
+// indexedEntries returns the seq:archiveName keys of the entries of the index.
+func (u *Uploader) indexedEntries(indexFilename string) (map[string]bool, error) {
+
+	entries, err := u.indexReader(indexFilename).List()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[entry.Seq+":"+entry.ArchiveName] = true
+	}
+
+	return names, nil
+}
+
+func (u *Uploader) importEntry(indexFilename string, entry Entry) error {
+
+	unlock := u.dirLocks.lock(indexFilename)
+	defer unlock()
+
+	if err := u.fileSystem().MkdirAll(path.Dir(indexFilename), 0750); err != nil {
+		return err
+	}
+
+	// the index of a datastore file is next to it
+	return u.appendEntry(indexFilename, entry)
+}
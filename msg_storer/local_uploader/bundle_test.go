@@ -0,0 +1,91 @@
+package uploader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImportIndex(t *testing.T) {
+	u := newTestUploader(t)
+	u.checksum = true
+
+	for i := 1; i <= 3; i++ {
+		filename := createTestFile(t, u, fmt.Sprintf("100/%d00/MSG_%d.db", i%2+1, i), "data")
+		_, err := u.Archive(fmt.Sprint(i), filename)
+		assert.Nil(t, err)
+	}
+
+	var bundle bytes.Buffer
+	assert.Nil(t, u.ExportIndex(&bundle))
+
+	line, err := bufio.NewReader(bytes.NewReader(bundle.Bytes())).ReadBytes('\n')
+	assert.Nil(t, err)
+
+	var header BundleHeader
+	assert.Nil(t, json.Unmarshal(line, &header))
+	assert.Equal(t, BundleFormat, header.Format)
+	assert.Equal(t, BundleVersion, header.Version)
+	assert.Equal(t, "uploader", header.Scope)
+	assert.Equal(t, u.archivestore, header.Archivestore)
+	assert.Equal(t, 2, header.Indexes)
+	assert.Equal(t, 3, header.Entries)
+	assert.NotEmpty(t, header.Checksum)
+
+	// another environment with other roots
+	other := newTestUploader(t)
+	assert.Nil(t, other.ImportIndex(bytes.NewReader(bundle.Bytes())))
+
+	// importing again skips the entries already indexed
+	assert.Nil(t, other.ImportIndex(bytes.NewReader(bundle.Bytes())))
+
+	for _, dir := range []string{"100/100", "100/200"} {
+		entries, err := NewIndexReader(path.Join(u.datastore, dir, DefaultArchiveIndex)).List()
+		assert.Nil(t, err)
+
+		imported, err := NewIndexReader(path.Join(other.datastore, dir, DefaultArchiveIndex)).List()
+		assert.Nil(t, err)
+
+		if assert.Len(t, imported, len(entries)) {
+			for i, entry := range entries {
+				entry.ArchiveName = rebasePath(entry.ArchiveName, u.archivestore, other.archivestore)
+				entry.Filename = rebasePath(entry.Filename, u.datastore, other.datastore)
+				entry.RawFilename = rebasePath(entry.RawFilename, u.datastore, other.datastore)
+				assert.True(t, entry.Time.Equal(imported[i].Time))
+				entry.Time = imported[i].Time
+				assert.Equal(t, entry, imported[i])
+			}
+		}
+	}
+}
+
+func TestImportIndexInvalid(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	var bundle bytes.Buffer
+	assert.Nil(t, u.ExportIndex(&bundle))
+
+	other := newTestUploader(t)
+
+	// a tampered entry fails the checksum
+	tampered := strings.Replace(bundle.String(), `"seq":"1"`, `"seq":"2"`, 1)
+	assert.ErrorIs(t, other.ImportIndex(strings.NewReader(tampered)), ErrInvalidBundle)
+
+	assert.ErrorIs(t, other.ImportIndex(strings.NewReader("{\"format\":\"tar\"}\n")), ErrInvalidBundle)
+	assert.ErrorIs(t, other.ImportIndex(strings.NewReader("")), ErrInvalidBundle)
+
+	// nothing was written
+	entries, err := NewIndexReader(path.Join(other.datastore, "100/100", DefaultArchiveIndex)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 0)
+}
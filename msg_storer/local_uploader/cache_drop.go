@@ -0,0 +1,29 @@
+package uploader
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrCacheDropUnsupported = errors.New("Dropping the page cache is not supported on this platform.")
+)
+
+// dropCache flushes the written file and evicts it from the page cache when
+// post_write_cache_drop is set, so the verification reads it back from the
+// storage rather than stale cached data. On failure the verification falls
+// back to a normal read.
+func (u *Uploader) dropCache(filename string) {
+
+	if !u.postWriteCacheDrop {
+		return
+	}
+
+	if err := dropPageCache(filename); err != nil {
+		u.logger.Warn("Failed to drop the page cache, verifying with a normal read",
+			zap.String("fileName", filename),
+			zap.Error(err),
+		)
+	}
+}
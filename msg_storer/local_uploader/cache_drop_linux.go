@@ -0,0 +1,26 @@
+//go:build linux
+
+package uploader
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropPageCache syncs the file, as dirty pages can't be dropped, and advises
+// the kernel to drop its cached pages.
+func dropPageCache(filename string) error {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	return unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package uploader
+
+func dropPageCache(filename string) error {
+	return ErrCacheDropUnsupported
+}
@@ -0,0 +1,35 @@
+package uploader
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyAfterCacheDrop(t *testing.T) {
+	u := newVerifyUploader(t, VerifyFull)
+	u.postWriteCacheDrop = true
+
+	content := strings.Repeat("0123456789", 1000)
+	filename := createTestFile(t, u, "100/300/MSG_1.db", content)
+	same := createTestFile(t, u, "100/300/MSG_1.same", content)
+	corrupt := createTestFile(t, u, "100/300/MSG_1.copy", "x"+content[1:])
+
+	if err := dropPageCache(same); err != nil {
+		t.Skipf("page cache drop unsupported: %v", err)
+	}
+
+	// read back from the storage after dropping the cache
+	assert.Nil(t, u.verifyCopy(filename, same, CompressionNone))
+	assert.True(t, errors.Is(u.verifyCopy(filename, corrupt, CompressionNone), ErrVerifyMismatch))
+}
+
+func TestCacheDropFallback(t *testing.T) {
+	u := newVerifyUploader(t, VerifyFull)
+	u.postWriteCacheDrop = true
+
+	// a failed drop only warns
+	u.dropCache("/nonexistent/MSG_1.db")
+}
@@ -0,0 +1,164 @@
+package uploader
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// comment lines aren't entries
+	IndexCommentPrefix = "#"
+
+	CheckpointPrefix    = "# checkpoint "
+	CheckpointCleanStop = "clean-stop"
+)
+
+// Checkpoint is a marker line appended to the index on a graceful stop, with
+// the number of entries the uploader appended to the index in its lifetime.
+type Checkpoint struct {
+	Time   time.Time
+	Count  uint64
+	Status string
+}
+
+func (c Checkpoint) String() string {
+	return fmt.Sprintf("%s%s %d %s", CheckpointPrefix, c.Time.UTC().Format(time.RFC3339Nano), c.Count, c.Status)
+}
+
+func parseCheckpoint(line string) (Checkpoint, bool) {
+
+	if !strings.HasPrefix(line, CheckpointPrefix) {
+		return Checkpoint{}, false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, CheckpointPrefix))
+	if len(fields) != 3 {
+		return Checkpoint{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return Checkpoint{}, false
+	}
+
+	count, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return Checkpoint{}, false
+	}
+
+	return Checkpoint{
+		Time:   t,
+		Count:  count,
+		Status: fields[2],
+	}, true
+}
+
+// StoppedCleanly reports whether the last line of the index is a clean stop
+// checkpoint. Entries appended after it, or a partial line, mean the uploader
+// writing them didn't stop gracefully, or is still running.
+func StoppedCleanly(indexFilename string) (bool, Checkpoint, error) {
+
+	f, err := os.Open(indexFilename)
+	if err != nil {
+		return false, Checkpoint{}, err
+	}
+	defer f.Close()
+
+	var last string
+	partial := false
+
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, Checkpoint{}, err
+		}
+
+		if line == "" {
+			break
+		}
+
+		if !strings.HasSuffix(line, "\n") {
+			partial = true
+			break
+		}
+
+		last = strings.TrimSuffix(line, "\n")
+	}
+
+	checkpoint, ok := parseCheckpoint(last)
+	if partial || !ok || checkpoint.Status != CheckpointCleanStop {
+		return false, checkpoint, nil
+	}
+
+	return true, checkpoint, nil
+}
+
+// indexCheckpoints counts the entries appended per index file, so a
+// checkpoint can be written to each index on stop.
+type indexCheckpoints struct {
+	counts sync.Map
+}
+
+func (c *indexCheckpoints) add(indexFilename string) {
+
+	count, _ := c.counts.LoadOrStore(indexFilename, &atomic.Uint64{})
+	count.(*atomic.Uint64).Add(1)
+}
+
+// writeCheckpoints appends a clean stop checkpoint to every index the
+// uploader appended to. Failures are only logged.
+func (u *Uploader) writeCheckpoints() {
+
+	if !u.indexCheckpoint || u.sqlIndex != nil {
+		return
+	}
+
+	now := time.Now()
+
+	u.checkpoints.counts.Range(func(key, value interface{}) bool {
+		indexFilename := key.(string)
+
+		checkpoint := Checkpoint{
+			Time:   now,
+			Count:  value.(*atomic.Uint64).Load(),
+			Status: CheckpointCleanStop,
+		}
+
+		if err := u.appendCheckpoint(indexFilename, checkpoint); err != nil {
+			u.logger.Warn("Failed to write index checkpoint",
+				zap.String("indexFilename", indexFilename),
+				zap.Error(err),
+			)
+		}
+
+		return true
+	})
+}
+
+func (u *Uploader) appendCheckpoint(indexFilename string, checkpoint Checkpoint) error {
+
+	unlock := u.dirLocks.lock(indexFilename)
+	defer unlock()
+
+	f, err := os.OpenFile(indexFilename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(checkpoint.String() + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,83 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexCheckpoint(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexCheckpoint = true
+
+	first := createTestFile(t, u, "100/300/MSG_1.db", "data")
+	second := createTestFile(t, u, "100/300/MSG_2.db", "data")
+
+	_, err := u.Archive("1", first)
+	assert.Nil(t, err)
+	_, err = u.Archive("2", second)
+	assert.Nil(t, err)
+
+	indexFilename := u.indexFilename(first)
+
+	clean, _, err := StoppedCleanly(indexFilename)
+	assert.Nil(t, err)
+	assert.False(t, clean)
+
+	assert.Nil(t, u.onStop(context.Background()))
+
+	data, err := os.ReadFile(indexFilename)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.True(t, strings.HasPrefix(lines[2], CheckpointPrefix))
+		assert.True(t, strings.HasSuffix(lines[2], " 2 "+CheckpointCleanStop))
+	}
+
+	clean, checkpoint, err := StoppedCleanly(indexFilename)
+	assert.Nil(t, err)
+	assert.True(t, clean)
+	assert.Equal(t, uint64(2), checkpoint.Count)
+
+	// the marker isn't an entry
+	entries, err := NewIndexReader(indexFilename).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+
+	report, err := u.Validate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Checked)
+	assert.Empty(t, report.Unclean)
+
+	// entries after the checkpoint, without a stop
+	third := createTestFile(t, u, "100/300/MSG_3.db", "data")
+	_, err = u.Archive("3", third)
+	assert.Nil(t, err)
+
+	clean, _, err = StoppedCleanly(indexFilename)
+	assert.Nil(t, err)
+	assert.False(t, clean)
+
+	report, err = u.Validate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{indexFilename}, report.Unclean)
+}
+
+func TestParseCheckpoint(t *testing.T) {
+	line := "# checkpoint 2024-03-01T10:00:00Z 5 clean-stop"
+
+	checkpoint, ok := parseCheckpoint(line)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(5), checkpoint.Count)
+	assert.Equal(t, CheckpointCleanStop, checkpoint.Status)
+	assert.Equal(t, line, checkpoint.String())
+
+	_, ok = parseEntry(line)
+	assert.False(t, ok)
+
+	_, ok = parseCheckpoint("1:archivestore/MSG_1.db")
+	assert.False(t, ok)
+}
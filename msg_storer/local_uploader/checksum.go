@@ -0,0 +1,77 @@
+package uploader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// fileChecksum returns the hex encoded sha256 of the file.
+func fileChecksum(filename string) (string, error) {
+
+	fr, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer fr.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, fr); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseChecksumExtensions normalizes the extensions to a leading dot.
+func parseChecksumExtensions(extensions []string) []string {
+
+	exts := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+
+	return exts
+}
+
+// checksumFile reports whether the source file should be checksummed, every
+// file when checksum_extensions is empty.
+func (u *Uploader) checksumFile(filename string) bool {
+
+	if !u.checksum {
+		return false
+	}
+
+	if len(u.checksumExtensions) == 0 {
+		return true
+	}
+
+	ext := path.Ext(filename)
+	for _, want := range u.checksumExtensions {
+		if strings.EqualFold(ext, want) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fileChecksum returns the checksum of the file with the hasher of the uploader.
+func (u *Uploader) fileChecksum(filename string) (string, error) {
+
+	if u.hasher != nil {
+		return u.hasher(filename)
+	}
+
+	return fileChecksum(filename)
+}
@@ -0,0 +1,56 @@
+package uploader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumExtensions(t *testing.T) {
+	u := newTestUploader(t)
+	u.checksum = true
+	u.checksumExtensions = parseChecksumExtensions([]string{"db", ".LOG", " "})
+	assert.Equal(t, []string{".db", ".LOG"}, u.checksumExtensions)
+
+	hashed := make([]string, 0)
+	u.hasher = func(filename string) (string, error) {
+		hashed = append(hashed, filename)
+		return fileChecksum(filename)
+	}
+
+	db := createTestFile(t, u, "100/100/MSG_1.db", "data")
+	_, err := u.Archive("1", db)
+	assert.Nil(t, err)
+
+	log := createTestFile(t, u, "100/100/MSG_2.log", "data")
+	_, err = u.Archive("2", log)
+	assert.Nil(t, err)
+
+	tmp := createTestFile(t, u, "100/100/MSG_3.tmp", "data")
+	_, err = u.Archive("3", tmp)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{db, log}, hashed)
+
+	entry, err := u.Lookup("1", db)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, entry.Checksum)
+
+	entry, err = u.Lookup("3", tmp)
+	assert.Nil(t, err)
+	assert.Empty(t, entry.Checksum)
+}
+
+func TestChecksumFile(t *testing.T) {
+	u := newTestUploader(t)
+	assert.False(t, u.checksumFile("MSG_1.db"))
+
+	// every file without extensions
+	u.checksum = true
+	assert.True(t, u.checksumFile("MSG_1.db"))
+	assert.True(t, u.checksumFile("MSG_1"))
+
+	u.checksumExtensions = []string{".db"}
+	assert.True(t, u.checksumFile("MSG_1.DB"))
+	assert.False(t, u.checksumFile("MSG_1"))
+}
@@ -0,0 +1,57 @@
+package uploader
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative archive_job.proto
+
+const (
+	PayloadCodecText     = "text"
+	PayloadCodecProtobuf = "protobuf"
+
+	DefaultPayloadCodec = PayloadCodecText
+)
+
+func validatePayloadCodec(codec string) error {
+
+	switch codec {
+	case PayloadCodecText, PayloadCodecProtobuf:
+		return nil
+	}
+
+	return fmt.Errorf("unknown payload_codec %s", codec)
+}
+
+// decodeJob decodes the message payload with the configured codec.
+func (u *Uploader) decodeJob(m *nats.Msg) (*job, error) {
+
+	if u.payloadCodec == PayloadCodecProtobuf {
+		return parseProtobufJob(m)
+	}
+
+	return parseJob(m)
+}
+
+// parseProtobufJob parses an ArchiveJob payload.
+func parseProtobufJob(m *nats.Msg) (*job, error) {
+
+	var aj ArchiveJob
+	if err := proto.Unmarshal(m.Data, &aj); err != nil {
+		return nil, fmt.Errorf("%w (%s)", ErrInvalidPayload, err)
+	}
+
+	if aj.Seq == "" || aj.Filename == "" {
+		return nil, fmt.Errorf("%w (%s)", ErrInvalidPayload, aj.String())
+	}
+
+	return &job{
+		seq:      aj.Seq,
+		filename: aj.Filename,
+		headers:  aj.Headers,
+		msg:      m,
+	}, nil
+}
@@ -0,0 +1,60 @@
+package uploader
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseProtobufJob(t *testing.T) {
+	data, err := proto.Marshal(&ArchiveJob{
+		Seq:      "1",
+		Filename: "datastore/100/100/MSG_1.db",
+		Headers:  map[string]string{"Tenant": "a"},
+	})
+	assert.Nil(t, err)
+
+	j, err := parseProtobufJob(&nats.Msg{Data: data})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", j.seq)
+	assert.Equal(t, "datastore/100/100/MSG_1.db", j.filename)
+	assert.Equal(t, map[string]string{"Tenant": "a"}, j.headers)
+
+	// headers are kept in the sidecar
+	assert.Equal(t, []string{"a"}, messageMetadata(j).Headers["Tenant"])
+}
+
+func TestParseProtobufJobMalformed(t *testing.T) {
+	_, err := parseProtobufJob(&nats.Msg{Data: []byte{0xff, 0xff, 0xff}})
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+	assert.True(t, isPermanent(err))
+
+	// the text payload isn't a valid job either
+	_, err = parseProtobufJob(&nats.Msg{Data: []byte("1:datastore/100/100/MSG_1.db")})
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+
+	// missing filename
+	data, _ := proto.Marshal(&ArchiveJob{Seq: "1"})
+	_, err = parseProtobufJob(&nats.Msg{Data: data})
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+}
+
+func TestDecodeJob(t *testing.T) {
+	u := newTestUploader(t)
+	u.payloadCodec = PayloadCodecText
+
+	j, err := u.decodeJob(&nats.Msg{Data: []byte("1:datastore/100/100/MSG_1.db")})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", j.seq)
+
+	u.payloadCodec = PayloadCodecProtobuf
+	data, _ := proto.Marshal(&ArchiveJob{Seq: "2", Filename: "datastore/100/100/MSG_2.db"})
+
+	j, err = u.decodeJob(&nats.Msg{Data: data})
+	assert.Nil(t, err)
+	assert.Equal(t, "2", j.seq)
+
+	assert.NotNil(t, validatePayloadCodec("json"))
+}
@@ -0,0 +1,211 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	CollisionSuffix    = "suffix"
+	CollisionError     = "error"
+	CollisionOverwrite = "overwrite"
+
+	DefaultOnCollision = CollisionError
+	maxCollisionSuffix = 1000
+)
+
+var (
+	ErrArchiveCollision = errors.New("Archive destination already belongs to another sequence.")
+)
+
+// resolveCollision checks whether archiveName is already taken by a different
+// sequence and returns the destination to use according to the collision policy.
+func (u *Uploader) resolveCollision(ctx context.Context, filename string, archiveName string, seq string) (string, error) {
+
+	if u.onCollision == CollisionOverwrite {
+		return archiveName, nil
+	}
+
+	reader := u.indexReader(filename)
+
+	candidate := archiveName
+	for i := 1; i <= maxCollisionSuffix; i++ {
+
+		owned, err := u.ownedByOtherSeq(ctx, reader, candidate, filename, seq)
+		if err != nil {
+			return "", err
+		}
+
+		if !owned {
+			return candidate, nil
+		}
+
+		if u.onCollision != CollisionSuffix {
+			return "", fmt.Errorf("%w (archiveName: %s, seq: %s)", ErrArchiveCollision, archiveName, seq)
+		}
+
+		ext := path.Ext(archiveName)
+		candidate = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(archiveName, ext), i, ext)
+	}
+
+	return "", fmt.Errorf("%w (archiveName: %s, seq: %s)", ErrArchiveCollision, archiveName, seq)
+}
+
+func (u *Uploader) ownedByOtherSeq(ctx context.Context, reader IndexReader, archiveName string, filename string, seq string) (bool, error) {
+
+	fi, err := u.fileSystem().Stat(archiveName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	entry, err := reader.LookupArchiveName(archiveName)
+	if errors.Is(err, ErrEntryNotFound) {
+		owned, err := u.ownedByCaseVariant(reader, fi, archiveName, seq)
+
+		// with lowercase_archive_paths, an archive missing from the index of
+		// the source may belong to a source in a directory differing in case
+		if err == nil && !owned && u.lowercaseArchivePaths {
+			return u.ownedByCaseVariantDir(ctx, archiveName, filename)
+		}
+		return owned, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// sources differing only in case share the lowercased archive name, entries
+	// without a filename only have the sequence to tell
+	if u.lowercaseArchivePaths && entry.Filename != "" && entry.Filename != u.normalizeFilename(filename) {
+		return true, nil
+	}
+
+	return entry.Seq != seq, nil
+}
+
+// ownedByCaseVariant checks whether the existing file is an archive indexed
+// under a name differing only in case, as found on case-insensitive
+// filesystems, which the rename would silently overwrite.
+func (u *Uploader) ownedByCaseVariant(reader IndexReader, fi fs.FileInfo, archiveName string, seq string) (bool, error) {
+
+	entries, err := reader.List()
+	if err != nil {
+		return false, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+
+		entry := entries[i]
+		if entry.ArchiveName == archiveName || !strings.EqualFold(entry.ArchiveName, archiveName) {
+			continue
+		}
+
+		efi, err := u.fileSystem().Stat(entry.ArchiveName)
+		if err != nil || !os.SameFile(fi, efi) {
+			continue
+		}
+
+		u.logger.Warn("Archive name differs only in case from an indexed archive on a case-insensitive filesystem",
+			zap.String("archiveName", archiveName),
+			zap.String("indexedName", entry.ArchiveName),
+			zap.String("seq", seq),
+			zap.String("indexedSeq", entry.Seq),
+		)
+
+		return entry.Seq != seq, nil
+	}
+
+	return false, nil
+}
+
+// ownedByCaseVariantDir checks whether archiveName is indexed for a source in a
+// directory differing only in case from the one of filename.
+func (u *Uploader) ownedByCaseVariantDir(ctx context.Context, archiveName string, filename string) (bool, error) {
+
+	own := u.indexFilename(filename)
+
+	var files []string
+	var err error
+	if u.sqlIndex != nil {
+		files, err = u.sqlIndex.archiveIndexNames(ctx, archiveName)
+	} else {
+		files, err = u.caseVariantIndexes(ctx, own)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, indexFilename := range files {
+
+		if indexFilename == own || !strings.EqualFold(indexFilename, own) {
+			continue
+		}
+
+		_, err := u.indexReader(indexFilename).LookupArchiveName(archiveName)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, ErrEntryNotFound) {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// caseVariantIndexes returns the index files of the directories differing only
+// in case from the one of indexFilename, found by listing each level of the
+// path below the datastore instead of walking it.
+func (u *Uploader) caseVariantIndexes(ctx context.Context, indexFilename string) ([]string, error) {
+
+	root := path.Clean(u.sourceRoot(indexFilename))
+
+	rel, ok := strings.CutPrefix(path.Dir(indexFilename), root+"/")
+	if !ok {
+		return nil, nil
+	}
+
+	dirs := []string{root}
+	for _, name := range strings.Split(rel, "/") {
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		next := make([]string, 0, len(dirs))
+		for _, dir := range dirs {
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+
+			for _, e := range entries {
+				if e.IsDir() && strings.EqualFold(e.Name(), name) {
+					next = append(next, path.Join(dir, e.Name()))
+				}
+			}
+		}
+
+		dirs = next
+	}
+
+	files := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		files = append(files, path.Join(dir, DefaultArchiveIndex))
+	}
+
+	return files, nil
+}
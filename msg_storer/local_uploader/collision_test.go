@@ -0,0 +1,189 @@
+package uploader
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareCollision(t *testing.T, u *Uploader) (string, string) {
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "seq-1")
+	archiveName := path.Join(u.archivestore, "100/100/MSG_1.db")
+
+	// archive file which belongs to another sequence
+	err := os.MkdirAll(path.Dir(archiveName), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(archiveName, []byte("seq-2"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = u.updateIndex(filename, archiveName, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filename, archiveName
+}
+
+func TestCollisionError(t *testing.T) {
+	u := newTestUploader(t)
+	u.onCollision = CollisionError
+	filename, archiveName := prepareCollision(t, u)
+
+	_, err := u.Archive("1", filename)
+	assert.True(t, errors.Is(err, ErrArchiveCollision), "error should be ErrArchiveCollision")
+
+	// nothing moved or overwritten
+	data, err := os.ReadFile(archiveName)
+	assert.Nil(t, err)
+	assert.Equal(t, "seq-2", string(data))
+	assert.FileExists(t, filename)
+}
+
+func TestCollisionSuffix(t *testing.T) {
+	u := newTestUploader(t)
+	u.onCollision = CollisionSuffix
+	filename, archiveName := prepareCollision(t, u)
+
+	result, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	expected := path.Join(u.archivestore, "100/100/MSG_1_1.db")
+	assert.Equal(t, expected, result)
+
+	data, err := os.ReadFile(archiveName)
+	assert.Nil(t, err)
+	assert.Equal(t, "seq-2", string(data))
+
+	data, err = os.ReadFile(expected)
+	assert.Nil(t, err)
+	assert.Equal(t, "seq-1", string(data))
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, entry.ArchiveName)
+}
+
+func TestCollisionOverwrite(t *testing.T) {
+	u := newTestUploader(t)
+	u.onCollision = CollisionOverwrite
+	filename, archiveName := prepareCollision(t, u)
+
+	result, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, result)
+
+	data, err := os.ReadFile(archiveName)
+	assert.Nil(t, err)
+	assert.Equal(t, "seq-1", string(data))
+}
+
+func TestCollisionSameSeq(t *testing.T) {
+	u := newTestUploader(t)
+	u.onCollision = CollisionError
+	filename, archiveName := prepareCollision(t, u)
+
+	// redelivery of the sequence which owns the destination is not a collision
+	result, err := u.Archive("2", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, result)
+}
+
+// caseInsensitiveFileSystem resolves names case-insensitively, like the
+// default filesystems of macOS.
+type caseInsensitiveFileSystem struct {
+	osFileSystem
+}
+
+func (caseInsensitiveFileSystem) resolve(name string) string {
+
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+
+	entries, err := os.ReadDir(path.Dir(name))
+	if err != nil {
+		return name
+	}
+
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), path.Base(name)) {
+			return path.Join(path.Dir(name), entry.Name())
+		}
+	}
+
+	return name
+}
+
+func (fs caseInsensitiveFileSystem) Stat(name string) (iofs.FileInfo, error) {
+	return os.Stat(fs.resolve(name))
+}
+
+func (fs caseInsensitiveFileSystem) Rename(oldpath string, newpath string) error {
+	return os.Rename(oldpath, fs.resolve(newpath))
+}
+
+func prepareCaseCollision(t *testing.T, u *Uploader) (string, string) {
+	filename := createTestFile(t, u, "100/100/MSG_a.db", "seq-1")
+	indexed := createArchiveFile(t, u, "100/100/MSG_A.db", "seq-2")
+
+	if err := u.updateIndex(filename, indexed, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	return filename, indexed
+}
+
+func TestCollisionCaseInsensitive(t *testing.T) {
+	u := newTestUploader(t)
+	u.fs = caseInsensitiveFileSystem{}
+	u.onCollision = CollisionError
+	filename, indexed := prepareCaseCollision(t, u)
+
+	_, err := u.Archive("1", filename)
+	assert.ErrorIs(t, err, ErrArchiveCollision)
+
+	data, err := os.ReadFile(indexed)
+	assert.Nil(t, err)
+	assert.Equal(t, "seq-2", string(data))
+	assert.FileExists(t, filename)
+}
+
+func TestCollisionCaseInsensitiveSuffix(t *testing.T) {
+	u := newTestUploader(t)
+	u.fs = caseInsensitiveFileSystem{}
+	u.onCollision = CollisionSuffix
+	filename, indexed := prepareCaseCollision(t, u)
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/100/MSG_a_1.db"), archiveName)
+
+	data, err := os.ReadFile(indexed)
+	assert.Nil(t, err)
+	assert.Equal(t, "seq-2", string(data))
+}
+
+func TestCollisionCaseSensitive(t *testing.T) {
+	u := newTestUploader(t)
+	u.onCollision = CollisionError
+	filename, indexed := prepareCaseCollision(t, u)
+
+	// probe the filesystem the test runs on
+	if _, err := os.Stat(strings.ToLower(indexed)); err == nil {
+		t.Skip("filesystem is case-insensitive")
+	}
+
+	// case-only different names are different files
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/100/MSG_a.db"), archiveName)
+	assert.FileExists(t, indexed)
+}
@@ -0,0 +1,166 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultCompletionSubject      = ""
+	DefaultCompletionRetries      = 3
+	DefaultCompletionRetryBackoff = 100 * time.Millisecond
+	DefaultCompletionAck          = false
+	DefaultCompletionAckWait      = 2 * time.Second
+	DefaultCompletionNakDelay     = 5 * time.Second
+)
+
+var (
+	ErrCompletionPublish  = errors.New("Failed to publish the completion event.")
+	ErrCompletionNoStream = errors.New("No stream matches the completion subject.")
+)
+
+// CompletionEvent is published to the completion subject once a file is archived.
+type CompletionEvent struct {
+	Seq         string    `json:"seq"`
+	Filename    string    `json:"filename"`
+	ArchiveName string    `json:"archive_name"`
+	Hostname    string    `json:"hostname"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// publishCompletion publishes the completion event, retrying transient
+// failures with a doubling backoff before giving up.
+func (u *Uploader) publishCompletion(seq string, filename string, archiveName string) error {
+
+	if u.completionSubject == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(u.completionEvent(seq, filename, archiveName))
+	if err != nil {
+		return err
+	}
+
+	return u.publishCompletionData(data, seq)
+}
+
+func (u *Uploader) completionEvent(seq string, filename string, archiveName string) CompletionEvent {
+	return CompletionEvent{
+		Seq:         seq,
+		Filename:    filename,
+		ArchiveName: archiveName,
+		Hostname:    u.hostname,
+		Timestamp:   time.Now(),
+	}
+}
+
+// publishCompletionData publishes the encoded completion event or batch of
+// the sequence, retrying transient failures with a doubling backoff.
+func (u *Uploader) publishCompletionData(data []byte, seq string) error {
+
+	m, err := u.eventMsg(u.completionSubject, data)
+	if err != nil {
+		return err
+	}
+
+	publish := u.completionPublisher
+	if publish == nil {
+		publish = u.defaultCompletionPublisher()
+	}
+
+	backoff := u.completionRetryBackoff
+	for attempt := 0; ; attempt++ {
+
+		err = publish(m)
+		if err == nil {
+			return nil
+		}
+
+		// retrying can't help while no stream is bound to the subject
+		if isNoStream(err) {
+			return fmt.Errorf("%w (seq: %s, subject: %s): %w", ErrCompletionNoStream, seq, u.completionSubject, err)
+		}
+
+		if attempt >= u.completionRetries {
+			return fmt.Errorf("%w (seq: %s, attempts: %d): %w", ErrCompletionPublish, seq, attempt+1, err)
+		}
+
+		u.logger.Warn("Failed to publish completion event, retrying",
+			zap.String("seq", seq),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// defaultCompletionPublisher publishes with core NATS, or with JetStream
+// waiting for the ack of the stream when completion_ack is set.
+func (u *Uploader) defaultCompletionPublisher() func(m *nats.Msg) error {
+
+	if !u.completionAck {
+		return u.params.NATSConnector.GetConnection().PublishMsg
+	}
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+
+	return func(m *nats.Msg) error {
+		_, err := js.PublishMsg(m, nats.AckWait(u.completionAckWait))
+		return err
+	}
+}
+
+// isNoStream reports whether the publish failed because no stream listens
+// on the subject.
+func isNoStream(err error) bool {
+	return errors.Is(err, nats.ErrNoStreamResponse) || errors.Is(err, nats.ErrNoResponders)
+}
+
+// isPublishTimeout reports whether the publish wasn't acked in time.
+func isPublishTimeout(err error) bool {
+	return errors.Is(err, nats.ErrTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (u *Uploader) publishCompletionTraced(ctx context.Context, seq string, filename string, archiveName string) error {
+
+	if u.completionSubject == "" {
+		return nil
+	}
+
+	_, span := u.startSpan(ctx, SpanPublish)
+	err := u.publishCompletion(seq, filename, archiveName)
+	endSpan(span, err)
+
+	return err
+}
+
+// archivedEntry returns the index entry of a job whose file was already moved
+// by an earlier delivery, e.g. when the completion publish failed after the move.
+func (u *Uploader) archivedEntry(j *job) (Entry, bool) {
+
+	filename := cleanFilename(j.filename)
+
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		return Entry{}, false
+	}
+
+	entry, err := u.indexReader(filename).Lookup(j.seq)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	if _, err := os.Stat(entry.ArchiveName); err != nil {
+		return Entry{}, false
+	}
+
+	return entry, true
+}
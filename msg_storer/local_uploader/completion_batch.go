@@ -0,0 +1,136 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultCompletionBatchSize     = 0
+	DefaultCompletionBatchInterval = time.Second
+)
+
+// completionBatcher coalesces completion events into one message, a JSON
+// array of events, flushed every size events or interval. The messages of
+// the batch are only acked once the batch is published, and Nak'd with
+// completion_nak_delay when it fails.
+type completionBatcher struct {
+	u        *Uploader
+	size     int
+	interval time.Duration
+
+	mutex   sync.Mutex
+	pending []pendingCompletion
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type pendingCompletion struct {
+	event CompletionEvent
+	msg   *nats.Msg
+}
+
+func newCompletionBatcher(u *Uploader, size int, interval time.Duration) *completionBatcher {
+	return &completionBatcher{
+		u:        u,
+		size:     size,
+		interval: interval,
+		pending:  make([]pendingCompletion, 0, size),
+		done:     make(chan struct{}),
+	}
+}
+
+func (b *completionBatcher) start() {
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				b.flush()
+			case <-b.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the ticker and flushes the remaining events.
+func (b *completionBatcher) stop() {
+	close(b.done)
+	b.wg.Wait()
+	b.flush()
+}
+
+func (b *completionBatcher) add(event CompletionEvent, m *nats.Msg) {
+
+	b.mutex.Lock()
+	b.pending = append(b.pending, pendingCompletion{event: event, msg: m})
+	full := len(b.pending) >= b.size
+	b.mutex.Unlock()
+
+	if full {
+		b.flush()
+	}
+}
+
+func (b *completionBatcher) flush() {
+
+	b.mutex.Lock()
+	pending := b.pending
+	b.pending = make([]pendingCompletion, 0, b.size)
+	b.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	u := b.u
+
+	events := make([]CompletionEvent, 0, len(pending))
+	for _, p := range pending {
+		events = append(events, p.event)
+	}
+
+	seq := fmt.Sprintf("%s..%s", events[0].Seq, events[len(events)-1].Seq)
+
+	data, err := json.Marshal(events)
+	if err == nil {
+		err = u.publishCompletionData(data, seq)
+	}
+	if err != nil {
+		u.logger.Error(err.Error())
+	}
+
+	for _, p := range pending {
+		if p.msg == nil {
+			continue
+		}
+
+		// a chain without a stream behind the subject would loop forever
+		if err != nil && isPermanent(err) {
+			u.term(p.msg)
+			u.recordFailure(&job{seq: p.event.Seq, filename: p.event.Filename, msg: p.msg}, err)
+			u.publishFailure(p.msg, p.event.Seq, p.event.Filename, ActionTerm, err)
+			continue
+		}
+
+		if err != nil {
+			u.nakWithDelay(p.msg, u.completionNakDelay)
+			u.publishFailure(p.msg, p.event.Seq, p.event.Filename, ActionNak, err)
+			continue
+		}
+
+		u.ack(p.msg)
+	}
+}
@@ -0,0 +1,103 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionBatcher(t *testing.T) {
+	u := newTestUploader(t)
+	u.ackMode = AckModeAuto
+	u.completionSubject = "uploader.completed"
+
+	var mutex sync.Mutex
+	batches := make([][]CompletionEvent, 0)
+	u.completionPublisher = func(m *nats.Msg) error {
+		var events []CompletionEvent
+		if err := json.Unmarshal(m.Data, &events); err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		batches = append(batches, events)
+		mutex.Unlock()
+
+		return nil
+	}
+
+	// the interval never elapses during the test
+	batcher := newCompletionBatcher(u, 10, time.Hour)
+	batcher.start()
+
+	for i := 0; i < 25; i++ {
+		seq := fmt.Sprintf("%d", i)
+		batcher.add(u.completionEvent(seq, "datastore/MSG_"+seq+".db", "archivestore/MSG_"+seq+".db"), &nats.Msg{})
+	}
+
+	mutex.Lock()
+	assert.Len(t, batches, 2)
+	for _, events := range batches {
+		assert.Len(t, events, 10)
+	}
+	mutex.Unlock()
+
+	// the remainder is flushed on stop
+	batcher.stop()
+
+	if assert.Len(t, batches, 3) {
+		assert.Len(t, batches[2], 5)
+		assert.Equal(t, "0", batches[0][0].Seq)
+		assert.Equal(t, "24", batches[2][4].Seq)
+	}
+}
+
+func TestCompletionBatcherInterval(t *testing.T) {
+	u := newTestUploader(t)
+	u.ackMode = AckModeAuto
+	u.completionSubject = "uploader.completed"
+
+	published := make(chan int, 1)
+	u.completionPublisher = func(m *nats.Msg) error {
+		var events []CompletionEvent
+		if err := json.Unmarshal(m.Data, &events); err != nil {
+			return err
+		}
+		published <- len(events)
+		return nil
+	}
+
+	batcher := newCompletionBatcher(u, 10, 20*time.Millisecond)
+	batcher.start()
+	defer batcher.stop()
+
+	batcher.add(u.completionEvent("1", "datastore/MSG_1.db", "archivestore/MSG_1.db"), &nats.Msg{})
+
+	select {
+	case n := <-published:
+		assert.Equal(t, 1, n)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch should be flushed by the ticker")
+	}
+}
+
+func TestValidateConfigCompletionBatchInterval(t *testing.T) {
+	root := t.TempDir()
+	scope := "validate_config_completion_batch"
+
+	viper.Set(scope+".datastore", path.Join(root, "datastore"))
+	viper.Set(scope+".archivestore", path.Join(root, "archivestore"))
+	viper.Set(scope+".completion_batch_size", 10)
+	viper.Set(scope+".completion_batch_interval", 0)
+	defer viper.Set(scope, nil)
+
+	err := ValidateConfig(scope)
+	assert.ErrorContains(t, err, "completion_batch_interval must be positive")
+}
@@ -0,0 +1,191 @@
+package uploader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishCompletionRetry(t *testing.T) {
+	u := newTestUploader(t)
+	u.completionSubject = "uploader.completed"
+	u.completionRetries = 3
+	u.completionRetryBackoff = time.Millisecond
+
+	// fail twice, then recover
+	var published [][]byte
+	attempts := 0
+	u.completionPublisher = func(m *nats.Msg) error {
+		attempts++
+		if attempts <= 2 {
+			return errors.New("nats: connection reconnecting")
+		}
+		published = append(published, m.Data)
+		return nil
+	}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	})
+
+	assert.Equal(t, 3, attempts)
+	assert.Len(t, published, 1)
+	assert.Equal(t, uint64(1), u.counters.archived.Load())
+	assert.Equal(t, uint64(0), u.counters.failed.Load())
+
+	var event CompletionEvent
+	assert.Nil(t, json.Unmarshal(published[0], &event))
+	assert.Equal(t, "1", event.Seq)
+	assert.Equal(t, filename, event.Filename)
+	assert.Equal(t, u.translate(filename, u.archivestore), event.ArchiveName)
+	assert.Equal(t, "test", event.Hostname)
+}
+
+func TestPublishCompletionExhausted(t *testing.T) {
+	u := newTestUploader(t)
+	u.completionSubject = "uploader.completed"
+	u.completionRetries = 2
+	u.completionRetryBackoff = time.Millisecond
+
+	attempts := 0
+	u.completionPublisher = func(m *nats.Msg) error {
+		attempts++
+		return errors.New("nats: connection closed")
+	}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+	m := &nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	}
+	u.msgHandler(m)
+
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, uint64(1), u.counters.archived.Load())
+
+	archiveName := u.translate(filename, u.archivestore)
+	_, err := os.Stat(archiveName)
+	assert.Nil(t, err)
+
+	// the redelivery only publishes again, the file isn't moved twice
+	attempts = 0
+	u.completionPublisher = func(m *nats.Msg) error {
+		attempts++
+		return nil
+	}
+	u.msgHandler(m)
+
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, uint64(1), u.counters.archived.Load())
+	assert.Equal(t, uint64(0), u.counters.failed.Load())
+
+	entries, err := NewIndexReader(u.indexFilename(filename)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestClassifyCompletionError(t *testing.T) {
+	u := newTestUploader(t)
+	u.completionSubject = "uploader.completed"
+	u.completionPublisher = func(m *nats.Msg) error {
+		return errors.New("nats: connection closed")
+	}
+
+	err := u.publishCompletion("1", "datastore/MSG_1.db", "archivestore/MSG_1.db")
+	assert.True(t, errors.Is(err, ErrCompletionPublish))
+	assert.Equal(t, ErrorClassPublish, classifyError(err))
+	assert.False(t, isPermanent(err))
+}
+
+func TestCompletionPublishTimeout(t *testing.T) {
+	u := newTestUploader(t)
+	u.completionSubject = "uploader.completed"
+	u.completionRetries = 1
+	u.completionRetryBackoff = time.Millisecond
+
+	attempts := 0
+	u.completionPublisher = func(m *nats.Msg) error {
+		attempts++
+		return nats.ErrTimeout
+	}
+
+	err := u.publishCompletion("1", "datastore/MSG_1.db", "archivestore/MSG_1.db")
+	assert.ErrorIs(t, err, ErrCompletionPublish)
+	assert.ErrorIs(t, err, nats.ErrTimeout)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, ErrorClassTimeout, classifyError(err))
+
+	// Nak'd with a delay
+	assert.False(t, isPermanent(err))
+}
+
+func TestCompletionNoResponders(t *testing.T) {
+	u := newTestUploader(t)
+	u.completionSubject = "uploader.completed"
+	u.completionRetries = 3
+
+	attempts := 0
+	u.completionPublisher = func(m *nats.Msg) error {
+		attempts++
+		return nats.ErrNoResponders
+	}
+
+	err := u.publishCompletion("1", "datastore/MSG_1.db", "archivestore/MSG_1.db")
+	assert.ErrorIs(t, err, ErrCompletionNoStream)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, ErrorClassNoStream, classifyError(err))
+	assert.True(t, isPermanent(err))
+}
+
+func (s *TestSuite) TestCompletionNoStream() {
+	u := s.uploader
+	defer func() {
+		u.completionSubject = DefaultCompletionSubject
+		u.completionAck = DefaultCompletionAck
+	}()
+
+	u.completionSubject = "uploader-nostream.completed"
+	u.completionAck = true
+	u.completionAckWait = time.Second
+
+	err := u.publishCompletion("1", "datastore/MSG_1.db", "archivestore/MSG_1.db")
+	s.ErrorIs(err, ErrCompletionNoStream)
+	s.Equal(ErrorClassNoStream, classifyError(err))
+	s.True(isPermanent(err))
+}
+
+func (s *TestSuite) TestCompletionAck() {
+	u := s.uploader
+	defer func() {
+		u.completionSubject = DefaultCompletionSubject
+		u.completionAck = DefaultCompletionAck
+	}()
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "uploader_completion_ack",
+		Subjects: []string{"uploader-completion-ack.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	u.completionSubject = "uploader-completion-ack.completed"
+	u.completionAck = true
+	u.completionAckWait = time.Second
+
+	s.Nil(u.publishCompletion("1", "datastore/MSG_1.db", "archivestore/MSG_1.db"))
+
+	info, err := js.StreamInfo("uploader_completion_ack")
+	s.Nil(err)
+	s.Equal(uint64(1), info.State.Msgs)
+}
@@ -0,0 +1,101 @@
+package uploader
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+
+	DefaultCompression = CompressionNone
+)
+
+func compressionExt(compression string) string {
+
+	switch compression {
+	case CompressionGzip:
+		return ".gz"
+	}
+
+	return ""
+}
+
+func validateCompression(compression string) error {
+
+	switch compression {
+	case CompressionNone, CompressionGzip:
+		return nil
+	}
+
+	return fmt.Errorf("invalid compression: %s", compression)
+}
+
+// compressFile writes the compressed file into a staging file, renames it to
+// the archive name and removes or trashes the source.
+func (u *Uploader) compressFile(filename string, archiveName string) error {
+
+	stagingName, err := u.stage(filename, archiveName, func(dst io.Writer, src io.Reader) error {
+
+		zw := gzip.NewWriter(dst)
+		if _, err := u.copyBuffer(zw, src); err != nil {
+			return err
+		}
+
+		return zw.Close()
+	})
+	if err != nil {
+		return err
+	}
+
+	fsys := u.fileSystem()
+
+	if err := u.verifyCopy(filename, stagingName, u.compression); err != nil {
+		fsys.Remove(stagingName)
+		return err
+	}
+
+	if err := u.renameStaged(stagingName, archiveName); err != nil {
+		fsys.Remove(stagingName)
+		return err
+	}
+
+	if err := u.syncArchiveDir(archiveName); err != nil {
+		return err
+	}
+
+	return u.removeSource(filename)
+}
+
+// contentInfo returns the size and the sha256 of the uncompressed content of an archive file.
+func contentInfo(filename string, compression string) (int64, string, error) {
+
+	fr, err := os.Open(filename)
+	if err != nil {
+		return 0, "", err
+	}
+	defer fr.Close()
+
+	var r io.Reader = fr
+	if compression == CompressionGzip {
+		zr, err := gzip.NewReader(fr)
+		if err != nil {
+			return 0, "", err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,72 @@
+package uploader
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressFile(t *testing.T) {
+	u := newTestUploader(t)
+	u.compression = CompressionGzip
+	u.checksum = true
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "compressed content")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	_, err = os.Stat(filename)
+	assert.True(t, os.IsNotExist(err))
+
+	fr, err := os.Open(archiveName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+
+	zr, err := gzip.NewReader(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(zr)
+	assert.Nil(t, err)
+	assert.Equal(t, "compressed content", string(data))
+
+	// size and checksum refer to the uncompressed content
+	report, err := u.Validate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Checked)
+	assert.Len(t, report.Missing, 0)
+	assert.Len(t, report.Corrupt, 0)
+}
+
+func TestValidateCorruptCompressedArchive(t *testing.T) {
+	u := newTestUploader(t)
+	u.compression = CompressionGzip
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "compressed content")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	err = os.WriteFile(archiveName, []byte("not gzip"), 0644)
+	assert.Nil(t, err)
+
+	report, err := u.Validate(context.Background())
+	assert.Nil(t, err)
+	if assert.Len(t, report.Corrupt, 1) {
+		assert.Equal(t, ReasonUnreadable, report.Corrupt[0].Reason)
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	assert.Nil(t, validateCompression(CompressionNone))
+	assert.Nil(t, validateCompression(CompressionGzip))
+	assert.NotNil(t, validateCompression("zstd"))
+}
@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// ValidateConfig validates the configs of the scope without connecting to
+// NATS or creating directories, e.g. for a --check-config flag. Every problem
+// found is reported in the returned error. The datastore must exist, the
+// other directories must exist or be creatable below an existing directory.
+func ValidateConfig(scope string) error {
+
+	if err := validateScope(scope); err != nil {
+		return err
+	}
+
+	u := &Uploader{
+		logger: zap.NewNop(),
+		scope:  scope,
+	}
+	u.initDefaultConfigs()
+
+	errs := []error{u.loadConfig()}
+
+	if err := checkStoreDir(u.datastore); err != nil {
+		errs = append(errs, fmt.Errorf("datastore: %w", err))
+	}
+
+	dirs := [][2]string{
+		{"archivestore", u.archivestore},
+		{"temp_dir", u.tempDir},
+		{"cold_archivestore", u.coldArchivestore},
+		{"quarantine_dir", u.quarantineDir},
+		{"fallback_archivestore", u.fallbackArchivestore},
+		{"source_trash_dir", u.sourceTrashDir},
+	}
+	for _, s := range u.subjectArchivestores {
+		dirs = append(dirs, [2]string{"subject_archivestores " + s.pattern, s.root})
+	}
+
+	for _, d := range dirs {
+		if d[1] == "" {
+			continue
+		}
+		if err := checkCreatable(d[1]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d[0], err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkCreatable checks that dir is a directory, or that its closest existing
+// parent is one so MkdirAll can create it.
+func checkCreatable(dir string) error {
+
+	for p := path.Clean(dir); ; p = path.Dir(p) {
+
+		fi, err := os.Stat(p)
+		// a file in the path makes stat fail with ENOTDIR
+		if (os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR)) && p != path.Dir(p) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if !fi.IsDir() {
+			return fmt.Errorf("%s is not a directory", p)
+		}
+
+		return nil
+	}
+}
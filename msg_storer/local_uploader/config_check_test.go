@@ -0,0 +1,53 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	root := t.TempDir()
+	scope := "validate_config"
+
+	err := os.MkdirAll(path.Join(root, "datastore"), 0750)
+	assert.Nil(t, err)
+
+	viper.Set(scope+".datastore", path.Join(root, "datastore"))
+	viper.Set(scope+".archivestore", path.Join(root, "archivestore/a/b"))
+	defer viper.Set(scope, nil)
+
+	assert.Nil(t, ValidateConfig(scope))
+}
+
+func TestValidateConfigInvalid(t *testing.T) {
+	root := t.TempDir()
+	scope := "validate_config_invalid"
+
+	// a file where a parent directory is expected
+	err := os.WriteFile(path.Join(root, "file"), nil, 0644)
+	assert.Nil(t, err)
+
+	viper.Set(scope+".datastore", path.Join(root, "missing"))
+	viper.Set(scope+".archivestore", path.Join(root, "file/archivestore"))
+	viper.Set(scope+".compression", "lz4")
+	viper.Set(scope+".workers", 0)
+	viper.Set(scope+".on_collision", "ignore")
+	viper.Set(scope+".dir_umask", "9")
+	defer viper.Set(scope, nil)
+
+	err = ValidateConfig(scope)
+	assert.NotNil(t, err)
+	assert.ErrorIs(t, err, ErrNotMounted)
+
+	msg := err.Error()
+	assert.Contains(t, msg, "lz4")
+	assert.Contains(t, msg, "invalid workers: 0")
+	assert.Contains(t, msg, "invalid on_collision policy: ignore")
+	assert.Contains(t, msg, "dir_umask")
+	assert.Contains(t, msg, "datastore:")
+	assert.Contains(t, msg, "archivestore: "+path.Join(root, "file")+" is not a directory")
+}
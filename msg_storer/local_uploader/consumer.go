@@ -0,0 +1,132 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	AckPolicyExplicit = "explicit"
+	AckPolicyAll      = "all"
+
+	DefaultDurable     = ""
+	DefaultAckPolicy   = AckPolicyExplicit
+	DefaultNumReplicas = 0
+
+	DeliverPolicyAll         = "all"
+	DeliverPolicyNew         = "new"
+	DeliverPolicyLast        = "last"
+	DeliverPolicyByStartTime = "by_start_time"
+
+	DefaultDeliverPolicy    = DeliverPolicyAll
+	DefaultDeliverStartTime = ""
+)
+
+// validateConsumerConfig rejects consumer settings which conflict with the ack mode.
+func (u *Uploader) validateConsumerConfig() error {
+
+	switch u.ackPolicy {
+	case AckPolicyExplicit:
+	case AckPolicyAll:
+		// acking a later message would also ack the ones we Nak'd
+		if u.ackMode == AckModeManual {
+			return fmt.Errorf("ack_policy %s is incompatible with ack_mode %s", AckPolicyAll, AckModeManual)
+		}
+	default:
+		return fmt.Errorf("invalid ack_policy: %s", u.ackPolicy)
+	}
+
+	if u.numReplicas < 0 {
+		return fmt.Errorf("invalid num_replicas: %d", u.numReplicas)
+	}
+
+	switch u.deliverPolicy {
+	case DeliverPolicyAll, DeliverPolicyNew, DeliverPolicyLast:
+	case DeliverPolicyByStartTime:
+		if u.deliverStartTime.IsZero() {
+			return fmt.Errorf("deliver_policy %s requires deliver_start_time", DeliverPolicyByStartTime)
+		}
+	default:
+		return fmt.Errorf("invalid deliver_policy: %s", u.deliverPolicy)
+	}
+
+	return nil
+}
+
+// parseDeliverStartTime parses the RFC3339 start time of the by_start_time deliver policy.
+func parseDeliverStartTime(value string) (time.Time, error) {
+
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deliver_start_time: %w", err)
+	}
+
+	return t, nil
+}
+
+// consumerOptions returns the options of the consumer created for the subscription.
+func (u *Uploader) consumerOptions() []nats.SubOpt {
+
+	opts := make([]nats.SubOpt, 0)
+
+	if u.durable != "" {
+		opts = append(opts, nats.Durable(u.durable))
+	}
+
+	switch u.ackPolicy {
+	case AckPolicyAll:
+		opts = append(opts, nats.AckAll())
+	default:
+		opts = append(opts, nats.AckExplicit())
+	}
+
+	if u.numReplicas > 0 {
+		opts = append(opts, nats.ConsumerReplicas(u.numReplicas))
+	}
+
+	if u.bindStream != "" {
+		opts = append(opts, nats.BindStream(u.bindStream))
+	}
+
+	return opts
+}
+
+// deliverOptions returns the deliver policy option when the consumer is about
+// to be created. An existing durable keeps its position, so the policy is only
+// applied when it is first created.
+func (u *Uploader) deliverOptions(js nats.JetStreamContext, subject string) ([]nats.SubOpt, error) {
+
+	if u.durable != "" {
+		stream, err := u.boundStream(js, subject)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = js.ConsumerInfo(stream, u.durable)
+		if err == nil {
+			return nil, nil
+		}
+
+		if !errors.Is(err, nats.ErrConsumerNotFound) {
+			return nil, err
+		}
+	}
+
+	switch u.deliverPolicy {
+	case DeliverPolicyNew:
+		return []nats.SubOpt{nats.DeliverNew()}, nil
+	case DeliverPolicyLast:
+		return []nats.SubOpt{nats.DeliverLast()}, nil
+	case DeliverPolicyByStartTime:
+		return []nats.SubOpt{nats.StartTime(u.deliverStartTime)}, nil
+	}
+
+	return []nats.SubOpt{nats.DeliverAll()}, nil
+}
@@ -0,0 +1,43 @@
+package uploader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultConsumerPendingInterval = time.Duration(0)
+)
+
+// consumerPending holds the counts of the consumer info last polled from the
+// server, so a growing backlog can be alerted on.
+type consumerPending struct {
+	pending     atomic.Uint64
+	ackPending  atomic.Uint64
+	redelivered atomic.Uint64
+}
+
+// pollConsumerPending fetches the consumer info of the subscription. It is
+// skipped until the subscription is ready.
+func (u *Uploader) pollConsumerPending(ctx context.Context) error {
+
+	u.subMutex.Lock()
+	sub := u.sub
+	u.subMutex.Unlock()
+
+	if sub == nil {
+		return nil
+	}
+
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		return err
+	}
+
+	u.consumerPending.pending.Store(info.NumPending)
+	u.consumerPending.ackPending.Store(uint64(info.NumAckPending))
+	u.consumerPending.redelivered.Store(uint64(info.NumRedelivered))
+
+	return nil
+}
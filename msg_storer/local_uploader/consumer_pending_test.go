@@ -0,0 +1,68 @@
+package uploader
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func (s *TestSuite) TestConsumerPending() {
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "uploader_pending",
+		Subjects: []string{"uploader-pending.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	// not subscribed yet
+	s.Nil(u.pollConsumerPending(context.Background()))
+	s.Equal(uint64(0), u.Stats().ConsumerPending)
+
+	sub, err := js.PullSubscribe("uploader-pending.jobs", "uploader_pending")
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer sub.Unsubscribe()
+
+	u.sub = sub
+
+	for i := 0; i < 3; i++ {
+		_, err := js.Publish("uploader-pending.jobs", []byte("1:MSG_1.db"))
+		if err != nil {
+			s.Fail(err.Error())
+			return
+		}
+	}
+
+	var stats Stats
+	s.Eventually(func() bool {
+		s.Nil(u.pollConsumerPending(context.Background()))
+		stats = u.Stats()
+		return stats.ConsumerPending == 3 && stats.ConsumerAckPending == 0
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// fetched but unacked
+	msgs, err := sub.Fetch(1)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	s.Len(msgs, 1)
+
+	// the consumer state is updated by the server asynchronously
+	s.Eventually(func() bool {
+		s.Nil(u.pollConsumerPending(context.Background()))
+		stats = u.Stats()
+		return stats.ConsumerPending == 2 && stats.ConsumerAckPending == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	s.Equal(uint64(0), stats.ConsumerRedelivered)
+}
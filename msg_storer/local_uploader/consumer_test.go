@@ -0,0 +1,223 @@
+package uploader
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConsumerConfig(t *testing.T) {
+	u := newTestUploader(t)
+
+	u.ackMode = AckModeManual
+	u.ackPolicy = AckPolicyExplicit
+	assert.Nil(t, u.validateConsumerConfig())
+
+	u.ackPolicy = AckPolicyAll
+	assert.NotNil(t, u.validateConsumerConfig())
+
+	u.ackMode = AckModeAuto
+	assert.Nil(t, u.validateConsumerConfig())
+
+	u.ackPolicy = "none"
+	assert.NotNil(t, u.validateConsumerConfig())
+
+	u.ackPolicy = AckPolicyExplicit
+	u.numReplicas = -1
+	assert.NotNil(t, u.validateConsumerConfig())
+
+	u.numReplicas = 0
+	u.deliverPolicy = "first"
+	assert.NotNil(t, u.validateConsumerConfig())
+
+	// by_start_time requires a start time
+	u.deliverPolicy = DeliverPolicyByStartTime
+	assert.NotNil(t, u.validateConsumerConfig())
+
+	u.deliverStartTime = time.Now()
+	assert.Nil(t, u.validateConsumerConfig())
+}
+
+func TestParseDeliverStartTime(t *testing.T) {
+	ts, err := parseDeliverStartTime("")
+	assert.Nil(t, err)
+	assert.True(t, ts.IsZero())
+
+	ts, err = parseDeliverStartTime("2023-11-02T10:00:00Z")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 11, 2, 10, 0, 0, 0, time.UTC), ts)
+
+	_, err = parseDeliverStartTime("yesterday")
+	assert.NotNil(t, err)
+}
+
+func (s *TestSuite) TestConsumerPolicy() {
+	u := s.uploader
+	defer func() {
+		u.durable = DefaultDurable
+		u.deliverPolicy = DefaultDeliverPolicy
+		u.ackMode = DefaultAckMode
+		u.ackPolicy = DefaultAckPolicy
+		u.numReplicas = DefaultNumReplicas
+	}()
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+
+	// work queue streams only allow explicit acks, so use a limits based stream
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "uploader_policy",
+		Subjects: []string{"uploader-policy.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	cases := []struct {
+		ackMode   string
+		ackPolicy string
+		expected  nats.AckPolicy
+	}{
+		{AckModeManual, AckPolicyExplicit, nats.AckExplicitPolicy},
+		{AckModeAuto, AckPolicyAll, nats.AckAllPolicy},
+	}
+
+	for i, c := range cases {
+		u.durable = fmt.Sprintf("uploader-policy-%d", i)
+		u.ackMode = c.ackMode
+		u.ackPolicy = c.ackPolicy
+		u.numReplicas = 1
+
+		s.Nil(u.validateConsumerConfig())
+
+		sub, err := js.Subscribe(fmt.Sprintf("uploader-policy.%s", u.durable),
+			func(m *nats.Msg) {},
+			u.subscribeOptions()...,
+		)
+		if err != nil {
+			s.Fail(err.Error())
+			return
+		}
+
+		info, err := sub.ConsumerInfo()
+		s.Nil(err)
+		s.Equal(u.durable, info.Config.Durable)
+		s.Equal(c.expected, info.Config.AckPolicy)
+		s.Equal(1, info.Config.Replicas)
+
+		sub.Unsubscribe()
+	}
+}
+
+// receiveAll subscribes with the uploader options and counts the messages received within the wait.
+func (s *TestSuite) receiveAll(u *Uploader, subject string, wait time.Duration) int {
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+
+	opts, err := u.deliverOptions(js, subject)
+	if err != nil {
+		s.Fail(err.Error())
+		return 0
+	}
+
+	sub, err := js.SubscribeSync(subject, append(u.subscribeOptions(), opts...)...)
+	if err != nil {
+		s.Fail(err.Error())
+		return 0
+	}
+	defer sub.Unsubscribe()
+
+	count := 0
+	for {
+		m, err := sub.NextMsg(wait)
+		if err != nil {
+			return count
+		}
+		m.Ack()
+		count++
+	}
+}
+
+func (s *TestSuite) TestDeliverPolicy() {
+	u := s.uploader
+	defer func() {
+		u.durable = DefaultDurable
+		u.deliverPolicy = DefaultDeliverPolicy
+	}()
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "uploader_deliver",
+		Subjects: []string{"uploader-deliver.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	// backlog
+	subject := "uploader-deliver.job"
+	for i := 0; i < 3; i++ {
+		if _, err := js.Publish(subject, []byte(fmt.Sprintf("%d:datastore/MSG_%d.db", i, i))); err != nil {
+			s.Fail(err.Error())
+			return
+		}
+	}
+
+	// all processes the backlog
+	u.durable = "uploader-deliver-all"
+	u.deliverPolicy = DeliverPolicyAll
+	s.Equal(3, s.receiveAll(u, subject, 200*time.Millisecond))
+
+	// new skips the backlog
+	u.durable = "uploader-deliver-new"
+	u.deliverPolicy = DeliverPolicyNew
+	s.Equal(0, s.receiveAll(u, subject, 200*time.Millisecond))
+}
+
+func (s *TestSuite) TestDeliverPolicyExistingDurable() {
+	u := s.uploader
+	defer func() {
+		u.durable = DefaultDurable
+		u.deliverPolicy = DefaultDeliverPolicy
+	}()
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "uploader_deliver_bind",
+		Subjects: []string{"uploader-deliver-bind.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	subject := "uploader-deliver-bind.job"
+	_, err = js.AddConsumer("uploader_deliver_bind", &nats.ConsumerConfig{
+		Durable:        "uploader-deliver-bind",
+		DeliverSubject: nats.NewInbox(),
+		DeliverPolicy:  nats.DeliverAllPolicy,
+		AckPolicy:      nats.AckExplicitPolicy,
+		FilterSubject:  subject,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	// the policy is ignored when binding to the existing durable
+	u.durable = "uploader-deliver-bind"
+	u.deliverPolicy = DeliverPolicyNew
+
+	opts, err := u.deliverOptions(js, subject)
+	s.Nil(err)
+	s.Len(opts, 0)
+}
@@ -0,0 +1,213 @@
+package uploader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultControlSubject      = ""
+	DefaultControlReplyTimeout = 10 * time.Second
+	ControlReprocess           = "reprocess"
+
+	// control reply statuses
+	ControlAccepted = "accepted"
+	ControlRejected = "rejected"
+	ControlDone     = "done"
+	ControlFailed   = "failed"
+)
+
+var (
+	ErrInvalidControlCommand = errors.New("Invalid control command.")
+	ErrNoControlResponders   = errors.New("No uploader is listening on the control subject.")
+)
+
+// ControlReply is the JSON reply to a control command. A command which is
+// still running after control_reply_timeout is replied as accepted, and
+// carries on in the background.
+type ControlReply struct {
+	Status      string `json:"status"`
+	Command     string `json:"command,omitempty"`
+	Seq         string `json:"seq,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	ArchiveName string `json:"archive_name,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// controlCommand is a parsed <command>:<seq>:<filename> command.
+type controlCommand struct {
+	name     string
+	seq      string
+	filename string
+}
+
+func parseControlCommand(cmd string) (controlCommand, error) {
+
+	cols := strings.SplitN(cmd, ":", 3)
+	if len(cols) != 3 || cols[1] == "" || cols[2] == "" {
+		return controlCommand{}, fmt.Errorf("%w (%s)", ErrInvalidControlCommand, cmd)
+	}
+
+	c := controlCommand{
+		name:     cols[0],
+		seq:      cols[1],
+		filename: cols[2],
+	}
+
+	switch c.name {
+	case ControlReprocess:
+		return c, nil
+	}
+
+	return controlCommand{}, fmt.Errorf("%w (%s)", ErrInvalidControlCommand, cmd)
+}
+
+// RequestControl sends the control command and waits for the reply of an
+// uploader, telling apart a subject nobody listens on.
+func RequestControl(nc *nats.Conn, subject string, cmd string, timeout time.Duration) (ControlReply, error) {
+
+	var reply ControlReply
+
+	m, err := nc.Request(subject, []byte(cmd), timeout)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return reply, fmt.Errorf("%w (subject: %s)", ErrNoControlResponders, subject)
+		}
+		return reply, err
+	}
+
+	if err := json.Unmarshal(m.Data, &reply); err != nil {
+		return reply, fmt.Errorf("invalid control reply: %w", err)
+	}
+
+	return reply, nil
+}
+
+func (u *Uploader) startControlSubscriber() error {
+
+	if u.controlSubject == "" {
+		return nil
+	}
+
+	nc := u.params.NATSConnector.GetConnection()
+	sub, err := nc.Subscribe(u.controlSubject, u.controlHandler)
+	if err != nil {
+		return err
+	}
+	u.controlSub = sub
+
+	u.logger.Info("Subscribed control subject", zap.String("subject", u.controlSubject))
+
+	return nil
+}
+
+func (u *Uploader) stopControlSubscriber() {
+
+	if u.controlSub == nil {
+		return
+	}
+
+	if err := u.controlSub.Unsubscribe(); err != nil {
+		u.logger.Error(err.Error())
+	}
+	u.controlSub = nil
+}
+
+// controlHandler handles commands like reprocess:<seq>:<filename>, and always
+// replies so the sender isn't left waiting.
+func (u *Uploader) controlHandler(m *nats.Msg) {
+
+	// only the control subject is allowed to trigger reprocessing
+	if m.Subject != u.controlSubject {
+		u.respond(m, ControlReply{
+			Status: ControlRejected,
+			Error:  fmt.Sprintf("%s (subject: %s)", ErrInvalidControlCommand, m.Subject),
+		})
+		return
+	}
+
+	c, err := parseControlCommand(string(m.Data))
+	if err != nil {
+		u.logger.Error(err.Error())
+		u.respond(m, ControlReply{
+			Status: ControlRejected,
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	done := make(chan ControlReply, 1)
+	go func() {
+		done <- u.handleControlCommand(c)
+	}()
+
+	timeout := u.controlReplyTimeout
+	if timeout <= 0 {
+		timeout = DefaultControlReplyTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case reply := <-done:
+		u.respond(m, reply)
+	case <-timer.C:
+		u.respond(m, ControlReply{
+			Status:   ControlAccepted,
+			Command:  c.name,
+			Seq:      c.seq,
+			Filename: c.filename,
+		})
+	}
+}
+
+func (u *Uploader) handleControlCommand(c controlCommand) ControlReply {
+
+	reply := ControlReply{
+		Command:  c.name,
+		Seq:      c.seq,
+		Filename: c.filename,
+	}
+
+	u.logger.Info("Reprocess file",
+		zap.String("seq", c.seq),
+		zap.String("fileName", c.filename),
+	)
+
+	archiveName, err := u.Archive(c.seq, c.filename)
+	if err != nil {
+		u.logger.Error(err.Error())
+		reply.Status = ControlFailed
+		reply.Error = err.Error()
+		return reply
+	}
+
+	reply.Status = ControlDone
+	reply.ArchiveName = archiveName
+
+	return reply
+}
+
+func (u *Uploader) respond(m *nats.Msg, reply ControlReply) {
+
+	if m.Reply == "" {
+		return
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return
+	}
+
+	if err := m.Respond(data); err != nil {
+		u.logger.Error(err.Error())
+	}
+}
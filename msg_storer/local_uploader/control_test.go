@@ -0,0 +1,118 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"time"
+)
+
+func (s *TestSuite) TestControlReprocess() {
+	u := s.uploader
+	u.controlSubject = "uploader.control.test"
+
+	err := u.startControlSubscriber()
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer u.stopControlSubscriber()
+
+	filename := "datastore/100/200/MSG_1.db"
+	err = os.MkdirAll(path.Dir(filename), 0750)
+	if err != nil {
+		s.Fail(err.Error())
+	}
+	err = os.WriteFile(filename, []byte("reprocess"), 0644)
+	if err != nil {
+		s.Fail(err.Error())
+	}
+
+	nc := u.params.NATSConnector.GetConnection()
+	reply, err := RequestControl(nc, u.controlSubject, "reprocess:1:"+filename, 5*time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	archiveName := "archivestore/100/200/MSG_1.db"
+	s.Equal(ControlReply{
+		Status:      ControlDone,
+		Command:     ControlReprocess,
+		Seq:         "1",
+		Filename:    filename,
+		ArchiveName: archiveName,
+	}, reply)
+	s.FileExists(archiveName)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	s.Nil(err)
+	s.Equal(archiveName, entry.ArchiveName)
+
+	// unknown command
+	reply, err = RequestControl(nc, u.controlSubject, "remove:1:"+filename, 5*time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	s.Equal(ControlRejected, reply.Status)
+	s.Contains(reply.Error, ErrInvalidControlCommand.Error())
+
+	// malformed command
+	reply, err = RequestControl(nc, u.controlSubject, "reprocess", 5*time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	s.Equal(ControlRejected, reply.Status)
+
+	// the source is gone
+	reply, err = RequestControl(nc, u.controlSubject, "reprocess:2:datastore/100/200/MSG_2.db", 5*time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	s.Equal(ControlFailed, reply.Status)
+	s.NotEmpty(reply.Error)
+}
+
+func (s *TestSuite) TestControlAccepted() {
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+	u.controlSubject = "uploader.control.accepted"
+	u.controlReplyTimeout = 20 * time.Millisecond
+
+	fsys := &blockingRenameFileSystem{release: make(chan struct{})}
+	u.fs = fsys
+
+	err := u.startControlSubscriber()
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer u.stopControlSubscriber()
+
+	filename := createTestFile(s.T(), u, "100/200/MSG_1.db", "reprocess")
+
+	nc := u.params.NATSConnector.GetConnection()
+	reply, err := RequestControl(nc, u.controlSubject, "reprocess:1:"+filename, 5*time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	s.Equal(ControlAccepted, reply.Status)
+	s.Equal("1", reply.Seq)
+
+	// carries on once the move is released
+	close(fsys.release)
+	s.Eventually(func() bool {
+		_, err := u.Lookup("1", filename)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *TestSuite) TestControlNoResponders() {
+	nc := s.uploader.params.NATSConnector.GetConnection()
+
+	_, err := RequestControl(nc, "uploader.control.nobody", "reprocess:1:MSG_1.db", time.Second)
+	s.ErrorIs(err, ErrNoControlResponders)
+}
@@ -0,0 +1,248 @@
+package uploader
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultTempDir        = ""
+	StagingPattern        = ".staging-*"
+	DefaultCopyBufferSize = 32 * 1024
+	MinCopyBufferSize     = 4 * 1024
+
+	DefaultRenameRetries      = 3
+	DefaultRenameRetryBackoff = 50 * time.Millisecond
+)
+
+// moveFile renames the file into the archive, falling back to a staged copy
+// when the archive is on another device.
+func (u *Uploader) moveFile(filename string, archiveName string) error {
+
+	fsys := u.fileSystem()
+
+	err := fsys.Rename(filename, archiveName)
+	if err == nil {
+		return u.syncArchiveDir(archiveName)
+	}
+
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	u.logger.Debug("Cross-device archive, copying file",
+		zap.String("fileName", filename),
+		zap.String("archiveName", archiveName),
+	)
+
+	err = u.copyFile(filename, archiveName)
+	if err != nil {
+		return err
+	}
+
+	return u.removeSource(filename)
+}
+
+// copyFile copies the file into a staging file, verifies it and renames it to
+// the archive name, so that a partial copy never shows up in the archive.
+func (u *Uploader) copyFile(filename string, archiveName string) error {
+
+	fsys := u.fileSystem()
+
+	stagingName, err := u.stageFile(filename, archiveName)
+	if err != nil {
+		return err
+	}
+
+	if err := u.verifyCopy(filename, stagingName, CompressionNone); err != nil {
+		fsys.Remove(stagingName)
+		return err
+	}
+
+	if err := u.renameStaged(stagingName, archiveName); err != nil {
+		fsys.Remove(stagingName)
+		return err
+	}
+
+	return u.syncArchiveDir(archiveName)
+}
+
+// renameStaged renames the staging file to the archive name, retrying
+// transient failures of network filesystems with a doubling backoff. The
+// staging file stays valid, so retrying is cheap compared to redelivering.
+func (u *Uploader) renameStaged(stagingName string, archiveName string) error {
+
+	fsys := u.fileSystem()
+
+	backoff := u.renameRetryBackoff
+	for attempt := 0; ; attempt++ {
+
+		err := fsys.Rename(stagingName, archiveName)
+		if err == nil || !isRetryableRename(err) || attempt >= u.renameRetries {
+			return err
+		}
+
+		u.logger.Warn("Failed to rename staging file, retrying",
+			zap.String("stagingName", stagingName),
+			zap.String("archiveName", archiveName),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// isRetryableRename reports whether the rename may succeed when retried.
+func isRetryableRename(err error) bool {
+	return errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.EAGAIN)
+}
+
+// syncArchiveDir flushes the directory of the archive file when fsync_dir is set.
+func (u *Uploader) syncArchiveDir(archiveName string) error {
+
+	if !u.fsyncDir {
+		return nil
+	}
+
+	return u.syncDir(path.Dir(archiveName))
+}
+
+// stageFile copies the file into the staging directory of the archive file and
+// returns the staging file name.
+func (u *Uploader) stageFile(filename string, archiveName string) (string, error) {
+	return u.stage(filename, archiveName, func(dst io.Writer, src io.Reader) error {
+		if parallel, err := u.copyParallel(dst, src); parallel || err != nil {
+			return err
+		}
+
+		_, err := u.copyBuffer(dst, src)
+		return err
+	})
+}
+
+// stage writes the file into a staging file for the archive file with write and
+// returns the staging file name.
+func (u *Uploader) stage(filename string, archiveName string, write func(dst io.Writer, src io.Reader) error) (string, error) {
+
+	fsys := u.fileSystem()
+
+	src, err := fsys.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	dst, err := fsys.CreateTemp(u.stagingDirFor(archiveName), StagingPattern)
+	if err != nil {
+		return "", err
+	}
+	stagingName := dst.Name()
+
+	if err := write(dst, src); err != nil {
+		dst.Close()
+		fsys.Remove(stagingName)
+		return "", err
+	}
+
+	if err := dst.Chmod(fi.Mode().Perm()); err != nil {
+		dst.Close()
+		fsys.Remove(stagingName)
+		return "", err
+	}
+
+	// flush the data before the file shows up in the archive
+	if u.fsyncArchive {
+		if err := dst.Sync(); err != nil {
+			dst.Close()
+			fsys.Remove(stagingName)
+			return "", err
+		}
+	}
+
+	if err := dst.Close(); err != nil {
+		fsys.Remove(stagingName)
+		return "", err
+	}
+
+	return stagingName, nil
+}
+
+// stagingDir returns the directory for staging copies. It defaults to the
+// archivestore so that the final rename stays on the same filesystem.
+func (u *Uploader) stagingDir() string {
+
+	if u.tempDir != "" {
+		return u.tempDir
+	}
+
+	return u.archivestore
+}
+
+// stagingDirFor returns the staging directory for a copy to the archive file.
+// Files outside of the archivestore, e.g. moved to the cold archivestore, are
+// staged in their own directory since the rename can't cross filesystems.
+func (u *Uploader) stagingDirFor(archiveName string) string {
+
+	if strings.HasPrefix(archiveName, path.Join(u.archivestore)+"/") {
+		return u.stagingDir()
+	}
+
+	return path.Dir(archiveName)
+}
+
+// checkStagingDir creates the staging directory and warns when the final rename
+// from it into the archivestore can't be atomic.
+func (u *Uploader) checkStagingDir() error {
+
+	dir := u.stagingDir()
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(u.archivestore, 0750); err != nil {
+		return err
+	}
+
+	same, err := sameDevice(dir, u.archivestore)
+	if err != nil {
+		return err
+	}
+
+	if !same {
+		u.logger.Warn("Temp dir is not on the same filesystem as the archivestore, rename from staging is not atomic",
+			zap.String("tempDir", dir),
+			zap.String("archivestore", u.archivestore),
+		)
+	}
+
+	return nil
+}
+
+// copyBuffer copies with a buffer of the configured size. Reader and writer are
+// wrapped so that io.CopyBuffer doesn't bypass the buffer via ReadFrom/WriteTo.
+func (u *Uploader) copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+
+	size := u.copyBufferSize
+	if size < MinCopyBufferSize {
+		size = DefaultCopyBufferSize
+	}
+
+	buf := make([]byte, size)
+
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, buf)
+}
@@ -0,0 +1,210 @@
+package uploader
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStageFileUnderTempDir(t *testing.T) {
+	u := newTestUploader(t)
+	u.tempDir = path.Join(t.TempDir(), "staging")
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "staging")
+
+	stagingName, err := u.stageFile(filename, path.Join(u.archivestore, "MSG_1.db"))
+	assert.Nil(t, err)
+	assert.Equal(t, u.tempDir, path.Dir(stagingName))
+
+	data, err := os.ReadFile(stagingName)
+	assert.Nil(t, err)
+	assert.Equal(t, "staging", string(data))
+}
+
+func TestStageFileDefaultsToArchivestore(t *testing.T) {
+	u := newTestUploader(t)
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "staging")
+
+	stagingName, err := u.stageFile(filename, path.Join(u.archivestore, "MSG_1.db"))
+	assert.Nil(t, err)
+	assert.Equal(t, u.archivestore, path.Dir(stagingName))
+}
+
+func TestCopyFile(t *testing.T) {
+	u := newTestUploader(t)
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "copy")
+	archiveName := path.Join(u.archivestore, "MSG_1.db")
+
+	err = u.copyFile(filename, archiveName)
+	assert.Nil(t, err)
+
+	data, err := os.ReadFile(archiveName)
+	assert.Nil(t, err)
+	assert.Equal(t, "copy", string(data))
+
+	// no staging file left behind
+	matches, err := os.ReadDir(u.archivestore)
+	assert.Nil(t, err)
+	assert.Len(t, matches, 1)
+}
+
+// flakyRenameFileSystem fails the first renames with err.
+type flakyRenameFileSystem struct {
+	osFileSystem
+	err      error
+	failures int
+	attempts int
+}
+
+func (f *flakyRenameFileSystem) Rename(oldpath string, newpath string) error {
+
+	f.attempts++
+	if f.attempts <= f.failures {
+		return &fs.PathError{Op: "rename", Path: oldpath, Err: f.err}
+	}
+
+	return f.osFileSystem.Rename(oldpath, newpath)
+}
+
+func TestCopyFileRenameRetry(t *testing.T) {
+	u := newTestUploader(t)
+	u.renameRetries = DefaultRenameRetries
+	u.renameRetryBackoff = time.Millisecond
+
+	fsys := &flakyRenameFileSystem{err: syscall.ESTALE, failures: 2}
+	u.fs = fsys
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "copy")
+	archiveName := path.Join(u.archivestore, "MSG_1.db")
+
+	err = u.copyFile(filename, archiveName)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, fsys.attempts)
+
+	data, err := os.ReadFile(archiveName)
+	assert.Nil(t, err)
+	assert.Equal(t, "copy", string(data))
+}
+
+func TestCopyFileRenameRetryExhausted(t *testing.T) {
+	u := newTestUploader(t)
+	u.renameRetries = 2
+	u.renameRetryBackoff = time.Millisecond
+
+	fsys := &flakyRenameFileSystem{err: syscall.EAGAIN, failures: 10}
+	u.fs = fsys
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "copy")
+
+	err = u.copyFile(filename, path.Join(u.archivestore, "MSG_1.db"))
+	assert.ErrorIs(t, err, syscall.EAGAIN)
+	assert.Equal(t, 3, fsys.attempts)
+}
+
+func TestCopyFileRenameNotRetryable(t *testing.T) {
+	u := newTestUploader(t)
+	u.renameRetries = DefaultRenameRetries
+	u.renameRetryBackoff = time.Millisecond
+
+	fsys := &flakyRenameFileSystem{err: syscall.EXDEV, failures: 1}
+	u.fs = fsys
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "copy")
+
+	err = u.copyFile(filename, path.Join(u.archivestore, "MSG_1.db"))
+	assert.ErrorIs(t, err, syscall.EXDEV)
+	assert.Equal(t, 1, fsys.attempts)
+}
+
+func benchmarkCopyBuffer(b *testing.B, size int) {
+	root := b.TempDir()
+	u := &Uploader{
+		archivestore:   path.Join(root, "archivestore"),
+		copyBufferSize: size,
+	}
+
+	err := os.MkdirAll(u.archivestore, 0750)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// 64MB source file
+	filename := path.Join(root, "large.db")
+	err = os.WriteFile(filename, make([]byte, 64*1024*1024), 0644)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(64 * 1024 * 1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stagingName, err := u.stageFile(filename, path.Join(u.archivestore, "MSG_1.db"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		os.Remove(stagingName)
+	}
+	b.StopTimer()
+}
+
+func BenchmarkCopyBuffer32K(b *testing.B) {
+	benchmarkCopyBuffer(b, 32*1024)
+}
+
+func BenchmarkCopyBuffer256K(b *testing.B) {
+	benchmarkCopyBuffer(b, 256*1024)
+}
+
+func BenchmarkCopyBuffer1M(b *testing.B) {
+	benchmarkCopyBuffer(b, 1024*1024)
+}
+
+func BenchmarkCopyBuffer8M(b *testing.B) {
+	benchmarkCopyBuffer(b, 8*1024*1024)
+}
+
+func TestCopyBufferSize(t *testing.T) {
+	u := newTestUploader(t)
+	u.copyBufferSize = MinCopyBufferSize
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	content := make([]byte, 3*MinCopyBufferSize+123)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	filename := createTestFile(t, u, "100/100/MSG_1.db", string(content))
+
+	stagingName, err := u.stageFile(filename, path.Join(u.archivestore, "MSG_1.db"))
+	assert.Nil(t, err)
+
+	data, err := os.ReadFile(stagingName)
+	assert.Nil(t, err)
+	assert.Equal(t, content, data)
+}
@@ -0,0 +1,84 @@
+package uploader
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultArchiveCounter = false
+	DefaultCounterFile    = ".archive.counter"
+)
+
+// archiveCounter is a monotonic counter of the archives of this node,
+// persisted in a state file so it keeps counting across restarts.
+type archiveCounter struct {
+	mutex    sync.Mutex
+	filename string
+	value    uint64
+}
+
+// loadArchiveCounter reads the counter from the state file. A missing file
+// starts the counter from zero.
+func loadArchiveCounter(filename string) (*archiveCounter, error) {
+
+	c := &archiveCounter{
+		filename: filename,
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	c.value, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive counter in %s: %w", filename, err)
+	}
+
+	return c, nil
+}
+
+// next increments the counter and persists it before returning the value.
+func (c *archiveCounter) next() (uint64, error) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	value := c.value + 1
+
+	if err := os.MkdirAll(path.Dir(c.filename), DefaultDirMode); err != nil {
+		return 0, err
+	}
+
+	tmpName := c.filename + ".tmp"
+	if err := os.WriteFile(tmpName, []byte(strconv.FormatUint(value, 10)+"\n"), 0644); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmpName, c.filename); err != nil {
+		os.Remove(tmpName)
+		return 0, err
+	}
+
+	c.value = value
+
+	return value, nil
+}
+
+// counterFilename returns the state file of the archive counter.
+func (u *Uploader) counterFilename() string {
+
+	if u.counterFile != "" {
+		return u.counterFile
+	}
+
+	return path.Join(u.archivestore, DefaultCounterFile)
+}
@@ -0,0 +1,56 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveCounter(t *testing.T) {
+	u := newTestUploader(t)
+
+	counter, err := loadArchiveCounter(u.counterFilename())
+	assert.Nil(t, err)
+	u.archiveCounter = counter
+
+	for i := 1; i <= 2; i++ {
+		filename := createTestFile(t, u, fmt.Sprintf("100/100/MSG_%d.db", i), "data")
+		_, err := u.Archive(fmt.Sprint(i), filename)
+		assert.Nil(t, err)
+	}
+
+	// simulated restart
+	counter, err = loadArchiveCounter(u.counterFilename())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), counter.value)
+	u.archiveCounter = counter
+
+	filename := createTestFile(t, u, "100/100/MSG_3.db", "data")
+	_, err = u.Archive("3", filename)
+	assert.Nil(t, err)
+
+	entries, err := NewIndexReader(u.indexFilename(filename)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+	for i, entry := range entries {
+		assert.Equal(t, uint64(i+1), entry.Counter)
+	}
+
+	// the state file isn't an orphaned archive
+	orphans, err := u.FindOrphans(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, orphans, 0)
+}
+
+func TestLoadArchiveCounterInvalid(t *testing.T) {
+	u := newTestUploader(t)
+
+	assert.Nil(t, os.MkdirAll(u.archivestore, 0750))
+	assert.Nil(t, os.WriteFile(u.counterFilename(), []byte("invalid"), 0644))
+
+	_, err := loadArchiveCounter(u.counterFilename())
+	assert.NotNil(t, err)
+}
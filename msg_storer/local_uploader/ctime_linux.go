@@ -0,0 +1,21 @@
+//go:build linux
+
+package uploader
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// changeTime returns the status change time of the file, which a rename into
+// the archivestore updates unlike the modification time.
+func changeTime(fi fs.FileInfo) time.Time {
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.ModTime()
+	}
+
+	return time.Unix(st.Ctim.Sec, st.Ctim.Nsec)
+}
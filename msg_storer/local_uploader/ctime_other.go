@@ -0,0 +1,13 @@
+//go:build !linux
+
+package uploader
+
+import (
+	"io/fs"
+	"time"
+)
+
+// changeTime falls back to the modification time on this platform.
+func changeTime(fi fs.FileInfo) time.Time {
+	return fi.ModTime()
+}
@@ -0,0 +1,127 @@
+package uploader
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DedupKeySeq   = "seq"
+	DedupKeyMsgID = "msg_id"
+
+	DefaultDedupKey    = DedupKeySeq
+	DefaultDedupWindow = time.Duration(0)
+)
+
+func validateDedupKey(key string) error {
+
+	switch key {
+	case DedupKeySeq, DedupKeyMsgID:
+		return nil
+	}
+
+	return fmt.Errorf("invalid dedup_key: %s", key)
+}
+
+// jobKey returns the key identifying the job for dedup. A message without
+// the Nats-Msg-Id header falls back to the sequence.
+func (u *Uploader) jobKey(j *job) string {
+
+	if u.dedupKey == DedupKeyMsgID && j.msg != nil {
+		if id := j.msg.Header.Get(nats.MsgIdHdr); id != "" {
+			return DedupKeyMsgID + ":" + id
+		}
+	}
+
+	return DedupKeySeq + ":" + j.seq + ":" + j.filename
+}
+
+type seenJob struct {
+	key         string
+	archiveName string
+	time        time.Time
+}
+
+// seenJobs remembers the jobs archived within the dedup window, so a job
+// delivered again is not archived twice.
+type seenJobs struct {
+	mutex sync.Mutex
+	jobs  map[string]seenJob
+	order []seenJob
+}
+
+// archived records the archive file of the job.
+func (s *seenJobs) archived(key string, archiveName string, window time.Duration) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.jobs == nil {
+		s.jobs = make(map[string]seenJob)
+	}
+
+	now := time.Now()
+	s.prune(now, window)
+
+	seen := seenJob{
+		key:         key,
+		archiveName: archiveName,
+		time:        now,
+	}
+	s.jobs[key] = seen
+	s.order = append(s.order, seen)
+}
+
+// lookup returns the archive file of the job when it was archived within the window.
+func (s *seenJobs) lookup(key string, window time.Duration) (string, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.prune(time.Now(), window)
+
+	seen, ok := s.jobs[key]
+
+	return seen.archiveName, ok
+}
+
+// prune forgets the jobs archived before the window, oldest first.
+func (s *seenJobs) prune(now time.Time, window time.Duration) {
+
+	n := 0
+	for _, seen := range s.order {
+		if now.Sub(seen.time) < window {
+			break
+		}
+
+		// the key was archived again since
+		if s.jobs[seen.key].time.Equal(seen.time) {
+			delete(s.jobs, seen.key)
+		}
+		n++
+	}
+
+	s.order = s.order[n:]
+}
+
+func (s *seenJobs) len() int {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.jobs)
+}
+
+// duplicate returns the archive file of the job when it was already archived
+// within dedup_window.
+func (u *Uploader) duplicate(j *job) (string, bool) {
+
+	if u.dedupWindow <= 0 {
+		return "", false
+	}
+
+	return u.seen.lookup(u.jobKey(j), u.dedupWindow)
+}
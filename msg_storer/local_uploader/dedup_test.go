@@ -0,0 +1,99 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMsgIDMsg(id string, data string) *nats.Msg {
+	m := nats.NewMsg("test")
+	m.Header.Set(nats.MsgIdHdr, id)
+	m.Data = []byte(data)
+
+	return m
+}
+
+func TestDedupMsgID(t *testing.T) {
+	u := newTestUploader(t)
+	u.dedupKey = DedupKeyMsgID
+	u.dedupWindow = time.Minute
+
+	first := createTestFile(t, u, "100/300/MSG_1.db", "1")
+	second := createTestFile(t, u, "100/300/MSG_2.db", "2")
+
+	u.msgHandler(newMsgIDMsg("job-1", "1:"+first))
+	u.msgHandler(newMsgIDMsg("job-1", "2:"+second))
+
+	_, err := os.Stat(path.Join(u.archivestore, "100/300/MSG_1.db"))
+	assert.Nil(t, err)
+
+	// the second delivery isn't archived
+	_, err = os.Stat(path.Join(u.archivestore, "100/300/MSG_2.db"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(second)
+	assert.Nil(t, err)
+
+	stats := u.Stats()
+	assert.Equal(t, uint64(1), stats.Archived)
+	assert.Equal(t, uint64(1), stats.Duplicates)
+
+	// another id is archived
+	u.msgHandler(newMsgIDMsg("job-2", "2:"+second))
+	_, err = os.Stat(path.Join(u.archivestore, "100/300/MSG_2.db"))
+	assert.Nil(t, err)
+}
+
+func TestDedupSeq(t *testing.T) {
+	u := newTestUploader(t)
+	u.dedupKey = DedupKeySeq
+	u.dedupWindow = time.Minute
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "1")
+
+	// the id is ignored with the seq key
+	u.msgHandler(newMsgIDMsg("job-1", "1:"+filename))
+	createTestFile(t, u, "100/300/MSG_1.db", "1")
+	u.msgHandler(newMsgIDMsg("job-2", "1:"+filename))
+
+	stats := u.Stats()
+	assert.Equal(t, uint64(1), stats.Archived)
+	assert.Equal(t, uint64(1), stats.Duplicates)
+	assert.Equal(t, uint64(0), stats.Failed)
+}
+
+func TestDedupKeyFallback(t *testing.T) {
+	u := newTestUploader(t)
+	u.dedupKey = DedupKeyMsgID
+
+	j := &job{seq: "1", filename: "MSG_1.db", msg: &nats.Msg{Subject: "test"}}
+	assert.Equal(t, "seq:1:MSG_1.db", u.jobKey(j))
+
+	j.msg = newMsgIDMsg("job-1", "")
+	assert.Equal(t, "msg_id:job-1", u.jobKey(j))
+}
+
+func TestSeenJobsWindow(t *testing.T) {
+	var seen seenJobs
+
+	seen.archived("a", "archive/a", time.Millisecond)
+	name, ok := seen.lookup("a", time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, "archive/a", name)
+
+	time.Sleep(5 * time.Millisecond)
+	seen.archived("b", "archive/b", time.Millisecond)
+
+	_, ok = seen.lookup("a", time.Millisecond)
+	assert.False(t, ok)
+	assert.Equal(t, 1, seen.len())
+}
+
+func TestValidateDedupKey(t *testing.T) {
+	assert.Nil(t, validateDedupKey(DedupKeyMsgID))
+	assert.NotNil(t, validateDedupKey("checksum"))
+}
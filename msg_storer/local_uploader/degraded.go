@@ -0,0 +1,74 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultDegradedNakDelay      = time.Minute
+	DefaultDegradedProbeInterval = 10 * time.Second
+)
+
+// isReadOnly reports whether the error was caused by a read-only filesystem.
+func isReadOnly(err error) bool {
+	return errors.Is(err, syscall.EROFS)
+}
+
+// enterDegraded stops archiving after a write failed on a read-only
+// filesystem. Messages are Nak'd with a long delay until probeWritable finds
+// the archivestore writable again, rather than each failing on its own.
+func (u *Uploader) enterDegraded(err error) {
+	if !u.degraded.Swap(true) {
+		u.logger.Error("Archivestore is read-only, archiving is suspended until it is writable again",
+			zap.String("archivestore", u.archivestore),
+			zap.Error(err),
+		)
+	}
+}
+
+// Degraded reports whether archiving is suspended because the archivestore is read-only.
+func (u *Uploader) Degraded() bool {
+	return u.degraded.Load()
+}
+
+// probeWritable leaves the degraded mode once a file can be created in the archivestore again.
+func (u *Uploader) probeWritable(ctx context.Context) error {
+
+	if !u.degraded.Load() {
+		return nil
+	}
+
+	fsys := u.fileSystem()
+
+	f, err := fsys.CreateTemp(u.archivestore, StagingPattern)
+	if os.IsNotExist(err) {
+		if err = fsys.MkdirAll(u.archivestore, 0750); err == nil {
+			f, err = fsys.CreateTemp(u.archivestore, StagingPattern)
+		}
+	}
+	if err != nil {
+		if isReadOnly(err) {
+			return nil
+		}
+		return err
+	}
+	f.Close()
+
+	if err := fsys.Remove(f.Name()); err != nil {
+		return err
+	}
+
+	if u.degraded.Swap(false) {
+		u.logger.Info("Archivestore is writable again, resumed archiving",
+			zap.String("archivestore", u.archivestore),
+		)
+	}
+
+	return nil
+}
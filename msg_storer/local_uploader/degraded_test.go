@@ -0,0 +1,98 @@
+package uploader
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+// readOnlyFileSystem fails every write with EROFS while readOnly is set.
+type readOnlyFileSystem struct {
+	osFileSystem
+	readOnly bool
+	writes   int
+}
+
+func (f *readOnlyFileSystem) write(op string, name string) error {
+
+	f.writes++
+	if f.readOnly {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.EROFS}
+	}
+
+	return nil
+}
+
+func (f *readOnlyFileSystem) CreateTemp(dir string, pattern string) (File, error) {
+
+	if err := f.write("open", dir); err != nil {
+		return nil, err
+	}
+
+	return f.osFileSystem.CreateTemp(dir, pattern)
+}
+
+func (f *readOnlyFileSystem) Rename(oldpath string, newpath string) error {
+
+	if err := f.write("rename", newpath); err != nil {
+		return err
+	}
+
+	return f.osFileSystem.Rename(oldpath, newpath)
+}
+
+func (f *readOnlyFileSystem) MkdirAll(p string, perm fs.FileMode) error {
+
+	if err := f.write("mkdir", p); err != nil {
+		return err
+	}
+
+	return f.osFileSystem.MkdirAll(p, perm)
+}
+
+func TestDegradedReadOnlyArchivestore(t *testing.T) {
+	u := newTestUploader(t)
+	u.degradedNakDelay = DefaultDegradedNakDelay
+
+	fsys := &readOnlyFileSystem{readOnly: true}
+	u.fs = fsys
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+	m := &nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	}
+
+	u.msgHandler(m)
+	assert.True(t, u.Degraded())
+	assert.True(t, u.Stats().Degraded)
+	assert.Equal(t, HealthReadOnly, u.health())
+	assert.FileExists(t, filename)
+
+	// no more moves are attempted while degraded
+	writes := fsys.writes
+	u.msgHandler(m)
+	assert.Equal(t, writes, fsys.writes)
+
+	// still read-only
+	assert.Nil(t, u.probeWritable(context.Background()))
+	assert.True(t, u.Degraded())
+
+	fsys.readOnly = false
+	assert.Nil(t, u.probeWritable(context.Background()))
+	assert.False(t, u.Degraded())
+
+	// the probe leaves nothing behind
+	entries, err := os.ReadDir(u.archivestore)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 0)
+
+	u.msgHandler(m)
+	assert.FileExists(t, path.Join(u.archivestore, "100/100/MSG_1.db"))
+}
@@ -0,0 +1,70 @@
+package uploader
+
+import (
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// archiveDeletedMarker writes an empty marker at the archive name of a source
+// which was deleted before it could be archived, and indexes it with the
+// deleted field set, so consumers learn the file existed. The marker is never
+// compressed.
+func (u *Uploader) archiveDeletedMarker(j *job, indexLock *heldLock, archiveName string, oldEntry *Entry) (string, error) {
+
+	filename := cleanFilename(j.filename)
+	now := time.Now()
+
+	entry := Entry{
+		Seq:         j.seq,
+		ArchiveName: archiveName,
+		Filename:    u.normalizeFilename(j.filename),
+		Time:        now,
+		Deleted:     now,
+		Labels:      u.subjectLabels(j.subject()),
+	}
+
+	if j.filename != entry.Filename {
+		entry.RawFilename = j.filename
+	}
+
+	var err error
+	if u.archiveCounter != nil {
+		entry.Counter, err = u.archiveCounter.next()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	f, err := os.OpenFile(archiveName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	if err := u.syncArchiveDir(archiveName); err != nil {
+		return "", err
+	}
+
+	u.logger.Warn("Source was deleted before archiving, archived a marker",
+		zap.String("fileName", filename),
+		zap.String("archiveName", archiveName),
+	)
+
+	err = u.writeIndexBounded(indexLock, filename, oldEntry, entry)
+	if err != nil {
+		return "", err
+	}
+
+	if u.recent != nil {
+		u.recent.add(entry)
+	}
+
+	u.counters.deletedMarkers.Add(1)
+
+	return archiveName, nil
+}
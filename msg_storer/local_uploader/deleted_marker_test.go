@@ -0,0 +1,60 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeletedMarker(t *testing.T) {
+	u := newTestUploader(t)
+	u.recordDeletedMarkers = true
+
+	// the directory of the source is gone too
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	})
+
+	archiveName := path.Join(u.archivestore, "100/300/MSG_1.db")
+	fi, err := os.Stat(archiveName)
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(0), fi.Size())
+	}
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+	assert.Equal(t, filename, entry.Filename)
+	assert.False(t, entry.Deleted.IsZero())
+	assert.Equal(t, int64(0), entry.Size)
+
+	stats := u.Stats()
+	assert.Equal(t, uint64(1), stats.DeletedMarkers)
+	assert.Equal(t, uint64(0), stats.Archived)
+	assert.Equal(t, uint64(0), stats.Failed)
+}
+
+func TestDeletedMarkerDisabled(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+
+	_, err := u.Archive("1", filename)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = os.Stat(path.Join(u.archivestore, "100/300/MSG_1.db"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDeletedMarkerEntry(t *testing.T) {
+	entry, ok := parseEntry("1:archivestore/MSG_1.db\tdeleted=2024-03-01T10:00:00Z")
+	assert.True(t, ok)
+	assert.Equal(t, "2024-03-01T10:00:00Z", entry.Deleted.Format("2006-01-02T15:04:05Z07:00"))
+	assert.Equal(t, "1:archivestore/MSG_1.db\tdeleted=2024-03-01T10:00:00Z", entry.String())
+}
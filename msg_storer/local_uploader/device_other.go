@@ -0,0 +1,13 @@
+//go:build !unix
+
+package uploader
+
+// sameDevice can't be determined on this platform, assume the same filesystem.
+func sameDevice(a string, b string) (bool, error) {
+	return true, nil
+}
+
+// isMountPoint can't be determined on this platform, assume a mount point.
+func isMountPoint(dir string) (bool, error) {
+	return true, nil
+}
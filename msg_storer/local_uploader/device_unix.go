@@ -0,0 +1,51 @@
+//go:build unix
+
+package uploader
+
+import (
+	"os"
+	"path"
+	"syscall"
+)
+
+// sameDevice reports whether both paths are on the same filesystem.
+func sameDevice(a string, b string) (bool, error) {
+
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+
+	sa, ok := fa.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true, nil
+	}
+
+	sb, ok := fb.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true, nil
+	}
+
+	return sa.Dev == sb.Dev, nil
+}
+
+// isMountPoint reports whether the directory is on another filesystem than
+// its parent, or is the root.
+func isMountPoint(dir string) (bool, error) {
+
+	if path.Clean(dir) == "/" {
+		return true, nil
+	}
+
+	same, err := sameDevice(dir, dir+"/..")
+	if err != nil {
+		return false, err
+	}
+
+	return !same, nil
+}
@@ -0,0 +1,59 @@
+package uploader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultCompressEvents = false
+
+	HeaderContentEncoding = "Content-Encoding"
+	ContentEncodingGzip   = "gzip"
+)
+
+// eventMsg returns the message of an event payload, gzipped and marked with
+// Content-Encoding when compress_events is set.
+func (u *Uploader) eventMsg(subject string, data []byte) (*nats.Msg, error) {
+
+	m := nats.NewMsg(subject)
+	m.Data = data
+
+	if !u.compressEvents {
+		return m, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	m.Data = buf.Bytes()
+	m.Header.Set(HeaderContentEncoding, ContentEncodingGzip)
+
+	return m, nil
+}
+
+// DecodeEventPayload returns the JSON payload of a completion or failure
+// event message, decompressing it according to its Content-Encoding.
+func DecodeEventPayload(m *nats.Msg) ([]byte, error) {
+
+	if m.Header.Get(HeaderContentEncoding) != ContentEncodingGzip {
+		return m.Data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(m.Data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
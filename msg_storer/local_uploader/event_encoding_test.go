@@ -0,0 +1,46 @@
+package uploader
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressEvents(t *testing.T) {
+	u := newTestUploader(t)
+	u.compressEvents = true
+	u.completionSubject = "uploader.completed"
+
+	var published *nats.Msg
+	u.completionPublisher = func(m *nats.Msg) error {
+		published = m
+		return nil
+	}
+
+	err := u.publishCompletion("1", "datastore/MSG_1.db", "archivestore/MSG_1.db")
+	assert.Nil(t, err)
+	assert.Equal(t, ContentEncodingGzip, published.Header.Get(HeaderContentEncoding))
+	assert.False(t, json.Valid(published.Data))
+
+	data, err := DecodeEventPayload(published)
+	assert.Nil(t, err)
+
+	var event CompletionEvent
+	assert.Nil(t, json.Unmarshal(data, &event))
+	assert.Equal(t, "1", event.Seq)
+	assert.Equal(t, "archivestore/MSG_1.db", event.ArchiveName)
+}
+
+func TestUncompressedEvents(t *testing.T) {
+	u := newTestUploader(t)
+
+	m, err := u.eventMsg("uploader.completed", []byte(`{"seq":"1"}`))
+	assert.Nil(t, err)
+	assert.Empty(t, m.Header.Get(HeaderContentEncoding))
+
+	data, err := DecodeEventPayload(m)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"seq":"1"}`, string(data))
+}
@@ -0,0 +1,158 @@
+package uploader
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EventArchived = "archived"
+	EventFailed   = "failed"
+	EventSkipped  = "skipped"
+
+	EventOverflowBlock      = "block"
+	EventOverflowDropOldest = "drop_oldest"
+
+	DefaultEventBufferSize = 0
+	DefaultEventOverflow   = EventOverflowBlock
+)
+
+// Event is emitted on the Events channel for every archive job. A skipped job
+// was archived by an earlier delivery already.
+type Event struct {
+	Type        string
+	Seq         string
+	Filename    string
+	ArchiveName string
+	Err         error
+	Time        time.Time
+}
+
+// eventStream delivers events to an in-process consumer. When the buffer is
+// full emit either blocks until the consumer catches up or drops the oldest
+// event.
+type eventStream struct {
+	mutex      sync.RWMutex
+	ch         chan Event
+	done       chan struct{}
+	closeOnce  sync.Once
+	closed     bool
+	dropOldest bool
+	dropped    atomic.Uint64
+}
+
+func validateEventOverflow(overflow string) error {
+
+	switch overflow {
+	case EventOverflowBlock, EventOverflowDropOldest:
+		return nil
+	}
+
+	return fmt.Errorf("invalid event_overflow: %s", overflow)
+}
+
+func newEventStream(size int, overflow string) *eventStream {
+	return &eventStream{
+		ch:         make(chan Event, size),
+		done:       make(chan struct{}),
+		dropOldest: overflow == EventOverflowDropOldest,
+	}
+}
+
+func (s *eventStream) emit(event Event) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return
+	}
+
+	if !s.dropOldest {
+		select {
+		case s.ch <- event:
+		case <-s.done:
+		}
+		return
+	}
+
+	for {
+		select {
+		case s.ch <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// close unblocks pending emits and closes the channel.
+func (s *eventStream) close() {
+
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		s.closed = true
+		close(s.ch)
+	})
+}
+
+// Events returns the channel archive events are emitted on, or nil unless
+// event_buffer_size is set. It is closed when the uploader stops.
+func (u *Uploader) Events() <-chan Event {
+
+	if u.events == nil {
+		return nil
+	}
+
+	return u.events.ch
+}
+
+// emitArchive emits the outcome of archiving the job.
+func (u *Uploader) emitArchive(j *job, archiveName string, err error) {
+
+	if u.events == nil {
+		return
+	}
+
+	event := Event{
+		Type:        EventArchived,
+		Seq:         j.seq,
+		Filename:    j.filename,
+		ArchiveName: archiveName,
+		Time:        time.Now(),
+	}
+
+	if err != nil {
+		event.Type = EventFailed
+		event.Err = err
+	}
+
+	u.events.emit(event)
+}
+
+// emitSkipped emits a job archived by an earlier delivery.
+func (u *Uploader) emitSkipped(j *job, archiveName string) {
+
+	if u.events == nil {
+		return
+	}
+
+	u.events.emit(Event{
+		Type:        EventSkipped,
+		Seq:         j.seq,
+		Filename:    j.filename,
+		ArchiveName: archiveName,
+		Time:        time.Now(),
+	})
+}
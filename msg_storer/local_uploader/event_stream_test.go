@@ -0,0 +1,94 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents(t *testing.T) {
+	u := newTestUploader(t)
+	u.events = newEventStream(16, EventOverflowBlock)
+
+	for i := 1; i <= 3; i++ {
+		filename := createTestFile(t, u, fmt.Sprintf("100/100/MSG_%d.db", i), "data")
+		_, err := u.Archive(fmt.Sprint(i), filename)
+		assert.Nil(t, err)
+	}
+
+	_, err := u.Archive("4", path.Join(u.datastore, "100/100/MSG_4.db"))
+	assert.NotNil(t, err)
+
+	// already archived
+	_, err = u.archiveOnce(&job{seq: "1", filename: path.Join(u.datastore, "100/100/MSG_1.db")})
+	assert.Nil(t, err)
+
+	assert.Nil(t, u.onStop(context.Background()))
+
+	events := make([]Event, 0)
+	for event := range u.Events() {
+		events = append(events, event)
+	}
+
+	if assert.Len(t, events, 5) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, EventArchived, events[i].Type)
+			assert.Equal(t, fmt.Sprint(i+1), events[i].Seq)
+			assert.Equal(t, path.Join(u.archivestore, fmt.Sprintf("100/100/MSG_%d.db", i+1)), events[i].ArchiveName)
+			assert.Nil(t, events[i].Err)
+		}
+
+		assert.Equal(t, EventFailed, events[3].Type)
+		assert.Equal(t, "4", events[3].Seq)
+		assert.NotNil(t, events[3].Err)
+
+		assert.Equal(t, EventSkipped, events[4].Type)
+		assert.Equal(t, "1", events[4].Seq)
+	}
+
+	// emitting after the channel is closed is a no-op
+	u.events.emit(Event{Type: EventArchived})
+}
+
+func TestEventsDropOldest(t *testing.T) {
+	s := newEventStream(2, EventOverflowDropOldest)
+
+	for i := 1; i <= 5; i++ {
+		s.emit(Event{Seq: fmt.Sprint(i)})
+	}
+	s.close()
+
+	seqs := make([]string, 0)
+	for event := range s.ch {
+		seqs = append(seqs, event.Seq)
+	}
+
+	assert.Equal(t, []string{"4", "5"}, seqs)
+	assert.Equal(t, uint64(3), s.dropped.Load())
+}
+
+func TestEventsBlockUntilClosed(t *testing.T) {
+	s := newEventStream(1, EventOverflowBlock)
+	s.emit(Event{Seq: "1"})
+
+	done := make(chan struct{})
+	go func() {
+		s.emit(Event{Seq: "2"})
+		close(done)
+	}()
+
+	// the blocked emit returns once the stream is closed
+	s.close()
+	<-done
+
+	event := <-s.ch
+	assert.Equal(t, "1", event.Seq)
+}
+
+func TestEventsDisabled(t *testing.T) {
+	u := newTestUploader(t)
+	assert.Nil(t, u.Events())
+}
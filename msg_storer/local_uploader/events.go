@@ -0,0 +1,116 @@
+package uploader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultErrorSubject = ""
+
+	ActionNak  = "nak"
+	ActionTerm = "term"
+
+	// the file is given up while the message is acked
+	ActionAck = "ack"
+
+	ErrorClassCollision    = "collision"
+	ErrorClassSamePath     = "same_path"
+	ErrorClassNotFound     = "not_found"
+	ErrorClassPermission   = "permission"
+	ErrorClassPublish      = "publish"
+	ErrorClassNoStream     = "no_stream"
+	ErrorClassTimeout      = "publish_timeout"
+	ErrorClassVerify       = "verify"
+	ErrorClassIndexTimeout = "index_timeout"
+	ErrorClassIndexNoSpace = "index_no_space"
+	ErrorClassNoSpace      = "no_space"
+	ErrorClassUnknown      = "unknown"
+)
+
+// FailureEvent is published to the error subject whenever a message is Nak'd or
+// Term'd, and for archive files failing the background verification.
+type FailureEvent struct {
+	Seq           string    `json:"seq"`
+	Filename      string    `json:"filename"`
+	Action        string    `json:"action"`
+	ErrorClass    string    `json:"error_class"`
+	Error         string    `json:"error"`
+	DeliveryCount uint64    `json:"delivery_count"`
+	Hostname      string    `json:"hostname"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func classifyError(err error) string {
+
+	switch {
+	case errors.Is(err, ErrVerifyFailed):
+		return ErrorClassVerify
+	case errors.Is(err, ErrIndexWriteTimeout):
+		return ErrorClassIndexTimeout
+	case errors.Is(err, ErrIndexNoSpace):
+		return ErrorClassIndexNoSpace
+	case isNoSpace(err):
+		return ErrorClassNoSpace
+	case errors.Is(err, ErrArchiveCollision):
+		return ErrorClassCollision
+	case errors.Is(err, ErrCompletionNoStream):
+		return ErrorClassNoStream
+	case errors.Is(err, ErrCompletionPublish) && isPublishTimeout(err):
+		return ErrorClassTimeout
+	case errors.Is(err, ErrCompletionPublish):
+		return ErrorClassPublish
+	case errors.Is(err, ErrSameArchivePath):
+		return ErrorClassSamePath
+	case errors.Is(err, os.ErrNotExist):
+		return ErrorClassNotFound
+	case errors.Is(err, os.ErrPermission):
+		return ErrorClassPermission
+	}
+
+	return ErrorClassUnknown
+}
+
+// publishFailure publishes a failure event on a best-effort basis.
+func (u *Uploader) publishFailure(m *nats.Msg, seq string, filename string, action string, err error) {
+
+	if u.errorSubject == "" {
+		return
+	}
+
+	event := FailureEvent{
+		Seq:        seq,
+		Filename:   filename,
+		Action:     action,
+		ErrorClass: classifyError(err),
+		Error:      err.Error(),
+		Hostname:   u.hostname,
+		Timestamp:  time.Now(),
+	}
+
+	if m != nil {
+		if meta, err := m.Metadata(); err == nil {
+			event.DeliveryCount = meta.NumDelivered
+		}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return
+	}
+
+	msg, err := u.eventMsg(u.errorSubject, data)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return
+	}
+
+	if err := u.params.NATSConnector.GetConnection().PublishMsg(msg); err != nil {
+		u.logger.Error(err.Error())
+	}
+}
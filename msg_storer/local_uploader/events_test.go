@@ -0,0 +1,47 @@
+package uploader
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func (s *TestSuite) TestPublishFailure() {
+	u := s.uploader
+	u.errorSubject = "uploader.errors.test"
+	defer func() {
+		u.errorSubject = DefaultErrorSubject
+	}()
+
+	nc := u.params.NATSConnector.GetConnection()
+	sub, err := nc.SubscribeSync(u.errorSubject)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// the source doesn't exist, so the archive fails
+	filename := "datastore/100/300/MSG_404.db"
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("404:" + filename),
+	})
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	var event FailureEvent
+	err = json.Unmarshal(msg.Data, &event)
+	s.Nil(err)
+	s.Equal("404", event.Seq)
+	s.Equal(filename, event.Filename)
+	s.Equal(ActionNak, event.Action)
+	s.Equal(ErrorClassNotFound, event.ErrorClass)
+	s.Equal(u.hostname, event.Hostname)
+	s.False(event.Timestamp.IsZero())
+}
@@ -0,0 +1,221 @@
+package uploader
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMaxStoreBytes = 0
+	DefaultEvictMinAge   = time.Hour
+	DefaultEvictInterval = time.Minute
+)
+
+type evictCandidate struct {
+	indexFilename string
+	entry         Entry
+	size          int64
+	time          time.Time
+}
+
+// evict deletes the oldest indexed archive files, tombstoning their index
+// entries, until the archivestore is under max_store_bytes. Archive files
+// younger than evict_min_age are kept.
+func (u *Uploader) evict(ctx context.Context) error {
+
+	if u.maxStoreBytes <= 0 {
+		return nil
+	}
+
+	total, err := u.storeSize(ctx)
+	if err != nil {
+		return err
+	}
+
+	if total <= u.maxStoreBytes {
+		return nil
+	}
+
+	candidates, err := u.evictCandidates(ctx)
+	if err != nil {
+		return err
+	}
+
+	// pick the oldest archive files until under budget
+	deadline := time.Now().Add(-u.evictMinAge)
+	victims := make(map[string][]evictCandidate)
+	for _, c := range candidates {
+		if total <= u.maxStoreBytes {
+			break
+		}
+
+		if c.time.After(deadline) {
+			continue
+		}
+
+		victims[c.indexFilename] = append(victims[c.indexFilename], c)
+		total -= c.size
+	}
+
+	evicted := 0
+	for indexFilename, cs := range victims {
+		n, err := u.evictFromIndex(indexFilename, cs)
+		evicted += n
+		if err != nil {
+			return err
+		}
+	}
+
+	if evicted > 0 {
+		u.logger.Info("Evicted archive files",
+			zap.Int("count", evicted),
+			zap.Int64("storeBytes", total),
+		)
+	}
+
+	return nil
+}
+
+// storeSize returns the total size of the files under the archivestore.
+func (u *Uploader) storeSize(ctx context.Context) (int64, error) {
+
+	var total int64
+
+	err := filepath.WalkDir(u.archivestore, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		total += fi.Size()
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	return total, err
+}
+
+// evictCandidates returns the live index entries, oldest indexed first.
+func (u *Uploader) evictCandidates(ctx context.Context) ([]evictCandidate, error) {
+
+	candidates := make([]evictCandidate, 0)
+
+	err := u.eachIndexFile(ctx, func(indexFilename string) error {
+		return NewIndexReader(indexFilename).each(ctx, func(entry Entry) error {
+
+			if !entry.Evicted.IsZero() {
+				return nil
+			}
+
+			fi, err := os.Stat(entry.ArchiveName)
+			if err != nil {
+				return nil
+			}
+
+			c := evictCandidate{
+				indexFilename: indexFilename,
+				entry:         entry,
+				size:          fi.Size(),
+				time:          entry.Time,
+			}
+
+			for _, suffix := range []string{MetadataSidecarSuffix, ProvenanceSidecarSuffix} {
+				if sfi, err := os.Stat(entry.ArchiveName + suffix); err == nil {
+					c.size += sfi.Size()
+				}
+			}
+
+			if c.time.IsZero() {
+				c.time = fi.ModTime()
+			}
+
+			candidates = append(candidates, c)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].time.Before(candidates[j].time)
+	})
+
+	return candidates, nil
+}
+
+// evictFromIndex removes the archive files of the candidates and tombstones
+// their entries, holding the index lock so no archive is indexed meanwhile.
+func (u *Uploader) evictFromIndex(indexFilename string, candidates []evictCandidate) (int, error) {
+
+	unlock := u.dirLocks.lock(indexFilename)
+	defer unlock()
+
+	entries := make([]Entry, 0, len(candidates))
+	for _, c := range candidates {
+		entries = append(entries, c.entry)
+	}
+
+	return u.removeArchives(indexFilename, entries)
+}
+
+// removeArchives removes the archive files of the entries and marks their
+// entries as evicted. The caller holds the lock of the index.
+func (u *Uploader) removeArchives(indexFilename string, entries []Entry) (int, error) {
+
+	now := time.Now()
+	removed := make(map[string]bool)
+	for _, entry := range entries {
+		if err := u.clearImmutable(entry.ArchiveName); err != nil {
+			u.logger.Error(err.Error())
+			continue
+		}
+		if err := os.Remove(entry.ArchiveName); err != nil && !os.IsNotExist(err) {
+			u.logger.Error(err.Error())
+			continue
+		}
+		os.Remove(entry.ArchiveName + MetadataSidecarSuffix)
+		os.Remove(entry.ArchiveName + ProvenanceSidecarSuffix)
+
+		removed[entry.Seq+":"+entry.ArchiveName] = true
+
+		u.logger.Debug("Evicted archive file",
+			zap.String("seq", entry.Seq),
+			zap.String("archiveName", entry.ArchiveName),
+		)
+	}
+
+	if len(removed) == 0 {
+		return 0, nil
+	}
+
+	err := rewriteIndex(indexFilename, func(entry Entry) (Entry, bool) {
+		if entry.Evicted.IsZero() && removed[entry.Seq+":"+entry.ArchiveName] {
+			entry.Evicted = now
+		}
+		return entry, true
+	})
+
+	return len(removed), err
+}
@@ -0,0 +1,68 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvict(t *testing.T) {
+	u := newTestUploader(t)
+	u.maxStoreBytes = 25
+
+	archiveNames := make([]string, 0)
+	for i := 1; i <= 3; i++ {
+		filename := createTestFile(t, u, fmt.Sprintf("100/100/MSG_%d.db", i), "0123456789")
+		archiveName, err := u.Archive(fmt.Sprint(i), filename)
+		assert.Nil(t, err)
+		archiveNames = append(archiveNames, archiveName)
+	}
+
+	// too young to be evicted
+	u.evictMinAge = time.Hour
+	assert.Nil(t, u.evict(context.Background()))
+	for _, archiveName := range archiveNames {
+		assert.FileExists(t, archiveName)
+	}
+
+	u.evictMinAge = 0
+	assert.Nil(t, u.evict(context.Background()))
+
+	_, err := os.Stat(archiveNames[0])
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, archiveNames[1])
+	assert.FileExists(t, archiveNames[2])
+
+	size, err := u.storeSize(context.Background())
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, size, u.maxStoreBytes)
+
+	// the entry is kept as a tombstone
+	entries, err := NewIndexReader(path.Join(u.datastore, "100/100", DefaultArchiveIndex)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+	assert.False(t, entries[0].Evicted.IsZero())
+	assert.True(t, entries[1].Evicted.IsZero())
+	assert.True(t, entries[2].Evicted.IsZero())
+
+	// tombstones aren't reported as missing
+	report, err := u.Validate(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, report.Missing, 0)
+
+	// under budget nothing else is evicted
+	assert.Nil(t, u.evict(context.Background()))
+	assert.FileExists(t, archiveNames[1])
+}
+
+func TestEntryEvicted(t *testing.T) {
+	ts := time.Date(2023, 11, 2, 10, 0, 0, 0, time.UTC)
+	entry, ok := parseEntry(Entry{Seq: "1", ArchiveName: "a", Evicted: ts}.String())
+	assert.True(t, ok)
+	assert.Equal(t, ts, entry.Evicted)
+}
@@ -0,0 +1,168 @@
+package uploader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultFailureLog             = "archive.failures"
+	DefaultMissingSourceTermAfter = 0
+
+	// failure record fields
+	FieldErrorClass = "class"
+	FieldError      = "error"
+)
+
+var (
+	ErrSourceMissing = errors.New("Source file is permanently missing.")
+)
+
+// FailureRecord is a single record of the failure log, written next to the
+// index when a message is Term'd or a file of a best_effort batch is given
+// up, so every sequence can be accounted for.
+type FailureRecord struct {
+	Seq        string
+	Filename   string
+	ErrorClass string
+	Error      string
+	Time       time.Time
+}
+
+func (r FailureRecord) String() string {
+
+	var sb strings.Builder
+	sb.WriteString(r.Seq)
+	sb.WriteString(":")
+	sb.WriteString(r.Filename)
+	sb.WriteString(fmt.Sprintf("\t%s=%s", FieldErrorClass, url.QueryEscape(r.ErrorClass)))
+	sb.WriteString(fmt.Sprintf("\t%s=%s", FieldError, url.QueryEscape(r.Error)))
+	sb.WriteString(fmt.Sprintf("\t%s=%s", FieldTime, r.Time.UTC().Format(time.RFC3339Nano)))
+
+	return sb.String()
+}
+
+func parseFailureRecord(line string) (FailureRecord, bool) {
+
+	fields := strings.Split(line, "\t")
+
+	cols := strings.SplitN(fields[0], ":", 2)
+	if len(cols) != 2 || cols[0] == "" {
+		return FailureRecord{}, false
+	}
+
+	record := FailureRecord{
+		Seq:      cols[0],
+		Filename: cols[1],
+	}
+
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		value, err := url.QueryUnescape(kv[1])
+		if err != nil {
+			continue
+		}
+
+		switch kv[0] {
+		case FieldErrorClass:
+			record.ErrorClass = value
+		case FieldError:
+			record.Error = value
+		case FieldTime:
+			record.Time, _ = time.Parse(time.RFC3339Nano, value)
+		}
+	}
+
+	return record, true
+}
+
+// ReadFailures returns the records of a failure log in the order they were written.
+// A missing log is treated as empty.
+func ReadFailures(failureLog string) ([]FailureRecord, error) {
+
+	records := make([]FailureRecord, 0)
+
+	f, err := os.Open(failureLog)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if record, ok := parseFailureRecord(scanner.Text()); ok {
+			records = append(records, record)
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+func (u *Uploader) failureLogFilename(filename string) string {
+	return path.Join(path.Dir(filename), DefaultFailureLog)
+}
+
+// recordFailure appends the Term'd job to the failure log next to its index.
+func (u *Uploader) recordFailure(j *job, err error) {
+
+	if !u.recordFailures {
+		return
+	}
+
+	filename := cleanFilename(j.filename)
+	record := FailureRecord{
+		Seq:        j.seq,
+		Filename:   u.normalizeFilename(j.filename),
+		ErrorClass: classifyError(err),
+		Error:      err.Error(),
+		Time:       time.Now(),
+	}
+
+	f, ferr := os.OpenFile(u.failureLogFilename(filename), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if ferr == nil {
+		_, ferr = f.WriteString(record.String() + "\n")
+		if cerr := f.Close(); ferr == nil {
+			ferr = cerr
+		}
+	}
+
+	if ferr != nil {
+		u.logger.Error("Failed to record failure",
+			zap.String("seq", j.seq),
+			zap.String("fileName", filename),
+			zap.Error(ferr),
+		)
+	}
+}
+
+// sourceGone reports whether a missing source should be given up on, after it
+// was missing for the configured number of deliveries.
+func (u *Uploader) sourceGone(m *nats.Msg) bool {
+
+	if u.missingSourceTermAfter <= 0 {
+		return false
+	}
+
+	delivered := uint64(1)
+	if meta, err := m.Metadata(); err == nil {
+		delivered = meta.NumDelivered
+	}
+
+	return delivered >= uint64(u.missingSourceTermAfter)
+}
@@ -0,0 +1,89 @@
+package uploader
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFailures(t *testing.T) {
+	u := newTestUploader(t)
+	u.recordFailures = true
+	u.missingSourceTermAfter = 1
+
+	createTestFile(t, u, "100/300/MSG_1.db", "data")
+	filename := path.Join(u.datastore, "100/300/MSG_404.db")
+
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("404:" + filename),
+	})
+
+	records, err := ReadFailures(u.failureLogFilename(filename))
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "404", records[0].Seq)
+		assert.Equal(t, filename, records[0].Filename)
+		assert.Equal(t, ErrorClassNotFound, records[0].ErrorClass)
+		assert.Contains(t, records[0].Error, "permanently missing")
+		assert.False(t, records[0].Time.IsZero())
+	}
+
+	// no index entry is written for the failure
+	entries, err := NewIndexReader(u.indexFilename(filename)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestRecordFailuresDisabled(t *testing.T) {
+	u := newTestUploader(t)
+	u.missingSourceTermAfter = 1
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+	missing := path.Join(u.datastore, "100/300/MSG_404.db")
+
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("404:" + missing),
+	})
+
+	records, err := ReadFailures(u.failureLogFilename(filename))
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+}
+
+func TestSourceGone(t *testing.T) {
+	u := newTestUploader(t)
+	m := &nats.Msg{Subject: "test"}
+
+	// a missing source is Nak'd by default
+	assert.False(t, u.sourceGone(m))
+
+	u.missingSourceTermAfter = 1
+	assert.True(t, u.sourceGone(m))
+
+	u.missingSourceTermAfter = 3
+	assert.False(t, u.sourceGone(m))
+
+	err := errors.Join(ErrSourceMissing)
+	assert.True(t, isPermanent(err))
+}
+
+func TestFailureRecordString(t *testing.T) {
+	record := FailureRecord{
+		Seq:        "1",
+		Filename:   "datastore/a b/MSG_1.db",
+		ErrorClass: ErrorClassNotFound,
+		Error:      "no such file\tor directory",
+	}
+
+	parsed, ok := parseFailureRecord(record.String())
+	assert.True(t, ok)
+	assert.Equal(t, record.Seq, parsed.Seq)
+	assert.Equal(t, record.Filename, parsed.Filename)
+	assert.Equal(t, record.ErrorClass, parsed.ErrorClass)
+	assert.Equal(t, record.Error, parsed.Error)
+}
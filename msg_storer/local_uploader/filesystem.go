@@ -0,0 +1,91 @@
+package uploader
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// File is an open file of a FileSystem.
+type File interface {
+	io.Reader
+	io.Writer
+	Name() string
+	Stat() (fs.FileInfo, error)
+	Chmod(mode fs.FileMode) error
+	Sync() error
+	Close() error
+}
+
+// FileSystem is the file system files are moved and copied on.
+type FileSystem interface {
+	Open(name string) (File, error)
+	CreateTemp(dir string, pattern string) (File, error)
+	Rename(oldpath string, newpath string) error
+	Remove(name string) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Chmod(name string, mode fs.FileMode) error
+	Stat(name string) (fs.FileInfo, error)
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// osFileSystem is the FileSystem of the os package.
+type osFileSystem struct{}
+
+func (osFileSystem) Open(name string) (File, error) {
+	return os.Open(name)
+}
+
+func (osFileSystem) CreateTemp(dir string, pattern string) (File, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+func (osFileSystem) Rename(oldpath string, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFileSystem) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFileSystem) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (osFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFileSystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (u *Uploader) fileSystem() FileSystem {
+
+	if u.fs == nil {
+		return osFileSystem{}
+	}
+
+	return u.fs
+}
+
+// syncDir flushes the directory, making renames into it durable.
+func (u *Uploader) syncDir(dir string) error {
+
+	d, err := u.fileSystem().Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+
+	return d.Close()
+}
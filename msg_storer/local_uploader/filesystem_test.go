@@ -0,0 +1,110 @@
+package uploader
+
+import (
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingFileSystem records the files synced and renamed on the os file system.
+type recordingFileSystem struct {
+	osFileSystem
+	mutex   sync.Mutex
+	synced  []string
+	renamed map[string]string
+}
+
+type recordingFile struct {
+	File
+	fs *recordingFileSystem
+}
+
+func newRecordingFileSystem() *recordingFileSystem {
+	return &recordingFileSystem{
+		renamed: make(map[string]string),
+	}
+}
+
+func (fs *recordingFileSystem) Open(name string) (File, error) {
+	f, err := fs.osFileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingFile{File: f, fs: fs}, nil
+}
+
+func (fs *recordingFileSystem) CreateTemp(dir string, pattern string) (File, error) {
+	f, err := fs.osFileSystem.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingFile{File: f, fs: fs}, nil
+}
+
+func (fs *recordingFileSystem) Rename(oldpath string, newpath string) error {
+	fs.mutex.Lock()
+	fs.renamed[oldpath] = newpath
+	fs.mutex.Unlock()
+
+	return fs.osFileSystem.Rename(oldpath, newpath)
+}
+
+func (f *recordingFile) Sync() error {
+	f.fs.mutex.Lock()
+	f.fs.synced = append(f.fs.synced, f.Name())
+	f.fs.mutex.Unlock()
+
+	return f.File.Sync()
+}
+
+func TestFsyncArchive(t *testing.T) {
+	fs := newRecordingFileSystem()
+
+	u := newTestUploader(t)
+	u.fs = fs
+	u.fsyncArchive = true
+	u.compression = CompressionGzip
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	// the staging file is synced before it is renamed to the archive name
+	if assert.Len(t, fs.synced, 1) {
+		assert.Equal(t, archiveName, fs.renamed[fs.synced[0]])
+	}
+}
+
+func TestFsyncArchiveDisabled(t *testing.T) {
+	fs := newRecordingFileSystem()
+
+	u := newTestUploader(t)
+	u.fs = fs
+	u.compression = CompressionGzip
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Len(t, fs.synced, 0)
+}
+
+func TestFsyncDir(t *testing.T) {
+	fs := newRecordingFileSystem()
+
+	u := newTestUploader(t)
+	u.fs = fs
+	u.fsyncArchive = true
+	u.fsyncDir = true
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	// a same device rename only syncs the directory
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{path.Dir(archiveName)}, fs.synced)
+	assert.Equal(t, archiveName, fs.renamed[filename])
+}
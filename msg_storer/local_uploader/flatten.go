@@ -0,0 +1,78 @@
+package uploader
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	DefaultFlattenDepth = 0
+
+	// separator of the directories collapsed by flatten_depth
+	FlattenSeparator = "__"
+)
+
+// underscores and percent signs of collapsed directories are escaped, so the
+// separator can't be confused with a directory name and the path is reversible
+var (
+	flattenEscaper   = strings.NewReplacer("%", "%25", "_", "%5F")
+	flattenUnescaper = strings.NewReplacer("%25", "%", "%5F", "_")
+)
+
+// flatten collapses the directories of the archive name below root deeper
+// than flatten_depth into a single directory, e.g. a/b/c/d/MSG_1.db becomes
+// a/b/c__d/MSG_1.db with a depth of 2.
+func (u *Uploader) flatten(archiveName string, root string) string {
+
+	root = path.Join(root)
+
+	rel, ok := strings.CutPrefix(archiveName, root+"/")
+	if u.flattenDepth <= 0 || !ok {
+		return archiveName
+	}
+
+	dirs := strings.Split(path.Dir(rel), "/")
+	if len(dirs) <= u.flattenDepth+1 {
+		return archiveName
+	}
+
+	collapsed := make([]string, 0, len(dirs)-u.flattenDepth)
+	for _, dir := range dirs[u.flattenDepth:] {
+		collapsed = append(collapsed, flattenEscaper.Replace(dir))
+	}
+
+	parts := append([]string{root}, dirs[:u.flattenDepth]...)
+	parts = append(parts, strings.Join(collapsed, FlattenSeparator), path.Base(rel))
+
+	return path.Join(parts...)
+}
+
+// unflatten reverses flatten. A directory of the depth which was named with
+// the separator can't be told apart, which the index entry resolves.
+func (u *Uploader) unflatten(archiveName string, root string) string {
+
+	root = path.Join(root)
+
+	rel, ok := strings.CutPrefix(archiveName, root+"/")
+	if u.flattenDepth <= 0 || !ok {
+		return archiveName
+	}
+
+	dirs := strings.Split(path.Dir(rel), "/")
+	if len(dirs) != u.flattenDepth+1 {
+		return archiveName
+	}
+
+	collapsed := dirs[u.flattenDepth]
+	if !strings.Contains(collapsed, FlattenSeparator) {
+		return archiveName
+	}
+
+	parts := append([]string{root}, dirs[:u.flattenDepth]...)
+	for _, dir := range strings.Split(collapsed, FlattenSeparator) {
+		parts = append(parts, flattenUnescaper.Replace(dir))
+	}
+	parts = append(parts, path.Base(rel))
+
+	return path.Join(parts...)
+}
@@ -0,0 +1,61 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatten(t *testing.T) {
+	u := newTestUploader(t)
+	u.flattenDepth = 2
+
+	root := u.archivestore
+	deep := path.Join(root, "a/b/c/d_1/e/MSG_1.db")
+
+	flat := u.flatten(deep, root)
+	assert.Equal(t, path.Join(root, "a/b/c__d%5F1__e/MSG_1.db"), flat)
+	assert.Equal(t, deep, u.unflatten(flat, root))
+
+	// shallow enough
+	shallow := path.Join(root, "a/b/c/MSG_1.db")
+	assert.Equal(t, shallow, u.flatten(shallow, root))
+	assert.Equal(t, shallow, u.unflatten(shallow, root))
+
+	// outside of the root
+	assert.Equal(t, "/tmp/a/b/c/d/MSG_1.db", u.flatten("/tmp/a/b/c/d/MSG_1.db", root))
+
+	u.flattenDepth = 0
+	assert.Equal(t, deep, u.flatten(deep, root))
+}
+
+func TestFlattenArchive(t *testing.T) {
+	u := newTestUploader(t)
+	u.flattenDepth = 1
+
+	filename := createTestFile(t, u, "100/200/300/400/MSG_1.db", "deep")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/200__300__400/MSG_1.db"), archiveName)
+
+	// the index records both paths
+	entry, err := u.Lookup("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+	assert.Equal(t, filename, entry.Filename)
+
+	restored, err := u.Restore("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, filename, restored)
+
+	data, err := os.ReadFile(restored)
+	assert.Nil(t, err)
+	assert.Equal(t, "deep", string(data))
+
+	// the rebuilt source maps back to the original
+	_, original := u.rebuildSource(archiveName)
+	assert.Equal(t, filename, original)
+}
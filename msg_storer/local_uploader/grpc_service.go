@@ -0,0 +1,258 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative uploader_service.proto
+
+const (
+	DefaultGRPCAddress = ""
+)
+
+// grpcService serves UploaderService with the same calls as the Go API.
+type grpcService struct {
+	UnimplementedUploaderServiceServer
+	u *Uploader
+}
+
+// newGRPCServer returns a server with UploaderService registered.
+func (u *Uploader) newGRPCServer() *grpc.Server {
+	s := grpc.NewServer()
+	RegisterUploaderServiceServer(s, &grpcService{u: u})
+	return s
+}
+
+// startGRPCServer serves UploaderService on grpc_address, when set.
+func (u *Uploader) startGRPCServer() error {
+
+	if u.grpcAddress == "" {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", u.grpcAddress)
+	if err != nil {
+		return err
+	}
+
+	s := u.newGRPCServer()
+	u.grpcServer = s
+
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			u.logger.Error("gRPC server stopped", zap.Error(err))
+		}
+	}()
+
+	u.logger.Info("Serving gRPC",
+		zap.String("address", lis.Addr().String()),
+	)
+
+	return nil
+}
+
+// stopGRPCServer stops the server once the pending calls are done, and
+// cancels them when ctx is done first, e.g. a long StreamEntries.
+func (u *Uploader) stopGRPCServer(ctx context.Context) {
+
+	s := u.grpcServer
+	if s == nil {
+		return
+	}
+	u.grpcServer = nil
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		u.logger.Warn("Cancelling pending gRPC calls", zap.Error(ctx.Err()))
+		s.Stop()
+		<-stopped
+	}
+}
+
+func (s *grpcService) Lookup(ctx context.Context, req *LookupRequest) (*IndexEntry, error) {
+
+	entry, err := s.u.Lookup(req.Seq, req.Filename)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return newIndexEntry("", entry), nil
+}
+
+func (s *grpcService) Restore(ctx context.Context, req *RestoreRequest) (*RestoreResponse, error) {
+
+	filename, err := s.u.Restore(req.Seq, req.Filename)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &RestoreResponse{Filename: filename}, nil
+}
+
+func (s *grpcService) Validate(ctx context.Context, req *ValidateRequest) (*ValidateResponse, error) {
+
+	report, err := s.u.Validate(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &ValidateResponse{
+		Checked: int64(report.Checked),
+		Missing: newValidateIssues(report.Missing),
+		Corrupt: newValidateIssues(report.Corrupt),
+		Unclean: report.Unclean,
+	}, nil
+}
+
+func (s *grpcService) Stats(ctx context.Context, req *StatsRequest) (*StatsResponse, error) {
+
+	stats := s.u.Stats()
+
+	return &StatsResponse{
+		Paused:              stats.Paused,
+		Degraded:            stats.Degraded,
+		LowSpace:            stats.LowSpace,
+		Archived:            stats.Archived,
+		Failed:              stats.Failed,
+		ArchivedBytes:       stats.ArchivedBytes,
+		Requeued:            stats.Requeued,
+		Verified:            stats.Verified,
+		VerifyFailures:      stats.VerifyFailures,
+		EventsDropped:       stats.EventsDropped,
+		Fallbacks:           stats.Fallbacks,
+		DeletedMarkers:      stats.DeletedMarkers,
+		Duplicates:          stats.Duplicates,
+		Rollbacks:           stats.Rollbacks,
+		ConsumerPending:     stats.ConsumerPending,
+		ConsumerAckPending:  stats.ConsumerAckPending,
+		ConsumerRedelivered: stats.ConsumerRedelivered,
+		Audited:             stats.Audited,
+		AuditDeviations:     stats.AuditDeviations,
+		AuditFixed:          stats.AuditFixed,
+	}, nil
+}
+
+// StreamEntries sends the entries one index at a time, so listing every index
+// doesn't hold the whole archive in memory.
+func (s *grpcService) StreamEntries(req *StreamEntriesRequest, stream UploaderService_StreamEntriesServer) error {
+
+	ctx := stream.Context()
+
+	files, err := s.u.indexFiles(ctx)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	// only indexes of the uploader are opened, whatever the caller names
+	if req.Index != "" {
+		files = filterIndex(files, req.Index)
+		if len(files) == 0 {
+			return status.Errorf(codes.NotFound, "unknown index: %s", req.Index)
+		}
+	}
+
+	for _, indexFilename := range files {
+		err := s.u.eachIndexEntry(ctx, indexFilename, func(entry Entry) error {
+			return stream.Send(newIndexEntry(indexFilename, entry))
+		})
+		if err != nil {
+			if _, ok := status.FromError(err); ok {
+				return err
+			}
+			return grpcError(err)
+		}
+	}
+
+	return nil
+}
+
+// filterIndex returns the index of files matching index.
+func filterIndex(files []string, index string) []string {
+
+	for _, indexFilename := range files {
+		if indexFilename == index {
+			return []string{indexFilename}
+		}
+	}
+
+	return nil
+}
+
+// grpcError maps the errors of the uploader to gRPC status codes.
+func grpcError(err error) error {
+
+	switch {
+	case errors.Is(err, ErrEntryNotFound), errors.Is(err, os.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrRestoreExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrRestoreEvicted), errors.Is(err, ErrReadOnlyReplica):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+func newIndexEntry(indexFilename string, e Entry) *IndexEntry {
+	return &IndexEntry{
+		Seq:         e.Seq,
+		ArchiveName: e.ArchiveName,
+		Size:        e.Size,
+		Checksum:    e.Checksum,
+		Filename:    e.Filename,
+		RawFilename: e.RawFilename,
+		Time:        unixNano(e.Time),
+		Compression: e.Compression,
+		Evicted:     unixNano(e.Evicted),
+		Deleted:     unixNano(e.Deleted),
+		Counter:     e.Counter,
+		Labels:      e.Labels,
+		Logical:     e.Logical,
+		Url:         e.URL,
+		Index:       indexFilename,
+	}
+}
+
+func newValidateIssues(issues []ValidateIssue) []*ValidateResponse_Issue {
+
+	res := make([]*ValidateResponse_Issue, 0, len(issues))
+	for _, issue := range issues {
+		res = append(res, &ValidateResponse_Issue{
+			IndexFile: issue.IndexFile,
+			Entry:     newIndexEntry(issue.IndexFile, issue.Entry),
+			Reason:    issue.Reason,
+		})
+	}
+
+	return res
+}
+
+// unixNano returns 0 for the zero time instead of its year 1 nanoseconds.
+func unixNano(t time.Time) int64 {
+
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
@@ -0,0 +1,180 @@
+package uploader
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPCClient serves the uploader on an in-process listener.
+func newTestGRPCClient(t *testing.T, u *Uploader) UploaderServiceClient {
+	return NewUploaderServiceClient(newTestGRPCConn(t, u))
+}
+
+func newTestGRPCConn(t *testing.T, u *Uploader) *grpc.ClientConn {
+
+	lis := bufconn.Listen(1 << 20)
+
+	s := u.newGRPCServer()
+	u.grpcServer = s
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestGRPCLookup(t *testing.T) {
+	u := newTestUploader(t)
+	client := newTestGRPCClient(t, u)
+
+	filename := createTestFile(t, u, "100/200/MSG_1.db", "grpc")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	entry, err := client.Lookup(context.Background(), &LookupRequest{Seq: "1", Filename: filename})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", entry.Seq)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+	assert.Equal(t, filename, entry.Filename)
+	assert.Equal(t, int64(4), entry.Size)
+	assert.NotZero(t, entry.Time)
+	assert.Zero(t, entry.Evicted)
+
+	_, err = client.Lookup(context.Background(), &LookupRequest{Seq: "2", Filename: filename})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCStats(t *testing.T) {
+	u := newTestUploader(t)
+	client := newTestGRPCClient(t, u)
+
+	filename := createTestFile(t, u, "100/200/MSG_1.db", "grpc")
+
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	stats, err := client.Stats(context.Background(), &StatsRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), stats.Archived)
+	assert.Equal(t, uint64(4), stats.ArchivedBytes)
+	assert.False(t, stats.Paused)
+}
+
+func TestGRPCStreamEntries(t *testing.T) {
+	u := newTestUploader(t)
+	client := newTestGRPCClient(t, u)
+
+	files := map[string]string{
+		"1": "100/200/MSG_1.db",
+		"2": "100/300/MSG_2.db",
+	}
+	for seq, name := range files {
+		_, err := u.Archive(seq, createTestFile(t, u, name, "grpc"))
+		assert.Nil(t, err)
+	}
+
+	stream, err := client.StreamEntries(context.Background(), &StreamEntriesRequest{})
+	assert.Nil(t, err)
+
+	seqs := make([]string, 0)
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.Nil(t, err) {
+			break
+		}
+		assert.NotEmpty(t, entry.Index)
+		seqs = append(seqs, entry.Seq)
+	}
+
+	assert.ElementsMatch(t, []string{"1", "2"}, seqs)
+}
+
+func TestGRPCStreamEntriesIndex(t *testing.T) {
+	u := newTestUploader(t)
+	client := newTestGRPCClient(t, u)
+
+	for seq, name := range map[string]string{"1": "100/200/MSG_1.db", "2": "100/300/MSG_2.db"} {
+		_, err := u.Archive(seq, createTestFile(t, u, name, "grpc"))
+		assert.Nil(t, err)
+	}
+
+	index := u.indexFilename(path.Join(u.datastore, "100/200/MSG_1.db"))
+
+	stream, err := client.StreamEntries(context.Background(), &StreamEntriesRequest{Index: index})
+	assert.Nil(t, err)
+
+	entry, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, "1", entry.Seq)
+	assert.Equal(t, index, entry.Index)
+
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	// an index file outside of the datastore isn't opened
+	outside := path.Join(t.TempDir(), DefaultArchiveIndex)
+	assert.Nil(t, os.WriteFile(outside, []byte("9:/etc/passwd\n"), 0644))
+
+	stream, err = client.StreamEntries(context.Background(), &StreamEntriesRequest{Index: outside})
+	assert.Nil(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCStopCancelsPendingCalls(t *testing.T) {
+	u := newTestUploader(t)
+	conn := newTestGRPCConn(t, u)
+
+	// the handler waits for the request which is never sent
+	_, err := conn.NewStream(context.Background(), &UploaderService_ServiceDesc.Streams[0], UploaderService_StreamEntries_FullMethodName)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		u.stopGRPCServer(ctx)
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop didn't cancel the pending call")
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	assert.Equal(t, codes.NotFound, status.Code(grpcError(ErrEntryNotFound)))
+	assert.Equal(t, codes.AlreadyExists, status.Code(grpcError(ErrRestoreExists)))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(grpcError(ErrReadOnlyReplica)))
+	assert.Equal(t, codes.Internal, status.Code(grpcError(io.ErrUnexpectedEOF)))
+}
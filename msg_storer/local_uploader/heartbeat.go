@@ -0,0 +1,89 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	DefaultHeartbeatSubject  = ""
+	DefaultHeartbeatInterval = 30 * time.Second
+
+	HealthOK          = "ok"
+	HealthPaused      = "paused"
+	HealthSubscribing = "subscribing"
+	HealthReadOnly    = "archivestore_read_only"
+)
+
+// Heartbeat is published to the heartbeat subject at every interval, so an
+// idle uploader can be told apart from a dead one.
+type Heartbeat struct {
+	Scope         string    `json:"scope"`
+	Hostname      string    `json:"hostname"`
+	Health        string    `json:"health"`
+	Uptime        float64   `json:"uptime_seconds"`
+	Archived      uint64    `json:"archived"`
+	Failed        uint64    `json:"failed"`
+	ArchivedBytes uint64    `json:"archived_bytes"`
+	Requeued      uint64    `json:"requeued"`
+	Timestamp     time.Time `json:"timestamp"`
+
+	// with consumer_pending_interval
+	ConsumerPending     uint64 `json:"consumer_pending,omitempty"`
+	ConsumerAckPending  uint64 `json:"consumer_ack_pending,omitempty"`
+	ConsumerRedelivered uint64 `json:"consumer_redelivered,omitempty"`
+}
+
+// health reports whether the uploader is degraded, paused or still waiting
+// for its subscription.
+func (u *Uploader) health() string {
+
+	if u.degraded.Load() {
+		return HealthReadOnly
+	}
+
+	if u.paused.Load() {
+		return HealthPaused
+	}
+
+	u.subMutex.Lock()
+	defer u.subMutex.Unlock()
+
+	if u.sub == nil {
+		return HealthSubscribing
+	}
+
+	return HealthOK
+}
+
+func (u *Uploader) publishHeartbeat(ctx context.Context) error {
+
+	stats := u.Stats()
+
+	data, err := json.Marshal(Heartbeat{
+		Scope:         u.scope,
+		Hostname:      u.hostname,
+		Health:        u.health(),
+		Uptime:        time.Since(u.startedAt).Seconds(),
+		Archived:      stats.Archived,
+		Failed:        stats.Failed,
+		ArchivedBytes: stats.ArchivedBytes,
+		Requeued:      stats.Requeued,
+		Timestamp:     time.Now(),
+
+		ConsumerPending:     stats.ConsumerPending,
+		ConsumerAckPending:  stats.ConsumerAckPending,
+		ConsumerRedelivered: stats.ConsumerRedelivered,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := u.eventMsg(u.heartbeatSubject, data)
+	if err != nil {
+		return err
+	}
+
+	return u.params.NATSConnector.GetConnection().PublishMsg(msg)
+}
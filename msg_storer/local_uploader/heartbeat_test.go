@@ -0,0 +1,54 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+func (s *TestSuite) TestHeartbeat() {
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+	u.heartbeatSubject = "uploader-heartbeat"
+	u.heartbeatInterval = 50 * time.Millisecond
+	u.startedAt = time.Now()
+
+	sub, err := u.params.NATSConnector.GetConnection().SubscribeSync(u.heartbeatSubject)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer sub.Unsubscribe()
+
+	start := time.Now()
+	u.startTask("heartbeat", u.heartbeatInterval, u.publishHeartbeat)
+
+	// idle and not subscribed yet
+	for i := 0; i < 3; i++ {
+		m, err := sub.NextMsg(time.Second)
+		if err != nil {
+			s.Fail(err.Error())
+			return
+		}
+
+		var heartbeat Heartbeat
+		s.Nil(json.Unmarshal(m.Data, &heartbeat))
+		s.Equal("uploader", heartbeat.Scope)
+		s.Equal("test", heartbeat.Hostname)
+		s.Equal(HealthSubscribing, heartbeat.Health)
+		s.Greater(heartbeat.Uptime, 0.0)
+	}
+
+	// three intervals, with some slack for a loaded machine
+	elapsed := time.Since(start)
+	s.GreaterOrEqual(elapsed, 3*u.heartbeatInterval)
+	s.Less(elapsed, 3*u.heartbeatInterval+time.Second)
+
+	s.Nil(u.onStop(context.Background()))
+
+	// drop a heartbeat published while stopping
+	sub.NextMsg(u.heartbeatInterval)
+
+	_, err = sub.NextMsg(3 * u.heartbeatInterval)
+	s.NotNil(err)
+}
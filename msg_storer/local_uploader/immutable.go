@@ -0,0 +1,46 @@
+package uploader
+
+import (
+	"errors"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrImmutableUnsupported = errors.New("Immutable flag is not supported on this platform.")
+)
+
+// makeImmutable sets the immutable flag on the archive file, so it can't be
+// modified or removed until the flag is cleared. It needs CAP_LINUX_IMMUTABLE
+// and a filesystem supporting it, so failures are only logged.
+func (u *Uploader) makeImmutable(archiveName string) {
+
+	if !u.setImmutable {
+		return
+	}
+
+	if err := setImmutable(archiveName, true); err != nil {
+		u.logger.Warn("Failed to set immutable flag, the archive file stays mutable",
+			zap.String("archiveName", archiveName),
+			zap.String("reason", immutableFailure(err)),
+			zap.Error(err),
+		)
+	}
+}
+
+// clearImmutable clears the immutable flag before the uploader removes or
+// moves an archive file itself.
+func (u *Uploader) clearImmutable(archiveName string) error {
+
+	if !u.setImmutable {
+		return nil
+	}
+
+	err := setImmutable(archiveName, false)
+	if err == nil || os.IsNotExist(err) || errors.Is(err, ErrImmutableUnsupported) {
+		return nil
+	}
+
+	return err
+}
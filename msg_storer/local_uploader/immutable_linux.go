@@ -0,0 +1,79 @@
+//go:build linux
+
+package uploader
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// FS_IMMUTABLE_FL of linux/fs.h
+const fsImmutableFlag = 0x00000010
+
+// immutableFlags opens the file and returns its inode flags.
+func immutableFlags(filename string) (*os.File, int, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the kernel reads and writes an int, whatever the size of the ioctl
+	flags, err := unix.IoctlGetUint32(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		f.Close()
+		return nil, 0, &os.PathError{Op: "ioctl", Path: filename, Err: err}
+	}
+
+	return f, int(flags), nil
+}
+
+func setImmutable(filename string, immutable bool) error {
+
+	f, flags, err := immutableFlags(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	want := flags &^ fsImmutableFlag
+	if immutable {
+		want |= fsImmutableFlag
+	}
+
+	if want == flags {
+		return nil
+	}
+
+	if err := unix.IoctlSetPointerInt(int(f.Fd()), unix.FS_IOC_SETFLAGS, want); err != nil {
+		return &os.PathError{Op: "ioctl", Path: filename, Err: err}
+	}
+
+	return nil
+}
+
+func isImmutable(filename string) (bool, error) {
+
+	f, flags, err := immutableFlags(filename)
+	if err != nil {
+		return false, err
+	}
+	f.Close()
+
+	return flags&fsImmutableFlag != 0, nil
+}
+
+// immutableFailure explains why the immutable flag couldn't be set.
+func immutableFailure(err error) string {
+
+	switch {
+	case errors.Is(err, unix.EPERM):
+		return "missing CAP_LINUX_IMMUTABLE"
+	case errors.Is(err, unix.ENOTTY), errors.Is(err, unix.EOPNOTSUPP), errors.Is(err, unix.EINVAL):
+		return "unsupported by the filesystem"
+	}
+
+	return "unknown"
+}
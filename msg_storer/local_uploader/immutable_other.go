@@ -0,0 +1,15 @@
+//go:build !linux
+
+package uploader
+
+func setImmutable(filename string, immutable bool) error {
+	return ErrImmutableUnsupported
+}
+
+func isImmutable(filename string) (bool, error) {
+	return false, ErrImmutableUnsupported
+}
+
+func immutableFailure(err error) string {
+	return "unsupported by the platform"
+}
@@ -0,0 +1,79 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// probeImmutable skips the test unless the immutable flag can be set on the
+// filesystem of the uploader, which needs CAP_LINUX_IMMUTABLE.
+func probeImmutable(t *testing.T, u *Uploader) {
+
+	probe := createTestFile(t, u, "probe", "")
+	if err := setImmutable(probe, true); err != nil {
+		t.Skipf("immutable flag unsupported: %v", err)
+	}
+
+	setImmutable(probe, false)
+}
+
+func TestSetImmutable(t *testing.T) {
+	u := newTestUploader(t)
+	u.setImmutable = true
+
+	probeImmutable(t, u)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	defer setImmutable(archiveName, false)
+
+	immutable, err := isImmutable(archiveName)
+	assert.Nil(t, err)
+	assert.True(t, immutable)
+
+	assert.NotNil(t, os.Remove(archiveName))
+	assert.NotNil(t, os.WriteFile(archiveName, []byte("changed"), 0644))
+
+	assert.Nil(t, u.clearImmutable(archiveName))
+
+	immutable, err = isImmutable(archiveName)
+	assert.Nil(t, err)
+	assert.False(t, immutable)
+}
+
+func TestEvictImmutable(t *testing.T) {
+	u := newTestUploader(t)
+	u.setImmutable = true
+	u.maxStoreBytes = 5
+
+	probeImmutable(t, u)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "0123456789")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	defer setImmutable(archiveName, false)
+
+	assert.Nil(t, u.evict(context.Background()))
+
+	_, err = os.Stat(archiveName)
+	assert.True(t, os.IsNotExist(err))
+
+	entries, err := NewIndexReader(path.Join(u.datastore, "100/100", DefaultArchiveIndex)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	assert.False(t, entries[0].Evicted.IsZero())
+}
+
+func TestClearImmutableDisabled(t *testing.T) {
+	u := newTestUploader(t)
+
+	// nothing to clear when the flag is never set
+	assert.Nil(t, u.clearImmutable(path.Join(u.archivestore, "missing")))
+}
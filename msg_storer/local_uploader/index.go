@@ -0,0 +1,561 @@
+package uploader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultArchiveIndex = "archive.index"
+
+	// index entry fields
+	FieldSize        = "size"
+	FieldChecksum    = "sha256"
+	FieldFilename    = "src"
+	FieldRawFilename = "raw"
+	FieldTime        = "ts"
+	FieldCompression = "z"
+	FieldEvicted     = "evicted"
+	FieldDeleted     = "deleted"
+	FieldCounter     = "n"
+	FieldLogical     = "logical"
+	FieldURL         = "url"
+
+	// prefix of the label fields, e.g. label.tenant
+	FieldLabelPrefix = "label."
+)
+
+var (
+	ErrEntryNotFound = errors.New("Entry not found in the index.")
+)
+
+// Entry is a single record of the archive index.
+//
+// An entry is written as seq:archiveName, optionally followed by tab separated
+// key=value fields, so that readers only interested in the archive name can
+// cut the line at the first tab.
+type Entry struct {
+	Seq         string
+	ArchiveName string
+	Size        int64
+	Checksum    string
+	Filename    string
+	RawFilename string
+	Time        time.Time
+	Compression string
+
+	// Evicted is set on the tombstone of an archive file evicted from the store.
+	Evicted time.Time
+
+	// Deleted is set on the entry of an empty marker archived for a source
+	// which was deleted before it could be archived.
+	Deleted time.Time
+
+	// Counter orders the archives of a node when archive_counter is set.
+	Counter uint64
+
+	// Labels are the subject tokens named by subject_token_map.
+	Labels map[string]string
+
+	// Logical is the archive name shared by the versions of an archive when
+	// versioning is set.
+	Logical string
+
+	// URL is the source fetched in url_mode.
+	URL string
+}
+
+// IndexReader reads the entries of an archive index.
+type IndexReader interface {
+	List() ([]Entry, error)
+	Lookup(seq string) (Entry, error)
+	LookupArchiveName(archiveName string) (Entry, error)
+}
+
+// IndexWriter appends entries to an archive index.
+type IndexWriter interface {
+	Append(entry Entry) error
+}
+
+// FileIndexReader reads entries from an archive index file.
+type FileIndexReader struct {
+	filename string
+}
+
+func NewIndexReader(filename string) *FileIndexReader {
+	return &FileIndexReader{
+		filename: filename,
+	}
+}
+
+func (e Entry) String() string {
+
+	var sb strings.Builder
+	sb.WriteString(e.Seq)
+	sb.WriteString(":")
+	sb.WriteString(e.ArchiveName)
+
+	if e.Size > 0 {
+		sb.WriteString(fmt.Sprintf("\t%s=%d", FieldSize, e.Size))
+	}
+
+	if e.Checksum != "" {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldChecksum, url.QueryEscape(e.Checksum)))
+	}
+
+	if e.Filename != "" {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldFilename, url.QueryEscape(e.Filename)))
+	}
+
+	if e.RawFilename != "" {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldRawFilename, url.QueryEscape(e.RawFilename)))
+	}
+
+	if !e.Time.IsZero() {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldTime, e.Time.UTC().Format(time.RFC3339Nano)))
+	}
+
+	if e.Compression != "" {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldCompression, url.QueryEscape(e.Compression)))
+	}
+
+	if e.Counter > 0 {
+		sb.WriteString(fmt.Sprintf("\t%s=%d", FieldCounter, e.Counter))
+	}
+
+	if !e.Evicted.IsZero() {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldEvicted, e.Evicted.UTC().Format(time.RFC3339Nano)))
+	}
+
+	if !e.Deleted.IsZero() {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldDeleted, e.Deleted.UTC().Format(time.RFC3339Nano)))
+	}
+
+	if e.Logical != "" {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldLogical, url.QueryEscape(e.Logical)))
+	}
+
+	if e.URL != "" {
+		sb.WriteString(fmt.Sprintf("\t%s=%s", FieldURL, url.QueryEscape(e.URL)))
+	}
+
+	names := make([]string, 0, len(e.Labels))
+	for name := range e.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("\t%s%s=%s", FieldLabelPrefix, name, url.QueryEscape(e.Labels[name])))
+	}
+
+	return sb.String()
+}
+
+func parseEntry(line string) (Entry, bool) {
+
+	if strings.HasPrefix(line, IndexCommentPrefix) {
+		return Entry{}, false
+	}
+
+	fields := strings.Split(line, "\t")
+
+	cols := strings.SplitN(fields[0], ":", 2)
+	if len(cols) != 2 || cols[0] == "" {
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		Seq:         cols[0],
+		ArchiveName: cols[1],
+	}
+
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		value, err := url.QueryUnescape(kv[1])
+		if err != nil {
+			continue
+		}
+
+		switch kv[0] {
+		case FieldSize:
+			entry.Size, _ = strconv.ParseInt(value, 10, 64)
+		case FieldChecksum:
+			entry.Checksum = value
+		case FieldFilename:
+			entry.Filename = value
+		case FieldRawFilename:
+			entry.RawFilename = value
+		case FieldTime:
+			entry.Time, _ = time.Parse(time.RFC3339Nano, value)
+		case FieldCompression:
+			entry.Compression = value
+		case FieldCounter:
+			entry.Counter, _ = strconv.ParseUint(value, 10, 64)
+		case FieldEvicted:
+			entry.Evicted, _ = time.Parse(time.RFC3339Nano, value)
+		case FieldDeleted:
+			entry.Deleted, _ = time.Parse(time.RFC3339Nano, value)
+		case FieldLogical:
+			entry.Logical = value
+		case FieldURL:
+			entry.URL = value
+		default:
+			if name, ok := strings.CutPrefix(kv[0], FieldLabelPrefix); ok && name != "" {
+				if entry.Labels == nil {
+					entry.Labels = make(map[string]string)
+				}
+				entry.Labels[name] = value
+			}
+		}
+	}
+
+	return entry, true
+}
+
+// List returns all entries of the index in the order they were written,
+// including the entries of rotated (gzipped) shards.
+// A missing index file is treated as an empty index.
+func (r *FileIndexReader) List() ([]Entry, error) {
+
+	entries := make([]Entry, 0)
+
+	err := r.each(context.Background(), func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// StreamEntries emits the entries of the index in the order they were written,
+// including rotated shards, and closes the entry channel at the end. A read
+// error, or the cancellation of the context, stops the stream and is sent on
+// the error channel, which is closed after the entry channel. Lines which
+// aren't entries are skipped, as in List.
+func (r *FileIndexReader) StreamEntries(ctx context.Context) (<-chan Entry, <-chan error) {
+
+	entries := make(chan Entry)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(entries)
+
+		err := r.each(ctx, func(entry Entry) error {
+			select {
+			case entries <- entry:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return entries, errs
+}
+
+// each calls fn for every entry of the index without loading the whole index.
+func (r *FileIndexReader) each(ctx context.Context, fn func(Entry) error) error {
+
+	files, err := rotatedIndexFiles(r.filename)
+	if err != nil {
+		return err
+	}
+	files = append(files, r.filename)
+
+	for _, filename := range files {
+		if err := r.eachInFile(ctx, filename, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *FileIndexReader) eachInFile(ctx context.Context, filename string, fn func(Entry) error) error {
+
+	fr, err := openIndexFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer fr.Close()
+
+	return eachEntry(ctx, fr, fn)
+}
+
+func eachEntry(ctx context.Context, rd io.Reader, fn func(Entry) error) error {
+
+	br := bufio.NewReader(rd)
+	for {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				// a trailing line without newline was cut off by a crash mid-append
+				return nil
+			}
+			return err
+		}
+
+		entry, ok := parseEntry(strings.TrimSuffix(line, "\n"))
+		if !ok {
+			continue
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// Lookup returns the latest entry recorded for the sequence.
+func (r *FileIndexReader) Lookup(seq string) (Entry, error) {
+	return r.lookup(func(entry Entry) bool {
+		return entry.Seq == seq
+	})
+}
+
+// LookupArchiveName returns the latest entry pointing at the archive file.
+func (r *FileIndexReader) LookupArchiveName(archiveName string) (Entry, error) {
+	return r.lookup(func(entry Entry) bool {
+		return entry.ArchiveName == archiveName
+	})
+}
+
+// lookup returns the latest entry matching. The index is read before the
+// rotated shards, from the newest to the oldest, and older shards aren't
+// read once a file has a match, so recent entries are found in one read.
+func (r *FileIndexReader) lookup(match func(Entry) bool) (Entry, error) {
+
+	files, err := rotatedIndexFiles(r.filename)
+	if err != nil {
+		return Entry{}, err
+	}
+	files = append(files, r.filename)
+
+	for i := len(files) - 1; i >= 0; i-- {
+
+		var latest Entry
+		found := false
+
+		err := r.eachInFile(context.Background(), files[i], func(entry Entry) error {
+			if match(entry) {
+				latest = entry
+				found = true
+			}
+			return nil
+		})
+		if err != nil {
+			return Entry{}, err
+		}
+
+		if found {
+			return latest, nil
+		}
+	}
+
+	return Entry{}, ErrEntryNotFound
+}
+
+// rewriteIndex rewrites every entry of the index, including rotated shards,
+// with the entry returned by fn, dropping the entry when fn returns false.
+// Files are replaced atomically.
+func rewriteIndex(indexFilename string, fn func(Entry) (Entry, bool)) error {
+
+	files, err := rotatedIndexFiles(indexFilename)
+	if err != nil {
+		return err
+	}
+	files = append(files, indexFilename)
+
+	for _, filename := range files {
+		if err := rewriteIndexFile(filename, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func rewriteIndexFile(filename string, fn func(Entry) (Entry, bool)) error {
+
+	fr, err := openIndexFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer fr.Close()
+
+	tmpName := filename + ".tmp"
+	fw, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = fw
+	var zw *gzip.Writer
+	if strings.HasSuffix(filename, ".gz") {
+		zw = gzip.NewWriter(fw)
+		w = zw
+	}
+
+	bw := bufio.NewWriter(w)
+
+	br := bufio.NewReader(fr)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			fw.Close()
+			os.Remove(tmpName)
+			return err
+		}
+
+		if line == "" {
+			break
+		}
+
+		// a trailing partial line is kept as is, so it is still recognized as partial
+		if !strings.HasSuffix(line, "\n") {
+			if _, err := bw.WriteString(line); err != nil {
+				fw.Close()
+				os.Remove(tmpName)
+				return err
+			}
+			break
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+
+		// keep lines which are not entries untouched
+		if entry, ok := parseEntry(line); ok {
+			entry, keep := fn(entry)
+			if !keep {
+				continue
+			}
+			line = entry.String()
+		}
+
+		if _, err := bw.WriteString(line + "\n"); err != nil {
+			fw.Close()
+			os.Remove(tmpName)
+			return err
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		fw.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			fw.Close()
+			os.Remove(tmpName)
+			return err
+		}
+	}
+
+	if err := fw.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, filename)
+}
+
+// RepairIndex truncates a dangling partial line left at the end of the index
+// by a crash mid-append. It reports whether the index was repaired.
+func RepairIndex(indexFilename string) (bool, error) {
+
+	f, err := os.OpenFile(indexFilename, os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	size := fi.Size()
+	if size == 0 {
+		return false, nil
+	}
+
+	end, err := completeLinesEnd(f, size)
+	if err != nil {
+		return false, err
+	}
+
+	if end == size {
+		return false, nil
+	}
+
+	if err := f.Truncate(end); err != nil {
+		return false, err
+	}
+
+	return true, f.Sync()
+}
+
+// completeLinesEnd returns the end of the last complete line of the first size
+// bytes of f.
+func completeLinesEnd(f *os.File, size int64) (int64, error) {
+
+	buf := make([]byte, 4096)
+	end := size
+	for end > 0 {
+		n := int64(len(buf))
+		if n > end {
+			n = end
+		}
+
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return 0, err
+		}
+
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return end - n + int64(i) + 1, nil
+		}
+
+		end -= n
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,294 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	IndexBackendFile = "file"
+	IndexBackendSQL  = "sql"
+
+	DefaultIndexBackend = IndexBackendFile
+)
+
+// validateIndexBackend validates index_backend against the other configs.
+// Maintenance which rewrites index files in place isn't available with the
+// SQL index.
+func (u *Uploader) validateIndexBackend() error {
+
+	switch u.indexBackend {
+	case IndexBackendFile:
+		return nil
+	case IndexBackendSQL:
+	default:
+		return fmt.Errorf("unknown index_backend %s", u.indexBackend)
+	}
+
+	switch {
+	case u.reindexPolicy == ReindexReplaceAndRemoveOld:
+		return fmt.Errorf("index_backend %s is incompatible with reindex_policy %s", u.indexBackend, u.reindexPolicy)
+	case u.coldArchivestore != "":
+		return fmt.Errorf("index_backend %s is incompatible with cold_archivestore", u.indexBackend)
+	case u.maxStoreBytes > 0:
+		return fmt.Errorf("index_backend %s is incompatible with max_store_bytes", u.indexBackend)
+	case u.maxVersions > 0:
+		return fmt.Errorf("index_backend %s is incompatible with max_versions", u.indexBackend)
+	}
+
+	return nil
+}
+
+// openIndexBackend opens the SQL index on the database connector when selected.
+func (u *Uploader) openIndexBackend() error {
+
+	if err := u.validateIndexBackend(); err != nil || u.indexBackend != IndexBackendSQL {
+		return err
+	}
+
+	if u.params.Database == nil {
+		return fmt.Errorf("index_backend %s requires a database connector", u.indexBackend)
+	}
+
+	index, err := NewSQLIndex(u.params.Database.GetDB())
+	if err != nil {
+		return err
+	}
+	u.sqlIndex = index
+
+	return nil
+}
+
+// indexRecord is the row of an index entry. Index is the path of the index
+// file the entry would be written to with the file backend.
+type indexRecord struct {
+	ID          uint   `gorm:"primaryKey"`
+	Index       string `gorm:"column:index_name;index:idx_archive_index_seq"`
+	Seq         string `gorm:"index:idx_archive_index_seq"`
+	ArchiveName string `gorm:"index"`
+	Size        int64
+	Checksum    string
+	Filename    string
+	RawFilename string
+	Time        time.Time
+	Compression string
+	Counter     uint64
+	Labels      string
+	Deleted     time.Time
+	Logical     string
+	URL         string
+}
+
+func (indexRecord) TableName() string {
+	return "archive_index"
+}
+
+func newIndexRecord(index string, entry Entry) indexRecord {
+	return indexRecord{
+		Index:       index,
+		Seq:         entry.Seq,
+		ArchiveName: entry.ArchiveName,
+		Size:        entry.Size,
+		Checksum:    entry.Checksum,
+		Filename:    entry.Filename,
+		RawFilename: entry.RawFilename,
+		Time:        entry.Time,
+		Compression: entry.Compression,
+		Counter:     entry.Counter,
+		Labels:      encodeLabels(entry.Labels),
+		Deleted:     entry.Deleted,
+		Logical:     entry.Logical,
+		URL:         entry.URL,
+	}
+}
+
+func (r indexRecord) entry() Entry {
+	return Entry{
+		Seq:         r.Seq,
+		ArchiveName: r.ArchiveName,
+		Size:        r.Size,
+		Checksum:    r.Checksum,
+		Filename:    r.Filename,
+		RawFilename: r.RawFilename,
+		Time:        r.Time,
+		Compression: r.Compression,
+		Counter:     r.Counter,
+		Labels:      decodeLabels(r.Labels),
+		Deleted:     r.Deleted,
+		Logical:     r.Logical,
+		URL:         r.URL,
+	}
+}
+
+// encodeLabels encodes the labels as a query string, sorted by name.
+func encodeLabels(labels map[string]string) string {
+
+	values := make(url.Values, len(labels))
+	for name, value := range labels {
+		values.Set(name, value)
+	}
+
+	return values.Encode()
+}
+
+func decodeLabels(encoded string) map[string]string {
+
+	values, err := url.ParseQuery(encoded)
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(values))
+	for name := range values {
+		labels[name] = values.Get(name)
+	}
+
+	return labels
+}
+
+// SQLIndex keeps the archive index in a database table, one row per archive.
+type SQLIndex struct {
+	db *gorm.DB
+}
+
+// NewSQLIndex creates the index table if needed.
+func NewSQLIndex(db *gorm.DB) (*SQLIndex, error) {
+
+	if err := db.AutoMigrate(&indexRecord{}); err != nil {
+		return nil, err
+	}
+
+	return &SQLIndex{
+		db: db,
+	}, nil
+}
+
+// Reader returns the reader of the entries recorded for the index file.
+func (s *SQLIndex) Reader(indexFilename string) IndexReader {
+	return &sqlIndexReader{
+		db:    s.db,
+		index: indexFilename,
+	}
+}
+
+// Writer returns the writer recording entries for the index file.
+func (s *SQLIndex) Writer(indexFilename string) IndexWriter {
+	return &sqlIndexWriter{
+		db:    s.db,
+		index: indexFilename,
+	}
+}
+
+type sqlIndexWriter struct {
+	db    *gorm.DB
+	index string
+}
+
+// Append inserts the entry and commits, so the message is only acked once the
+// row is durable.
+func (w *sqlIndexWriter) Append(entry Entry) error {
+
+	record := newIndexRecord(w.index, entry)
+
+	return w.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&record).Error
+	})
+}
+
+type sqlIndexReader struct {
+	db    *gorm.DB
+	index string
+}
+
+// List returns the entries of the index in the order they were written.
+func (r *sqlIndexReader) List() ([]Entry, error) {
+
+	records := make([]indexRecord, 0)
+
+	err := r.db.Where("index_name = ?", r.index).Order("id").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(records))
+	for _, record := range records {
+		entries = append(entries, record.entry())
+	}
+
+	return entries, nil
+}
+
+// Lookup returns the latest entry recorded for the sequence.
+func (r *sqlIndexReader) Lookup(seq string) (Entry, error) {
+	return r.latest("index_name = ? AND seq = ?", r.index, seq)
+}
+
+// LookupArchiveName returns the latest entry pointing at the archive file.
+func (r *sqlIndexReader) LookupArchiveName(archiveName string) (Entry, error) {
+	return r.latest("index_name = ? AND archive_name = ?", r.index, archiveName)
+}
+
+func (r *sqlIndexReader) latest(query string, args ...interface{}) (Entry, error) {
+
+	var record indexRecord
+
+	err := r.db.Where(query, args...).Order("id desc").First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Entry{}, ErrEntryNotFound
+		}
+		return Entry{}, err
+	}
+
+	return record.entry(), nil
+}
+
+// archiveNames returns the set of archive names of all entries.
+func (s *SQLIndex) archiveNames(ctx context.Context) (map[string]bool, error) {
+
+	names := make([]string, 0)
+
+	err := s.db.WithContext(ctx).Model(&indexRecord{}).Distinct().Pluck("archive_name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	indexed := make(map[string]bool, len(names))
+	for _, name := range names {
+		indexed[name] = true
+	}
+
+	return indexed, nil
+}
+
+// archiveIndexNames returns the index paths of the entries pointing at the
+// archive file.
+func (s *SQLIndex) archiveIndexNames(ctx context.Context, archiveName string) ([]string, error) {
+
+	names := make([]string, 0)
+
+	err := s.db.WithContext(ctx).Model(&indexRecord{}).Distinct().Where("archive_name = ?", archiveName).Pluck("index_name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// indexNames returns the index paths of all entries.
+func (s *SQLIndex) indexNames(ctx context.Context) ([]string, error) {
+
+	names := make([]string, 0)
+
+	err := s.db.WithContext(ctx).Model(&indexRecord{}).Distinct().Order("index_name").Pluck("index_name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
@@ -0,0 +1,165 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestSQLIndex(t *testing.T) *SQLIndex {
+	db, err := gorm.Open(sqlite.Open(path.Join(t.TempDir(), "index.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := NewSQLIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return index
+}
+
+func TestSQLIndex(t *testing.T) {
+	u := newTestUploader(t)
+	u.checksum = true
+	u.sqlIndex = newTestSQLIndex(t)
+
+	first := createTestFile(t, u, "100/100/MSG_1.db", "first")
+	firstName, err := u.Archive("1", first)
+	assert.Nil(t, err)
+
+	second := createTestFile(t, u, "100/100/MSG_2.db", "second")
+	secondName, err := u.Archive("2", second)
+	assert.Nil(t, err)
+
+	// nothing is written to the index file
+	_, err = os.Stat(u.indexFilename(first))
+	assert.True(t, os.IsNotExist(err))
+
+	reader := u.indexReader(first)
+
+	entries, err := reader.List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "1", entries[0].Seq)
+	assert.Equal(t, firstName, entries[0].ArchiveName)
+	assert.Equal(t, int64(len("first")), entries[0].Size)
+	assert.NotEmpty(t, entries[0].Checksum)
+	assert.Equal(t, first, entries[0].Filename)
+
+	entry, err := reader.Lookup("2")
+	assert.Nil(t, err)
+	assert.Equal(t, secondName, entry.ArchiveName)
+
+	entry, err = reader.LookupArchiveName(firstName)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", entry.Seq)
+
+	_, err = reader.Lookup("3")
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+
+	// entries are kept per index
+	entries, err = u.indexReader(path.Join(u.datastore, "100/200/MSG_1.db")).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestSQLIndexOrphans(t *testing.T) {
+	u := newTestUploader(t)
+	u.sqlIndex = newTestSQLIndex(t)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "indexed")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	orphan := createArchiveFile(t, u, "100/100/MSG_2.db", "orphan")
+
+	orphans, err := u.FindOrphans(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{orphan}, orphans)
+}
+
+func TestSQLIndexValidate(t *testing.T) {
+	u := newTestUploader(t)
+	u.sqlIndex = newTestSQLIndex(t)
+
+	good := createTestFile(t, u, "100/100/MSG_1.db", "good")
+	_, err := u.Archive("1", good)
+	assert.Nil(t, err)
+
+	missing := createTestFile(t, u, "100/200/MSG_2.db", "missing")
+	missingArchive, err := u.Archive("2", missing)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(missingArchive))
+
+	report, err := u.Validate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Checked)
+	assert.Len(t, report.Missing, 1)
+	assert.Equal(t, "2", report.Missing[0].Entry.Seq)
+}
+
+func TestSQLIndexVerifyBatch(t *testing.T) {
+	u := newTestUploader(t)
+	u.sqlIndex = newTestSQLIndex(t)
+	u.verifyBatchSize = 10
+
+	archiveNames := prepareVerify(t, u, 2)
+	assert.Nil(t, os.WriteFile(archiveNames[1], []byte("data-x"), 0644))
+
+	assert.Nil(t, u.verifyBatch(context.Background()))
+	assert.Equal(t, uint64(2), u.Stats().Verified)
+	assert.Equal(t, uint64(1), u.Stats().VerifyFailures)
+}
+
+func TestSQLIndexRebuildResume(t *testing.T) {
+	u := newTestUploader(t)
+	u.sqlIndex = newTestSQLIndex(t)
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "one")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	createArchiveFile(t, u, "100/300/MSG_2.db", "two")
+
+	// already indexed files are skipped
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, u.RebuildIndex(context.Background()))
+	}
+
+	entries, err := u.indexReader(filename).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestSQLIndexRebase(t *testing.T) {
+	u := newTestUploader(t)
+	u.sqlIndex = newTestSQLIndex(t)
+
+	err := u.RebaseIndex(u.archivestore, "/mnt/archivestore")
+	assert.ErrorContains(t, err, "isn't supported")
+}
+
+func TestOpenIndexBackend(t *testing.T) {
+	u := newTestUploader(t)
+
+	u.indexBackend = IndexBackendFile
+	assert.Nil(t, u.openIndexBackend())
+	assert.Nil(t, u.sqlIndex)
+
+	// no database connector
+	u.indexBackend = IndexBackendSQL
+	assert.NotNil(t, u.openIndexBackend())
+
+	u.indexBackend = "csv"
+	assert.NotNil(t, u.openIndexBackend())
+}
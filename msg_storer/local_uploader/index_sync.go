@@ -0,0 +1,107 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultIndexSyncEvery    = 0
+	DefaultIndexSyncInterval = 0
+)
+
+// indexSyncer fsyncs the index files every index_sync_every entries instead
+// of after every entry. Entries written since the last fsync are at risk on a
+// crash, which index_sync_interval bounds in time when writes stop.
+type indexSyncer struct {
+	mutex   sync.Mutex
+	pending map[string]int
+	syncs   atomic.Uint64
+}
+
+// wrote counts the entry just written to the open index file, and fsyncs it
+// once every entries are pending.
+func (s *indexSyncer) wrote(f *os.File, every int) error {
+
+	s.mutex.Lock()
+	if s.pending == nil {
+		s.pending = make(map[string]int)
+	}
+	s.pending[f.Name()]++
+	due := s.pending[f.Name()] >= every
+	if due {
+		delete(s.pending, f.Name())
+	}
+	s.mutex.Unlock()
+
+	if !due {
+		return nil
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	s.syncs.Add(1)
+
+	return nil
+}
+
+// syncPending fsyncs the index files with entries written since their last fsync.
+func (s *indexSyncer) syncPending() error {
+
+	s.mutex.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mutex.Unlock()
+
+	for indexFilename := range pending {
+		if err := syncFile(indexFilename); err != nil {
+			// a rotated index was already flushed by the rotation
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		s.syncs.Add(1)
+	}
+
+	return nil
+}
+
+func syncFile(filename string) error {
+
+	f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// syncIndexes is the task flushing the pending index entries every index_sync_interval.
+func (u *Uploader) syncIndexes(ctx context.Context) error {
+	return u.indexSync.syncPending()
+}
+
+// syncIndexesOnStop flushes the pending index entries on a graceful stop.
+func (u *Uploader) syncIndexesOnStop() {
+
+	if u.indexSyncEvery <= 0 {
+		return
+	}
+
+	if err := u.indexSync.syncPending(); err != nil {
+		u.logger.Error("Failed to fsync index on stop",
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,91 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestIndexSyncEvery(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexSyncEvery = 3
+
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+
+	for i := 1; i <= 7; i++ {
+		err := u.updateIndex(filename, path.Join(u.archivestore, fmt.Sprintf("100/300/MSG_%d.db", i)), fmt.Sprint(i))
+		assert.Nil(t, err)
+
+		// every third entry
+		assert.Equal(t, uint64(i/3), u.indexSync.syncs.Load(), i)
+	}
+
+	// the entry pending since is synced on stop
+	assert.Nil(t, u.onStop(context.Background()))
+	assert.Equal(t, uint64(3), u.indexSync.syncs.Load())
+
+	// nothing left pending
+	assert.Nil(t, u.syncIndexes(context.Background()))
+	assert.Equal(t, uint64(3), u.indexSync.syncs.Load())
+}
+
+func TestIndexSyncInterval(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexSyncEvery = 100
+
+	first := path.Join(u.datastore, "100/300/MSG_1.db")
+	second := path.Join(u.datastore, "100/400/MSG_1.db")
+
+	assert.Nil(t, u.updateIndex(first, path.Join(u.archivestore, "100/300/MSG_1.db"), "1"))
+	assert.Nil(t, u.updateIndex(second, path.Join(u.archivestore, "100/400/MSG_1.db"), "1"))
+	assert.Equal(t, uint64(0), u.indexSync.syncs.Load())
+
+	// the interval task syncs every index with pending entries
+	assert.Nil(t, u.syncIndexes(context.Background()))
+	assert.Equal(t, uint64(2), u.indexSync.syncs.Load())
+}
+
+func TestIndexSyncDisabled(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+	assert.Nil(t, u.updateIndex(filename, path.Join(u.archivestore, "100/300/MSG_1.db"), "1"))
+
+	assert.Nil(t, u.onStop(context.Background()))
+	assert.Equal(t, uint64(0), u.indexSync.syncs.Load())
+}
+
+func benchmarkIndexSync(b *testing.B, every int) {
+	root := b.TempDir()
+	u := &Uploader{
+		logger:         zap.NewNop(),
+		indexSyncEvery: every,
+	}
+
+	filename := path.Join(root, "datastore/100/100/MSG_1.db")
+	archiveName := path.Join(root, "archivestore/100/100/MSG_1.db")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := u.updateIndex(filename, archiveName, fmt.Sprint(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkIndexSyncEvery1(b *testing.B) {
+	benchmarkIndexSync(b, 1)
+}
+
+func BenchmarkIndexSyncEvery100(b *testing.B) {
+	benchmarkIndexSync(b, 100)
+}
+
+func BenchmarkIndexSyncNever(b *testing.B) {
+	benchmarkIndexSync(b, 0)
+}
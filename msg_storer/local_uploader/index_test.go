@@ -0,0 +1,208 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeIndex(t *testing.T, content string) string {
+	indexFilename := path.Join(t.TempDir(), DefaultArchiveIndex)
+
+	err := os.WriteFile(indexFilename, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return indexFilename
+}
+
+func TestIndexCompleteLines(t *testing.T) {
+	indexFilename := writeIndex(t, "1:archivestore/MSG_1.db\n2:archivestore/MSG_2.db\n")
+
+	entries, err := NewIndexReader(indexFilename).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "archivestore/MSG_2.db", entries[1].ArchiveName)
+
+	repaired, err := RepairIndex(indexFilename)
+	assert.Nil(t, err)
+	assert.False(t, repaired)
+}
+
+func TestIndexPartialLine(t *testing.T) {
+	indexFilename := writeIndex(t, "1:archivestore/MSG_1.db\n2:archivestore/MSG_2.db\n3:archivesto")
+
+	entries, err := NewIndexReader(indexFilename).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+
+	_, err = NewIndexReader(indexFilename).Lookup("3")
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+
+	repaired, err := RepairIndex(indexFilename)
+	assert.Nil(t, err)
+	assert.True(t, repaired)
+
+	data, err := os.ReadFile(indexFilename)
+	assert.Nil(t, err)
+	assert.Equal(t, "1:archivestore/MSG_1.db\n2:archivestore/MSG_2.db\n", string(data))
+}
+
+func TestRepairIndexOnlyPartialLine(t *testing.T) {
+	indexFilename := writeIndex(t, "1:archivesto")
+
+	repaired, err := RepairIndex(indexFilename)
+	assert.Nil(t, err)
+	assert.True(t, repaired)
+
+	data, err := os.ReadFile(indexFilename)
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(data))
+}
+
+func TestAppendAfterPartialLine(t *testing.T) {
+	u := newTestUploader(t)
+
+	first := createTestFile(t, u, "100/200/MSG_12.db", "first")
+	_, err := u.Archive("12", first)
+	assert.Nil(t, err)
+
+	// crash mid-append
+	indexFilename := u.indexFilename(first)
+	f, err := os.OpenFile(indexFilename, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteString("14:" + u.archivestore + "/100/20")
+	assert.Nil(t, err)
+	f.Close()
+
+	second := createTestFile(t, u, "100/200/MSG_13.db", "second")
+	archiveName, err := u.Archive("13", second)
+	assert.Nil(t, err)
+
+	entry, err := u.Lookup("13", second)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+
+	entries, err := NewIndexReader(indexFilename).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestStreamEntries(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexMaxSize = 200
+
+	// spread the entries over rotated shards
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("100/300/MSG_%d.db", i)
+		createTestFile(t, u, name, "data")
+		_, err := u.Archive(fmt.Sprint(i), path.Join(u.datastore, name))
+		assert.Nil(t, err)
+	}
+
+	files, err := rotatedIndexFiles(u.indexFilename(filename))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, files)
+
+	entries, errs := NewIndexReader(u.indexFilename(filename)).StreamEntries(context.Background())
+
+	i := 0
+	for entry := range entries {
+		assert.Equal(t, fmt.Sprint(i), entry.Seq)
+		i++
+	}
+	assert.Equal(t, 20, i)
+	assert.Nil(t, <-errs)
+}
+
+func TestStreamEntriesCanceled(t *testing.T) {
+	indexFilename := writeIndex(t, "1:a\n2:b\n3:c\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries, errs := NewIndexReader(indexFilename).StreamEntries(ctx)
+
+	entry := <-entries
+	assert.Equal(t, "1", entry.Seq)
+	cancel()
+
+	// drain until the stream stops
+	for range entries {
+	}
+	assert.ErrorIs(t, <-errs, context.Canceled)
+}
+
+func TestStreamEntriesReadError(t *testing.T) {
+	indexFilename := writeIndex(t, "1:a\n")
+
+	// a corrupt rotated shard
+	err := os.WriteFile(indexFilename+".1.gz", []byte("not gzip"), 0644)
+	assert.Nil(t, err)
+
+	entries, errs := NewIndexReader(indexFilename).StreamEntries(context.Background())
+	for range entries {
+	}
+	assert.NotNil(t, <-errs)
+}
+
+func TestLookupNewestShardFirst(t *testing.T) {
+	indexFilename := writeIndex(t, "3:c\n1:d\n")
+
+	// an older shard, found to be corrupt only when it is read
+	err := os.WriteFile(indexFilename+".1.gz", []byte("not gzip"), 0644)
+	assert.Nil(t, err)
+
+	r := NewIndexReader(indexFilename)
+
+	// found in the index, the shard isn't read
+	entry, err := r.Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, "d", entry.ArchiveName)
+
+	entry, err = r.LookupArchiveName("c")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", entry.Seq)
+
+	// only the shard can tell whether the sequence is indexed
+	_, err = r.Lookup("2")
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, ErrEntryNotFound)
+}
+
+func TestLookupAcrossShards(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexMaxSize = 200
+
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("100/300/MSG_%d.db", i)
+		createTestFile(t, u, name, "data")
+		_, err := u.Archive(fmt.Sprint(i%10), path.Join(u.datastore, name))
+		assert.Nil(t, err)
+	}
+
+	files, err := rotatedIndexFiles(u.indexFilename(filename))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, files)
+
+	r := NewIndexReader(u.indexFilename(filename))
+
+	// the latest entry of the sequence wins
+	for i := 0; i < 10; i++ {
+		entry, err := r.Lookup(fmt.Sprint(i))
+		assert.Nil(t, err)
+		assert.Equal(t, path.Join(u.archivestore, fmt.Sprintf("100/300/MSG_%d.db", i+10)), entry.ArchiveName)
+	}
+
+	entry, err := r.LookupArchiveName(path.Join(u.archivestore, "100/300/MSG_0.db"))
+	assert.Nil(t, err)
+	assert.Equal(t, "0", entry.Seq)
+
+	_, err = r.Lookup("20")
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+}
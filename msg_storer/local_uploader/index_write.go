@@ -0,0 +1,77 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultIndexWriteTimeout = 0
+)
+
+var (
+	ErrIndexWriteTimeout = errors.New("Index write timed out.")
+	ErrIndexNoSpace      = errors.New("No space left on the index filesystem.")
+)
+
+// writeIndexBounded writes the index entry within index_write_timeout. The
+// archive file was moved already, so on a timeout or a full filesystem the
+// entry is recorded in the journal when there is one, and the message is
+// Nak'd otherwise. The hung write is left running with the index lock kept,
+// so no rewrite of the index races it, and the journal reconciliation skips
+// the entry if it completes after all.
+func (u *Uploader) writeIndexBounded(indexLock *heldLock, filename string, oldEntry *Entry, entry Entry) error {
+
+	write := u.indexWriter
+	if write == nil {
+		write = u.writeIndex
+	}
+
+	if u.indexWriteTimeout <= 0 {
+		return u.journalNoSpace(filename, entry, write(filename, oldEntry, entry))
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- write(filename, oldEntry, entry)
+	}()
+
+	timer := time.NewTimer(u.indexWriteTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return u.journalNoSpace(filename, entry, err)
+	case <-timer.C:
+	}
+
+	unlock := indexLock.keep()
+	go func() {
+		<-done
+		unlock()
+	}()
+
+	err := fmt.Errorf("%w after %s (%s)", ErrIndexWriteTimeout, u.indexWriteTimeout, u.indexFilename(filename))
+
+	return u.journalEntry(filename, entry, err)
+}
+
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// journalNoSpace records the entry in the journal when the index filesystem
+// is full. The archive file was moved already, so the entry must not be lost
+// with a Nak, and the journal lives on another filesystem.
+func (u *Uploader) journalNoSpace(filename string, entry Entry, err error) error {
+
+	if err == nil || !isNoSpace(err) {
+		return err
+	}
+
+	err = fmt.Errorf("%w (%s): %w", ErrIndexNoSpace, u.indexFilename(filename), err)
+
+	return u.journalEntry(filename, entry, err)
+}
@@ -0,0 +1,197 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowIndexWriter blocks index writes until release is closed, and sends the
+// result of the write on done.
+func slowIndexWriter(u *Uploader, release chan struct{}, done chan error) func(string, *Entry, Entry) error {
+	return func(filename string, oldEntry *Entry, entry Entry) error {
+		<-release
+		err := u.writeIndex(filename, oldEntry, entry)
+		done <- err
+		return err
+	}
+}
+
+func TestIndexWriteTimeoutJournal(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexWriteTimeout = 20 * time.Millisecond
+
+	journal, err := newJournal(path.Join(t.TempDir(), "journal"))
+	assert.Nil(t, err)
+	u.journal = journal
+
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	u.indexWriter = slowIndexWriter(u, release, done)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	start := time.Now()
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.FileExists(t, archiveName)
+
+	records, err := u.journal.records()
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, u.indexFilename(filename), records[0].Index)
+
+		entry, ok := parseEntry(records[0].Entry)
+		assert.True(t, ok)
+		assert.Equal(t, "1", entry.Seq)
+		assert.Equal(t, archiveName, entry.ArchiveName)
+	}
+
+	// the reconciliation waits for the hung write keeping the index lock
+	reconciled := make(chan error, 1)
+	go func() {
+		reconciled <- u.reconcileJournal(context.Background())
+	}()
+
+	select {
+	case <-reconciled:
+		t.Fatal("reconciled while the index write is pending")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the hung write completes late and the journal entry is skipped
+	close(release)
+	assert.Nil(t, <-done)
+	assert.Nil(t, <-reconciled)
+
+	entries, err := u.indexReader(filename).List()
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, archiveName, entries[0].ArchiveName)
+	}
+
+	records, err = u.journal.records()
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+}
+
+func TestIndexWriteTimeoutNak(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexWriteTimeout = 20 * time.Millisecond
+
+	// the index exists already
+	_, err := u.Archive("0", createTestFile(t, u, "100/100/MSG_0.db", "data"))
+	assert.Nil(t, err)
+
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	u.indexWriter = slowIndexWriter(u, release, done)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	_, err = u.Archive("1", filename)
+	assert.ErrorIs(t, err, ErrIndexWriteTimeout)
+	assert.False(t, isPermanent(err))
+	assert.Equal(t, ErrorClassIndexTimeout, classifyError(err))
+
+	// a rewrite of the index doesn't race the hung write
+	rebased := make(chan error, 1)
+	go func() {
+		rebased <- u.RebaseIndex(u.archivestore, "/mnt/archivestore")
+	}()
+
+	select {
+	case <-rebased:
+		t.Fatal("rebased while the index write is pending")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Nil(t, <-done)
+	assert.Nil(t, <-rebased)
+
+	entry, err := u.Lookup("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "/mnt/archivestore/100/100/MSG_1.db", entry.ArchiveName)
+}
+
+func TestIndexWriteWithinTimeout(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexWriteTimeout = time.Second
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	entry, err := u.Lookup("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+}
+
+// fullIndexWriter fails the index writes as a full filesystem would.
+func fullIndexWriter(u *Uploader) func(string, *Entry, Entry) error {
+	return func(filename string, oldEntry *Entry, entry Entry) error {
+		return &os.PathError{Op: "write", Path: u.indexFilename(filename), Err: syscall.ENOSPC}
+	}
+}
+
+func TestIndexNoSpaceJournal(t *testing.T) {
+	u := newTestUploader(t)
+
+	journal, err := newJournal(path.Join(t.TempDir(), "journal"))
+	assert.Nil(t, err)
+	u.journal = journal
+	u.indexWriter = fullIndexWriter(u)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.FileExists(t, archiveName)
+
+	_, err = u.Lookup("1", filename)
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+
+	records, err := u.journal.records()
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, u.indexFilename(filename), records[0].Index)
+	}
+
+	// reconciled once there is space again
+	u.indexWriter = nil
+	assert.Nil(t, u.reconcileJournal(context.Background()))
+
+	entry, err := u.Lookup("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+
+	records, err = u.journal.records()
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+}
+
+func TestIndexNoSpaceNak(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexWriter = fullIndexWriter(u)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	_, err := u.Archive("1", filename)
+	assert.ErrorIs(t, err, ErrIndexNoSpace)
+	assert.ErrorIs(t, err, syscall.ENOSPC)
+	assert.False(t, isPermanent(err))
+	assert.Equal(t, ErrorClassIndexNoSpace, classifyError(err))
+
+	// a full archivestore isn't an index failure
+	err = fmt.Errorf("move: %w", &os.LinkError{Op: "rename", Err: syscall.ENOSPC})
+	assert.Equal(t, ErrorClassNoSpace, classifyError(err))
+}
@@ -0,0 +1,121 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMaxPayloadSize = 0
+	DefaultSubjectToken   = -1
+	subjectTokenDisabled  = -1
+
+	DefaultFallbackArchivestore = ""
+)
+
+var (
+	ErrInvalidPayload  = errors.New("Invalid archive job payload.")
+	ErrSubjectToken    = errors.New("Subject token index is out of range.")
+	ErrPayloadTooLarge = errors.New("Archive job payload exceeds max_payload_size.")
+)
+
+// job is a single archive request, optionally carrying the message it came from.
+type job struct {
+	seq      string
+	filename string
+	headers  map[string]string
+	msg      *nats.Msg
+	ctx      context.Context
+
+	// url is the source fetched in url_mode, filename is then its virtual
+	// path under the datastore
+	url string
+}
+
+// checkPayloadSize rejects payloads larger than max_payload_size before they
+// are decoded.
+func (u *Uploader) checkPayloadSize(m *nats.Msg) error {
+
+	if u.maxPayloadSize <= 0 || len(m.Data) <= u.maxPayloadSize {
+		return nil
+	}
+
+	return fmt.Errorf("%w (size: %d, max: %d)", ErrPayloadTooLarge, len(m.Data), u.maxPayloadSize)
+}
+
+// parseJob parses a seq:filename payload.
+func parseJob(m *nats.Msg) (*job, error) {
+
+	mdata := strings.SplitN(string(m.Data), ":", 2)
+	if len(mdata) != 2 || mdata[0] == "" || mdata[1] == "" {
+		return nil, fmt.Errorf("%w (%s)", ErrInvalidPayload, string(m.Data))
+	}
+
+	return &job{
+		seq:      mdata[0],
+		filename: mdata[1],
+		msg:      m,
+	}, nil
+}
+
+func (j *job) context() context.Context {
+
+	if j.ctx == nil {
+		return context.Background()
+	}
+
+	return j.ctx
+}
+
+func (j *job) subject() string {
+
+	if j.msg == nil {
+		return ""
+	}
+
+	return j.msg.Subject
+}
+
+// archiveRoot returns the archivestore directory of the job, including the
+// archivestore mapped from the subject, the subdirectory taken from the
+// subject token or the fallback archivestore without it, the subdirectory
+// captured by subdir_regex, the host and date partitions and the size bucket
+// of the source file.
+func (u *Uploader) archiveRoot(j *job) (string, error) {
+
+	root := u.archivestore
+	if j.msg != nil {
+		root = u.subjectRoot(j.subject())
+	}
+
+	if u.subjectToken != subjectTokenDisabled && j.msg != nil {
+		tokens := strings.Split(j.subject(), ".")
+		switch {
+		case u.subjectToken >= 0 && u.subjectToken < len(tokens) && tokens[u.subjectToken] != "":
+			root = path.Join(root, tokens[u.subjectToken])
+		case u.fallbackArchivestore != "":
+			// a subject without the tenant token lands in the catch-all
+			// archivestore instead of being Term'd
+			u.logger.Warn("Archiving to the fallback archivestore, subject has no tenant token",
+				zap.String("subject", j.subject()),
+				zap.Int("subjectToken", u.subjectToken),
+				zap.String("fallbackArchivestore", u.fallbackArchivestore),
+			)
+			u.counters.fallbacks.Add(1)
+			root = u.fallbackArchivestore
+		default:
+			return "", fmt.Errorf("%w (subject: %s, index: %d)", ErrSubjectToken, j.subject(), u.subjectToken)
+		}
+	}
+
+	root = u.subdirRoot(root, cleanFilename(j.filename))
+
+	return u.sizeBucketRoot(u.partitionRoot(root, time.Now()), cleanFilename(j.filename))
+}
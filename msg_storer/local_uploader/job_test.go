@@ -0,0 +1,107 @@
+package uploader
+
+import (
+	"path"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJob(t *testing.T) {
+	j, err := parseJob(&nats.Msg{Data: []byte("1:datastore/100/100/MSG_1.db")})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", j.seq)
+	assert.Equal(t, "datastore/100/100/MSG_1.db", j.filename)
+
+	_, err = parseJob(&nats.Msg{Data: []byte("invalid")})
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+	assert.True(t, isPermanent(err))
+}
+
+func TestSubjectTokenSubdir(t *testing.T) {
+	u := newTestUploader(t)
+	u.subjectToken = 0
+
+	subjects := map[string]string{
+		"tenantA.archive.bucket.job.host": "tenantA",
+		"tenantB.archive.bucket.job.host": "tenantB",
+	}
+
+	for subject, tenant := range subjects {
+		filename := createTestFile(t, u, "100/100/MSG_1.db", tenant)
+
+		archiveName, err := u.archive(&job{
+			seq:      "1",
+			filename: filename,
+			msg:      &nats.Msg{Subject: subject},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, path.Join(u.archivestore, tenant, "100/100/MSG_1.db"), archiveName)
+		assert.FileExists(t, archiveName)
+	}
+}
+
+func TestSubjectTokenOutOfRange(t *testing.T) {
+	u := newTestUploader(t)
+	u.subjectToken = 5
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	_, err := u.archive(&job{
+		seq:      "1",
+		filename: filename,
+		msg:      &nats.Msg{Subject: "tenantA.archive.bucket.job.host"},
+	})
+	assert.ErrorIs(t, err, ErrSubjectToken)
+	assert.True(t, isPermanent(err))
+	assert.FileExists(t, filename)
+}
+
+func TestMaxPayloadSize(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+	payload := []byte("1:" + filename)
+
+	// just over the limit
+	u.maxPayloadSize = len(payload) - 1
+	err := u.checkPayloadSize(&nats.Msg{Data: payload})
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+	assert.True(t, isPermanent(err))
+
+	u.msgHandler(&nats.Msg{Subject: "test", Data: payload})
+	assert.FileExists(t, filename)
+	assert.Equal(t, uint64(0), u.counters.archived.Load())
+
+	// at the limit
+	u.maxPayloadSize = len(payload)
+	u.msgHandler(&nats.Msg{Subject: "test", Data: payload})
+	assert.Equal(t, uint64(1), u.counters.archived.Load())
+}
+
+func TestSubjectTokenFallback(t *testing.T) {
+	u := newTestUploader(t)
+	u.subjectToken = 5
+	u.fallbackArchivestore = path.Join(t.TempDir(), "fallback")
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	u.msgHandler(&nats.Msg{
+		Subject: "tenantA.archive.bucket.job.host",
+		Data:    []byte("1:" + filename),
+	})
+
+	expected := path.Join(u.fallbackArchivestore, "100/100/MSG_1.db")
+	assert.FileExists(t, expected)
+	assert.NoFileExists(t, filename)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, entry.ArchiveName)
+
+	stats := u.Stats()
+	assert.Equal(t, uint64(1), stats.Archived)
+	assert.Equal(t, uint64(0), stats.Failed)
+	assert.Equal(t, uint64(1), stats.Fallbacks)
+}
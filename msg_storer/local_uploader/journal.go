@@ -0,0 +1,229 @@
+package uploader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultJournalDir               = ""
+	DefaultJournalFile              = "archive.journal"
+	DefaultJournalReconcileInterval = 30 * time.Second
+)
+
+// journal keeps index entries which couldn't be written to their index, so
+// archives whose file was already moved aren't lost. It should live on
+// another filesystem than the indexes.
+type journal struct {
+	mutex    sync.Mutex
+	filename string
+}
+
+// journalRecord is a line of the journal.
+type journalRecord struct {
+	Index string `json:"index"`
+	Entry string `json:"entry"`
+}
+
+func newJournal(dir string) (*journal, error) {
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	return &journal{
+		filename: path.Join(dir, DefaultJournalFile),
+	}, nil
+}
+
+// append records the entry of the index and syncs the journal.
+func (jr *journal) append(indexFilename string, entry Entry) error {
+
+	data, err := json.Marshal(journalRecord{
+		Index: indexFilename,
+		Entry: entry.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	jr.mutex.Lock()
+	defer jr.mutex.Unlock()
+
+	f, err := os.OpenFile(jr.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// records returns the records of the journal in the order they were appended.
+func (jr *journal) records() ([]journalRecord, error) {
+
+	jr.mutex.Lock()
+	defer jr.mutex.Unlock()
+
+	return jr.read()
+}
+
+func (jr *journal) read() ([]journalRecord, error) {
+
+	data, err := os.ReadFile(jr.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	records := make([]journalRecord, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record journalRecord
+		// a line cut off by a crash mid-append
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// drop removes the first n records except the ones kept, leaving the
+// records appended meanwhile.
+func (jr *journal) drop(n int, keep []journalRecord) error {
+
+	jr.mutex.Lock()
+	defer jr.mutex.Unlock()
+
+	records, err := jr.read()
+	if err != nil {
+		return err
+	}
+
+	if n > len(records) {
+		n = len(records)
+	}
+	records = append(keep, records[n:]...)
+
+	if len(records) == 0 {
+		if err := os.Remove(jr.filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(jr.filename, buf.Bytes())
+}
+
+// journalEntry records the entry in the journal instead of its index.
+func (u *Uploader) journalEntry(filename string, entry Entry, cause error) error {
+
+	if u.journal == nil {
+		return cause
+	}
+
+	indexFilename := u.indexFilename(filename)
+	if err := u.journal.append(indexFilename, entry); err != nil {
+		return errors.Join(cause, err)
+	}
+
+	u.logger.Warn("Recorded index entry in the journal",
+		zap.String("index", indexFilename),
+		zap.String("seq", entry.Seq),
+		zap.String("archiveName", entry.ArchiveName),
+		zap.Error(cause),
+	)
+
+	return nil
+}
+
+// reconcileJournal appends the journaled entries to their indexes. Entries
+// which are indexed already are dropped, failed ones stay for the next run.
+func (u *Uploader) reconcileJournal(ctx context.Context) error {
+
+	if u.journal == nil {
+		return nil
+	}
+
+	records, err := u.journal.records()
+	if err != nil || len(records) == 0 {
+		return err
+	}
+
+	failed := make([]journalRecord, 0)
+	for i, record := range records {
+
+		if err := ctx.Err(); err != nil {
+			failed = append(failed, records[i:]...)
+			break
+		}
+
+		entry, ok := parseEntry(record.Entry)
+		if !ok {
+			continue
+		}
+
+		if err := u.reconcileEntry(record.Index, entry); err != nil {
+			u.logger.Error("Failed to reconcile journaled index entry",
+				zap.String("index", record.Index),
+				zap.String("seq", entry.Seq),
+				zap.Error(err),
+			)
+			failed = append(failed, record)
+			continue
+		}
+
+		u.logger.Info("Reconciled journaled index entry",
+			zap.String("index", record.Index),
+			zap.String("seq", entry.Seq),
+			zap.String("archiveName", entry.ArchiveName),
+		)
+	}
+
+	return u.journal.drop(len(records), failed)
+}
+
+func (u *Uploader) reconcileEntry(indexFilename string, entry Entry) error {
+
+	unlock := u.dirLocks.lock(indexFilename)
+	defer unlock()
+
+	// a write which timed out may have completed after all
+	indexed, err := u.indexReader(indexFilename).LookupArchiveName(entry.ArchiveName)
+	if err == nil && indexed.Seq == entry.Seq {
+		return nil
+	}
+	if err != nil && !errors.Is(err, ErrEntryNotFound) {
+		return err
+	}
+
+	// the index of a datastore file is next to it
+	return u.appendEntry(indexFilename, entry)
+}
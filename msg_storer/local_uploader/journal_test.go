@@ -0,0 +1,82 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcileJournal(t *testing.T) {
+	u := newTestUploader(t)
+
+	journal, err := newJournal(path.Join(t.TempDir(), "journal"))
+	assert.Nil(t, err)
+	u.journal = journal
+
+	indexFilename := path.Join(u.datastore, "100/100", DefaultArchiveIndex)
+	assert.Nil(t, os.MkdirAll(path.Dir(indexFilename), 0750))
+
+	// already indexed by a write which completed after timing out
+	indexed := Entry{Seq: "1", ArchiveName: path.Join(u.archivestore, "100/100/MSG_1.db")}
+	assert.Nil(t, u.appendEntry(indexFilename, indexed))
+	assert.Nil(t, u.journal.append(indexFilename, indexed))
+
+	for i := 2; i <= 3; i++ {
+		entry := Entry{Seq: fmt.Sprint(i), ArchiveName: path.Join(u.archivestore, fmt.Sprintf("100/100/MSG_%d.db", i))}
+		assert.Nil(t, u.journal.append(indexFilename, entry))
+	}
+
+	// an index which can't be written stays in the journal
+	blocked := path.Join(u.datastore, "file", DefaultArchiveIndex)
+	assert.Nil(t, os.WriteFile(path.Join(u.datastore, "file"), nil, 0644))
+	assert.Nil(t, u.journal.append(blocked, Entry{Seq: "4", ArchiveName: "MSG_4.db"}))
+
+	assert.Nil(t, u.reconcileJournal(context.Background()))
+
+	entries, err := NewIndexReader(indexFilename).List()
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 3) {
+		for i, entry := range entries {
+			assert.Equal(t, fmt.Sprint(i+1), entry.Seq)
+		}
+	}
+
+	records, err := u.journal.records()
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, blocked, records[0].Index)
+	}
+}
+
+func TestJournalDropKeepsNewRecords(t *testing.T) {
+	journal, err := newJournal(t.TempDir())
+	assert.Nil(t, err)
+
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, journal.append(DefaultArchiveIndex, Entry{Seq: fmt.Sprint(i), ArchiveName: "a"}))
+	}
+
+	records, err := journal.records()
+	assert.Nil(t, err)
+	assert.Len(t, records, 3)
+
+	// appended while the first records were reconciled
+	assert.Nil(t, journal.append(DefaultArchiveIndex, Entry{Seq: "4", ArchiveName: "a"}))
+
+	assert.Nil(t, journal.drop(3, records[1:2]))
+
+	records, err = journal.records()
+	assert.Nil(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "2:a", records[0].Entry)
+		assert.Equal(t, "4:a", records[1].Entry)
+	}
+
+	assert.Nil(t, journal.drop(2, nil))
+	_, err = os.Stat(journal.filename)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"sync"
+)
+
+// dirLocks serializes operations on the same key while different keys proceed
+// in parallel. Locks are dropped once nobody holds or waits for them.
+type dirLocks struct {
+	mutex sync.Mutex
+	locks map[string]*dirLock
+}
+
+type dirLock struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// lock locks the key and returns the function to unlock it.
+func (d *dirLocks) lock(key string) func() {
+
+	d.mutex.Lock()
+	if d.locks == nil {
+		d.locks = make(map[string]*dirLock)
+	}
+
+	l, ok := d.locks[key]
+	if !ok {
+		l = &dirLock{}
+		d.locks[key] = l
+	}
+	l.refs++
+	d.mutex.Unlock()
+
+	l.mutex.Lock()
+
+	return func() {
+		l.mutex.Unlock()
+
+		d.mutex.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(d.locks, key)
+		}
+		d.mutex.Unlock()
+	}
+}
+
+// heldLock is a lock which a goroutine outliving the holder can keep.
+type heldLock struct {
+	unlock func()
+	kept   bool
+}
+
+// lockHeld locks the key like lock.
+func (d *dirLocks) lockHeld(key string) *heldLock {
+	return &heldLock{unlock: d.lock(key)}
+}
+
+// release unlocks the key unless the lock was kept.
+func (h *heldLock) release() {
+
+	if !h.kept {
+		h.unlock()
+	}
+}
+
+// keep keeps the lock past release and returns the function to unlock it.
+func (h *heldLock) keep() func() {
+	h.kept = true
+	return h.unlock
+}
+
+func (d *dirLocks) size() int {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return len(d.locks)
+}
@@ -0,0 +1,96 @@
+package uploader
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirLocksSameKey(t *testing.T) {
+	var locks dirLocks
+	var active, maxActive atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock := locks.lock("same")
+			defer unlock()
+
+			n := active.Add(1)
+			if n > maxActive.Load() {
+				maxActive.Store(n)
+			}
+			time.Sleep(time.Millisecond)
+			active.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxActive.Load())
+	assert.Equal(t, 0, locks.size())
+}
+
+func TestDirLocksDifferentKeys(t *testing.T) {
+	var locks dirLocks
+
+	unlockA := locks.lock("a")
+
+	// a different key is not blocked
+	done := make(chan struct{})
+	go func() {
+		unlockB := locks.lock("b")
+		unlockB()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock of a different key was blocked")
+	}
+
+	unlockA()
+	assert.Equal(t, 0, locks.size())
+}
+
+func TestConcurrentArchive(t *testing.T) {
+	u := newTestUploader(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		same := createTestFile(t, u, fmt.Sprintf("100/same/MSG_%d.db", i), "same")
+		other := createTestFile(t, u, fmt.Sprintf("100/dir%d/MSG_%d.db", i, i), "other")
+
+		wg.Add(2)
+		go func(seq string, filename string) {
+			defer wg.Done()
+			_, err := u.Archive(seq, filename)
+			errs <- err
+		}(fmt.Sprintf("%d", i), same)
+		go func(seq string, filename string) {
+			defer wg.Done()
+			_, err := u.Archive(seq, filename)
+			errs <- err
+		}(fmt.Sprintf("%d", i), other)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+
+	entries, err := NewIndexReader(u.indexFilename(u.datastore + "/100/same/MSG_0.db")).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 20)
+
+	assert.Equal(t, 0, u.dirLocks.size())
+}
@@ -0,0 +1,180 @@
+package uploader
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMaintenanceInterval = 0
+	DefaultStagingMaxAge       = 24 * time.Hour
+	DefaultQuarantineDir       = ""
+)
+
+// maintain removes stale staging files and reports orphaned archive files,
+// quarantining them when a quarantine directory is configured.
+func (u *Uploader) maintain(ctx context.Context) error {
+
+	removed, err := u.cleanStaging(ctx, u.stagingMaxAge)
+	if err != nil {
+		return err
+	}
+
+	if removed > 0 {
+		u.logger.Info("Removed stale staging files", zap.Int("count", removed))
+	}
+
+	orphans, err := u.FindOrphans(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, orphan := range orphans {
+		u.logger.Warn("Found orphaned archive file", zap.String("archiveName", orphan))
+
+		if u.quarantineDir == "" {
+			continue
+		}
+
+		if err := u.quarantine(orphan); err != nil {
+			u.logger.Error("Failed to quarantine orphaned archive file",
+				zap.String("archiveName", orphan),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil
+}
+
+// cleanStaging removes staging files older than maxAge, left behind by a crash
+// mid-copy, and returns how many were removed.
+func (u *Uploader) cleanStaging(ctx context.Context, maxAge time.Duration) (int, error) {
+
+	files, err := filepath.Glob(path.Join(u.stagingDir(), StagingPattern))
+	if err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(-maxAge)
+	removed := 0
+
+	for _, filename := range files {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+
+		fi, err := os.Stat(filename)
+		if err != nil || fi.IsDir() || fi.ModTime().After(deadline) {
+			continue
+		}
+
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// FindOrphans returns the archive files under the archivestore which are not
+// referenced by any index entry. Files changed within staging_max_age are
+// skipped, since an archive is moved before it is indexed, and the index is
+// read after the walk for the same reason.
+func (u *Uploader) FindOrphans(ctx context.Context) ([]string, error) {
+
+	candidates := make([]string, 0)
+	quarantineDir := path.Clean(u.quarantineDir)
+	deadline := time.Now().Add(-u.stagingMaxAge)
+
+	err := filepath.WalkDir(u.archivestore, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if u.quarantineDir != "" && p == quarantineDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || skipRebuild(d.Name()) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil || changeTime(fi).After(deadline) {
+			return nil
+		}
+
+		candidates = append(candidates, p)
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	indexed, err := u.indexedArchives(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := make([]string, 0)
+	for _, p := range candidates {
+		if !indexed[p] {
+			orphans = append(orphans, p)
+		}
+	}
+
+	return orphans, nil
+}
+
+// indexedArchives returns the set of archive names referenced by the index.
+func (u *Uploader) indexedArchives(ctx context.Context) (map[string]bool, error) {
+
+	if u.sqlIndex != nil {
+		return u.sqlIndex.archiveNames(ctx)
+	}
+
+	indexed := make(map[string]bool)
+
+	err := u.eachIndexFile(ctx, func(indexFilename string) error {
+		return NewIndexReader(indexFilename).each(ctx, func(entry Entry) error {
+			indexed[entry.ArchiveName] = true
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return indexed, nil
+}
+
+// quarantine moves an orphaned archive file into the quarantine directory,
+// keeping its path below the archivestore.
+func (u *Uploader) quarantine(archiveName string) error {
+
+	rel := strings.TrimPrefix(archiveName, path.Clean(u.archivestore)+"/")
+	dest := path.Join(u.quarantineDir, rel)
+
+	if err := os.MkdirAll(path.Dir(dest), DefaultDirMode); err != nil {
+		return err
+	}
+
+	return u.moveFile(archiveName, dest)
+}
@@ -0,0 +1,118 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createStagingFile(t *testing.T, u *Uploader, age time.Duration) string {
+	if err := os.MkdirAll(u.stagingDir(), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.CreateTemp(u.stagingDir(), StagingPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	ts := time.Now().Add(-age)
+	if err := os.Chtimes(f.Name(), ts, ts); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestCleanStaging(t *testing.T) {
+	u := newTestUploader(t)
+
+	stale := createStagingFile(t, u, 2*time.Hour)
+	fresh := createStagingFile(t, u, time.Minute)
+
+	removed, err := u.cleanStaging(context.Background(), time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(fresh)
+	assert.Nil(t, err)
+}
+
+func TestFindOrphans(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "indexed")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	orphan := createArchiveFile(t, u, "100/300/MSG_2.db", "orphan")
+	createStagingFile(t, u, time.Minute)
+
+	orphans, err := u.FindOrphans(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{orphan}, orphans)
+}
+
+func TestFindOrphansSkipsRecent(t *testing.T) {
+	u := newTestUploader(t)
+	u.stagingMaxAge = time.Hour
+
+	// moved into the archivestore but not indexed yet
+	createArchiveFile(t, u, "100/300/MSG_2.db", "unindexed")
+
+	orphans, err := u.FindOrphans(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, orphans, 0)
+}
+
+func TestMaintainQuarantine(t *testing.T) {
+	u := newTestUploader(t)
+	u.stagingMaxAge = 50 * time.Millisecond
+	u.quarantineDir = path.Join(u.archivestore, "quarantine")
+
+	stale := createStagingFile(t, u, 2*time.Hour)
+	orphan := createArchiveFile(t, u, "100/300/MSG_2.db", "orphan")
+
+	// past the grace period of orphans
+	time.Sleep(100 * time.Millisecond)
+
+	err := u.maintain(context.Background())
+	assert.Nil(t, err)
+
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(orphan)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(path.Join(u.quarantineDir, "100/300/MSG_2.db"))
+	assert.Nil(t, err)
+
+	// quarantined files aren't reported again
+	orphans, err := u.FindOrphans(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, orphans, 0)
+}
+
+func TestMaintenanceTask(t *testing.T) {
+	u := newTestUploader(t)
+	u.stagingMaxAge = time.Hour
+
+	stale := createStagingFile(t, u, 2*time.Hour)
+
+	u.startTask("maintenance", 10*time.Millisecond, u.maintain)
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(stale)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	u.stopTasks()
+}
@@ -0,0 +1,21 @@
+package uploader
+
+import (
+	"github.com/nats-io/nats.go"
+)
+
+// Middleware wraps the message handler, e.g. for authorization, tracing or
+// custom metrics, and calls next to let the uploader handle the message.
+type Middleware func(next nats.MsgHandler) nats.MsgHandler
+
+// coreHandler returns the message handler wrapped by the middleware, the
+// first one being the outermost. The workers run the whole chain per message.
+func (u *Uploader) coreHandler() nats.MsgHandler {
+
+	h := nats.MsgHandler(u.msgHandler)
+	for i := len(u.middleware) - 1; i >= 0; i-- {
+		h = u.middleware[i](h)
+	}
+
+	return h
+}
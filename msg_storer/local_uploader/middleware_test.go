@@ -0,0 +1,63 @@
+package uploader
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	u := newTestUploader(t)
+	u.ackMode = AckModeAuto
+
+	calls := make([]string, 0)
+	middleware := func(name string) Middleware {
+		return func(next nats.MsgHandler) nats.MsgHandler {
+			return func(m *nats.Msg) {
+				calls = append(calls, name+" before")
+				next(m)
+				calls = append(calls, name+" after")
+			}
+		}
+	}
+	u.middleware = []Middleware{middleware("auth"), middleware("metrics")}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	u.handler()(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	})
+
+	assert.Equal(t, []string{"auth before", "metrics before", "metrics after", "auth after"}, calls)
+
+	// the core handler ran inside the chain
+	assert.Equal(t, uint64(1), u.Stats().Archived)
+	assert.NoFileExists(t, filename)
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	u := newTestUploader(t)
+	u.ackMode = AckModeAuto
+	u.middleware = []Middleware{
+		func(next nats.MsgHandler) nats.MsgHandler {
+			return func(m *nats.Msg) {
+				if m.Header.Get("Authorization") == "" {
+					return
+				}
+				next(m)
+			}
+		},
+	}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	u.handler()(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	})
+
+	assert.Equal(t, uint64(0), u.Stats().Archived)
+	assert.FileExists(t, filename)
+}
@@ -0,0 +1,37 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// NAME_MAX of most filesystems
+	DefaultMaxNameLength = 255
+)
+
+var (
+	ErrNameTooLong = errors.New("Archive path component exceeds the maximum name length.")
+)
+
+// checkNameLength rejects archive names with a path component below the
+// archivestore longer than the maximum name length, before the filesystem
+// fails with ENAMETOOLONG.
+func (u *Uploader) checkNameLength(archiveName string) error {
+
+	if u.maxNameLength <= 0 {
+		return nil
+	}
+
+	name := strings.TrimPrefix(archiveName, path.Clean(u.archivestore)+"/")
+
+	for _, component := range strings.Split(name, "/") {
+		if len(component) > u.maxNameLength {
+			return fmt.Errorf("%w (length: %d, max: %d, component: %s)", ErrNameTooLong, len(component), u.maxNameLength, component)
+		}
+	}
+
+	return nil
+}
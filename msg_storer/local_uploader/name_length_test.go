@@ -0,0 +1,68 @@
+package uploader
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxNameLength(t *testing.T) {
+	u := newTestUploader(t)
+	u.maxNameLength = 32
+	u.archiveSuffix = ".archived"
+
+	// the source fits, but not with the suffix
+	name := strings.Repeat("a", 28)
+	filename := createTestFile(t, u, "100/300/"+name, "data")
+
+	_, err := u.Archive("1", filename)
+	assert.True(t, errors.Is(err, ErrNameTooLong))
+	assert.True(t, isPermanent(err))
+
+	// the source is left untouched
+	_, err = os.Stat(filename)
+	assert.Nil(t, err)
+
+	entries, err := NewIndexReader(u.indexFilename(filename)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestMaxNameLengthTerm(t *testing.T) {
+	u := newTestUploader(t)
+	u.maxNameLength = 16
+	u.recordFailures = true
+
+	filename := createTestFile(t, u, "100/300/"+strings.Repeat("a", 20), "data")
+
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	})
+
+	records, err := ReadFailures(u.failureLogFilename(filename))
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Contains(t, records[0].Error, "maximum name length")
+	}
+}
+
+func TestCheckNameLength(t *testing.T) {
+	u := newTestUploader(t)
+
+	u.maxNameLength = 0
+	assert.Nil(t, u.checkNameLength(u.archivestore+"/"+strings.Repeat("a", 300)))
+
+	u.maxNameLength = DefaultMaxNameLength
+	assert.Nil(t, u.checkNameLength(u.archivestore+"/"+strings.Repeat("a", 255)))
+	assert.NotNil(t, u.checkNameLength(u.archivestore+"/"+strings.Repeat("a", 256)))
+
+	// the configured archivestore itself isn't checked
+	u.maxNameLength = 4
+	assert.Nil(t, u.checkNameLength(u.archivestore+"/100/300"))
+	assert.NotNil(t, u.checkNameLength(u.archivestore+"/100/30000"))
+}
@@ -0,0 +1,46 @@
+package uploader
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cleanFilename removes redundant slashes, dot segments and surrounding spaces,
+// so the result still points at the same file.
+func cleanFilename(filename string) string {
+	return filepath.Clean(strings.TrimSpace(filename))
+}
+
+// normalizeFilename returns the logical name of the file which is recorded in
+// the index, optionally lowercased.
+func (u *Uploader) normalizeFilename(filename string) string {
+
+	name := cleanFilename(filename)
+
+	if u.normalizeCase {
+		name = strings.ToLower(name)
+	}
+
+	return name
+}
+
+// translate returns the archive file name of a datastore file under root.
+// lowercase_archive_paths lowercases it like normalize_case, but the index
+// keeps the original case of the source.
+func (u *Uploader) translate(filename string, root string) string {
+
+	root = path.Join(root)
+	archiveName := strings.ReplaceAll(filename, path.Join(u.sourceRoot(filename)), root)
+
+	if !u.normalizeCase && !u.lowercaseArchivePaths {
+		return archiveName
+	}
+
+	// only the part below the archive root is normalized
+	if strings.HasPrefix(archiveName, root+"/") {
+		return root + strings.ToLower(strings.TrimPrefix(archiveName, root))
+	}
+
+	return strings.ToLower(archiveName)
+}
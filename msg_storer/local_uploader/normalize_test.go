@@ -0,0 +1,186 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeFilenameVariants(t *testing.T) {
+	u := newTestUploader(t)
+
+	expected := path.Join(u.archivestore, "100/100/MSG_1.db")
+	variants := []string{
+		u.datastore + "/100/100/MSG_1.db",
+		u.datastore + "//100///100/MSG_1.db",
+		u.datastore + "/100/./100/MSG_1.db",
+		u.datastore + "/100/200/../100/MSG_1.db",
+		"  " + u.datastore + "/100/100/MSG_1.db  ",
+	}
+
+	for _, variant := range variants {
+		archiveName := u.translate(cleanFilename(variant), u.archivestore)
+		assert.Equal(t, expected, archiveName, "variant %q", variant)
+	}
+}
+
+func TestNormalizeCase(t *testing.T) {
+	u := newTestUploader(t)
+	u.normalizeCase = true
+
+	filename := createTestFile(t, u, "100/Job/MSG_1.DB", "case")
+
+	archiveName, err := u.Archive("1", filename+" ")
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/job/msg_1.db"), archiveName)
+	assert.FileExists(t, archiveName)
+}
+
+func TestNormalizeRecordsRawAndNormalized(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "raw")
+	raw := path.Dir(filename) + "/./MSG_1.db "
+
+	archiveName, err := u.Archive("1", raw)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/100/MSG_1.db"), archiveName)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, filename, entry.Filename)
+	assert.Equal(t, raw, entry.RawFilename)
+}
+
+func TestArchiveNameEqualsSource(t *testing.T) {
+	u := newTestUploader(t)
+	u.archivestore = u.datastore
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "same")
+
+	_, err := u.Archive("1", filename)
+	assert.ErrorIs(t, err, ErrSameArchivePath)
+	assert.FileExists(t, filename)
+	assert.NoFileExists(t, u.indexFilename(filename))
+}
+
+func TestLowercaseArchivePaths(t *testing.T) {
+	u := newTestUploader(t)
+	u.lowercaseArchivePaths = true
+
+	filename := createTestFile(t, u, "100/Job/MSG_1.DB", "case")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/job/msg_1.db"), archiveName)
+
+	// the index keeps the original case for the restore
+	entry, err := u.Lookup("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, filename, entry.Filename)
+
+	restored, err := u.Restore("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, filename, restored)
+	assert.FileExists(t, filename)
+}
+
+func TestLowercaseArchivePathsCollision(t *testing.T) {
+	u := newTestUploader(t)
+	u.lowercaseArchivePaths = true
+
+	upper := createTestFile(t, u, "100/Job/MSG_1.DB", "upper")
+	lower := createTestFile(t, u, "100/job/msg_1.db", "lower")
+
+	archiveName, err := u.Archive("1", upper)
+	assert.Nil(t, err)
+
+	// a source differing in case is a collision, even with the same sequence
+	_, err = u.Archive("1", lower)
+	assert.ErrorIs(t, err, ErrArchiveCollision)
+	assert.FileExists(t, lower)
+
+	u.onCollision = CollisionSuffix
+	suffixed, err := u.Archive("1", lower)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/job/msg_1_1.db"), suffixed)
+	assert.FileExists(t, archiveName)
+
+	// a third case variant in the same directory takes the next suffix
+	other := createTestFile(t, u, "100/job/MSG_1.db", "same dir")
+	suffixed, err = u.Archive("2", other)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/job/msg_1_2.db"), suffixed)
+}
+
+func TestLowercaseArchivePathsLegacyEntry(t *testing.T) {
+	u := newTestUploader(t)
+	u.lowercaseArchivePaths = true
+
+	filename := createTestFile(t, u, "100/job/msg_1.db", "legacy")
+	archiveName := path.Join(u.archivestore, "100/job/msg_1.db")
+
+	// entries of the seq:archiveName format have no filename
+	assert.Nil(t, os.MkdirAll(path.Dir(archiveName), 0755))
+	assert.Nil(t, os.WriteFile(archiveName, []byte("legacy"), 0644))
+	assert.Nil(t, os.WriteFile(u.indexFilename(filename), []byte("1:"+archiveName+"\n"), 0644))
+
+	owned, err := u.ownedByOtherSeq(context.Background(), u.indexReader(filename), archiveName, filename, "1")
+	assert.Nil(t, err)
+	assert.False(t, owned)
+
+	owned, err = u.ownedByOtherSeq(context.Background(), u.indexReader(filename), archiveName, filename, "2")
+	assert.Nil(t, err)
+	assert.True(t, owned)
+}
+
+func TestLowercaseArchivePathsNotIndexed(t *testing.T) {
+	u := newTestUploader(t)
+	u.lowercaseArchivePaths = true
+
+	filename := createTestFile(t, u, "100/Job/MSG_1.DB", "moved")
+	archiveName := path.Join(u.archivestore, "100/job/msg_1.db")
+
+	// moved by a previous attempt which failed before indexing it
+	assert.Nil(t, os.MkdirAll(path.Dir(archiveName), 0755))
+	assert.Nil(t, os.WriteFile(archiveName, []byte("moved"), 0644))
+
+	archived, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, archived)
+}
+
+func TestCaseVariantIndexes(t *testing.T) {
+	u := newTestUploader(t)
+
+	for _, name := range []string{"100/Job/a.db", "100/job/a.db", "100/JOB/a.db", "100/jobs/a.db", "200/job/a.db"} {
+		createTestFile(t, u, name, "dir")
+	}
+
+	files, err := u.caseVariantIndexes(context.Background(), path.Join(u.datastore, "100/job", DefaultArchiveIndex))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{
+		path.Join(u.datastore, "100/Job", DefaultArchiveIndex),
+		path.Join(u.datastore, "100/job", DefaultArchiveIndex),
+		path.Join(u.datastore, "100/JOB", DefaultArchiveIndex),
+	}, files)
+}
+
+func TestLowercaseArchivePathsCollisionSQL(t *testing.T) {
+	u := newTestUploader(t)
+	u.sqlIndex = newTestSQLIndex(t)
+	u.lowercaseArchivePaths = true
+
+	upper := createTestFile(t, u, "100/Job/MSG_1.DB", "upper")
+	lower := createTestFile(t, u, "100/job/msg_1.db", "lower")
+
+	_, err := u.Archive("1", upper)
+	assert.Nil(t, err)
+
+	_, err = u.Archive("1", lower)
+	assert.ErrorIs(t, err, ErrArchiveCollision)
+	assert.FileExists(t, lower)
+}
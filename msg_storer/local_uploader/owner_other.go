@@ -0,0 +1,12 @@
+//go:build !unix
+
+package uploader
+
+import (
+	"io/fs"
+)
+
+// fileOwner isn't available on this platform, the owner is never audited.
+func fileOwner(fi fs.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
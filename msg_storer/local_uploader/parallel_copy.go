@@ -0,0 +1,107 @@
+package uploader
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+const (
+	DefaultParallelCopyThreshold = 0
+	DefaultParallelCopyChunkSize = 64 * 1024 * 1024
+	DefaultParallelCopyWorkers   = 4
+)
+
+// copyParallel copies files of at least parallel_copy_threshold bytes in
+// chunks, each read and written at its offset by one of a bounded number of
+// goroutines. It reports false when the copy has to take the simple path, for
+// small files or files which can't be accessed at offsets.
+func (u *Uploader) copyParallel(dst io.Writer, src io.Reader) (bool, error) {
+
+	if u.parallelCopyThreshold <= 0 {
+		return false, nil
+	}
+
+	stater, ok := src.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return false, nil
+	}
+
+	r, rok := src.(io.ReaderAt)
+	w, wok := dst.(io.WriterAt)
+	if !rok || !wok {
+		return false, nil
+	}
+
+	fi, err := stater.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	size := fi.Size()
+	if size < u.parallelCopyThreshold {
+		return false, nil
+	}
+
+	chunkSize := u.parallelCopyChunkSize
+	if chunkSize < MinCopyBufferSize {
+		chunkSize = DefaultParallelCopyChunkSize
+	}
+
+	workers := u.parallelCopyWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+		})
+	}
+
+	offsets := make(chan int64)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			buf := make([]byte, chunkSize)
+			for offset := range offsets {
+				n := chunkSize
+				if remaining := size - offset; remaining < n {
+					n = remaining
+				}
+
+				if _, err := r.ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+					fail(err)
+					continue
+				}
+
+				if _, err := w.WriteAt(buf[:n], offset); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	for offset := int64(0); offset < size; offset += chunkSize {
+		offsets <- offset
+	}
+	close(offsets)
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return true, fmt.Errorf("parallel copy: %w", firstErr)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package uploader
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyFileParallel(t *testing.T) {
+	u := newTestUploader(t)
+	u.parallelCopyThreshold = 256 * 1024
+	u.parallelCopyChunkSize = 64 * 1024
+	u.parallelCopyWorkers = 4
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	// not a multiple of the chunk size
+	content := make([]byte, 1024*1024+123)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", string(content))
+	archiveName := path.Join(u.archivestore, "MSG_1.db")
+
+	checksum, err := fileChecksum(filename)
+	assert.Nil(t, err)
+
+	err = u.copyFile(filename, archiveName)
+	assert.Nil(t, err)
+
+	data, err := os.ReadFile(archiveName)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(content, data))
+
+	archiveChecksum, err := fileChecksum(archiveName)
+	assert.Nil(t, err)
+	assert.Equal(t, checksum, archiveChecksum)
+}
+
+func TestCopyParallelBelowThreshold(t *testing.T) {
+	u := newTestUploader(t)
+	u.parallelCopyThreshold = 1024
+	u.parallelCopyChunkSize = 4096
+	u.parallelCopyWorkers = 2
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "small")
+
+	src, err := os.Open(filename)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path.Join(t.TempDir(), "dst"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer dst.Close()
+
+	parallel, err := u.copyParallel(dst, src)
+	assert.Nil(t, err)
+	assert.False(t, parallel)
+
+	// plain writers can't be written at offsets
+	u.parallelCopyThreshold = 1
+	parallel, err = u.copyParallel(&bytes.Buffer{}, src)
+	assert.Nil(t, err)
+	assert.False(t, parallel)
+}
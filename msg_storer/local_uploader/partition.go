@@ -0,0 +1,25 @@
+package uploader
+
+import (
+	"path"
+	"time"
+)
+
+const (
+	DefaultPartitionByDate = ""
+)
+
+// partitionRoot adds the host and date partitions to the archive root, in
+// that order, so each host keeps its own date tree.
+func (u *Uploader) partitionRoot(root string, t time.Time) string {
+
+	if u.partitionByHost {
+		root = path.Join(root, u.hostname)
+	}
+
+	if u.partitionByDate != "" {
+		root = path.Join(root, t.Format(u.partitionByDate))
+	}
+
+	return root
+}
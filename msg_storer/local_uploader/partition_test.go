@@ -0,0 +1,60 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionByHost(t *testing.T) {
+	u := newTestUploader(t)
+	u.partitionByHost = true
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "test", "100/300/MSG_1.db"), archiveName)
+
+	_, err = os.Stat(archiveName)
+	assert.Nil(t, err)
+
+	// the index records the host partitioned path
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+}
+
+func TestPartitionByHostAndDate(t *testing.T) {
+	u := newTestUploader(t)
+	u.partitionByHost = true
+	u.partitionByDate = "2006/01/02"
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	expected := path.Join(u.archivestore, "test", time.Now().Format("2006/01/02"), "100/300/MSG_1.db")
+	assert.Equal(t, expected, archiveName)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, entry.ArchiveName)
+}
+
+func TestPartitionRoot(t *testing.T) {
+	u := newTestUploader(t)
+	ts := time.Date(2023, 11, 2, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "/archivestore", u.partitionRoot("/archivestore", ts))
+
+	u.partitionByDate = "2006-01-02"
+	assert.Equal(t, "/archivestore/2023-11-02", u.partitionRoot("/archivestore", ts))
+
+	u.partitionByHost = true
+	assert.Equal(t, "/archivestore/test/2023-11-02", u.partitionRoot("/archivestore", ts))
+}
@@ -0,0 +1,28 @@
+package uploader
+
+import (
+	"time"
+)
+
+const (
+	DefaultPauseNakDelay = 5 * time.Second
+)
+
+// Pause stops archiving without dropping the subscription. Messages delivered
+// while paused are Nak'd with a delay, so they stay in the stream.
+func (u *Uploader) Pause() {
+	if !u.paused.Swap(true) {
+		u.logger.Info("Paused Uploader")
+	}
+}
+
+// Resume continues archiving after Pause.
+func (u *Uploader) Resume() {
+	if u.paused.Swap(false) {
+		u.logger.Info("Resumed Uploader")
+	}
+}
+
+func (u *Uploader) Paused() bool {
+	return u.paused.Load()
+}
@@ -0,0 +1,36 @@
+package uploader
+
+import (
+	"path"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseResume(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "pause")
+	archiveName := path.Join(u.archivestore, "100/100/MSG_1.db")
+	msg := &nats.Msg{Data: []byte("1:" + filename)}
+
+	u.Pause()
+	assert.True(t, u.Stats().Paused)
+
+	u.msgHandler(msg)
+	assert.FileExists(t, filename)
+	assert.NoFileExists(t, archiveName)
+	assert.Equal(t, uint64(0), u.Stats().Archived)
+
+	u.Resume()
+	assert.False(t, u.Stats().Paused)
+
+	u.msgHandler(msg)
+	assert.NoFileExists(t, filename)
+	assert.FileExists(t, archiveName)
+
+	stats := u.Stats()
+	assert.Equal(t, uint64(1), stats.Archived)
+	assert.Equal(t, uint64(5), stats.ArchivedBytes)
+}
@@ -0,0 +1,163 @@
+package uploader
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultPriorityHeader = ""
+	DefaultPriorityLevels = 3
+	DefaultPriorityAging  = time.Second
+)
+
+type queuedMsg struct {
+	msg      *nats.Msg
+	priority int
+	queued   time.Time
+}
+
+// priorityQueue holds one FIFO queue per priority level. The workers take the
+// head with the highest priority, where every aging interval a message waits
+// counts as one more level, so low priority messages aren't starved.
+type priorityQueue struct {
+	mutex    sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	levels   [][]queuedMsg
+	size     int
+	capacity int
+	aging    time.Duration
+	closed   bool
+}
+
+func newPriorityQueue(levels int, capacity int, aging time.Duration) *priorityQueue {
+
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	q := &priorityQueue{
+		levels:   make([][]queuedMsg, levels),
+		capacity: capacity,
+		aging:    aging,
+	}
+	q.notEmpty = sync.NewCond(&q.mutex)
+	q.notFull = sync.NewCond(&q.mutex)
+
+	return q
+}
+
+// push queues the message, clamping the priority to the levels. It blocks
+// while the queue is full.
+func (q *priorityQueue) push(priority int, m *nats.Msg) {
+
+	if priority < 0 {
+		priority = 0
+	}
+	if priority >= len(q.levels) {
+		priority = len(q.levels) - 1
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for q.size >= q.capacity && !q.closed {
+		q.notFull.Wait()
+	}
+
+	q.levels[priority] = append(q.levels[priority], queuedMsg{
+		msg:      m,
+		priority: priority,
+		queued:   time.Now(),
+	})
+	q.size++
+
+	q.notEmpty.Signal()
+}
+
+// pop returns the next message, or false once the queue is closed and drained.
+func (q *priorityQueue) pop() (*nats.Msg, bool) {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for q.size == 0 {
+		if q.closed {
+			return nil, false
+		}
+		q.notEmpty.Wait()
+	}
+
+	// on a tie the message which waited longer wins
+	now := time.Now()
+	best := -1
+	bestScore := 0
+	for level := len(q.levels) - 1; level >= 0; level-- {
+		if len(q.levels[level]) == 0 {
+			continue
+		}
+
+		head := q.levels[level][0]
+		score := q.score(head, now)
+		if best < 0 || score > bestScore || (score == bestScore && head.queued.Before(q.levels[best][0].queued)) {
+			best = level
+			bestScore = score
+		}
+	}
+
+	qm := q.levels[best][0]
+	q.levels[best] = q.levels[best][1:]
+	q.size--
+
+	q.notFull.Signal()
+
+	return qm.msg, true
+}
+
+// score is the priority of the message raised by one level per aging interval waited.
+func (q *priorityQueue) score(qm queuedMsg, now time.Time) int {
+
+	if q.aging <= 0 {
+		return qm.priority
+	}
+
+	return qm.priority + int(now.Sub(qm.queued)/q.aging)
+}
+
+func (q *priorityQueue) len() int {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.size
+}
+
+func (q *priorityQueue) close() {
+
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// messagePriority reads the priority from the priority header. A missing or
+// invalid priority is the lowest.
+func (u *Uploader) messagePriority(m *nats.Msg) int {
+
+	if m.Header == nil {
+		return 0
+	}
+
+	priority, err := strconv.Atoi(m.Header.Get(u.priorityHeader))
+	if err != nil {
+		return 0
+	}
+
+	return priority
+}
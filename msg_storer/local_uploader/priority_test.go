@@ -0,0 +1,117 @@
+package uploader
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func priorityMsg(priority string, data string) *nats.Msg {
+	m := nats.NewMsg("test")
+	m.Data = []byte(data)
+	if priority != "" {
+		m.Header.Set("Priority", priority)
+	}
+	return m
+}
+
+func TestPriorityQueue(t *testing.T) {
+	q := newPriorityQueue(3, 16, time.Hour)
+
+	q.push(0, priorityMsg("", "low-1"))
+	q.push(2, priorityMsg("", "high-1"))
+	q.push(0, priorityMsg("", "low-2"))
+	q.push(1, priorityMsg("", "mid-1"))
+	q.push(7, priorityMsg("", "high-2"))
+
+	expected := []string{"high-1", "high-2", "mid-1", "low-1", "low-2"}
+	for _, data := range expected {
+		m, ok := q.pop()
+		assert.True(t, ok)
+		assert.Equal(t, data, string(m.Data))
+	}
+
+	q.close()
+	_, ok := q.pop()
+	assert.False(t, ok)
+}
+
+func TestPriorityQueueAging(t *testing.T) {
+	q := newPriorityQueue(3, 16, 10*time.Millisecond)
+
+	q.push(0, priorityMsg("", "low"))
+	time.Sleep(50 * time.Millisecond)
+	q.push(2, priorityMsg("", "high"))
+
+	// the low priority message waited long enough to overtake
+	m, _ := q.pop()
+	assert.Equal(t, "low", string(m.Data))
+}
+
+func TestMessagePriority(t *testing.T) {
+	u := newTestUploader(t)
+	u.priorityHeader = "Priority"
+
+	assert.Equal(t, 2, u.messagePriority(priorityMsg("2", "")))
+	assert.Equal(t, 0, u.messagePriority(priorityMsg("", "")))
+	assert.Equal(t, 0, u.messagePriority(priorityMsg("urgent", "")))
+	assert.Equal(t, 0, u.messagePriority(&nats.Msg{}))
+}
+
+func TestPriorityWorkerPool(t *testing.T) {
+	u := newTestUploader(t)
+	u.priorityHeader = "Priority"
+
+	var mutex sync.Mutex
+	handled := make([]string, 0)
+	block := make(chan struct{})
+
+	u.pool = newPriorityWorkerPool(1, 128, 3, 5*time.Millisecond, func(m *nats.Msg) {
+		if string(m.Data) == "block" {
+			<-block
+			return
+		}
+		time.Sleep(time.Millisecond)
+
+		mutex.Lock()
+		handled = append(handled, string(m.Data))
+		mutex.Unlock()
+	})
+
+	// keep the worker busy while the backlog builds up
+	u.dispatch(priorityMsg("", "block"))
+	time.Sleep(5 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		u.dispatch(priorityMsg("0", "low"))
+	}
+	for i := 0; i < 10; i++ {
+		u.dispatch(priorityMsg("2", "high"))
+	}
+	close(block)
+
+	// high priority messages keep coming
+	for i := 0; i < 50; i++ {
+		u.dispatch(priorityMsg("2", "high"))
+		time.Sleep(time.Millisecond)
+	}
+	u.pool.stop()
+
+	assert.Len(t, handled, 70)
+
+	// the queued high priority messages are handled first
+	for _, data := range handled[:10] {
+		assert.Equal(t, "high", data)
+	}
+
+	// the aged low priority messages aren't starved until the end
+	lastLow := 0
+	for i, data := range handled {
+		if data == "low" {
+			lastLow = i
+		}
+	}
+	assert.Less(t, lastLow, len(handled)-10)
+}
@@ -0,0 +1,67 @@
+package uploader
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ProvenanceSidecarSuffix = ".prov.json"
+)
+
+// Provenance is written next to the archive file to record where the file
+// came from, for the chain of custody.
+type Provenance struct {
+	Seq         string            `json:"seq"`
+	Filename    string            `json:"filename"`
+	ArchiveName string            `json:"archive_name"`
+	Hostname    string            `json:"hostname"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Headers     map[string]string `json:"headers"`
+}
+
+// provenance returns the provenance of the job. Every configured header is
+// recorded, empty when the message doesn't carry it.
+func (u *Uploader) provenance(j *job, archiveName string) Provenance {
+
+	p := Provenance{
+		Seq:         j.seq,
+		Filename:    j.filename,
+		ArchiveName: archiveName,
+		Hostname:    u.hostname,
+		Timestamp:   time.Now(),
+		Headers:     make(map[string]string, len(u.provenanceHeaders)),
+	}
+
+	for _, name := range u.provenanceHeaders {
+		var value string
+		if j.msg != nil && j.msg.Header != nil {
+			value = j.msg.Header.Get(name)
+		}
+
+		// headers decoded from the payload
+		if value == "" {
+			value = j.headers[name]
+		}
+
+		p.Headers[name] = value
+	}
+
+	return p
+}
+
+// writeProvenanceSidecar writes <archiveName>.prov.json atomically when
+// provenance_headers is set.
+func (u *Uploader) writeProvenanceSidecar(j *job, archiveName string) error {
+
+	if len(u.provenanceHeaders) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(u.provenance(j, archiveName), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(archiveName+ProvenanceSidecarSuffix, data)
+}
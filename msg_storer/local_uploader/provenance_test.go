@@ -0,0 +1,54 @@
+package uploader
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenanceSidecar(t *testing.T) {
+	u := newTestUploader(t)
+	u.provenanceHeaders = []string{"X-Producer", "X-Origin-Host", "X-Pipeline"}
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "provenance")
+
+	m := nats.NewMsg("test")
+	m.Header.Set("X-Producer", "recorder")
+	m.Header.Set("X-Origin-Host", "edge-1")
+
+	archiveName, err := u.archive(&job{seq: "1", filename: filename, msg: m})
+	assert.Nil(t, err)
+
+	data, err := os.ReadFile(archiveName + ProvenanceSidecarSuffix)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var p Provenance
+	assert.Nil(t, json.Unmarshal(data, &p))
+	assert.Equal(t, "1", p.Seq)
+	assert.Equal(t, archiveName, p.ArchiveName)
+	assert.Equal(t, "recorder", p.Headers["X-Producer"])
+	assert.Equal(t, "edge-1", p.Headers["X-Origin-Host"])
+
+	// a missing header is recorded empty
+	pipeline, ok := p.Headers["X-Pipeline"]
+	assert.True(t, ok)
+	assert.Empty(t, pipeline)
+
+	// not mistaken for an archive
+	assert.True(t, skipRebuild(archiveName+ProvenanceSidecarSuffix))
+}
+
+func TestProvenanceSidecarDisabled(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "provenance")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.NoFileExists(t, archiveName+ProvenanceSidecarSuffix)
+}
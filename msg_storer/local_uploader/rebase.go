@@ -0,0 +1,51 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RebaseIndex rewrites the archive names of all index entries under oldRoot to
+// newRoot, e.g. after the archivestore moved to another mount. Entries under
+// other roots are left untouched. Every index file is replaced atomically.
+func (u *Uploader) RebaseIndex(oldRoot string, newRoot string) error {
+
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	if u.sqlIndex != nil {
+		return fmt.Errorf("RebaseIndex isn't supported by index_backend %s", u.indexBackend)
+	}
+
+	oldRoot = path.Clean(oldRoot)
+	newRoot = path.Clean(newRoot)
+
+	return u.eachIndexFile(context.Background(), func(indexFilename string) error {
+
+		// no entry is appended while the index is replaced
+		unlock := u.dirLocks.lock(indexFilename)
+		defer unlock()
+
+		return rewriteIndex(indexFilename, func(entry Entry) (Entry, bool) {
+			entry.ArchiveName = rebasePath(entry.ArchiveName, oldRoot, newRoot)
+			return entry, true
+		})
+	})
+}
+
+// rebasePath moves name from oldRoot to newRoot when it is below oldRoot.
+func rebasePath(name string, oldRoot string, newRoot string) string {
+
+	if name == oldRoot {
+		return newRoot
+	}
+
+	if rest, ok := strings.CutPrefix(name, oldRoot+"/"); ok {
+		return path.Join(newRoot, rest)
+	}
+
+	return name
+}
@@ -0,0 +1,76 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebaseIndex(t *testing.T) {
+	u := newTestUploader(t)
+
+	dir := path.Join(u.datastore, "100/300")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	indexFilename := path.Join(dir, DefaultArchiveIndex)
+	content := "1:/mnt/old/100/300/MSG_1.db\tsize=4\n" +
+		"2:/mnt/other/100/300/MSG_2.db\n" +
+		"# comment\n" +
+		"3:/mnt/older/100/300/MSG_3.db\n" +
+		"4:/mnt/old/100/300/MSG_4.db\n"
+	if err := os.WriteFile(indexFilename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := u.RebaseIndex("/mnt/old/", "/mnt/new")
+	assert.Nil(t, err)
+
+	entries, err := NewIndexReader(indexFilename).List()
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, "/mnt/new/100/300/MSG_1.db", entries[0].ArchiveName)
+		assert.Equal(t, int64(4), entries[0].Size)
+		assert.Equal(t, "/mnt/other/100/300/MSG_2.db", entries[1].ArchiveName)
+		assert.Equal(t, "/mnt/older/100/300/MSG_3.db", entries[2].ArchiveName)
+		assert.Equal(t, "/mnt/new/100/300/MSG_4.db", entries[3].ArchiveName)
+	}
+
+	data, err := os.ReadFile(indexFilename)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "# comment\n")
+
+	// no temp file is left behind
+	_, err = os.Stat(indexFilename + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRebaseIndexKeepsPartialLine(t *testing.T) {
+	u := newTestUploader(t)
+
+	dir := path.Join(u.datastore, "100/300")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	indexFilename := path.Join(dir, DefaultArchiveIndex)
+	if err := os.WriteFile(indexFilename, []byte("1:/mnt/old/MSG_1.db\n2:/mnt/old/MS"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := u.RebaseIndex("/mnt/old", "/mnt/new")
+	assert.Nil(t, err)
+
+	data, err := os.ReadFile(indexFilename)
+	assert.Nil(t, err)
+	assert.Equal(t, "1:/mnt/new/MSG_1.db\n2:/mnt/old/MS", string(data))
+}
+
+func TestRebasePath(t *testing.T) {
+	assert.Equal(t, "/new", rebasePath("/old", "/old", "/new"))
+	assert.Equal(t, "/new/a/b", rebasePath("/old/a/b", "/old", "/new"))
+	assert.Equal(t, "/older/a", rebasePath("/older/a", "/old", "/new"))
+}
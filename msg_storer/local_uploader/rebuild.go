@@ -0,0 +1,178 @@
+package uploader
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var seqPattern = regexp.MustCompile(`^MSG_(\d+)`)
+
+// RebuildIndex walks the archivestore and indexes every archive file which is
+// not indexed yet, so it can be resumed after a cancellation.
+//
+// The sequence and the source file are taken from the extended attributes
+// when present. Otherwise the source is derived by mapping the archive path
+// back into the datastore, and the sequence from a MSG_<seq> base name or, as
+// a last resort, the base name itself. Host, date and subject token
+// partitions can't be mapped back without the attributes.
+func (u *Uploader) RebuildIndex(ctx context.Context) error {
+
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	// archive names already indexed, per index file
+	indexed := make(map[string]map[string]bool)
+
+	err := filepath.WalkDir(u.archivestore, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() || !d.Type().IsRegular() || skipRebuild(d.Name()) {
+			return nil
+		}
+
+		seq, filename := u.rebuildSource(p)
+		indexFilename := u.indexFilename(filename)
+
+		names, ok := indexed[indexFilename]
+		if !ok {
+			names, err = u.indexedArchiveNames(ctx, indexFilename)
+			if err != nil {
+				return err
+			}
+			indexed[indexFilename] = names
+		}
+
+		if names[p] {
+			return nil
+		}
+
+		entry, err := u.rebuildEntry(p, seq, filename)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(path.Dir(filename), 0750); err != nil {
+			return err
+		}
+
+		if err := u.appendEntry(filename, entry); err != nil {
+			return err
+		}
+		names[p] = true
+
+		u.logger.Debug("Rebuilt index entry",
+			zap.String("archiveName", p),
+			zap.String("seq", seq),
+		)
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// skipRebuild reports whether the file is a by-product of the uploader rather than an archive.
+func skipRebuild(name string) bool {
+
+	if strings.HasSuffix(name, MetadataSidecarSuffix) || strings.HasSuffix(name, ProvenanceSidecarSuffix) || name == DefaultCounterFile {
+		return true
+	}
+
+	ok, _ := path.Match(StagingPattern, name)
+
+	return ok
+}
+
+// rebuildSource returns the sequence and the source file of an archive file.
+func (u *Uploader) rebuildSource(archiveName string) (string, string) {
+
+	var seq, filename string
+	if u.xattrSeq != "" {
+		seq, _ = getXattr(archiveName, u.xattrSeq)
+	}
+	if u.xattrOrigin != "" {
+		filename, _ = getXattr(archiveName, u.xattrOrigin)
+	}
+
+	if filename == "" {
+		dir, base := path.Split(archiveName)
+		base = strings.TrimSuffix(base, compressionExt(u.compression))
+		base = strings.TrimSuffix(base, u.archiveSuffix)
+		base = strings.TrimPrefix(base, u.archivePrefix)
+
+		filename = strings.Replace(u.unflatten(dir+base, u.archivestore), path.Join(u.archivestore), path.Join(u.datastore), 1)
+	}
+
+	if seq == "" {
+		base := path.Base(filename)
+		if m := seqPattern.FindStringSubmatch(base); m != nil {
+			seq = m[1]
+		} else {
+			seq = strings.TrimSuffix(base, path.Ext(base))
+		}
+	}
+
+	return seq, filename
+}
+
+func (u *Uploader) rebuildEntry(archiveName string, seq string, filename string) (Entry, error) {
+
+	fi, err := os.Stat(archiveName)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		Seq:         seq,
+		ArchiveName: archiveName,
+		Filename:    filename,
+		Time:        fi.ModTime(),
+	}
+
+	if u.compression != CompressionNone && strings.HasSuffix(archiveName, compressionExt(u.compression)) {
+		entry.Compression = u.compression
+	} else {
+		entry.Size = fi.Size()
+	}
+
+	if u.checksumFile(filename) {
+		entry.Size, entry.Checksum, err = contentInfo(archiveName, entry.Compression)
+		if err != nil {
+			return Entry{}, err
+		}
+	}
+
+	return entry, nil
+}
+
+func (u *Uploader) indexedArchiveNames(ctx context.Context, indexFilename string) (map[string]bool, error) {
+
+	names := make(map[string]bool)
+
+	err := u.eachIndexEntry(ctx, indexFilename, func(entry Entry) error {
+		names[entry.ArchiveName] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
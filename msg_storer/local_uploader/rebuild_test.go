@@ -0,0 +1,122 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createArchiveFile(t *testing.T, u *Uploader, name string, content string) string {
+	archiveName := path.Join(u.archivestore, name)
+
+	if err := os.MkdirAll(path.Dir(archiveName), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(archiveName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return archiveName
+}
+
+func TestRebuildIndex(t *testing.T) {
+	u := newTestUploader(t)
+	u.checksum = true
+
+	a := createArchiveFile(t, u, "100/300/MSG_1.db", "one")
+	b := createArchiveFile(t, u, "100/300/MSG_2.db", "two")
+	c := createArchiveFile(t, u, "100/400/report.db", "three")
+	createArchiveFile(t, u, "100/300/MSG_1.db"+MetadataSidecarSuffix, "{}")
+	createArchiveFile(t, u, ".staging-123", "partial")
+
+	err := u.RebuildIndex(context.Background())
+	assert.Nil(t, err)
+
+	reader := NewIndexReader(path.Join(u.datastore, "100/300", DefaultArchiveIndex))
+	entries, err := reader.List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+
+	entry, err := reader.Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, a, entry.ArchiveName)
+	assert.Equal(t, path.Join(u.datastore, "100/300/MSG_1.db"), entry.Filename)
+	assert.Equal(t, int64(3), entry.Size)
+	assert.NotEmpty(t, entry.Checksum)
+
+	entry, err = reader.Lookup("2")
+	assert.Nil(t, err)
+	assert.Equal(t, b, entry.ArchiveName)
+
+	// without a MSG_<seq> name the base name is the sequence
+	entry, err = NewIndexReader(path.Join(u.datastore, "100/400", DefaultArchiveIndex)).Lookup("report")
+	assert.Nil(t, err)
+	assert.Equal(t, c, entry.ArchiveName)
+
+	report, err := u.Validate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.Checked)
+	assert.Len(t, report.Missing, 0)
+	assert.Len(t, report.Corrupt, 0)
+}
+
+func TestRebuildIndexResume(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "one")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	createArchiveFile(t, u, "100/300/MSG_2.db", "two")
+
+	// already indexed files are skipped
+	for i := 0; i < 2; i++ {
+		err = u.RebuildIndex(context.Background())
+		assert.Nil(t, err)
+	}
+
+	entries, err := NewIndexReader(u.indexFilename(filename)).List()
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "1", entries[0].Seq)
+		assert.Equal(t, "2", entries[1].Seq)
+	}
+}
+
+func TestRebuildIndexCanceled(t *testing.T) {
+	u := newTestUploader(t)
+	createArchiveFile(t, u, "100/300/MSG_1.db", "one")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := u.RebuildIndex(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRebuildIndexCompressed(t *testing.T) {
+	u := newTestUploader(t)
+	u.compression = CompressionGzip
+	u.archiveSuffix = ".archived"
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "one")
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	// drop the index and rebuild it
+	err = os.Remove(u.indexFilename(filename))
+	assert.Nil(t, err)
+
+	err = u.RebuildIndex(context.Background())
+	assert.Nil(t, err)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+	assert.Equal(t, filename, entry.Filename)
+	assert.Equal(t, CompressionGzip, entry.Compression)
+}
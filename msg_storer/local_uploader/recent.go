@@ -0,0 +1,66 @@
+package uploader
+
+import (
+	"sync"
+)
+
+const (
+	DefaultRecentArchives = 100
+)
+
+// recentBuffer is a fixed size ring buffer of the latest archived entries.
+type recentBuffer struct {
+	mutex   sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+func newRecentBuffer(size int) *recentBuffer {
+	return &recentBuffer{
+		entries: make([]Entry, size),
+	}
+}
+
+func (b *recentBuffer) add(entry Entry) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.entries) == 0 {
+		return
+	}
+
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// snapshot returns the entries from the oldest to the newest.
+func (b *recentBuffer) snapshot() []Entry {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.full {
+		return append([]Entry{}, b.entries[:b.next]...)
+	}
+
+	result := make([]Entry, 0, len(b.entries))
+	result = append(result, b.entries[b.next:]...)
+	result = append(result, b.entries[:b.next]...)
+
+	return result
+}
+
+// RecentArchives returns a snapshot of the latest archived entries, oldest first.
+func (u *Uploader) RecentArchives() []Entry {
+
+	if u.recent == nil {
+		return []Entry{}
+	}
+
+	return u.recent.snapshot()
+}
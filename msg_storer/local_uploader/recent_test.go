@@ -0,0 +1,66 @@
+package uploader
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentArchives(t *testing.T) {
+	u := newTestUploader(t)
+	u.recent = newRecentBuffer(3)
+
+	assert.Len(t, u.RecentArchives(), 0)
+
+	for i := 1; i <= 5; i++ {
+		filename := createTestFile(t, u, fmt.Sprintf("100/100/MSG_%d.db", i), "recent")
+		_, err := u.Archive(fmt.Sprintf("%d", i), filename)
+		assert.Nil(t, err)
+	}
+
+	recent := u.RecentArchives()
+	assert.Len(t, recent, 3)
+	for i, entry := range recent {
+		assert.Equal(t, fmt.Sprintf("%d", i+3), entry.Seq)
+		assert.Equal(t, int64(6), entry.Size)
+		assert.False(t, entry.Time.IsZero())
+	}
+
+	// snapshot is a copy
+	recent[0].Seq = "changed"
+	assert.Equal(t, "3", u.RecentArchives()[0].Seq)
+}
+
+func TestRecentBufferConcurrent(t *testing.T) {
+	b := newRecentBuffer(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b.add(Entry{Seq: fmt.Sprintf("%d", i)})
+			b.snapshot()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, b.snapshot(), 10)
+}
+
+func TestValidateConfigRecentArchives(t *testing.T) {
+	root := t.TempDir()
+	scope := "validate_config_recent"
+
+	viper.Set(scope+".datastore", path.Join(root, "datastore"))
+	viper.Set(scope+".archivestore", path.Join(root, "archivestore"))
+	viper.Set(scope+".recent_archives", -1)
+	defer viper.Set(scope, nil)
+
+	err := ValidateConfig(scope)
+	assert.ErrorContains(t, err, "invalid recent_archives: -1")
+}
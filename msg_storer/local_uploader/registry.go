@@ -0,0 +1,61 @@
+package uploader
+
+import (
+	"sort"
+	"sync"
+)
+
+// UploaderInfo describes an active uploader.
+type UploaderInfo struct {
+	Scope   string
+	Subject string
+	Stats   Stats
+}
+
+var registry = struct {
+	mutex     sync.Mutex
+	uploaders map[*Uploader]struct{}
+}{
+	uploaders: make(map[*Uploader]struct{}),
+}
+
+func (u *Uploader) register() {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.uploaders[u] = struct{}{}
+}
+
+func (u *Uploader) deregister() {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	delete(registry.uploaders, u)
+}
+
+// ActiveUploaders returns the uploaders of all started modules of the process,
+// sorted by scope.
+func ActiveUploaders() []UploaderInfo {
+
+	registry.mutex.Lock()
+	infos := make([]UploaderInfo, 0, len(registry.uploaders))
+	for u := range registry.uploaders {
+		infos = append(infos, u.Info())
+	}
+	registry.mutex.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Scope < infos[j].Scope
+	})
+
+	return infos
+}
+
+// Info returns the scope, subject and stats of the uploader.
+func (u *Uploader) Info() UploaderInfo {
+	return UploaderInfo{
+		Scope:   u.scope,
+		Subject: u.subject(),
+		Stats:   u.Stats(),
+	}
+}
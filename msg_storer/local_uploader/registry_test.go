@@ -0,0 +1,59 @@
+package uploader
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveUploaders(t *testing.T) {
+	a := newTestUploader(t)
+	a.scope = "uploader_a"
+	b := newTestUploader(t)
+	b.scope = "uploader_b"
+	b.Pause()
+
+	a.register()
+	b.register()
+
+	infos := ActiveUploaders()
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "uploader_a", infos[0].Scope)
+		assert.Equal(t, fmt.Sprintf(DefaultSubject, DefaultDomain, "test"), infos[0].Subject)
+		assert.False(t, infos[0].Stats.Paused)
+		assert.Equal(t, "uploader_b", infos[1].Scope)
+		assert.True(t, infos[1].Stats.Paused)
+	}
+
+	// a stopped uploader is removed
+	a.deregister()
+
+	infos = ActiveUploaders()
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "uploader_b", infos[0].Scope)
+	}
+
+	b.deregister()
+	assert.Len(t, ActiveUploaders(), 0)
+}
+
+func TestActiveUploadersConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		u := newTestUploader(t)
+		u.scope = fmt.Sprintf("uploader_%d", i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.register()
+			ActiveUploaders()
+			u.deregister()
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, ActiveUploaders(), 0)
+}
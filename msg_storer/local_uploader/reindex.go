@@ -0,0 +1,75 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ReindexAppend              = "append"
+	ReindexRejectIfChanged     = "reject_if_changed"
+	ReindexReplaceAndRemoveOld = "replace_and_remove_old"
+
+	DefaultReindexPolicy = ReindexAppend
+)
+
+var (
+	ErrReindexChanged = errors.New("Sequence is already indexed with a different archive name.")
+)
+
+// checkReindex returns the existing entry of the sequence if it points at a
+// different archive file, and rejects it when the policy says so.
+func (u *Uploader) checkReindex(filename string, seq string, archiveName string) (*Entry, error) {
+
+	if u.reindexPolicy == ReindexAppend {
+		return nil, nil
+	}
+
+	entry, err := u.indexReader(filename).Lookup(seq)
+	if err != nil {
+		if errors.Is(err, ErrEntryNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if entry.ArchiveName == archiveName {
+		return nil, nil
+	}
+
+	if u.reindexPolicy == ReindexRejectIfChanged {
+		return nil, fmt.Errorf("%w (seq: %s, indexed: %s, new: %s)", ErrReindexChanged, seq, entry.ArchiveName, archiveName)
+	}
+
+	return &entry, nil
+}
+
+// replaceEntry drops the old entries of the sequence and removes the old archive file.
+func (u *Uploader) replaceEntry(filename string, old *Entry, archiveName string) error {
+
+	err := rewriteIndex(u.indexFilename(filename), func(entry Entry) (Entry, bool) {
+		return entry, entry.Seq != old.Seq || entry.ArchiveName == archiveName
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := u.clearImmutable(old.ArchiveName); err != nil {
+		return err
+	}
+
+	if err := os.Remove(old.ArchiveName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	u.logger.Info("Replaced archive of sequence",
+		zap.String("seq", old.Seq),
+		zap.String("oldArchiveName", old.ArchiveName),
+		zap.String("archiveName", archiveName),
+	)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// prepareReindex archives seq 1 under one name and returns a new source for
+// the same sequence which translates to a different archive name.
+func prepareReindex(t *testing.T, u *Uploader) (string, string) {
+	first := createTestFile(t, u, "100/100/MSG_1.db", "first")
+
+	oldArchive, err := u.Archive("1", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := createTestFile(t, u, "100/100/MSG_1_again.db", "second")
+
+	return oldArchive, second
+}
+
+func TestReindexAppend(t *testing.T) {
+	u := newTestUploader(t)
+	u.reindexPolicy = ReindexAppend
+	oldArchive, second := prepareReindex(t, u)
+
+	newArchive, err := u.Archive("1", second)
+	assert.Nil(t, err)
+	assert.FileExists(t, oldArchive)
+	assert.FileExists(t, newArchive)
+
+	entries, err := NewIndexReader(u.indexFilename(second)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestReindexRejectIfChanged(t *testing.T) {
+	u := newTestUploader(t)
+	u.reindexPolicy = ReindexRejectIfChanged
+	oldArchive, second := prepareReindex(t, u)
+
+	_, err := u.Archive("1", second)
+	assert.ErrorIs(t, err, ErrReindexChanged)
+	assert.True(t, isPermanent(err))
+	assert.FileExists(t, oldArchive)
+	assert.FileExists(t, second)
+
+	// same archive name is not a change
+	err = os.WriteFile(path.Join(u.datastore, "100/100/MSG_1.db"), []byte("again"), 0644)
+	assert.Nil(t, err)
+	_, err = u.Archive("1", path.Join(u.datastore, "100/100/MSG_1.db"))
+	assert.Nil(t, err)
+}
+
+func TestReindexReplaceAndRemoveOld(t *testing.T) {
+	u := newTestUploader(t)
+	u.reindexPolicy = ReindexReplaceAndRemoveOld
+	oldArchive, second := prepareReindex(t, u)
+
+	newArchive, err := u.Archive("1", second)
+	assert.Nil(t, err)
+	assert.NoFileExists(t, oldArchive)
+	assert.FileExists(t, newArchive)
+
+	entries, err := NewIndexReader(u.indexFilename(second)).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, newArchive, entries[0].ArchiveName)
+}
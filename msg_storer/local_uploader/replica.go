@@ -0,0 +1,83 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultReadOnly = false
+)
+
+var (
+	ErrReadOnlyReplica = errors.New("Uploader is a read-only replica.")
+)
+
+// replicaIgnoredConfigs are the configs of archiving which a read-only replica
+// ignores, with their defaults.
+var replicaIgnoredConfigs = []struct {
+	key   string
+	value interface{}
+}{
+	{"workers", DefaultWorkers},
+	{"ack_mode", DefaultAckMode},
+	{"control_subject", DefaultControlSubject},
+	{"completion_subject", DefaultCompletionSubject},
+	{"heartbeat_subject", DefaultHeartbeatSubject},
+	{"create_stream_if_missing", false},
+	{"archive_counter", DefaultArchiveCounter},
+	{"journal_dir", DefaultJournalDir},
+	{"source_trash_dir", DefaultSourceTrashDir},
+	{"cold_archivestore", DefaultColdArchivestore},
+	{"max_store_bytes", DefaultMaxStoreBytes},
+	{"maintenance_interval", DefaultMaintenanceInterval},
+	{"verify_interval", DefaultVerifyInterval},
+	{"audit_interval", DefaultAuditInterval},
+	{"consumer_pending_interval", DefaultConsumerPendingInterval},
+	{"url_mode", DefaultURLMode},
+}
+
+// startReplica starts a read-only replica, which serves lookups and restores
+// from the shared index and archivestore but never subscribes to the stream
+// nor writes the archivestore or the index.
+func (u *Uploader) startReplica() error {
+
+	// IsSet is true for the defaults as well
+	for _, config := range replicaIgnoredConfigs {
+		key := u.getConfigPath(config.key)
+		if viper.InConfig(key) || fmt.Sprint(viper.Get(key)) != fmt.Sprint(config.value) {
+			u.logger.Warn("Ignoring config of a read-only replica",
+				zap.String("config", config.key),
+			)
+		}
+	}
+
+	if err := u.openIndexBackend(); err != nil {
+		return err
+	}
+
+	if err := u.startGRPCServer(); err != nil {
+		return err
+	}
+
+	u.logger.Info("Started read-only replica",
+		zap.String("datastore", u.datastore),
+		zap.String("archivestore", u.archivestore),
+	)
+
+	return nil
+}
+
+// checkWritable rejects the calls writing the archivestore or the index on a
+// read-only replica.
+func (u *Uploader) checkWritable() error {
+
+	if u.readOnly {
+		return ErrReadOnlyReplica
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestReadOnlyReplica(t *testing.T) {
+
+	// archive with a writer sharing the stores
+	w := newTestUploader(t)
+	filename := createTestFile(t, w, "100/300/MSG_1.db", "data")
+	archiveName, err := w.Archive("1", filename)
+	assert.Nil(t, err)
+
+	viper.Set("replica_test.read_only", true)
+	viper.Set("replica_test.datastore", w.datastore)
+	viper.Set("replica_test.archivestore", w.archivestore)
+	viper.Set("replica_test.completion_subject", "uploader.completed")
+	defer viper.Set("replica_test", nil)
+
+	// started without a NATS connection, so it can't subscribe
+	core, logs := observer.New(zap.WarnLevel)
+	u := &Uploader{logger: zap.New(core), scope: "replica_test"}
+	u.initDefaultConfigs()
+	assert.Nil(t, u.onStart(context.Background()))
+	assert.Nil(t, u.sub)
+
+	ignored := logs.FilterMessage("Ignoring config of a read-only replica").All()
+	if assert.Len(t, ignored, 1) {
+		assert.Equal(t, "completion_subject", ignored[0].ContextMap()["config"])
+	}
+
+	entry, err := u.Lookup("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+
+	// nothing is archived or written
+	other := createTestFile(t, w, "100/300/MSG_2.db", "data")
+	_, err = u.Archive("2", other)
+	assert.ErrorIs(t, err, ErrReadOnlyReplica)
+	assert.FileExists(t, other)
+
+	_, err = u.RotateIndex(u.indexFilename(filename))
+	assert.ErrorIs(t, err, ErrReadOnlyReplica)
+	assert.ErrorIs(t, u.RebuildIndex(context.Background()), ErrReadOnlyReplica)
+
+	restored, err := u.Restore("1", filename)
+	assert.Nil(t, err)
+	data, err := os.ReadFile(restored)
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+
+	assert.Nil(t, u.onStop(context.Background()))
+}
+
+func TestReadOnlyReplicaDefaultConfig(t *testing.T) {
+	root := t.TempDir()
+
+	viper.Set("replica_default_test.read_only", true)
+	viper.Set("replica_default_test.datastore", path.Join(root, "datastore"))
+	viper.Set("replica_default_test.archivestore", path.Join(root, "archivestore"))
+	defer viper.Set("replica_default_test", nil)
+
+	core, logs := observer.New(zap.WarnLevel)
+	u := &Uploader{logger: zap.New(core), scope: "replica_default_test"}
+	u.initDefaultConfigs()
+	assert.Nil(t, u.onStart(context.Background()))
+
+	assert.Zero(t, logs.FilterMessage("Ignoring config of a read-only replica").Len())
+
+	assert.Nil(t, u.onStop(context.Background()))
+}
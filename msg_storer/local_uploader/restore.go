@@ -0,0 +1,111 @@
+package uploader
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRestoreExists  = errors.New("Restore destination already exists.")
+	ErrRestoreEvicted = errors.New("Archive file was evicted.")
+)
+
+// Restore copies the archive file of the sequence back to the datastore path
+// recorded in its index entry, decompressing it. An existing file is never
+// overwritten.
+func (u *Uploader) Restore(seq string, filename string) (string, error) {
+
+	entry, err := u.Lookup(seq, filename)
+	if err != nil {
+		return "", err
+	}
+
+	if !entry.Evicted.IsZero() {
+		return "", fmt.Errorf("%w (archiveName: %s)", ErrRestoreEvicted, entry.ArchiveName)
+	}
+
+	dest := u.restoreFilename(filename, entry)
+
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		return "", fmt.Errorf("%w (%s)", ErrRestoreExists, dest)
+	}
+
+	if err := os.MkdirAll(path.Dir(dest), 0750); err != nil {
+		return "", err
+	}
+
+	if err := restoreFile(entry.ArchiveName, entry.Compression, dest); err != nil {
+		return "", err
+	}
+
+	u.logger.Info("Restored archive file",
+		zap.String("seq", entry.Seq),
+		zap.String("archiveName", entry.ArchiveName),
+		zap.String("fileName", dest),
+	)
+
+	return dest, nil
+}
+
+// restoreFilename returns the original path of the archived file, mapping
+// the archive name back into the datastore for entries without it.
+func (u *Uploader) restoreFilename(filename string, entry Entry) string {
+
+	switch {
+	case entry.RawFilename != "":
+		return cleanFilename(entry.RawFilename)
+	case entry.Filename != "":
+		return entry.Filename
+	}
+
+	_, original := u.rebuildSource(entry.ArchiveName)
+	if original == entry.ArchiveName {
+		return cleanFilename(filename)
+	}
+
+	return original
+}
+
+func restoreFile(archiveName string, compression string, dest string) error {
+
+	fr, err := os.Open(archiveName)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+
+	var r io.Reader = fr
+	if compression == CompressionGzip {
+		zr, err := gzip.NewReader(fr)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tmpName := dest + ".tmp"
+	fw, err := os.OpenFile(tmpName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(fw, r); err != nil {
+		fw.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := fw.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, dest)
+}
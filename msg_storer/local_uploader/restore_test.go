@@ -0,0 +1,34 @@
+package uploader
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestore(t *testing.T) {
+	u := newTestUploader(t)
+	u.compression = CompressionGzip
+
+	filename := createTestFile(t, u, "100/200/MSG_1.db", "restore")
+
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.NoFileExists(t, filename)
+
+	restored, err := u.Restore("1", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, filename, restored)
+
+	data, err := os.ReadFile(restored)
+	assert.Nil(t, err)
+	assert.Equal(t, "restore", string(data))
+
+	// never overwritten
+	_, err = u.Restore("1", filename)
+	assert.ErrorIs(t, err, ErrRestoreExists)
+
+	_, err = u.Restore("2", filename)
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+}
@@ -0,0 +1,112 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	StreamRetentionCheckOff   = "off"
+	StreamRetentionCheckWarn  = "warn"
+	StreamRetentionCheckError = "error"
+
+	DefaultStreamRetentionCheck = StreamRetentionCheckWarn
+)
+
+var (
+	ErrStreamRetention = errors.New("Stream may discard jobs before they are archived.")
+)
+
+func validateStreamRetentionCheck(check string) error {
+
+	switch check {
+	case StreamRetentionCheckOff, StreamRetentionCheckWarn, StreamRetentionCheckError:
+		return nil
+	}
+
+	return fmt.Errorf("invalid stream_retention_check: %s", check)
+}
+
+// retentionRisks returns why the stream may remove jobs which aren't acked
+// yet. With the discard old policy every limit makes the stream drop the
+// oldest jobs once more are pending, and the max age applies to any policy.
+func (u *Uploader) retentionRisks(cfg *nats.StreamConfig) []string {
+
+	risks := make([]string, 0)
+
+	if cfg.MaxAge > 0 {
+		risks = append(risks, fmt.Sprintf("max_age %s removes jobs not archived in time", cfg.MaxAge))
+	}
+
+	if cfg.Discard != nats.DiscardOld {
+		return risks
+	}
+
+	if cfg.MaxMsgs > 0 {
+		risk := fmt.Sprintf("max_msgs %d with discard old drops the oldest pending jobs", cfg.MaxMsgs)
+
+		// jobs buffered by the workers are delivered but not acked yet
+		if buffered := int64(u.workers * (u.workerQueueSize + 1)); u.workers > 1 && cfg.MaxMsgs < buffered {
+			risk += fmt.Sprintf(", below the %d jobs buffered by %d workers", buffered, u.workers)
+		}
+
+		risks = append(risks, risk)
+	}
+
+	if cfg.MaxBytes > 0 {
+		risks = append(risks, fmt.Sprintf("max_bytes %d with discard old drops the oldest pending jobs", cfg.MaxBytes))
+	}
+
+	if cfg.MaxMsgsPerSubject > 0 {
+		risks = append(risks, fmt.Sprintf("max_msgs_per_subject %d with discard old drops the oldest pending jobs of the uploader subject", cfg.MaxMsgsPerSubject))
+	}
+
+	return risks
+}
+
+// checkStreamRetention inspects the stream bound to the subject, and warns or
+// fails per stream_retention_check when it may discard unacked jobs.
+func (u *Uploader) checkStreamRetention(js nats.JetStreamContext, subject string) error {
+
+	if u.streamRetentionCheck == StreamRetentionCheckOff || u.streamRetentionCheck == "" {
+		return nil
+	}
+
+	info, err := u.streamInfoBySubject(js, subject)
+	if err != nil {
+		// the stream may be created after the uploader started
+		u.logger.Warn("Failed to check the stream retention",
+			zap.String("subject", subject),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	risks := u.retentionRisks(&info.Config)
+	if len(risks) == 0 {
+		return nil
+	}
+
+	err = fmt.Errorf("%w (stream: %s): %s", ErrStreamRetention, info.Config.Name, strings.Join(risks, "; "))
+	if u.streamRetentionCheck == StreamRetentionCheckError {
+		return err
+	}
+
+	u.logger.Warn(err.Error())
+
+	return nil
+}
+
+func (u *Uploader) streamInfoBySubject(js nats.JetStreamContext, subject string) (*nats.StreamInfo, error) {
+
+	stream, err := u.boundStream(js, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return js.StreamInfo(stream)
+}
@@ -0,0 +1,70 @@
+package uploader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionRisks(t *testing.T) {
+	u := newTestUploader(t)
+	u.workers = 4
+	u.workerQueueSize = 2
+
+	assert.Empty(t, u.retentionRisks(&nats.StreamConfig{
+		MaxMsgs: 10,
+		Discard: nats.DiscardNew,
+	}))
+
+	risks := u.retentionRisks(&nats.StreamConfig{
+		MaxAge:  time.Hour,
+		Discard: nats.DiscardNew,
+	})
+	assert.Len(t, risks, 1)
+	assert.Contains(t, risks[0], "max_age")
+
+	risks = u.retentionRisks(&nats.StreamConfig{
+		MaxMsgs: 10,
+		Discard: nats.DiscardOld,
+	})
+	assert.Len(t, risks, 1)
+	assert.Contains(t, risks[0], "below the 12 jobs buffered by 4 workers")
+}
+
+func TestValidateStreamRetentionCheck(t *testing.T) {
+	assert.Nil(t, validateStreamRetentionCheck(StreamRetentionCheckWarn))
+	assert.NotNil(t, validateStreamRetentionCheck("strict"))
+}
+
+func (s *TestSuite) TestStreamRetention() {
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "uploader_retention",
+		Subjects: []string{"uploader-retention.>"},
+		Storage:  nats.MemoryStorage,
+		MaxMsgs:  10,
+		Discard:  nats.DiscardOld,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	u.streamRetentionCheck = StreamRetentionCheckError
+	s.ErrorIs(u.checkStreamRetention(js, "uploader-retention.jobs"), ErrStreamRetention)
+
+	u.streamRetentionCheck = StreamRetentionCheckWarn
+	s.Nil(u.checkStreamRetention(js, "uploader-retention.jobs"))
+
+	u.streamRetentionCheck = StreamRetentionCheckOff
+	s.Nil(u.checkStreamRetention(js, "uploader-retention.jobs"))
+
+	// a missing stream only warns
+	u.streamRetentionCheck = StreamRetentionCheckError
+	s.Nil(u.checkStreamRetention(js, "uploader-retention-missing.jobs"))
+}
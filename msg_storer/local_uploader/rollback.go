@@ -0,0 +1,90 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultNoSpaceNakDelay = 30 * time.Second
+)
+
+var (
+	ErrArchiveNoSpace = errors.New("Archivestore is out of space, the move was rolled back.")
+)
+
+// checkMoved verifies the size of the archive file against the source after
+// the move, since a volume filling up may only surface as a short file.
+// Compressed archives are checked by verify_mode before the rename.
+func (u *Uploader) checkMoved(archiveName string, size int64) error {
+
+	if u.compression != CompressionNone {
+		return nil
+	}
+
+	fi, err := u.fileSystem().Stat(archiveName)
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() != size {
+		return fmt.Errorf("archive file is short (%s: %d of %d bytes): %w", archiveName, fi.Size(), size, io.ErrShortWrite)
+	}
+
+	return nil
+}
+
+// rollbackMove puts the source back when the move ran out of space after the
+// archive file showed up: the archive is moved back when the source is gone,
+// or removed when the source is still there and the archive didn't replace
+// an older one. The uploader is marked low on space until an archive
+// succeeds again.
+func (u *Uploader) rollbackMove(filename string, archiveName string, replaced bool, cause error) error {
+
+	if !isNoSpace(cause) && !errors.Is(cause, io.ErrShortWrite) {
+		return cause
+	}
+
+	fsys := u.fileSystem()
+
+	if !u.lowSpace.Swap(true) {
+		u.logger.Error("Archivestore is low on space",
+			zap.String("archivestore", u.archivestore),
+			zap.Error(cause),
+		)
+	}
+
+	var err error
+	if _, serr := fsys.Stat(filename); os.IsNotExist(serr) {
+		err = fsys.Rename(archiveName, filename)
+	} else if !replaced {
+		err = fsys.Remove(archiveName)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		u.logger.Error("Failed to roll back the archive file",
+			zap.String("fileName", filename),
+			zap.String("archiveName", archiveName),
+			zap.Error(err),
+		)
+		return fmt.Errorf("%w: %w", cause, err)
+	}
+
+	u.counters.rollbacks.Add(1)
+
+	u.logger.Warn("Rolled back the archive file to the source",
+		zap.String("fileName", filename),
+		zap.String("archiveName", archiveName),
+	)
+
+	return fmt.Errorf("%w (%s): %w", ErrArchiveNoSpace, filename, cause)
+}
+
+// LowSpace reports whether the last move ran out of space in the archivestore.
+func (u *Uploader) LowSpace() bool {
+	return u.lowSpace.Load()
+}
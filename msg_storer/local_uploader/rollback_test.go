@@ -0,0 +1,104 @@
+package uploader
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fullFileSystem fails to sync the directories below full with ENOSPC, as a
+// volume filling up between the rename and the sync.
+type fullFileSystem struct {
+	osFileSystem
+	full string
+}
+
+type fullDir struct {
+	File
+}
+
+func (f *fullFileSystem) Open(name string) (File, error) {
+	file, err := f.osFileSystem.Open(name)
+	if err != nil || !strings.HasPrefix(name, f.full) {
+		return file, err
+	}
+	return &fullDir{File: file}, nil
+}
+
+func (d *fullDir) Sync() error {
+	return &fs.PathError{Op: "sync", Path: d.Name(), Err: syscall.ENOSPC}
+}
+
+// shortFileSystem reports the files below short one byte shorter, as a copy
+// cut by a full volume.
+type shortFileSystem struct {
+	osFileSystem
+	short string
+}
+
+type shortFileInfo struct {
+	fs.FileInfo
+}
+
+func (fi shortFileInfo) Size() int64 {
+	return fi.FileInfo.Size() - 1
+}
+
+func (f *shortFileSystem) Stat(name string) (fs.FileInfo, error) {
+	fi, err := f.osFileSystem.Stat(name)
+	if err != nil || !strings.HasPrefix(name, f.short) {
+		return fi, err
+	}
+	return shortFileInfo{FileInfo: fi}, nil
+}
+
+func TestRollbackMoveNoSpace(t *testing.T) {
+	u := newTestUploader(t)
+	u.fsyncDir = true
+	u.fs = &fullFileSystem{full: u.archivestore}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+	archiveName, err := u.ArchivePathFor(filename)
+	assert.Nil(t, err)
+
+	_, err = u.Archive("1", filename)
+	assert.ErrorIs(t, err, ErrArchiveNoSpace)
+	assert.True(t, errors.Is(err, syscall.ENOSPC))
+	assert.False(t, isPermanent(err))
+
+	// the source is back and nothing is archived nor indexed
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.NoFileExists(t, archiveName)
+
+	_, err = u.indexReader(filename).Lookup("1")
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+
+	stats := u.Stats()
+	assert.True(t, stats.LowSpace)
+	assert.Equal(t, uint64(1), stats.Rollbacks)
+
+	// space is back
+	u.fs = nil
+	_, err = u.Archive("1", filename)
+	assert.Nil(t, err)
+	assert.False(t, u.LowSpace())
+}
+
+func TestRollbackMoveShort(t *testing.T) {
+	u := newTestUploader(t)
+	u.fs = &shortFileSystem{short: u.archivestore}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	_, err := u.Archive("1", filename)
+	assert.ErrorIs(t, err, ErrArchiveNoSpace)
+	assert.FileExists(t, filename)
+	assert.True(t, u.LowSpace())
+}
@@ -0,0 +1,79 @@
+package uploader
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRollupFile = "archive.rollup.json"
+)
+
+// Rollup holds the totals of the archives recorded in the index of a
+// directory, so they can be read without scanning the index. Evicted or
+// replaced archive files aren't subtracted.
+type Rollup struct {
+	Files   int64     `json:"files"`
+	Bytes   int64     `json:"bytes"`
+	Updated time.Time `json:"updated"`
+}
+
+// ReadRollup returns the totals of the directory, zero when nothing was archived from it yet.
+func ReadRollup(dir string) (Rollup, error) {
+
+	var rollup Rollup
+
+	data, err := os.ReadFile(path.Join(dir, DefaultRollupFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rollup, nil
+		}
+		return rollup, err
+	}
+
+	if err := json.Unmarshal(data, &rollup); err != nil {
+		return rollup, err
+	}
+
+	return rollup, nil
+}
+
+// updateRollup adds the entry to the totals next to the index, right after
+// it was appended under the index lock. The index is the record of truth,
+// so a failure is only logged.
+func (u *Uploader) updateRollup(indexFilename string, entry Entry) {
+
+	if !u.rollupStats {
+		return
+	}
+
+	dir := path.Dir(indexFilename)
+
+	err := func() error {
+		rollup, err := ReadRollup(dir)
+		if err != nil {
+			return err
+		}
+
+		rollup.Files++
+		rollup.Bytes += entry.Size
+		rollup.Updated = time.Now()
+
+		data, err := json.Marshal(rollup)
+		if err != nil {
+			return err
+		}
+
+		return writeFileAtomic(path.Join(dir, DefaultRollupFile), data)
+	}()
+	if err != nil {
+		u.logger.Error("Failed to update rollup stats",
+			zap.String("dir", dir),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,64 @@
+package uploader
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollup(t *testing.T) {
+	u := newTestUploader(t)
+	u.rollupStats = true
+
+	var size int64
+	for i := 1; i <= 3; i++ {
+		content := strings.Repeat("x", i*10)
+		filename := createTestFile(t, u, fmt.Sprintf("100/100/MSG_%d.db", i), content)
+		_, err := u.Archive(fmt.Sprint(i), filename)
+		assert.Nil(t, err)
+		size += int64(len(content))
+	}
+
+	// another directory has its own totals
+	filename := createTestFile(t, u, "100/200/MSG_4.db", "other")
+	_, err := u.Archive("4", filename)
+	assert.Nil(t, err)
+
+	dir := path.Join(u.datastore, "100/100")
+	rollup, err := ReadRollup(dir)
+	assert.Nil(t, err)
+
+	entries, err := NewIndexReader(path.Join(dir, DefaultArchiveIndex)).List()
+	assert.Nil(t, err)
+
+	var indexed int64
+	for _, entry := range entries {
+		indexed += entry.Size
+	}
+
+	assert.Equal(t, int64(len(entries)), rollup.Files)
+	assert.Equal(t, int64(3), rollup.Files)
+	assert.Equal(t, size, rollup.Bytes)
+	assert.Equal(t, indexed, rollup.Bytes)
+	assert.False(t, rollup.Updated.IsZero())
+
+	rollup, err = ReadRollup(path.Join(u.datastore, "100/200"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), rollup.Files)
+	assert.Equal(t, int64(5), rollup.Bytes)
+}
+
+func TestRollupDisabled(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	rollup, err := ReadRollup(path.Join(u.datastore, "100/100"))
+	assert.Nil(t, err)
+	assert.Equal(t, Rollup{}, rollup)
+}
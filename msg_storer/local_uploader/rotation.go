@@ -0,0 +1,183 @@
+package uploader
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rotatedIndexPattern returns the glob pattern matching the rotated shards of an index.
+func rotatedIndexPattern(indexFilename string) string {
+	return indexFilename + ".*.gz"
+}
+
+// rotatedIndexFiles returns the rotated shards of an index from the oldest to the newest.
+func rotatedIndexFiles(indexFilename string) ([]string, error) {
+
+	files, err := filepath.Glob(rotatedIndexPattern(indexFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// rotateIndexIfNeeded rotates the index when it exceeds the configured size.
+func (u *Uploader) rotateIndexIfNeeded(indexFilename string) error {
+
+	if u.indexMaxSize <= 0 {
+		return nil
+	}
+
+	fi, err := os.Stat(indexFilename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if fi.Size() < u.indexMaxSize {
+		return nil
+	}
+
+	_, err = u.rotateIndex(indexFilename)
+
+	return err
+}
+
+// RotateIndex rotates the index file into a timestamped shard, e.g. after a
+// backup, and starts a fresh empty index. It returns the path of the shard.
+// Archives into the directory of the index wait for the rotation, so no entry
+// is lost, and lookups keep reading the shard.
+func (u *Uploader) RotateIndex(indexFilename string) (string, error) {
+
+	if err := u.checkWritable(); err != nil {
+		return "", err
+	}
+
+	if u.sqlIndex != nil {
+		return "", fmt.Errorf("RotateIndex isn't supported by index_backend %s", u.indexBackend)
+	}
+
+	unlock := u.dirLocks.lock(indexFilename)
+	defer unlock()
+
+	rotatedName, err := u.rotateIndex(indexFilename)
+	if err != nil {
+		return "", err
+	}
+
+	indexFile, err := os.OpenFile(indexFilename, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return rotatedName, indexFile.Close()
+}
+
+// rotateIndex compresses the current index into a timestamped shard, so that
+// the next entry starts a fresh index.
+func (u *Uploader) rotateIndex(indexFilename string) (string, error) {
+
+	rotatedName := fmt.Sprintf("%s.%d", indexFilename, time.Now().UnixNano())
+
+	if err := os.Rename(indexFilename, rotatedName); err != nil {
+		return "", err
+	}
+
+	gzName, err := gzipFile(rotatedName)
+	if err != nil {
+		// only gzipped shards are read, so the entries are put back into the index
+		if _, serr := os.Stat(rotatedName + ".gz"); os.IsNotExist(serr) {
+			if rerr := os.Rename(rotatedName, indexFilename); rerr != nil {
+				return "", fmt.Errorf("%w: %w", err, rerr)
+			}
+		}
+		return "", err
+	}
+
+	u.logger.Info(fmt.Sprintf("rotated index %s to %s", indexFilename, gzName))
+
+	return gzName, nil
+}
+
+// gzipFile compresses the file into <filename>.gz and removes the original.
+func gzipFile(filename string) (string, error) {
+
+	src, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	gzName := filename + ".gz"
+	tmpName := gzName + ".tmp"
+
+	dst, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(tmpName)
+		return "", err
+	}
+
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmpName)
+		return "", err
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpName)
+		return "", err
+	}
+
+	if err := os.Rename(tmpName, gzName); err != nil {
+		return "", err
+	}
+
+	return gzName, os.Remove(filename)
+}
+
+// openIndexFile opens an index file, decompressing it when it is gzipped.
+func openIndexFile(filename string) (io.ReadCloser, error) {
+
+	fr, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(filename, ".gz") {
+		return fr, nil
+	}
+
+	zr, err := gzip.NewReader(fr)
+	if err != nil {
+		fr.Close()
+		return nil, err
+	}
+
+	return &gzipReadCloser{Reader: zr, file: fr}, nil
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
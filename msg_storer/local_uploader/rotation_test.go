@@ -0,0 +1,177 @@
+package uploader
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateIndex(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := path.Join(u.datastore, "100/100/MSG_1.db")
+	indexFilename := u.indexFilename(filename)
+	err := os.MkdirAll(path.Dir(filename), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// under the limit
+	err = u.updateIndex(filename, path.Join(u.archivestore, "100/100/MSG_1.db"), "1")
+	assert.Nil(t, err)
+
+	rotated, err := rotatedIndexFiles(indexFilename)
+	assert.Nil(t, err)
+	assert.Len(t, rotated, 0)
+
+	// rotate once the index holds more than two entries
+	fi, err := os.Stat(indexFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.indexMaxSize = fi.Size()*2 + 1
+
+	// exceed the limit
+	for i := 2; i <= 4; i++ {
+		err = u.updateIndex(filename, path.Join(u.archivestore, fmt.Sprintf("100/100/MSG_%d.db", i)), fmt.Sprintf("%d", i))
+		assert.Nil(t, err)
+	}
+
+	rotated, err = rotatedIndexFiles(indexFilename)
+	assert.Nil(t, err)
+	assert.Len(t, rotated, 1)
+	assert.True(t, strings.HasSuffix(rotated[0], ".gz"))
+
+	// current index only holds entries written after rotation
+	data, err := os.ReadFile(indexFilename)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "MSG_1.db")
+}
+
+func TestRotateIndexRestoredOnFailure(t *testing.T) {
+	u := newTestUploader(t)
+
+	// an index which can be renamed but not read
+	indexFilename := path.Join(u.datastore, "100/100", DefaultArchiveIndex)
+	err := os.MkdirAll(indexFilename, 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = u.rotateIndex(indexFilename)
+	assert.NotNil(t, err)
+
+	// no plain shard is left behind
+	assert.DirExists(t, indexFilename)
+	leftovers, err := filepath.Glob(indexFilename + ".*")
+	assert.Nil(t, err)
+	assert.Len(t, leftovers, 0)
+}
+
+func TestLookupAcrossRotatedIndex(t *testing.T) {
+	u := newTestUploader(t)
+	u.indexMaxSize = 1
+
+	filename := path.Join(u.datastore, "100/100/MSG_1.db")
+	indexFilename := u.indexFilename(filename)
+	err := os.MkdirAll(path.Dir(filename), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		err = u.updateIndex(filename, path.Join(u.archivestore, fmt.Sprintf("100/100/MSG_%d.db", i)), fmt.Sprintf("%d", i))
+		assert.Nil(t, err)
+	}
+
+	rotated, err := rotatedIndexFiles(indexFilename)
+	assert.Nil(t, err)
+	assert.Len(t, rotated, 2)
+
+	reader := NewIndexReader(indexFilename)
+
+	// gzipped shard
+	entry, err := reader.Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/100/MSG_1.db"), entry.ArchiveName)
+
+	// current index
+	entry, err = reader.Lookup("3")
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/100/MSG_3.db"), entry.ArchiveName)
+
+	entries, err := reader.List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+	for i, entry := range entries {
+		assert.Equal(t, fmt.Sprintf("%d", i+1), entry.Seq)
+	}
+}
+
+func TestRotateIndexConcurrent(t *testing.T) {
+	u := newTestUploader(t)
+
+	const count = 50
+	filenames := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		filenames = append(filenames, createTestFile(t, u, fmt.Sprintf("100/100/MSG_%d.db", i), "data"))
+	}
+	indexFilename := u.indexFilename(filenames[0])
+
+	var wg sync.WaitGroup
+	for i, filename := range filenames {
+		wg.Add(1)
+		go func(seq int, filename string) {
+			defer wg.Done()
+			_, err := u.Archive(fmt.Sprint(seq), filename)
+			assert.Nil(t, err)
+		}(i+1, filename)
+	}
+
+	shards := make([]string, 0)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for rotating := true; rotating; {
+		select {
+		case <-done:
+			rotating = false
+		default:
+			shard, err := u.RotateIndex(indexFilename)
+			if err == nil {
+				shards = append(shards, shard)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// the last rotation leaves a fresh index behind
+	shard, err := u.RotateIndex(indexFilename)
+	assert.Nil(t, err)
+	shards = append(shards, shard)
+	assert.FileExists(t, indexFilename)
+
+	for _, shard := range shards {
+		assert.FileExists(t, shard)
+	}
+
+	entries, err := NewIndexReader(indexFilename).List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, count)
+
+	seqs := make(map[string]bool)
+	for _, entry := range entries {
+		seqs[entry.Seq] = true
+	}
+	assert.Len(t, seqs, count)
+}
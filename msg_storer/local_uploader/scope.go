@@ -0,0 +1,108 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidScope   = errors.New("Invalid scope.")
+	ErrScopeCollision = errors.New("Scope collides with the scope of another uploader.")
+
+	// scopes of the uploaders of the process
+	scopes scopeRegistry
+)
+
+// validateScope checks the scope is a viper key path of non-empty names.
+func validateScope(scope string) error {
+
+	if scope == "" {
+		return fmt.Errorf("%w (empty)", ErrInvalidScope)
+	}
+
+	for _, name := range strings.Split(scope, ".") {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w (%s)", ErrInvalidScope, scope)
+		}
+	}
+
+	return nil
+}
+
+// scopesCollide reports whether the configs of the scopes overlap. Viper keys
+// are case-insensitive and dot separated, so the same scope in another case,
+// or a scope nested in the other, reads the keys of the other. Scopes only
+// sharing a string prefix, like uploader and uploader2, are isolated.
+func scopesCollide(a string, b string) bool {
+
+	a = strings.ToLower(a)
+	b = strings.ToLower(b)
+
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// scopeRegistry rejects the scope of an uploader colliding with the scope of
+// another uploader of the process.
+type scopeRegistry struct {
+	mutex  sync.Mutex
+	scopes map[string]bool
+}
+
+func (r *scopeRegistry) register(scope string) error {
+
+	if err := validateScope(scope); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for registered := range r.scopes {
+		if scopesCollide(scope, registered) {
+			return fmt.Errorf("%w (scope: %s, registered: %s)", ErrScopeCollision, scope, registered)
+		}
+	}
+
+	if r.scopes == nil {
+		r.scopes = make(map[string]bool)
+	}
+	r.scopes[scope] = true
+
+	return nil
+}
+
+// onStartScope registers the scope before starting, so the config of other
+// uploaders of the process is kept apart. fx doesn't call OnStop after a
+// failed start, so the scope is released right away then.
+func (u *Uploader) onStartScope(ctx context.Context) error {
+
+	if err := scopes.register(u.scope); err != nil {
+		return err
+	}
+
+	if err := u.onStart(ctx); err != nil {
+		scopes.release(u.scope)
+		return err
+	}
+
+	return nil
+}
+
+func (u *Uploader) onStopScope(ctx context.Context) error {
+
+	defer scopes.release(u.scope)
+
+	return u.onStop(ctx)
+}
+
+// release frees the scope of a stopped uploader.
+func (r *scopeRegistry) release(scope string) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.scopes, scope)
+}
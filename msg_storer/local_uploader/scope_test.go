@@ -0,0 +1,83 @@
+package uploader
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestScopePrefixIsolated(t *testing.T) {
+	root := t.TempDir()
+
+	viper.Set("scope_test.datastore", path.Join(root, "a/datastore"))
+	viper.Set("scope_test2.datastore", path.Join(root, "b/datastore"))
+	viper.Set("scope_test2.workers", 4)
+	defer viper.Set("scope_test", nil)
+	defer viper.Set("scope_test2", nil)
+
+	var registry scopeRegistry
+	assert.Nil(t, registry.register("scope_test"))
+	assert.Nil(t, registry.register("scope_test2"))
+
+	a := &Uploader{logger: zap.NewNop(), scope: "scope_test"}
+	a.initDefaultConfigs()
+	assert.Nil(t, a.loadConfig())
+
+	b := &Uploader{logger: zap.NewNop(), scope: "scope_test2"}
+	b.initDefaultConfigs()
+	assert.Nil(t, b.loadConfig())
+
+	assert.Equal(t, path.Join(root, "a/datastore"), a.datastore)
+	assert.Equal(t, path.Join(root, "b/datastore"), b.datastore)
+	assert.Equal(t, DefaultWorkers, a.workers)
+	assert.Equal(t, 4, b.workers)
+}
+
+func TestScopeCollision(t *testing.T) {
+	var registry scopeRegistry
+
+	assert.Nil(t, registry.register("uploader"))
+	assert.ErrorIs(t, registry.register("uploader"), ErrScopeCollision)
+	assert.ErrorIs(t, registry.register("Uploader"), ErrScopeCollision)
+	assert.ErrorIs(t, registry.register("uploader.tenant"), ErrScopeCollision)
+	assert.Nil(t, registry.register("uploader2"))
+
+	// free again once stopped
+	registry.release("uploader")
+	assert.Nil(t, registry.register("uploader.tenant"))
+	assert.ErrorIs(t, registry.register("uploader"), ErrScopeCollision)
+}
+
+func TestScopeReleasedOnFailedStart(t *testing.T) {
+	scope := "scope_failed_start_test"
+
+	viper.Set(scope+".ack_mode", "bogus")
+	defer viper.Set(scope, nil)
+
+	u := &Uploader{logger: zap.NewNop(), scope: scope}
+	u.initDefaultConfigs()
+
+	// a retry fails with the config again instead of the collision
+	for i := 0; i < 2; i++ {
+		err := u.onStartScope(context.Background())
+		assert.ErrorContains(t, err, "invalid ack_mode")
+		assert.NotErrorIs(t, err, ErrScopeCollision)
+	}
+
+	assert.Nil(t, scopes.register(scope))
+	scopes.release(scope)
+}
+
+func TestValidateScope(t *testing.T) {
+	assert.Nil(t, validateScope("modules.uploader"))
+	assert.ErrorIs(t, validateScope(""), ErrInvalidScope)
+	assert.ErrorIs(t, validateScope("uploader."), ErrInvalidScope)
+	assert.ErrorIs(t, validateScope("modules..uploader"), ErrInvalidScope)
+
+	var registry scopeRegistry
+	assert.ErrorIs(t, registry.register(".uploader"), ErrInvalidScope)
+}
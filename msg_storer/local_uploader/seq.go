@@ -0,0 +1,145 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	SeqSourcePayload  = "payload"
+	SeqSourceMetadata = "metadata"
+
+	DefaultSeqSource = SeqSourcePayload
+
+	SeqFormatNone      = ""
+	SeqFormatTrimZeros = "trim_zeros"
+	SeqFormatPad       = "pad"
+
+	DefaultSeqFormat = SeqFormatNone
+)
+
+var (
+	ErrNoMetadata = errors.New("Message carries no JetStream metadata.")
+)
+
+// resolveSeq replaces the payload sequence of the job with the stream
+// sequence of its message when the sequence is read from the metadata, and
+// normalizes it with the seq_format.
+func (u *Uploader) resolveSeq(j *job) error {
+
+	if u.seqSource == SeqSourceMetadata {
+		if j.msg == nil {
+			return ErrNoMetadata
+		}
+
+		meta, err := j.msg.Metadata()
+		if err != nil {
+			return fmt.Errorf("%w (%v)", ErrNoMetadata, err)
+		}
+
+		j.seq = strconv.FormatUint(meta.Sequence.Stream, 10)
+	}
+
+	j.seq = u.seqFormat.normalize(j.seq)
+
+	return nil
+}
+
+// checkSeqSource validates the sequence source, making sure JetStream is
+// available to provide the metadata.
+func (u *Uploader) checkSeqSource() error {
+
+	if err := validateSeqSource(u.seqSource); err != nil || u.seqSource == SeqSourcePayload {
+		return err
+	}
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+	if js == nil {
+		return fmt.Errorf("seq_source %s requires JetStream", u.seqSource)
+	}
+
+	if _, err := js.AccountInfo(); err != nil {
+		return fmt.Errorf("seq_source %s requires JetStream: %w", u.seqSource, err)
+	}
+
+	return nil
+}
+
+func validateSeqSource(seqSource string) error {
+
+	switch seqSource {
+	case SeqSourcePayload, SeqSourceMetadata:
+		return nil
+	}
+
+	return fmt.Errorf("invalid seq_source: %s", seqSource)
+}
+
+// seqFormat normalizes numeric sequences, so "7" and "007" are the same key
+// of the index. Other sequences are kept as they are.
+type seqFormat struct {
+	trimZeros bool
+	width     int
+}
+
+// parseSeqFormat parses trim_zeros or pad:<width>.
+func parseSeqFormat(format string) (seqFormat, error) {
+
+	name, arg, _ := strings.Cut(format, ":")
+
+	switch name {
+	case SeqFormatNone:
+		return seqFormat{}, nil
+	case SeqFormatTrimZeros:
+		return seqFormat{trimZeros: true}, nil
+	case SeqFormatPad:
+		width, err := strconv.Atoi(arg)
+		if err != nil || width < 1 {
+			return seqFormat{}, fmt.Errorf("invalid seq_format width: %s", format)
+		}
+		return seqFormat{trimZeros: true, width: width}, nil
+	}
+
+	return seqFormat{}, fmt.Errorf("invalid seq_format: %s", format)
+}
+
+func (f seqFormat) normalize(seq string) string {
+
+	if !f.trimZeros || !isDigits(seq) {
+		return seq
+	}
+
+	seq = strings.TrimLeft(seq, "0")
+	if seq == "" {
+		seq = "0"
+	}
+
+	if len(seq) < f.width {
+		seq = strings.Repeat("0", f.width-len(seq)) + seq
+	}
+
+	return seq
+}
+
+func isDigits(s string) bool {
+
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Lookup returns the latest index entry of the sequence archived from the
+// datastore file, normalizing the sequence like incoming jobs.
+func (u *Uploader) Lookup(seq string, filename string) (Entry, error) {
+	return u.indexReader(cleanFilename(filename)).Lookup(u.seqFormat.normalize(seq))
+}
@@ -0,0 +1,135 @@
+package uploader
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *TestSuite) TestSeqSourceMetadata() {
+	js := s.uploader.params.NATSConnector.GetJetStreamContext()
+
+	u := newTestUploader(s.T())
+	u.seqSource = SeqSourceMetadata
+	filename := createTestFile(s.T(), u, "100/100/MSG_1.db", "metadata")
+
+	subject := fmt.Sprintf(DefaultSubject, DefaultDomain, "seq-source")
+	sub, err := js.SubscribeSync(subject, nats.Durable("seq-source-test"))
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// the payload sequence is ignored
+	_, err = js.Publish(subject, []byte("1:"+filename))
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	m, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	meta, err := m.Metadata()
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	s.NotEqual(uint64(1), meta.Sequence.Stream)
+
+	u.msgHandler(m)
+
+	entries, err := NewIndexReader(u.indexFilename(filename)).List()
+	s.Nil(err)
+	if s.Len(entries, 1) {
+		s.Equal(strconv.FormatUint(meta.Sequence.Stream, 10), entries[0].Seq)
+	}
+}
+
+func (s *TestSuite) TestCheckSeqSource() {
+	u := s.uploader
+	defer func() {
+		u.seqSource = DefaultSeqSource
+	}()
+
+	u.seqSource = SeqSourceMetadata
+	s.Nil(u.checkSeqSource())
+
+	u.seqSource = "header"
+	s.NotNil(u.checkSeqSource())
+}
+
+func TestSeqSourceWithoutMetadata(t *testing.T) {
+	u := newTestUploader(t)
+	u.seqSource = SeqSourceMetadata
+
+	j, err := parseJob(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:datastore/MSG_1.db"),
+	})
+	assert.Nil(t, err)
+
+	err = u.resolveSeq(j)
+	assert.ErrorIs(t, err, ErrNoMetadata)
+	assert.True(t, isPermanent(err))
+}
+
+func TestSeqFormat(t *testing.T) {
+	trim, err := parseSeqFormat(SeqFormatTrimZeros)
+	assert.Nil(t, err)
+	assert.Equal(t, trim.normalize("7"), trim.normalize("007"))
+	assert.Equal(t, "7", trim.normalize("007"))
+	assert.Equal(t, "0", trim.normalize("000"))
+	assert.Equal(t, "abc007", trim.normalize("abc007"))
+
+	pad, err := parseSeqFormat("pad:5")
+	assert.Nil(t, err)
+	assert.Equal(t, pad.normalize("7"), pad.normalize("007"))
+	assert.Equal(t, "00007", pad.normalize("007"))
+	assert.Equal(t, "123456", pad.normalize("0123456"))
+
+	none, err := parseSeqFormat(DefaultSeqFormat)
+	assert.Nil(t, err)
+	assert.Equal(t, "007", none.normalize("007"))
+
+	for _, format := range []string{"pad", "pad:0", "pad:x", "upper"} {
+		_, err := parseSeqFormat(format)
+		assert.NotNil(t, err, format)
+	}
+}
+
+func TestSeqFormatIndexKey(t *testing.T) {
+	u := newTestUploader(t)
+	u.seqFormat, _ = parseSeqFormat(SeqFormatTrimZeros)
+
+	filename := createTestFile(t, u, "100/100/MSG_7.db", "data")
+	archiveName, err := u.Archive("007", filename)
+	assert.Nil(t, err)
+
+	entry, err := u.Lookup("7", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "7", entry.Seq)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+
+	entry, err = u.Lookup("0007", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+
+	// a job of the same sequence is recognized as archived
+	m := &nats.Msg{Subject: "test", Data: []byte("7:" + filename)}
+	j, err := u.decodeJob(m)
+	assert.Nil(t, err)
+	assert.Nil(t, u.resolveSeq(j))
+
+	archived, ok := u.archivedEntry(j)
+	assert.True(t, ok)
+	assert.Equal(t, archiveName, archived.ArchiveName)
+}
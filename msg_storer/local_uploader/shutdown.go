@@ -0,0 +1,90 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ShutdownDrain   = "drain"
+	ShutdownRequeue = "requeue"
+
+	DefaultShutdownMode = ShutdownDrain
+)
+
+func validateShutdownMode(mode string) error {
+
+	switch mode {
+	case ShutdownDrain, ShutdownRequeue:
+		return nil
+	}
+
+	return fmt.Errorf("invalid shutdown_mode: %s", mode)
+}
+
+// shutdown stops the job subscription according to the shutdown mode. In drain
+// mode delivered messages are archived before it returns, in requeue mode they
+// are Nak'd right away so that other uploaders pick them up. A move which has
+// already started is always finished, as Naking it would archive it twice.
+func (u *Uploader) shutdown(ctx context.Context) error {
+
+	if u.shutdownMode == ShutdownRequeue {
+		u.requeueing.Store(true)
+	}
+
+	u.subMutex.Lock()
+	sub := u.sub
+	u.sub = nil
+	u.subMutex.Unlock()
+
+	if sub != nil {
+		// messages buffered in the client still reach the handler while draining
+		if err := sub.Drain(); err != nil {
+			u.logger.Error(err.Error())
+		}
+	}
+
+	for sub != nil && sub.IsValid() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// handle the messages queued for the workers
+	if u.pool != nil {
+		pool := u.pool
+		u.pool = nil
+
+		done := make(chan struct{})
+		go func() {
+			pool.stop()
+			close(done)
+		}()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+		}
+	}
+
+	for u.inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	u.logger.Info("Stopped job subscription",
+		zap.String("mode", u.shutdownMode),
+		zap.Uint64("requeued", u.counters.requeued.Load()),
+	)
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package uploader
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultShutdownReportSubject = ""
+)
+
+// ShutdownReport is logged, and published to the shutdown report subject,
+// when the uploader stops. InFlight and Queued are the messages being handled
+// and waiting for a worker when the stop began, the totals cover the whole
+// lifetime including the messages drained while stopping.
+type ShutdownReport struct {
+	Scope         string    `json:"scope"`
+	Hostname      string    `json:"hostname"`
+	Mode          string    `json:"mode"`
+	InFlight      int64     `json:"in_flight"`
+	Queued        int       `json:"queued"`
+	Archived      uint64    `json:"archived"`
+	Failed        uint64    `json:"failed"`
+	ArchivedBytes uint64    `json:"archived_bytes"`
+	Requeued      uint64    `json:"requeued"`
+	Uptime        float64   `json:"uptime_seconds"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// backlog returns the messages being handled and the messages queued for the
+// workers.
+func (u *Uploader) backlog() (int64, int) {
+
+	queued := 0
+	if u.pool != nil {
+		queued = u.pool.queued()
+	}
+
+	return u.inflight.Load(), queued
+}
+
+// reportShutdown completes the report with the lifetime totals, logs it and
+// publishes it. A failed publish is only logged.
+func (u *Uploader) reportShutdown(report ShutdownReport) {
+
+	stats := u.Stats()
+
+	report.Scope = u.scope
+	report.Hostname = u.hostname
+	report.Mode = u.shutdownMode
+	report.Archived = stats.Archived
+	report.Failed = stats.Failed
+	report.ArchivedBytes = stats.ArchivedBytes
+	report.Requeued = stats.Requeued
+	report.Uptime = time.Since(u.startedAt).Seconds()
+	report.Timestamp = time.Now()
+
+	u.logger.Info("Shutdown report",
+		zap.String("mode", report.Mode),
+		zap.Int64("inFlight", report.InFlight),
+		zap.Int("queued", report.Queued),
+		zap.Uint64("archived", report.Archived),
+		zap.Uint64("failed", report.Failed),
+		zap.Uint64("archivedBytes", report.ArchivedBytes),
+		zap.Uint64("requeued", report.Requeued),
+	)
+
+	if u.shutdownReportSubject == "" {
+		return
+	}
+
+	if err := u.publishShutdownReport(report); err != nil {
+		u.logger.Warn("Failed to publish shutdown report",
+			zap.String("subject", u.shutdownReportSubject),
+			zap.Error(err),
+		)
+	}
+}
+
+func (u *Uploader) publishShutdownReport(report ShutdownReport) error {
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	msg, err := u.eventMsg(u.shutdownReportSubject, data)
+	if err != nil {
+		return err
+	}
+
+	nc := u.params.NATSConnector.GetConnection()
+	if err := nc.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	// the connection may close right after the uploader stopped
+	return nc.Flush()
+}
@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// blockingRenameFileSystem holds renames until release is closed.
+type blockingRenameFileSystem struct {
+	osFileSystem
+	release chan struct{}
+}
+
+func (f *blockingRenameFileSystem) Rename(oldpath string, newpath string) error {
+	<-f.release
+	return f.osFileSystem.Rename(oldpath, newpath)
+}
+
+func (s *TestSuite) TestShutdownReport() {
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+	u.shutdownReportSubject = "uploader-shutdown-report"
+	u.shutdownMode = ShutdownDrain
+	u.startedAt = time.Now()
+
+	fsys := &blockingRenameFileSystem{release: make(chan struct{})}
+	u.fs = fsys
+
+	sub, err := u.params.NATSConnector.GetConnection().SubscribeSync(u.shutdownReportSubject)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// one message being moved by the single worker, two waiting for it
+	u.pool = newWorkerPool(1, 4, u.msgHandler)
+	for i := 1; i <= 3; i++ {
+		filename := createTestFile(s.T(), u, fmt.Sprintf("100/300/MSG_%d.db", i), "data")
+		u.dispatch(&nats.Msg{
+			Subject: "test",
+			Data:    []byte(fmt.Sprintf("%d:%s", i, filename)),
+		})
+	}
+
+	s.Eventually(func() bool {
+		inflight, queued := u.backlog()
+		return inflight == 1 && queued == 2
+	}, time.Second, 10*time.Millisecond)
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- u.onStop(context.Background())
+	}()
+
+	// the drained messages still count as archived
+	time.Sleep(50 * time.Millisecond)
+	close(fsys.release)
+	s.Nil(<-stopped)
+
+	m, err := sub.NextMsg(time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	var report ShutdownReport
+	s.Nil(json.Unmarshal(m.Data, &report))
+	s.Equal("uploader", report.Scope)
+	s.Equal(ShutdownDrain, report.Mode)
+	s.Equal(int64(1), report.InFlight)
+	s.Equal(2, report.Queued)
+	s.Equal(uint64(3), report.Archived)
+	s.Equal(uint64(12), report.ArchivedBytes)
+	s.Equal(uint64(0), report.Failed)
+}
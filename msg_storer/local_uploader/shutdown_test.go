@@ -0,0 +1,131 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+// startWaitingHandler runs the handler on a job whose source doesn't exist yet,
+// so it is in-flight in the source wait.
+func startWaitingHandler(t *testing.T, u *Uploader, filename string) *sync.WaitGroup {
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		u.msgHandler(&nats.Msg{
+			Subject: "test",
+			Data:    []byte("1:" + filename),
+		})
+	}()
+
+	// wait until the handler is in-flight
+	time.Sleep(50 * time.Millisecond)
+
+	return &wg
+}
+
+func TestShutdownDrain(t *testing.T) {
+	u := newTestUploader(t)
+	u.shutdownMode = ShutdownDrain
+	u.sourceWait = 5 * time.Second
+	u.sourceWaitInterval = 10 * time.Millisecond
+
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+	wg := startWaitingHandler(t, u, filename)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		createTestFile(t, u, "100/300/MSG_1.db", "data")
+	}()
+
+	// the in-flight message is finished before shutdown returns
+	err := u.shutdown(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), u.counters.archived.Load())
+	assert.Equal(t, uint64(0), u.counters.requeued.Load())
+
+	wg.Wait()
+}
+
+func TestShutdownRequeue(t *testing.T) {
+	u := newTestUploader(t)
+	u.shutdownMode = ShutdownRequeue
+	u.sourceWait = 5 * time.Second
+	u.sourceWaitInterval = 10 * time.Millisecond
+
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+	wg := startWaitingHandler(t, u, filename)
+
+	// the in-flight message is Nak'd without waiting for the source
+	start := time.Now()
+	err := u.shutdown(context.Background())
+	assert.Nil(t, err)
+	assert.Less(t, time.Since(start), u.sourceWait)
+	wg.Wait()
+
+	// messages delivered afterwards are Nak'd as well
+	other := createTestFile(t, u, "100/300/MSG_2.db", "data")
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("2:" + other),
+	})
+
+	assert.Equal(t, uint64(0), u.counters.archived.Load())
+	assert.Equal(t, uint64(2), u.counters.requeued.Load())
+
+	_, err = os.Stat(other)
+	assert.Nil(t, err)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	u := newTestUploader(t)
+	u.shutdownMode = ShutdownDrain
+	u.sourceWait = time.Second
+	u.sourceWaitInterval = 10 * time.Millisecond
+
+	wg := startWaitingHandler(t, u, path.Join(u.datastore, "100/300/MSG_1.db"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := u.shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	wg.Wait()
+}
+
+func (s *TestSuite) TestShutdownRequeueSubscription() {
+	js := s.uploader.params.NATSConnector.GetJetStreamContext()
+
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+	u.shutdownMode = ShutdownRequeue
+
+	subject := fmt.Sprintf(DefaultSubject, DefaultDomain, "shutdown")
+	sub, err := js.Subscribe(subject, u.msgHandler, nats.Durable("shutdown-test"), nats.ManualAck())
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	u.sub = sub
+
+	err = u.shutdown(context.Background())
+	s.Nil(err)
+	s.False(sub.IsValid())
+	s.Nil(u.sub)
+}
+
+func TestValidateShutdownMode(t *testing.T) {
+	assert.Nil(t, validateShutdownMode(ShutdownDrain))
+	assert.Nil(t, validateShutdownMode(ShutdownRequeue))
+	assert.NotNil(t, validateShutdownMode("abort"))
+}
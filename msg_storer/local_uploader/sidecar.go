@@ -0,0 +1,98 @@
+package uploader
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const (
+	MetadataSidecarSuffix = ".meta.json"
+)
+
+// MessageMetadata is written next to the archive file to keep the context of
+// the message which triggered the archive.
+type MessageMetadata struct {
+	Seq          string              `json:"seq"`
+	Filename     string              `json:"filename"`
+	Subject      string              `json:"subject"`
+	Stream       string              `json:"stream,omitempty"`
+	Consumer     string              `json:"consumer,omitempty"`
+	StreamSeq    uint64              `json:"stream_seq,omitempty"`
+	ConsumerSeq  uint64              `json:"consumer_seq,omitempty"`
+	NumDelivered uint64              `json:"num_delivered,omitempty"`
+	NumPending   uint64              `json:"num_pending,omitempty"`
+	Timestamp    time.Time           `json:"timestamp"`
+	Headers      map[string][]string `json:"headers,omitempty"`
+}
+
+func messageMetadata(j *job) MessageMetadata {
+
+	meta := MessageMetadata{
+		Seq:      j.seq,
+		Filename: j.filename,
+		Subject:  j.subject(),
+	}
+
+	// headers decoded from the payload
+	if len(j.headers) > 0 {
+		meta.Headers = make(map[string][]string, len(j.headers))
+		for k, v := range j.headers {
+			meta.Headers[k] = []string{v}
+		}
+	}
+
+	if j.msg == nil {
+		return meta
+	}
+
+	for k, v := range j.msg.Header {
+		if meta.Headers == nil {
+			meta.Headers = make(map[string][]string, len(j.msg.Header))
+		}
+		meta.Headers[k] = v
+	}
+
+	if md, err := j.msg.Metadata(); err == nil {
+		meta.Stream = md.Stream
+		meta.Consumer = md.Consumer
+		meta.StreamSeq = md.Sequence.Stream
+		meta.ConsumerSeq = md.Sequence.Consumer
+		meta.NumDelivered = md.NumDelivered
+		meta.NumPending = md.NumPending
+		meta.Timestamp = md.Timestamp
+	}
+
+	return meta
+}
+
+// writeMetadataSidecar writes <archiveName>.meta.json atomically.
+func (u *Uploader) writeMetadataSidecar(j *job, archiveName string) error {
+
+	if !u.metadataSidecar {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(messageMetadata(j), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(archiveName+MetadataSidecarSuffix, data)
+}
+
+// writeFileAtomic writes the file via a temporary file and a rename.
+func writeFileAtomic(filename string, data []byte) error {
+
+	tmpName := filename + ".tmp"
+	if err := os.WriteFile(tmpName, data, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func (s *TestSuite) TestMetadataSidecar() {
+	js := s.uploader.params.NATSConnector.GetJetStreamContext()
+
+	u := newTestUploader(s.T())
+	u.metadataSidecar = true
+	filename := createTestFile(s.T(), u, "100/100/MSG_1.db", "sidecar")
+
+	subject := fmt.Sprintf(DefaultSubject, DefaultDomain, "sidecar")
+	sub, err := js.SubscribeSync(subject, nats.Durable("sidecar-test"))
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer sub.Unsubscribe()
+
+	msg := nats.NewMsg(subject)
+	msg.Data = []byte("1:" + filename)
+	msg.Header.Set("X-Producer", "sidecar-test")
+	_, err = js.PublishMsg(msg)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	m, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	defer m.Ack()
+
+	j, err := parseJob(m)
+	s.Nil(err)
+
+	archiveName, err := u.archive(j)
+	s.Nil(err)
+
+	data, err := os.ReadFile(archiveName + MetadataSidecarSuffix)
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	var meta MessageMetadata
+	err = json.Unmarshal(data, &meta)
+	s.Nil(err)
+	s.Equal("1", meta.Seq)
+	s.Equal(subject, meta.Subject)
+	s.Equal(fmt.Sprintf("%s_Archive_Job", DefaultDomain), meta.Stream)
+	s.Equal("sidecar-test", meta.Consumer)
+	s.NotZero(meta.StreamSeq)
+	s.Equal(uint64(1), meta.ConsumerSeq)
+	s.Equal(uint64(1), meta.NumDelivered)
+	s.False(meta.Timestamp.IsZero())
+	s.Equal([]string{"sidecar-test"}, meta.Headers["X-Producer"])
+}
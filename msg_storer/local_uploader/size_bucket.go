@@ -0,0 +1,124 @@
+package uploader
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultSizeBucketOverflow = "overflow"
+)
+
+// sizeBucket holds the archives of at most max bytes.
+type sizeBucket struct {
+	max  int64
+	name string
+}
+
+// parseSizeBuckets parses the size_buckets mappings, each max_bytes=name,
+// into buckets sorted by size.
+func parseSizeBuckets(mappings []string, overflow string) ([]sizeBucket, error) {
+
+	buckets := make([]sizeBucket, 0, len(mappings))
+	names := make(map[string]bool, len(mappings))
+
+	for _, mapping := range mappings {
+
+		threshold, name, ok := strings.Cut(mapping, "=")
+		threshold = strings.TrimSpace(threshold)
+		name = strings.TrimSpace(name)
+		if !ok || threshold == "" || name == "" {
+			return nil, fmt.Errorf("invalid size_buckets mapping %q, expected max_bytes=name", mapping)
+		}
+
+		max, err := strconv.ParseInt(threshold, 10, 64)
+		if err != nil || max <= 0 {
+			return nil, fmt.Errorf("invalid size_buckets threshold %q", threshold)
+		}
+
+		if err := validateBucketName(name); err != nil {
+			return nil, err
+		}
+
+		if names[name] {
+			return nil, fmt.Errorf("duplicate size_buckets name %s", name)
+		}
+		names[name] = true
+
+		buckets = append(buckets, sizeBucket{
+			max:  max,
+			name: name,
+		})
+	}
+
+	if len(buckets) == 0 {
+		return buckets, nil
+	}
+
+	if err := validateBucketName(overflow); err != nil {
+		return nil, err
+	}
+
+	if names[overflow] {
+		return nil, fmt.Errorf("size_bucket_overflow %s is also a size_buckets name", overflow)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].max < buckets[j].max
+	})
+
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i].max == buckets[i-1].max {
+			return nil, fmt.Errorf("duplicate size_buckets threshold %d", buckets[i].max)
+		}
+	}
+
+	return buckets, nil
+}
+
+// validateBucketName allows a single directory name only, so a bucket can't
+// escape the archive root.
+func validateBucketName(name string) error {
+
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid size bucket name %q", name)
+	}
+
+	return nil
+}
+
+// sizeBucketName returns the bucket of the file size, the overflow bucket
+// when it's larger than the largest threshold.
+func (u *Uploader) sizeBucketName(size int64) string {
+
+	for _, bucket := range u.sizeBuckets {
+		if size <= bucket.max {
+			return bucket.name
+		}
+	}
+
+	return u.sizeBucketOverflow
+}
+
+// sizeBucketRoot adds the size bucket of the source file to the archive root.
+func (u *Uploader) sizeBucketRoot(root string, filename string) (string, error) {
+
+	if len(u.sizeBuckets) == 0 {
+		return root, nil
+	}
+
+	fi, err := os.Stat(filename)
+	if os.IsNotExist(err) && u.recordDeletedMarkers {
+		// the empty marker of a deleted source
+		return path.Join(root, u.sizeBucketName(0)), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(root, u.sizeBucketName(fi.Size())), nil
+}
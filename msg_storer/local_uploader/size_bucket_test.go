@@ -0,0 +1,74 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSizeBuckets(t *testing.T) {
+	buckets, err := parseSizeBuckets([]string{"1048576=medium", "1024=small"}, DefaultSizeBucketOverflow)
+	assert.Nil(t, err)
+	assert.Equal(t, []sizeBucket{{max: 1024, name: "small"}, {max: 1048576, name: "medium"}}, buckets)
+
+	invalid := [][]string{
+		{"small"},
+		{"0=small"},
+		{"abc=small"},
+		{"1024=a/b"},
+		{"1024=.."},
+		{"1024=small", "2048=small"},
+		{"1024=small", "1024=tiny"},
+		{"1024=overflow"},
+	}
+	for _, mappings := range invalid {
+		_, err := parseSizeBuckets(mappings, DefaultSizeBucketOverflow)
+		assert.NotNil(t, err, mappings)
+	}
+
+	_, err = parseSizeBuckets([]string{"1024=small"}, "")
+	assert.NotNil(t, err)
+}
+
+func TestSizeBuckets(t *testing.T) {
+	u := newTestUploader(t)
+
+	buckets, err := parseSizeBuckets([]string{"4=small", "16=medium"}, DefaultSizeBucketOverflow)
+	assert.Nil(t, err)
+	u.sizeBuckets = buckets
+	u.sizeBucketOverflow = DefaultSizeBucketOverflow
+
+	cases := []struct {
+		seq    string
+		size   int
+		bucket string
+	}{
+		{"1", 0, "small"},
+		{"2", 4, "small"},
+		{"3", 5, "medium"},
+		{"4", 16, "medium"},
+		{"5", 17, "overflow"},
+	}
+
+	for _, c := range cases {
+		filename := createTestFile(t, u, "100/300/MSG_"+c.seq+".db", strings.Repeat("x", c.size))
+
+		u.msgHandler(&nats.Msg{
+			Subject: "test",
+			Data:    []byte(c.seq + ":" + filename),
+		})
+
+		expected := path.Join(u.archivestore, c.bucket, "100/300/MSG_"+c.seq+".db")
+		_, err := os.Stat(expected)
+		assert.Nil(t, err, c.bucket)
+
+		// the index records the bucketed path
+		entry, err := NewIndexReader(u.indexFilename(filename)).Lookup(c.seq)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, entry.ArchiveName)
+	}
+}
@@ -0,0 +1,42 @@
+package uploader
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	DefaultSourceWait         = 0
+	DefaultSourceWaitInterval = 100 * time.Millisecond
+)
+
+// waitForSource waits up to the source wait window for a missing source file to
+// appear, covering producers which publish the job before the file is written.
+// It reports whether the file exists.
+func (u *Uploader) waitForSource(filename string) bool {
+
+	deadline := time.Now().Add(u.sourceWait)
+	for {
+
+		_, err := os.Stat(filename)
+		if !os.IsNotExist(err) {
+			return true
+		}
+
+		// stop waiting when the uploader is requeueing on shutdown
+		if u.sourceWait <= 0 || !time.Now().Before(deadline) || u.requeueing.Load() {
+			return false
+		}
+
+		interval := u.sourceWaitInterval
+		if interval <= 0 {
+			interval = DefaultSourceWaitInterval
+		}
+
+		if remaining := time.Until(deadline); remaining < interval {
+			interval = remaining
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,64 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForSourceAppears(t *testing.T) {
+	u := newTestUploader(t)
+	u.sourceWait = 2 * time.Second
+	u.sourceWaitInterval = 10 * time.Millisecond
+
+	filename := path.Join(u.datastore, "100/300/MSG_1.db")
+
+	// the file is written partway through the wait window
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		createTestFile(t, u, "100/300/MSG_1.db", "late")
+	}()
+
+	start := time.Now()
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	})
+	assert.Less(t, time.Since(start), u.sourceWait)
+
+	assert.Equal(t, uint64(1), u.counters.archived.Load())
+	assert.Equal(t, uint64(0), u.counters.failed.Load())
+
+	_, err := os.Stat(u.translate(filename, u.archivestore))
+	assert.Nil(t, err)
+}
+
+func TestWaitForSourceMissing(t *testing.T) {
+	u := newTestUploader(t)
+	u.sourceWait = 50 * time.Millisecond
+	u.sourceWaitInterval = 10 * time.Millisecond
+
+	start := time.Now()
+	found := u.waitForSource(path.Join(u.datastore, "100/300/MSG_404.db"))
+	assert.False(t, found)
+	assert.GreaterOrEqual(t, time.Since(start), u.sourceWait)
+
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("404:" + path.Join(u.datastore, "100/300/MSG_404.db")),
+	})
+	assert.Equal(t, uint64(0), u.counters.archived.Load())
+	assert.Equal(t, uint64(1), u.counters.failed.Load())
+}
+
+func TestWaitForSourceDisabled(t *testing.T) {
+	u := newTestUploader(t)
+
+	start := time.Now()
+	assert.False(t, u.waitForSource(path.Join(u.datastore, "MSG_404.db")))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
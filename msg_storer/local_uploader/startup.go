@@ -0,0 +1,126 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultStartupDelay       = 0
+	DefaultStartupCheckMounts = false
+)
+
+var (
+	ErrNotMounted = errors.New("Store directory is not mounted.")
+)
+
+// delayedStart waits startup_delay for the shared filesystems to be mounted,
+// verifies them when startup_check_mounts is set, and calls start. A cancelled
+// context aborts the wait.
+func (u *Uploader) delayedStart(ctx context.Context, start func() error) error {
+
+	if u.startupDelay > 0 {
+		u.logger.Info("Delaying start", zap.Duration("delay", u.startupDelay))
+
+		timer := time.NewTimer(u.startupDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	if u.startupCheckMounts {
+		for _, dir := range []string{u.datastore, u.archivestore} {
+			if err := checkMountPoint(dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return start()
+}
+
+// startAfterDelay runs the delayed start in the background, so that a delay
+// beyond the start timeout of fx doesn't fail the start. The start context of
+// fx is cancelled once the start returns, so the wait only ends with the delay
+// or with OnStop. The app is shut down when the start fails after the delay.
+func (u *Uploader) startAfterDelay() {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	u.startupCancel = cancel
+	u.startupDone = make(chan struct{})
+
+	go func() {
+		defer close(u.startupDone)
+
+		err := u.delayedStart(ctx, u.start)
+		switch {
+		case err == nil:
+			u.startupStarted = true
+		case errors.Is(err, context.Canceled):
+		default:
+			u.logger.Error("Failed to start Uploader", zap.Error(err))
+			if u.params.Shutdowner != nil {
+				u.params.Shutdowner.Shutdown(fx.ExitCode(1))
+			}
+		}
+	}()
+}
+
+// stopDelayedStart aborts a delayed start which is still waiting and reports
+// whether the uploader was started.
+func (u *Uploader) stopDelayedStart() bool {
+
+	if u.startupCancel == nil {
+		return true
+	}
+
+	u.startupCancel()
+	<-u.startupDone
+
+	return u.startupStarted
+}
+
+// checkStoreDir verifies the store directory is present.
+func checkStoreDir(dir string) error {
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%w (%s): %w", ErrNotMounted, dir, err)
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("%w (%s): not a directory", ErrNotMounted, dir)
+	}
+
+	return nil
+}
+
+// checkMountPoint verifies the store directory is present and on another
+// filesystem than its parent, so that an unmounted share isn't filled in on
+// the filesystem below the mount point.
+func checkMountPoint(dir string) error {
+
+	if err := checkStoreDir(dir); err != nil {
+		return err
+	}
+
+	mounted, err := isMountPoint(dir)
+	if err != nil {
+		return fmt.Errorf("%w (%s): %w", ErrNotMounted, dir, err)
+	}
+
+	if !mounted {
+		return fmt.Errorf("%w (%s): on the filesystem of its parent", ErrNotMounted, dir)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+func TestDelayedStart(t *testing.T) {
+	u := newTestUploader(t)
+	u.startupDelay = 50 * time.Millisecond
+
+	begin := time.Now()
+	var started time.Time
+	err := u.delayedStart(context.Background(), func() error {
+		started = time.Now()
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, started.Sub(begin), u.startupDelay)
+}
+
+func TestDelayedStartCancelled(t *testing.T) {
+	u := newTestUploader(t)
+	u.startupDelay = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	started := false
+	err := u.delayedStart(ctx, func() error {
+		started = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, started)
+}
+
+func TestDelayedStartCheckMounts(t *testing.T) {
+	u := newTestUploader(t)
+	u.startupCheckMounts = true
+
+	if mounted, err := isMountPoint("/dev/shm"); err != nil || !mounted {
+		t.Skip("/dev/shm is not a mount point")
+	}
+
+	started := false
+	start := func() error {
+		started = true
+		return nil
+	}
+
+	// the archivestore isn't there yet
+	u.datastore = "/dev/shm"
+	err := u.delayedStart(context.Background(), start)
+	assert.ErrorIs(t, err, ErrNotMounted)
+	assert.False(t, started)
+
+	// a directory on the filesystem of its parent isn't the mount
+	assert.Nil(t, os.MkdirAll(u.archivestore, 0750))
+	err = u.delayedStart(context.Background(), start)
+	assert.ErrorIs(t, err, ErrNotMounted)
+	assert.False(t, started)
+
+	u.archivestore = "/dev/shm"
+	assert.Nil(t, u.delayedStart(context.Background(), start))
+	assert.True(t, started)
+}
+
+func TestCheckMountsBeforeCreatingDirectories(t *testing.T) {
+	u := newTestUploader(t)
+	u.startupCheckMounts = true
+
+	err := u.delayedStart(context.Background(), u.start)
+	assert.ErrorIs(t, err, ErrNotMounted)
+
+	// nothing was written below the mount point
+	assert.NoDirExists(t, u.archivestore)
+}
+
+func TestStartAfterDelay(t *testing.T) {
+	u := newTestUploader(t)
+	u.startupDelay = time.Hour
+
+	// the start doesn't wait for the delay
+	begin := time.Now()
+	u.startAfterDelay()
+	assert.Less(t, time.Since(begin), time.Second)
+
+	// stopping aborts the wait, nothing was started
+	assert.False(t, u.stopDelayedStart())
+	assert.NoDirExists(t, u.archivestore)
+	assert.Nil(t, u.onStop(context.Background()))
+}
+
+// shutdowner records the shutdown of the app.
+type shutdowner struct {
+	shutdown chan struct{}
+}
+
+func (s *shutdowner) Shutdown(opts ...fx.ShutdownOption) error {
+	close(s.shutdown)
+	return nil
+}
+
+func TestStartAfterDelayOutlivesStartContext(t *testing.T) {
+	root := t.TempDir()
+	scope := "startup_delay_test"
+
+	viper.Set(scope+".datastore", path.Join(root, "datastore"))
+	viper.Set(scope+".archivestore", path.Join(root, "archivestore"))
+	viper.Set(scope+".startup_delay", 50*time.Millisecond)
+	viper.Set(scope+".startup_check_mounts", true)
+	defer viper.Set(scope, nil)
+
+	s := &shutdowner{shutdown: make(chan struct{})}
+	u := &Uploader{logger: zap.NewNop(), scope: scope, params: Params{Shutdowner: s}}
+	u.initDefaultConfigs()
+
+	// fx cancels the start context once the start returns
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.Nil(t, u.onStart(ctx))
+	cancel()
+
+	// the wait goes on and the failed mount check shuts the app down
+	select {
+	case <-s.shutdown:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delayed start was aborted")
+	}
+
+	assert.False(t, u.stopDelayedStart())
+}
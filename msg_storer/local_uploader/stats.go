@@ -0,0 +1,99 @@
+package uploader
+
+import (
+	"sync/atomic"
+)
+
+// Stats is a snapshot of the uploader state and counters.
+type Stats struct {
+	Paused        bool
+	Degraded      bool
+	LowSpace      bool
+	Archived      uint64
+	Failed        uint64
+	ArchivedBytes uint64
+	Requeued      uint64
+
+	// background verification
+	Verified       uint64
+	VerifyFailures uint64
+
+	// events dropped from a full Events channel
+	EventsDropped uint64
+
+	// jobs archived to the fallback archivestore
+	Fallbacks uint64
+
+	// markers archived for sources deleted before archiving
+	DeletedMarkers uint64
+
+	// jobs skipped as already archived within dedup_window
+	Duplicates uint64
+
+	// moves rolled back after running out of space
+	Rollbacks uint64
+
+	// consumer info polled every consumer_pending_interval
+	ConsumerPending     uint64
+	ConsumerAckPending  uint64
+	ConsumerRedelivered uint64
+
+	// archive paths audited against the expected owner and mode
+	Audited         uint64
+	AuditDeviations uint64
+	AuditFixed      uint64
+}
+
+type counters struct {
+	archived      atomic.Uint64
+	failed        atomic.Uint64
+	archivedBytes atomic.Uint64
+	requeued      atomic.Uint64
+
+	verified       atomic.Uint64
+	verifyFailures atomic.Uint64
+
+	fallbacks      atomic.Uint64
+	deletedMarkers atomic.Uint64
+	duplicates     atomic.Uint64
+	rollbacks      atomic.Uint64
+
+	audited         atomic.Uint64
+	auditDeviations atomic.Uint64
+	auditFixed      atomic.Uint64
+}
+
+// Stats returns a snapshot of the uploader state and counters.
+func (u *Uploader) Stats() Stats {
+	stats := Stats{
+		Paused:        u.paused.Load(),
+		Degraded:      u.degraded.Load(),
+		LowSpace:      u.lowSpace.Load(),
+		Archived:      u.counters.archived.Load(),
+		Failed:        u.counters.failed.Load(),
+		ArchivedBytes: u.counters.archivedBytes.Load(),
+		Requeued:      u.counters.requeued.Load(),
+
+		Verified:       u.counters.verified.Load(),
+		VerifyFailures: u.counters.verifyFailures.Load(),
+
+		Fallbacks:      u.counters.fallbacks.Load(),
+		DeletedMarkers: u.counters.deletedMarkers.Load(),
+		Duplicates:     u.counters.duplicates.Load(),
+		Rollbacks:      u.counters.rollbacks.Load(),
+
+		ConsumerPending:     u.consumerPending.pending.Load(),
+		ConsumerAckPending:  u.consumerPending.ackPending.Load(),
+		ConsumerRedelivered: u.consumerPending.redelivered.Load(),
+
+		Audited:         u.counters.audited.Load(),
+		AuditDeviations: u.counters.auditDeviations.Load(),
+		AuditFixed:      u.counters.auditFixed.Load(),
+	}
+
+	if u.events != nil {
+		stats.EventsDropped = u.events.dropped.Load()
+	}
+
+	return stats
+}
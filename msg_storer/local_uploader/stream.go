@@ -0,0 +1,93 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultStreamName   = "%s_Archive_Job"
+	DefaultStreamMaxAge = 0
+
+	StreamRetentionLimits    = "limits"
+	StreamRetentionInterest  = "interest"
+	StreamRetentionWorkQueue = "workqueue"
+
+	DefaultStreamRetentionPolicy = StreamRetentionWorkQueue
+)
+
+func parseStreamRetentionPolicy(policy string) (nats.RetentionPolicy, error) {
+
+	switch policy {
+	case StreamRetentionLimits:
+		return nats.LimitsPolicy, nil
+	case StreamRetentionInterest:
+		return nats.InterestPolicy, nil
+	case StreamRetentionWorkQueue:
+		return nats.WorkQueuePolicy, nil
+	}
+
+	return 0, fmt.Errorf("invalid stream_retention_policy: %s", policy)
+}
+
+// streamConfig returns the config of the stream created when missing. The
+// stream defaults to the jobs of every host of the domain.
+func (u *Uploader) streamConfig() *nats.StreamConfig {
+
+	name := u.streamName
+	if name == "" {
+		name = fmt.Sprintf(DefaultStreamName, u.domain)
+	}
+
+	subjects := u.streamSubjects
+	if len(subjects) == 0 {
+		subjects = []string{fmt.Sprintf(DefaultSubject, u.domain, "*")}
+	}
+
+	return &nats.StreamConfig{
+		Name:      name,
+		Subjects:  subjects,
+		Retention: u.streamRetentionPolicy,
+		Storage:   nats.FileStorage,
+		MaxAge:    u.streamMaxAge,
+	}
+}
+
+// ensureStream creates the stream of the subject when create_stream_if_missing
+// is set and no stream captures the subject yet. An existing stream is left
+// untouched, also when another uploader created it concurrently.
+func (u *Uploader) ensureStream(js nats.JetStreamContext, subject string) error {
+
+	if !u.createStreamIfMissing {
+		return nil
+	}
+
+	_, err := js.StreamNameBySubject(subject)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrNoMatchingStream) {
+		return err
+	}
+
+	cfg := u.streamConfig()
+
+	_, err = js.AddStream(cfg)
+	if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create stream %s: %w", cfg.Name, err)
+	}
+
+	u.logger.Info("Created missing stream",
+		zap.String("stream", cfg.Name),
+		zap.Strings("subjects", cfg.Subjects),
+		zap.Duration("maxAge", cfg.MaxAge),
+	)
+
+	return nil
+}
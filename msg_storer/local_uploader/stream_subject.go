@@ -0,0 +1,127 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultBindStream = ""
+
+	StreamSubjectCheckOff   = "off"
+	StreamSubjectCheckWarn  = "warn"
+	StreamSubjectCheckError = "error"
+
+	DefaultStreamSubjectCheck = StreamSubjectCheckWarn
+)
+
+var (
+	ErrStreamSubject = errors.New("Subscription subject is not covered by the stream subjects.")
+)
+
+func validateStreamSubjectCheck(check string) error {
+
+	switch check {
+	case StreamSubjectCheckOff, StreamSubjectCheckWarn, StreamSubjectCheckError:
+		return nil
+	}
+
+	return fmt.Errorf("invalid stream_subject_check: %s", check)
+}
+
+// boundStream returns the stream the subscription binds to, bind_stream or
+// the stream capturing the subject.
+func (u *Uploader) boundStream(js nats.JetStreamContext, subject string) (string, error) {
+
+	if u.bindStream != "" {
+		return u.bindStream, nil
+	}
+
+	return js.StreamNameBySubject(subject)
+}
+
+// subjectSubsetOf reports whether every subject matching subject also matches
+// pattern. Both may contain the * and > wildcards.
+func subjectSubsetOf(subject string, pattern string) bool {
+
+	tokens := strings.Split(subject, ".")
+	patternTokens := strings.Split(pattern, ".")
+
+	for i, p := range patternTokens {
+		if p == ">" {
+			return len(tokens) > i
+		}
+
+		if i >= len(tokens) {
+			return false
+		}
+
+		switch {
+		case p == "*":
+			if tokens[i] == ">" {
+				return false
+			}
+		case p != tokens[i]:
+			return false
+		}
+	}
+
+	return len(tokens) == len(patternTokens)
+}
+
+// checkStreamSubject verifies the subscription subject is covered by the
+// subjects of the bound stream, as a subscription to a subject the stream
+// doesn't capture never receives a job. It warns or fails per
+// stream_subject_check.
+func (u *Uploader) checkStreamSubject(js nats.JetStreamContext, subject string) error {
+
+	if u.streamSubjectCheck == StreamSubjectCheckOff || u.streamSubjectCheck == "" {
+		return nil
+	}
+
+	var err error
+
+	stream, lerr := u.boundStream(js, subject)
+	switch {
+	case errors.Is(lerr, nats.ErrNoMatchingStream):
+		err = fmt.Errorf("%w (subject: %s): no stream captures the subject", ErrStreamSubject, subject)
+	case lerr != nil:
+		err = lerr
+	default:
+		info, ierr := js.StreamInfo(stream)
+		if ierr != nil {
+			err = fmt.Errorf("%w (subject: %s, stream: %s): %w", ErrStreamSubject, subject, stream, ierr)
+			break
+		}
+
+		covered := false
+		for _, pattern := range info.Config.Subjects {
+			if subjectSubsetOf(subject, pattern) {
+				covered = true
+				break
+			}
+		}
+
+		if !covered {
+			err = fmt.Errorf("%w (subject: %s, stream: %s, stream subjects: %s)", ErrStreamSubject, subject, stream, strings.Join(info.Config.Subjects, ", "))
+		}
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	if u.streamSubjectCheck == StreamSubjectCheckError {
+		return err
+	}
+
+	u.logger.Warn(err.Error(),
+		zap.String("subject", subject),
+	)
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package uploader
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubjectSubsetOf(t *testing.T) {
+	assert.True(t, subjectSubsetOf("a.b", "a.b"))
+	assert.True(t, subjectSubsetOf("a.b", "a.*"))
+	assert.True(t, subjectSubsetOf("a.b.c", "a.>"))
+	assert.True(t, subjectSubsetOf("a.*", "a.*"))
+	assert.True(t, subjectSubsetOf("a.*.c", "a.>"))
+	assert.True(t, subjectSubsetOf("a.>", ">"))
+
+	assert.False(t, subjectSubsetOf("a.b", "a.c"))
+	assert.False(t, subjectSubsetOf("a", "a.>"))
+	assert.False(t, subjectSubsetOf("a.b.c", "a.*"))
+	assert.False(t, subjectSubsetOf("a.*", "a.b"))
+	assert.False(t, subjectSubsetOf("a.>", "a.*"))
+}
+
+func TestValidateStreamSubjectCheck(t *testing.T) {
+	assert.Nil(t, validateStreamSubjectCheck(StreamSubjectCheckError))
+	assert.NotNil(t, validateStreamSubjectCheck("strict"))
+}
+
+func (s *TestSuite) TestStreamSubject() {
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "uploader_subject_check",
+		Subjects: []string{"uploader-subject-check.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	u.bindStream = "uploader_subject_check"
+
+	u.streamSubjectCheck = StreamSubjectCheckError
+	s.Nil(u.checkStreamSubject(js, "uploader-subject-check.jobs"))
+	s.ErrorIs(u.checkStreamSubject(js, "uploader-subject-mismatch.jobs"), ErrStreamSubject)
+
+	u.streamSubjectCheck = StreamSubjectCheckWarn
+	s.Nil(u.checkStreamSubject(js, "uploader-subject-mismatch.jobs"))
+
+	// without a bound stream, a subject no stream captures
+	u.bindStream = ""
+	u.streamSubjectCheck = StreamSubjectCheckError
+	s.ErrorIs(u.checkStreamSubject(js, "uploader-subject-mismatch.jobs"), ErrStreamSubject)
+	s.Nil(u.checkStreamSubject(js, "uploader-subject-check.jobs"))
+}
@@ -0,0 +1,100 @@
+package uploader
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStreamRetentionPolicy(t *testing.T) {
+	policy, err := parseStreamRetentionPolicy(StreamRetentionInterest)
+	assert.Nil(t, err)
+	assert.Equal(t, nats.InterestPolicy, policy)
+
+	_, err = parseStreamRetentionPolicy("queue")
+	assert.NotNil(t, err)
+}
+
+func (s *TestSuite) TestEnsureStream() {
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+	u.domain = "uploader-create-stream"
+	u.createStreamIfMissing = true
+	u.streamRetentionPolicy = nats.WorkQueuePolicy
+	u.streamMaxAge = time.Hour
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+	subject := u.subject()
+
+	s.Nil(u.ensureStream(js, subject))
+
+	info, err := js.StreamInfo("uploader-create-stream_Archive_Job")
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+	s.Equal([]string{"uploader-create-stream.archive.bucket.job.*"}, info.Config.Subjects)
+	s.Equal(nats.WorkQueuePolicy, info.Config.Retention)
+	s.Equal(time.Hour, info.Config.MaxAge)
+
+	// idempotent
+	s.Nil(u.ensureStream(js, subject))
+}
+
+func (s *TestSuite) TestEnsureStreamPresent() {
+	u := newTestUploader(s.T())
+	u.params = s.uploader.params
+	u.domain = "uploader-stream-present"
+	u.createStreamIfMissing = true
+	u.streamRetentionPolicy = nats.WorkQueuePolicy
+
+	js := u.params.NATSConnector.GetJetStreamContext()
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "uploader_stream_present",
+		Subjects: []string{"uploader-stream-present.>"},
+		Storage:  nats.MemoryStorage,
+		MaxMsgs:  10,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	s.Nil(u.ensureStream(js, u.subject()))
+
+	info, err := js.StreamInfo("uploader_stream_present")
+	s.Nil(err)
+	s.Equal(int64(10), info.Config.MaxMsgs)
+	s.Equal(nats.LimitsPolicy, info.Config.Retention)
+
+	_, err = js.StreamInfo(fmt.Sprintf(DefaultStreamName, u.domain))
+	s.ErrorIs(err, nats.ErrStreamNotFound)
+
+	// a stream of the same name created by another uploader is left alone
+	u.domain = "uploader-stream-raced"
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     fmt.Sprintf(DefaultStreamName, u.domain),
+		Subjects: []string{"uploader-stream-raced-other.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	if err != nil {
+		s.Fail(err.Error())
+		return
+	}
+
+	s.Nil(u.ensureStream(js, u.subject()))
+
+	info, err = js.StreamInfo(fmt.Sprintf(DefaultStreamName, u.domain))
+	s.Nil(err)
+	s.Equal([]string{"uploader-stream-raced-other.>"}, info.Config.Subjects)
+}
+
+func TestEnsureStreamDisabled(t *testing.T) {
+	u := newTestUploader(t)
+
+	// no JetStream needed when disabled
+	assert.Nil(t, u.ensureStream(nil, u.subject()))
+}
@@ -0,0 +1,56 @@
+package uploader
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	DefaultSubdirRegex = ""
+
+	// name of the capture group of subdir_regex
+	SubdirGroup = "subdir"
+)
+
+// parseSubdirRegex compiles subdir_regex, which needs a subdir named group.
+func parseSubdirRegex(expr string) (*regexp.Regexp, error) {
+
+	if expr == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subdir_regex: %w", err)
+	}
+
+	if re.SubexpIndex(SubdirGroup) < 0 {
+		return nil, fmt.Errorf("subdir_regex needs a (?P<%s>...) group: %s", SubdirGroup, expr)
+	}
+
+	return re, nil
+}
+
+// subdirRoot adds the subdirectory captured by subdir_regex from the base
+// name of the file to the archive root. Names which don't match, or capture
+// an empty value or one which isn't a single directory name, keep the root.
+func (u *Uploader) subdirRoot(root string, filename string) string {
+
+	if u.subdirRegex == nil {
+		return root
+	}
+
+	m := u.subdirRegex.FindStringSubmatch(path.Base(filename))
+	if m == nil {
+		return root
+	}
+
+	subdir := m[u.subdirRegex.SubexpIndex(SubdirGroup)]
+	if subdir == "" || subdir == "." || subdir == ".." || strings.Contains(subdir, "/") {
+		return root
+	}
+
+	return path.Join(root, subdir)
+}
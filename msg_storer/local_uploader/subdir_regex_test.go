@@ -0,0 +1,63 @@
+package uploader
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubdirRegex(t *testing.T) {
+	re, err := parseSubdirRegex("")
+	assert.Nil(t, err)
+	assert.Nil(t, re)
+
+	_, err = parseSubdirRegex(`^MSG_(\d+)`)
+	assert.NotNil(t, err)
+
+	_, err = parseSubdirRegex(`^MSG_(?P<subdir>`)
+	assert.NotNil(t, err)
+}
+
+func TestSubdirRegex(t *testing.T) {
+	u := newTestUploader(t)
+
+	re, err := parseSubdirRegex(`^MSG_\d+_job-(?P<subdir>[a-z0-9]+)_`)
+	assert.Nil(t, err)
+	u.subdirRegex = re
+
+	filename := createTestFile(t, u, "100/300/MSG_1_job-a7f3_part.db", "data")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	expected := path.Join(u.archivestore, "a7f3", "100/300/MSG_1_job-a7f3_part.db")
+	assert.Equal(t, expected, archiveName)
+	assert.FileExists(t, expected)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, entry.ArchiveName)
+}
+
+func TestSubdirRegexNoMatch(t *testing.T) {
+	u := newTestUploader(t)
+
+	re, err := parseSubdirRegex(`^MSG_\d+_job-(?P<subdir>[a-z0-9]*)_`)
+	assert.Nil(t, err)
+	u.subdirRegex = re
+
+	// no job id in the name
+	filename := createTestFile(t, u, "100/300/MSG_2.db", "data")
+
+	archiveName, err := u.Archive("2", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/300/MSG_2.db"), archiveName)
+
+	// an empty capture falls back too
+	filename = createTestFile(t, u, "100/300/MSG_3_job-_part.db", "data")
+
+	archiveName, err = u.Archive("3", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/300/MSG_3_job-_part.db"), archiveName)
+}
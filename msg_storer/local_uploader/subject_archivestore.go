@@ -0,0 +1,81 @@
+package uploader
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// subjectArchivestore routes the messages whose subject matches the pattern
+// to another archivestore root.
+type subjectArchivestore struct {
+	pattern string
+	tokens  []string
+	root    string
+}
+
+// parseSubjectArchivestores parses pattern=root mappings. Patterns are NATS
+// subjects which may contain the * and > wildcards.
+func parseSubjectArchivestores(mappings []string, datastore string) ([]subjectArchivestore, error) {
+
+	routes := make([]subjectArchivestore, 0, len(mappings))
+
+	for _, mapping := range mappings {
+
+		pattern, root, ok := strings.Cut(mapping, "=")
+		pattern = strings.TrimSpace(pattern)
+		root = strings.TrimSpace(root)
+		if !ok || pattern == "" || root == "" {
+			return nil, fmt.Errorf("invalid subject_archivestores mapping %q, expected pattern=root", mapping)
+		}
+
+		tokens := strings.Split(pattern, ".")
+		for i, token := range tokens {
+			if token == "" || (token == ">" && i != len(tokens)-1) {
+				return nil, fmt.Errorf("invalid subject pattern %q in subject_archivestores", pattern)
+			}
+		}
+
+		if path.Clean(root) == path.Clean(datastore) {
+			return nil, fmt.Errorf("archivestore of subject %s must differ from the datastore: %s", pattern, root)
+		}
+
+		routes = append(routes, subjectArchivestore{
+			pattern: pattern,
+			tokens:  tokens,
+			root:    root,
+		})
+	}
+
+	return routes, nil
+}
+
+func (r subjectArchivestore) matches(subject string) bool {
+
+	tokens := strings.Split(subject, ".")
+
+	for i, token := range r.tokens {
+		if token == ">" {
+			return len(tokens) > i
+		}
+
+		if i >= len(tokens) || (token != "*" && token != tokens[i]) {
+			return false
+		}
+	}
+
+	return len(tokens) == len(r.tokens)
+}
+
+// subjectRoot returns the archivestore of the first mapping matching the
+// subject, or the default archivestore.
+func (u *Uploader) subjectRoot(subject string) string {
+
+	for _, r := range u.subjectArchivestores {
+		if r.matches(subject) {
+			return r.root
+		}
+	}
+
+	return u.archivestore
+}
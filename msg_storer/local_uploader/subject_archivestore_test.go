@@ -0,0 +1,86 @@
+package uploader
+
+import (
+	"path"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubjectArchivestores(t *testing.T) {
+	routes, err := parseSubjectArchivestores([]string{
+		"archive.bucketA.> = /archive/a",
+		"archive.*.job=/archive/any",
+	}, "/datastore")
+	assert.Nil(t, err)
+	assert.Len(t, routes, 2)
+	assert.Equal(t, "archive.bucketA.>", routes[0].pattern)
+	assert.Equal(t, "/archive/a", routes[0].root)
+
+	invalid := []string{
+		"archive.bucketA",
+		"=/archive/a",
+		"archive..job=/archive/a",
+		"archive.>.job=/archive/a",
+		"archive.bucketA=/datastore/",
+	}
+	for _, mapping := range invalid {
+		_, err := parseSubjectArchivestores([]string{mapping}, "/datastore")
+		assert.NotNil(t, err, mapping)
+	}
+}
+
+func TestSubjectArchivestoreMatches(t *testing.T) {
+	routes, err := parseSubjectArchivestores([]string{
+		"archive.bucketA.>=/a",
+		"archive.*.job=/b",
+	}, "/datastore")
+	assert.Nil(t, err)
+
+	assert.True(t, routes[0].matches("archive.bucketA.job"))
+	assert.True(t, routes[0].matches("archive.bucketA.job.host"))
+	assert.False(t, routes[0].matches("archive.bucketA"))
+	assert.False(t, routes[0].matches("archive.bucketB.job"))
+
+	assert.True(t, routes[1].matches("archive.bucketB.job"))
+	assert.False(t, routes[1].matches("archive.bucketB.job.host"))
+}
+
+func TestSubjectArchivestoreRouting(t *testing.T) {
+	u := newTestUploader(t)
+
+	storeA := path.Join(t.TempDir(), "archivestore-a")
+	storeB := path.Join(t.TempDir(), "archivestore-b")
+
+	routes, err := parseSubjectArchivestores([]string{
+		"archive.bucketA.>=" + storeA,
+		"archive.bucketB.>=" + storeB,
+	}, u.datastore)
+	assert.Nil(t, err)
+	u.subjectArchivestores = routes
+
+	cases := map[string]string{
+		"archive.bucketA.job.host": storeA,
+		"archive.bucketB.job.host": storeB,
+		"archive.bucketC.job.host": u.archivestore,
+	}
+
+	for subject, root := range cases {
+		filename := createTestFile(t, u, "100/100/MSG_1.db", subject)
+
+		archiveName, err := u.archive(&job{
+			seq:      "1",
+			filename: filename,
+			msg:      &nats.Msg{Subject: subject},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, path.Join(root, "100/100/MSG_1.db"), archiveName)
+		assert.FileExists(t, archiveName)
+	}
+
+	// without a message the default archivestore is used
+	archiveName, err := u.ArchivePathFor(path.Join(u.datastore, "100/100/MSG_2.db"))
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/100/MSG_2.db"), archiveName)
+}
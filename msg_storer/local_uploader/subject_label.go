@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var labelNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// subjectLabel records the subject token at the position as an index label.
+type subjectLabel struct {
+	position int
+	name     string
+}
+
+// parseSubjectTokenMap parses the subject_token_map mappings, each
+// position=name, where position is the index of the dot separated subject
+// token.
+func parseSubjectTokenMap(mappings []string) ([]subjectLabel, error) {
+
+	labels := make([]subjectLabel, 0, len(mappings))
+	names := make(map[string]bool, len(mappings))
+
+	for _, mapping := range mappings {
+
+		position, name, ok := strings.Cut(mapping, "=")
+		position = strings.TrimSpace(position)
+		name = strings.TrimSpace(name)
+		if !ok || position == "" || name == "" {
+			return nil, fmt.Errorf("invalid subject_token_map mapping %q, expected position=name", mapping)
+		}
+
+		p, err := strconv.Atoi(position)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("invalid subject_token_map position %q", position)
+		}
+
+		if !labelNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid subject_token_map name %q", name)
+		}
+
+		if names[name] {
+			return nil, fmt.Errorf("duplicate subject_token_map name %s", name)
+		}
+		names[name] = true
+
+		labels = append(labels, subjectLabel{
+			position: p,
+			name:     name,
+		})
+	}
+
+	return labels, nil
+}
+
+// subjectLabels returns the labels of the subject. Tokens missing from the
+// subject are left out.
+func (u *Uploader) subjectLabels(subject string) map[string]string {
+
+	if len(u.subjectTokenMap) == 0 || subject == "" {
+		return nil
+	}
+
+	tokens := strings.Split(subject, ".")
+
+	labels := make(map[string]string, len(u.subjectTokenMap))
+	for _, l := range u.subjectTokenMap {
+		if l.position < len(tokens) && tokens[l.position] != "" {
+			labels[l.name] = tokens[l.position]
+		}
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+
+	return labels
+}
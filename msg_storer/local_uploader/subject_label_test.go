@@ -0,0 +1,82 @@
+package uploader
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubjectTokenMap(t *testing.T) {
+	labels, err := parseSubjectTokenMap([]string{"0=tenant", " 4 = host "})
+	assert.Nil(t, err)
+	assert.Equal(t, []subjectLabel{{position: 0, name: "tenant"}, {position: 4, name: "host"}}, labels)
+
+	invalid := [][]string{
+		{"tenant"},
+		{"-1=tenant"},
+		{"x=tenant"},
+		{"0=ten ant"},
+		{"0=tenant", "1=tenant"},
+	}
+	for _, mappings := range invalid {
+		_, err := parseSubjectTokenMap(mappings)
+		assert.NotNil(t, err, mappings)
+	}
+}
+
+func TestSubjectTokenLabels(t *testing.T) {
+	u := newTestUploader(t)
+
+	labels, err := parseSubjectTokenMap([]string{"0=tenant", "4=host", "9=missing"})
+	assert.Nil(t, err)
+	u.subjectTokenMap = labels
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	u.msgHandler(&nats.Msg{
+		Subject: "tenantA.archive.bucket.job.host1",
+		Data:    []byte("1:" + filename),
+	})
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"tenant": "tenantA", "host": "host1"}, entry.Labels)
+
+	data, err := os.ReadFile(u.indexFilename(filename))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(data)), "\tlabel.host=host1\tlabel.tenant=tenantA"))
+
+	// no labels without a subject
+	second := createTestFile(t, u, "100/300/MSG_2.db", "data")
+	_, err = u.Archive("2", second)
+	assert.Nil(t, err)
+
+	entry, err = NewIndexReader(u.indexFilename(second)).Lookup("2")
+	assert.Nil(t, err)
+	assert.Nil(t, entry.Labels)
+}
+
+func TestSubjectTokenLabelsSQL(t *testing.T) {
+	u := newTestUploader(t)
+	u.sqlIndex = newTestSQLIndex(t)
+
+	labels, err := parseSubjectTokenMap([]string{"0=tenant"})
+	assert.Nil(t, err)
+	u.subjectTokenMap = labels
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	_, err = u.archive(&job{
+		seq:      "1",
+		filename: filename,
+		msg:      &nats.Msg{Subject: "tenant&A.archive.bucket.job.host"},
+	})
+	assert.Nil(t, err)
+
+	entry, err := u.indexReader(filename).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"tenant": "tenant&A"}, entry.Labels)
+}
@@ -0,0 +1,54 @@
+package uploader
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startTask runs fn every interval in the background until stopTasks is called.
+func (u *Uploader) startTask(name string, interval time.Duration, fn func(ctx context.Context) error) {
+
+	if u.taskCtx == nil {
+		u.taskCtx, u.taskCancel = context.WithCancel(context.Background())
+	}
+
+	ctx := u.taskCtx
+
+	u.taskWg.Add(1)
+	go func() {
+		defer u.taskWg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := fn(ctx); err != nil && ctx.Err() == nil {
+					u.logger.Error("Background task failed",
+						zap.String("task", name),
+						zap.Error(err),
+					)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// stopTasks stops all background tasks and waits for them to return.
+func (u *Uploader) stopTasks() {
+
+	if u.taskCancel == nil {
+		return
+	}
+
+	u.taskCancel()
+	u.taskWg.Wait()
+
+	u.taskCtx = nil
+	u.taskCancel = nil
+}
@@ -0,0 +1,153 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultColdArchivestore = ""
+	DefaultTierAfter        = 30 * 24 * time.Hour
+	DefaultTierInterval     = time.Hour
+)
+
+// promoteToColdTier moves archive files older than tier_after from the hot
+// archivestore to the cold archivestore and updates their index entries.
+func (u *Uploader) promoteToColdTier(ctx context.Context) error {
+
+	if u.coldArchivestore == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(-u.tierAfter)
+
+	return u.eachIndexFile(ctx, func(indexFilename string) error {
+		return u.promoteIndex(ctx, indexFilename, deadline)
+	})
+}
+
+// promoteIndex moves the archive files of the index older than deadline and
+// then rewrites the index, holding the index lock so no entry is appended
+// meanwhile. The files are moved back when the index can't be rewritten.
+func (u *Uploader) promoteIndex(ctx context.Context, indexFilename string, deadline time.Time) error {
+
+	unlock := u.dirLocks.lock(indexFilename)
+	defer unlock()
+
+	entries, err := NewIndexReader(indexFilename).List()
+	if err != nil {
+		return err
+	}
+
+	// hot archive name to cold archive name
+	moved := make(map[string]string)
+	for _, entry := range entries {
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		if _, ok := moved[entry.ArchiveName]; ok {
+			continue
+		}
+
+		coldName, ok := u.coldArchiveName(entry.ArchiveName)
+		if !ok {
+			continue
+		}
+
+		fi, err := os.Stat(entry.ArchiveName)
+		if err != nil || fi.ModTime().After(deadline) {
+			continue
+		}
+
+		if err := u.moveToTier(entry.ArchiveName, coldName); err != nil {
+			u.logger.Error(err.Error())
+			continue
+		}
+		moved[entry.ArchiveName] = coldName
+
+		u.logger.Debug("Moved archive file to cold tier",
+			zap.String("archiveName", entry.ArchiveName),
+			zap.String("coldName", coldName),
+		)
+	}
+
+	if len(moved) == 0 {
+		return nil
+	}
+
+	err = rewriteIndex(indexFilename, func(entry Entry) (Entry, bool) {
+		if coldName, ok := moved[entry.ArchiveName]; ok {
+			entry.ArchiveName = coldName
+		}
+		return entry, true
+	})
+	if err == nil {
+		return nil
+	}
+
+	// shards already rewritten are pointed back at the hot archive names
+	back := make(map[string]string, len(moved))
+	for hotName, coldName := range moved {
+		if rerr := u.moveToTier(coldName, hotName); rerr != nil {
+			u.logger.Error("Failed to move archive file back from cold tier",
+				zap.String("coldName", coldName),
+				zap.String("archiveName", hotName),
+				zap.Error(rerr),
+			)
+			continue
+		}
+		back[coldName] = hotName
+	}
+
+	rerr := rewriteIndex(indexFilename, func(entry Entry) (Entry, bool) {
+		if hotName, ok := back[entry.ArchiveName]; ok {
+			entry.ArchiveName = hotName
+		}
+		return entry, true
+	})
+	if rerr != nil {
+		return fmt.Errorf("%w: %w", err, rerr)
+	}
+
+	return err
+}
+
+// moveToTier moves an archive file between the archivestores, keeping it
+// immutable.
+func (u *Uploader) moveToTier(archiveName string, dest string) error {
+
+	if err := os.MkdirAll(path.Dir(dest), 0750); err != nil {
+		return err
+	}
+
+	if err := u.clearImmutable(archiveName); err != nil {
+		return err
+	}
+
+	if err := u.moveFile(archiveName, dest); err != nil {
+		u.makeImmutable(archiveName)
+		return err
+	}
+	u.makeImmutable(dest)
+
+	return nil
+}
+
+// coldArchiveName translates a hot archive file name into the cold archivestore.
+func (u *Uploader) coldArchiveName(archiveName string) (string, bool) {
+
+	hot := path.Join(u.archivestore)
+	if !strings.HasPrefix(archiveName, hot+"/") {
+		return "", false
+	}
+
+	return path.Join(u.coldArchivestore, strings.TrimPrefix(archiveName, hot+"/")), true
+}
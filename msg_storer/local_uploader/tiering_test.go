@@ -0,0 +1,145 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromoteToColdTier(t *testing.T) {
+	u := newTestUploader(t)
+	u.coldArchivestore = path.Join(t.TempDir(), "coldstore")
+	u.tierAfter = time.Hour
+
+	oldFile := createTestFile(t, u, "100/100/MSG_1.db", "old")
+	newFile := createTestFile(t, u, "100/100/MSG_2.db", "new")
+
+	oldArchive, err := u.Archive("1", oldFile)
+	assert.Nil(t, err)
+	newArchive, err := u.Archive("2", newFile)
+	assert.Nil(t, err)
+
+	past := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(oldArchive, past, past)
+	assert.Nil(t, err)
+
+	err = u.promoteToColdTier(context.Background())
+	assert.Nil(t, err)
+
+	coldArchive := path.Join(u.coldArchivestore, "100/100/MSG_1.db")
+	assert.FileExists(t, coldArchive)
+	assert.NoFileExists(t, oldArchive)
+	assert.FileExists(t, newArchive)
+
+	reader := NewIndexReader(u.indexFilename(oldFile))
+
+	entry, err := reader.Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, coldArchive, entry.ArchiveName)
+	assert.Equal(t, int64(3), entry.Size)
+
+	entry, err = reader.Lookup("2")
+	assert.Nil(t, err)
+	assert.Equal(t, newArchive, entry.ArchiveName)
+
+	entries, err := reader.List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestPromoteToColdTierCrossDevice(t *testing.T) {
+	u := newTestUploader(t)
+
+	if fi, err := os.Stat("/dev/shm"); err != nil || !fi.IsDir() {
+		t.Skip("no /dev/shm")
+	}
+
+	coldRoot, err := os.MkdirTemp("/dev/shm", "coldstore-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(coldRoot)
+
+	if same, err := sameDevice(path.Dir(u.archivestore), coldRoot); err != nil || same {
+		t.Skip("/dev/shm is on the same filesystem")
+	}
+
+	u.coldArchivestore = coldRoot
+	u.tierAfter = time.Hour
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "old")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	past := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(archiveName, past, past)
+	assert.Nil(t, err)
+
+	err = u.promoteToColdTier(context.Background())
+	assert.Nil(t, err)
+
+	coldArchive := path.Join(coldRoot, "100/100/MSG_1.db")
+	data, err := os.ReadFile(coldArchive)
+	assert.Nil(t, err)
+	assert.Equal(t, "old", string(data))
+	assert.NoFileExists(t, archiveName)
+
+	// no staging file is left in either archivestore
+	staged, _ := filepath.Glob(path.Join(coldRoot, "100/100", StagingPattern))
+	assert.Empty(t, staged)
+	staged, _ = filepath.Glob(path.Join(u.archivestore, StagingPattern))
+	assert.Empty(t, staged)
+
+	entry, err := NewIndexReader(u.indexFilename(filename)).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, coldArchive, entry.ArchiveName)
+}
+
+func TestPromoteToColdTierRollsBack(t *testing.T) {
+	u := newTestUploader(t)
+	u.coldArchivestore = path.Join(t.TempDir(), "coldstore")
+	u.tierAfter = time.Hour
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "old")
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	past := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(archiveName, past, past)
+	assert.Nil(t, err)
+
+	// the temporary index can't be created
+	indexFilename := u.indexFilename(filename)
+	err = os.Mkdir(indexFilename+".tmp", 0750)
+	assert.Nil(t, err)
+
+	err = u.promoteToColdTier(context.Background())
+	assert.NotNil(t, err)
+
+	assert.FileExists(t, archiveName)
+	assert.NoFileExists(t, path.Join(u.coldArchivestore, "100/100/MSG_1.db"))
+
+	entry, err := NewIndexReader(indexFilename).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+}
+
+func TestValidateConfigTierInterval(t *testing.T) {
+	root := t.TempDir()
+	scope := "validate_config_tier_interval"
+
+	viper.Set(scope+".datastore", path.Join(root, "datastore"))
+	viper.Set(scope+".archivestore", path.Join(root, "archivestore"))
+	viper.Set(scope+".cold_archivestore", path.Join(root, "coldstore"))
+	viper.Set(scope+".tier_interval", 0)
+	defer viper.Set(scope, nil)
+
+	err := ValidateConfig(scope)
+	assert.ErrorContains(t, err, "tier_interval must be positive")
+}
@@ -0,0 +1,95 @@
+package uploader
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	TracerName = "github.com/weedbox/whisper-modules/msg_storer/local_uploader"
+
+	SpanHandle   = "uploader.handle"
+	SpanChecksum = "uploader.checksum"
+	SpanMove     = "uploader.move"
+	SpanIndex    = "uploader.index"
+	SpanPublish  = "uploader.publish"
+
+	AttrSeq         = attribute.Key("archive.seq")
+	AttrFilename    = attribute.Key("archive.filename")
+	AttrArchiveName = attribute.Key("archive.archive_name")
+	AttrFileSize    = attribute.Key("archive.file_size")
+	AttrSubject     = attribute.Key("messaging.destination.name")
+)
+
+var propagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// newTracer returns the tracer of the provider, or a no-op tracer without one.
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+
+	return tp.Tracer(TracerName)
+}
+
+// messageContext extracts the trace context propagated in the message headers.
+func messageContext(m *nats.Msg) context.Context {
+
+	ctx := context.Background()
+	if m == nil || m.Header == nil {
+		return ctx
+	}
+
+	return propagator.Extract(ctx, headerCarrier(m.Header))
+}
+
+// headerCarrier adapts NATS headers, which are case sensitive unlike HTTP headers.
+type headerCarrier nats.Header
+
+func (c headerCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (u *Uploader) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+
+	if u.tracer == nil {
+		u.tracer = newTracer(nil)
+	}
+
+	return u.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span, recording the error status.
+func endSpan(span trace.Span, err error) {
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
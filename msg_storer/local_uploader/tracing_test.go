@@ -0,0 +1,112 @@
+package uploader
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTracedUploader(t *testing.T) (*Uploader, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+	})
+
+	u := newTestUploader(t)
+	u.tracer = newTracer(tp)
+
+	return u, exporter
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracingSpans(t *testing.T) {
+	u, exporter := newTracedUploader(t)
+	u.checksum = true
+	u.completionSubject = "uploader.completed"
+	u.completionPublisher = func(m *nats.Msg) error {
+		return nil
+	}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "traced")
+
+	m := nats.NewMsg("test")
+	m.Data = []byte("1:" + filename)
+	m.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	u.msgHandler(m)
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+
+	root, ok := byName[SpanHandle]
+	if !assert.True(t, ok) {
+		return
+	}
+
+	// the root span continues the trace of the message
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", root.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", root.Parent.SpanID().String())
+	assert.Equal(t, codes.Unset, root.Status.Code)
+
+	value, ok := spanAttr(root, AttrSeq)
+	assert.True(t, ok)
+	assert.Equal(t, "1", value.AsString())
+
+	value, ok = spanAttr(root, AttrFileSize)
+	assert.True(t, ok)
+	assert.Equal(t, int64(len("traced")), value.AsInt64())
+
+	for _, name := range []string{SpanChecksum, SpanMove, SpanIndex, SpanPublish} {
+		span, ok := byName[name]
+		if assert.True(t, ok, name) {
+			assert.Equal(t, root.SpanContext.SpanID(), span.Parent.SpanID(), name)
+			assert.Equal(t, root.SpanContext.TraceID(), span.SpanContext.TraceID(), name)
+		}
+	}
+}
+
+func TestTracingError(t *testing.T) {
+	u, exporter := newTracedUploader(t)
+
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("404:" + path.Join(u.datastore, "100/300/MSG_404.db")),
+	})
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, SpanHandle, spans[0].Name)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.False(t, spans[0].Parent.IsValid())
+	}
+}
+
+func TestTracingNoop(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + filename),
+	})
+
+	assert.Equal(t, uint64(1), u.counters.archived.Load())
+}
@@ -0,0 +1,152 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultSourceTrashDir     = ""
+	DefaultTrashRetention     = 24 * time.Hour
+	DefaultTrashPurgeInterval = time.Hour
+)
+
+// removeSource removes the source once its copy is archived, or moves it into
+// source_trash_dir, under its path relative to the datastore, to keep it for
+// trash_retention in case the archive turns out bad.
+func (u *Uploader) removeSource(filename string) error {
+
+	fsys := u.fileSystem()
+
+	if u.sourceTrashDir == "" {
+		return fsys.Remove(filename)
+	}
+
+	trashName := u.trashName(filename)
+
+	if err := fsys.MkdirAll(path.Dir(trashName), DefaultDirMode); err != nil {
+		return err
+	}
+
+	if err := u.moveToTrash(filename, trashName); err != nil {
+		return err
+	}
+
+	// the retention counts from the time the source was trashed
+	now := time.Now()
+	if err := fsys.Chtimes(trashName, now, now); err != nil {
+		u.logger.Warn("Failed to touch trashed source",
+			zap.String("trashName", trashName),
+			zap.Error(err),
+		)
+	}
+
+	u.logger.Debug("Moved source to trash",
+		zap.String("fileName", filename),
+		zap.String("trashName", trashName),
+	)
+
+	return nil
+}
+
+// moveToTrash renames the source into the trash, or copies it when the trash
+// is on another filesystem than the datastore.
+func (u *Uploader) moveToTrash(filename string, trashName string) error {
+
+	fsys := u.fileSystem()
+
+	err := fsys.Rename(filename, trashName)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := u.copyFile(filename, trashName); err != nil {
+		return err
+	}
+
+	return fsys.Remove(filename)
+}
+
+func (u *Uploader) trashName(filename string) string {
+
+	filename = path.Clean(filename)
+	datastore := path.Clean(u.datastore)
+
+	rel := path.Base(filename)
+	if strings.HasPrefix(filename, datastore+"/") {
+		rel = strings.TrimPrefix(filename, datastore+"/")
+	}
+
+	return path.Join(u.sourceTrashDir, rel)
+}
+
+// purgeTrash removes trashed sources older than trash_retention, and the
+// directories left empty.
+func (u *Uploader) purgeTrash(ctx context.Context) error {
+
+	fsys := u.fileSystem()
+
+	deadline := time.Now().Add(-u.trashRetention)
+	dirs := make([]string, 0)
+
+	err := filepath.WalkDir(u.sourceTrashDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if p != u.sourceTrashDir {
+				dirs = append(dirs, p)
+			}
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if fi.ModTime().After(deadline) {
+			return nil
+		}
+
+		if err := fsys.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		u.logger.Debug("Purged trashed source",
+			zap.String("trashName", p),
+		)
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// deepest first, removing a directory which isn't empty fails
+	for i := len(dirs) - 1; i >= 0; i-- {
+		fsys.Remove(dirs[i])
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package uploader
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceTrash(t *testing.T) {
+	u := newTestUploader(t)
+	u.sourceTrashDir = path.Join(t.TempDir(), "trash")
+	u.trashRetention = time.Hour
+
+	// cross-device, so the source is copied instead of renamed
+	u.fs = &flakyRenameFileSystem{err: syscall.EXDEV, failures: 1}
+
+	err := u.checkStagingDir()
+	assert.Nil(t, err)
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+	archiveName := path.Join(u.archivestore, "100/300/MSG_1.db")
+	os.MkdirAll(path.Dir(archiveName), 0750)
+
+	err = u.moveFile(filename, archiveName)
+	assert.Nil(t, err)
+	assert.FileExists(t, archiveName)
+	assert.NoFileExists(t, filename)
+
+	trashName := path.Join(u.sourceTrashDir, "100/300/MSG_1.db")
+	data, err := os.ReadFile(trashName)
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+
+	// kept within the retention
+	err = u.purgeTrash(context.Background())
+	assert.Nil(t, err)
+	assert.FileExists(t, trashName)
+
+	// purged past the retention, with the emptied directories
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(trashName, old, old)
+
+	err = u.purgeTrash(context.Background())
+	assert.Nil(t, err)
+	assert.NoFileExists(t, trashName)
+	assert.NoDirExists(t, path.Join(u.sourceTrashDir, "100"))
+	assert.DirExists(t, u.sourceTrashDir)
+}
+
+func TestSourceTrashDisabled(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+
+	err := u.removeSource(filename)
+	assert.Nil(t, err)
+	assert.NoFileExists(t, filename)
+
+	// nothing to purge without a trash dir
+	u.sourceTrashDir = path.Join(t.TempDir(), "missing")
+	assert.Nil(t, u.purgeTrash(context.Background()))
+}
+
+// crossDeviceFileSystem fails to rename the files of the datastore with EXDEV.
+type crossDeviceFileSystem struct {
+	osFileSystem
+	datastore string
+}
+
+func (f *crossDeviceFileSystem) Rename(oldpath string, newpath string) error {
+
+	if strings.HasPrefix(oldpath, f.datastore+"/") {
+		return &fs.PathError{Op: "rename", Path: oldpath, Err: syscall.EXDEV}
+	}
+
+	return f.osFileSystem.Rename(oldpath, newpath)
+}
+
+func TestSourceTrashCrossDevice(t *testing.T) {
+	u := newTestUploader(t)
+	u.sourceTrashDir = path.Join(t.TempDir(), "trash")
+
+	// the trash is on another filesystem as well
+	u.fs = &crossDeviceFileSystem{datastore: u.datastore}
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", "data")
+	archiveName := path.Join(u.archivestore, "100/300/MSG_1.db")
+	os.MkdirAll(path.Dir(archiveName), 0750)
+
+	err := u.moveFile(filename, archiveName)
+	assert.Nil(t, err)
+	assert.FileExists(t, archiveName)
+	assert.NoFileExists(t, filename)
+
+	data, err := os.ReadFile(path.Join(u.sourceTrashDir, "100/300/MSG_1.db"))
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestValidateConfigTrashRetention(t *testing.T) {
+	root := t.TempDir()
+	scope := "validate_config_trash"
+
+	viper.Set(scope+".datastore", path.Join(root, "datastore"))
+	viper.Set(scope+".archivestore", path.Join(root, "archivestore"))
+	viper.Set(scope+".source_trash_dir", path.Join(root, "trash"))
+	viper.Set(scope+".trash_retention", -time.Hour)
+	defer viper.Set(scope, nil)
+
+	err := ValidateConfig(scope)
+	assert.ErrorContains(t, err, "trash_retention must be positive")
+}
@@ -2,24 +2,37 @@ package uploader
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
-	"strings"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
+	"github.com/weedbox/common-modules/database"
 	"github.com/weedbox/common-modules/nats_connector"
 )
 
+var (
+	ErrSameArchivePath = errors.New("Archive path is the same as the source, check datastore and archivestore.")
+)
+
 const (
 	DefaultDomain       = "onglai-msg"
 	DefaultSubject      = "%s.archive.bucket.job.%s"
 	DefaultDatastore    = "/datastore"
 	DefaultArchivestore = "/archivestore"
+	DefaultIndexMaxSize = 0
 )
 
 type Uploader struct {
@@ -30,6 +43,236 @@ type Uploader struct {
 	datastore    string
 	archivestore string
 	hostname     string
+	onCollision  string
+	indexMaxSize int64
+	setXattrs    bool
+	setImmutable bool
+	rollupStats  bool
+	xattrSeq     string
+	xattrOrigin  string
+	tempDir      string
+	checksum     bool
+
+	coldArchivestore string
+	tierAfter        time.Duration
+	tierInterval     time.Duration
+
+	taskCtx    context.Context
+	taskCancel context.CancelFunc
+	taskWg     sync.WaitGroup
+
+	ackMode       string
+	normalizeCase bool
+	errorSubject  string
+	recent        *recentBuffer
+	durable       string
+	ackPolicy     string
+	numReplicas   int
+
+	deliverPolicy    string
+	deliverStartTime time.Time
+
+	subjectToken    int
+	reindexPolicy   string
+	copyBufferSize  int
+	metadataSidecar bool
+	pauseNakDelay   time.Duration
+	paused          atomic.Bool
+	counters        counters
+	tracer          trace.Tracer
+	seqFormat       seqFormat
+	fs              FileSystem
+	fsyncArchive    bool
+	fsyncDir        bool
+	maxNameLength   int
+
+	renameRetries      int
+	renameRetryBackoff time.Duration
+
+	degraded              atomic.Bool
+	degradedNakDelay      time.Duration
+	degradedProbeInterval time.Duration
+
+	fixDirPermissions bool
+	dirUmask          fs.FileMode
+	enforceDirModes   bool
+	enforcedDirs      sync.Map
+
+	maintenanceInterval time.Duration
+	stagingMaxAge       time.Duration
+	quarantineDir       string
+
+	verifyInterval  time.Duration
+	verifyBatchSize int
+	verifyRate      int
+	verifyEvents    bool
+	verifyCursor    int
+
+	auditInterval  time.Duration
+	auditBatchSize int
+	auditRate      int
+	auditFileMode  fs.FileMode
+	auditUID       int
+	auditGID       int
+	auditFix       bool
+	auditEvents    bool
+	auditCursor    int
+
+	startupDelay       time.Duration
+	startupCheckMounts bool
+
+	// start running in the background after startup_delay
+	startupCancel  context.CancelFunc
+	startupDone    chan struct{}
+	startupStarted bool
+
+	subjectArchivestores []subjectArchivestore
+	fallbackArchivestore string
+	subjectTokenMap      []subjectLabel
+	subdirRegex          *regexp.Regexp
+
+	archiveCounter *archiveCounter
+	counterFile    string
+
+	indexBackend string
+	sqlIndex     *SQLIndex
+
+	maxStoreBytes int64
+	evictMinAge   time.Duration
+	evictInterval time.Duration
+
+	multilinePayload bool
+	payloadCodec     string
+	maxPayloadSize   int
+
+	streamRetentionCheck string
+	streamSubjectCheck   string
+	bindStream           string
+
+	createStreamIfMissing bool
+	streamName            string
+	streamSubjects        []string
+	streamRetentionPolicy nats.RetentionPolicy
+	streamMaxAge          time.Duration
+
+	parallelCopyThreshold int64
+	parallelCopyChunkSize int64
+	parallelCopyWorkers   int
+
+	sourceTrashDir     string
+	trashRetention     time.Duration
+	trashPurgeInterval time.Duration
+
+	workers         int
+	workerQueueSize int
+	orderKeySource  string
+	pool            *workerPool
+	priorityHeader  string
+	priorityLevels  int
+	priorityAging   time.Duration
+	dirLocks        dirLocks
+	partitionByHost bool
+	partitionByDate string
+	seqSource       string
+
+	sizeBuckets        []sizeBucket
+	sizeBucketOverflow string
+
+	archivePrefix string
+	archiveSuffix string
+	compression   string
+
+	sourceWait         time.Duration
+	sourceWaitInterval time.Duration
+
+	controlSubject      string
+	controlSub          *nats.Subscription
+	controlReplyTimeout time.Duration
+
+	sub          *nats.Subscription
+	subMutex     sync.Mutex
+	shutdownMode string
+	startedAt    time.Time
+
+	heartbeatSubject  string
+	heartbeatInterval time.Duration
+
+	shutdownReportSubject string
+
+	indexCheckpoint bool
+	checkpoints     indexCheckpoints
+
+	recordDeletedMarkers bool
+
+	indexSyncEvery    int
+	indexSyncInterval time.Duration
+	indexSync         indexSyncer
+
+	dedupKey    string
+	dedupWindow time.Duration
+	seen        seenJobs
+
+	consumerPendingInterval time.Duration
+	consumerPending         consumerPending
+
+	versioning  string
+	maxVersions int
+
+	checksumExtensions []string
+
+	flattenDepth int
+
+	urlMode     bool
+	urlTimeout  time.Duration
+	urlNakDelay time.Duration
+	urlIndexDir string
+
+	provenanceHeaders []string
+
+	lowSpace        atomic.Bool
+	noSpaceNakDelay time.Duration
+
+	middleware []Middleware
+
+	// readOnly is set on replicas only serving queries, unlike degraded
+	// which suspends archiving while the archivestore is read-only
+	readOnly bool
+
+	lowercaseArchivePaths bool
+
+	partialFailureMode string
+
+	grpcAddress string
+	grpcServer  *grpc.Server
+
+	events *eventStream
+
+	verifyMode       string
+	verifySamples    int
+	verifySampleSize int
+
+	postWriteCacheDrop bool
+
+	recordFailures         bool
+	missingSourceTermAfter int
+	requeueing             atomic.Bool
+	inflight               atomic.Int64
+
+	completionSubject      string
+	completionRetries      int
+	completionRetryBackoff time.Duration
+	completionPublisher    func(m *nats.Msg) error
+	compressEvents         bool
+	completionAck          bool
+	completionAckWait      time.Duration
+	completionNakDelay     time.Duration
+	completionBatcher      *completionBatcher
+
+	indexWriteTimeout        time.Duration
+	indexWriter              func(filename string, oldEntry *Entry, entry Entry) error
+	hasher                   func(filename string) (string, error)
+	journal                  *journal
+	journalReconcileInterval time.Duration
 }
 
 type Params struct {
@@ -37,30 +280,47 @@ type Params struct {
 	NATSConnector *nats_connector.NATSConnector
 	Lifecycle     fx.Lifecycle
 	Logger        *zap.Logger
+	Shutdowner    fx.Shutdowner
+
+	TracerProvider trace.TracerProvider `optional:"true"`
+	FileSystem     FileSystem           `optional:"true"`
+
+	// Database backs the index when index_backend is sql.
+	Database database.DatabaseConnector `optional:"true"`
 }
 
-func Module(scope string) fx.Option {
+// Module provides the uploader of the scope. The middleware is applied around
+// the message handler in order, the first one being the outermost.
+func Module(scope string, middleware ...Middleware) fx.Option {
 
 	var u *Uploader
 
 	return fx.Options(
-		fx.Provide(func(p Params) *Uploader {
+		fx.Provide(func(p Params) (*Uploader, error) {
+
+			if err := validateScope(scope); err != nil {
+				return nil, err
+			}
 
 			u = &Uploader{
 				params: p,
 				logger: p.Logger.Named(scope),
 				scope:  scope,
+				tracer: newTracer(p.TracerProvider),
+				fs:     p.FileSystem,
+
+				middleware: middleware,
 			}
 			u.initDefaultConfigs()
-			return u
+			return u, nil
 		}),
 		fx.Populate(&u),
 		fx.Invoke(func(p Params) {
 
 			p.Lifecycle.Append(
 				fx.Hook{
-					OnStart: u.onStart,
-					OnStop:  u.onStop,
+					OnStart: u.onStartScope,
+					OnStop:  u.onStopScope,
 				},
 			)
 		}),
@@ -76,15 +336,160 @@ func (u *Uploader) initDefaultConfigs() {
 	viper.SetDefault(u.getConfigPath("archive_domain"), DefaultDomain)
 	viper.SetDefault(u.getConfigPath("datastore"), DefaultDatastore)
 	viper.SetDefault(u.getConfigPath("archivestore"), DefaultArchivestore)
+	viper.SetDefault(u.getConfigPath("on_collision"), DefaultOnCollision)
+	viper.SetDefault(u.getConfigPath("index_max_size"), DefaultIndexMaxSize)
+	viper.SetDefault(u.getConfigPath("set_xattrs"), false)
+	viper.SetDefault(u.getConfigPath("set_immutable"), false)
+	viper.SetDefault(u.getConfigPath("rollup_stats"), false)
+	viper.SetDefault(u.getConfigPath("xattr_seq"), DefaultXattrSeq)
+	viper.SetDefault(u.getConfigPath("xattr_origin"), DefaultXattrOrigin)
+	viper.SetDefault(u.getConfigPath("control_subject"), DefaultControlSubject)
+	viper.SetDefault(u.getConfigPath("control_reply_timeout"), DefaultControlReplyTimeout)
+	viper.SetDefault(u.getConfigPath("temp_dir"), DefaultTempDir)
+	viper.SetDefault(u.getConfigPath("checksum"), false)
+	viper.SetDefault(u.getConfigPath("ack_mode"), DefaultAckMode)
+	viper.SetDefault(u.getConfigPath("normalize_case"), false)
+	viper.SetDefault(u.getConfigPath("error_subject"), DefaultErrorSubject)
+	viper.SetDefault(u.getConfigPath("recent_archives"), DefaultRecentArchives)
+	viper.SetDefault(u.getConfigPath("durable"), DefaultDurable)
+	viper.SetDefault(u.getConfigPath("ack_policy"), DefaultAckPolicy)
+	viper.SetDefault(u.getConfigPath("num_replicas"), DefaultNumReplicas)
+	viper.SetDefault(u.getConfigPath("subject_token"), DefaultSubjectToken)
+	viper.SetDefault(u.getConfigPath("subject_token_map"), []string{})
+	viper.SetDefault(u.getConfigPath("subdir_regex"), DefaultSubdirRegex)
+	viper.SetDefault(u.getConfigPath("reindex_policy"), DefaultReindexPolicy)
+	viper.SetDefault(u.getConfigPath("copy_buffer_size"), DefaultCopyBufferSize)
+	viper.SetDefault(u.getConfigPath("parallel_copy_threshold"), DefaultParallelCopyThreshold)
+	viper.SetDefault(u.getConfigPath("parallel_copy_chunk_size"), DefaultParallelCopyChunkSize)
+	viper.SetDefault(u.getConfigPath("parallel_copy_workers"), DefaultParallelCopyWorkers)
+	viper.SetDefault(u.getConfigPath("source_trash_dir"), DefaultSourceTrashDir)
+	viper.SetDefault(u.getConfigPath("trash_retention"), DefaultTrashRetention)
+	viper.SetDefault(u.getConfigPath("trash_purge_interval"), DefaultTrashPurgeInterval)
+	viper.SetDefault(u.getConfigPath("write_metadata_sidecar"), false)
+	viper.SetDefault(u.getConfigPath("provenance_headers"), []string{})
+	viper.SetDefault(u.getConfigPath("pause_nak_delay"), DefaultPauseNakDelay)
+	viper.SetDefault(u.getConfigPath("degraded_nak_delay"), DefaultDegradedNakDelay)
+	viper.SetDefault(u.getConfigPath("degraded_probe_interval"), DefaultDegradedProbeInterval)
+	viper.SetDefault(u.getConfigPath("partition_by_host"), false)
+	viper.SetDefault(u.getConfigPath("partition_by_date"), DefaultPartitionByDate)
+	viper.SetDefault(u.getConfigPath("size_buckets"), []string{})
+	viper.SetDefault(u.getConfigPath("size_bucket_overflow"), DefaultSizeBucketOverflow)
+	viper.SetDefault(u.getConfigPath("seq_source"), DefaultSeqSource)
+	viper.SetDefault(u.getConfigPath("seq_format"), DefaultSeqFormat)
+	viper.SetDefault(u.getConfigPath("source_wait"), DefaultSourceWait)
+	viper.SetDefault(u.getConfigPath("source_wait_interval"), DefaultSourceWaitInterval)
+	viper.SetDefault(u.getConfigPath("archive_prefix"), DefaultArchivePrefix)
+	viper.SetDefault(u.getConfigPath("archive_suffix"), DefaultArchiveSuffix)
+	viper.SetDefault(u.getConfigPath("compression"), DefaultCompression)
+	viper.SetDefault(u.getConfigPath("shutdown_mode"), DefaultShutdownMode)
+	viper.SetDefault(u.getConfigPath("record_failures"), false)
+	viper.SetDefault(u.getConfigPath("missing_source_term_after"), DefaultMissingSourceTermAfter)
+	viper.SetDefault(u.getConfigPath("deliver_policy"), DefaultDeliverPolicy)
+	viper.SetDefault(u.getConfigPath("deliver_start_time"), DefaultDeliverStartTime)
+	viper.SetDefault(u.getConfigPath("verify_mode"), DefaultVerifyMode)
+	viper.SetDefault(u.getConfigPath("post_write_cache_drop"), false)
+	viper.SetDefault(u.getConfigPath("verify_samples"), DefaultVerifySamples)
+	viper.SetDefault(u.getConfigPath("verify_sample_size"), DefaultVerifySampleSize)
+	viper.SetDefault(u.getConfigPath("fsync_archive"), true)
+	viper.SetDefault(u.getConfigPath("fsync_dir"), false)
+	viper.SetDefault(u.getConfigPath("rename_retries"), DefaultRenameRetries)
+	viper.SetDefault(u.getConfigPath("rename_retry_backoff"), DefaultRenameRetryBackoff)
+	viper.SetDefault(u.getConfigPath("max_name_length"), DefaultMaxNameLength)
+	viper.SetDefault(u.getConfigPath("multiline_payload"), false)
+	viper.SetDefault(u.getConfigPath("payload_codec"), DefaultPayloadCodec)
+	viper.SetDefault(u.getConfigPath("max_payload_size"), DefaultMaxPayloadSize)
+	viper.SetDefault(u.getConfigPath("workers"), DefaultWorkers)
+	viper.SetDefault(u.getConfigPath("worker_queue_size"), DefaultQueueSize)
+	viper.SetDefault(u.getConfigPath("stream_retention_check"), DefaultStreamRetentionCheck)
+	viper.SetDefault(u.getConfigPath("stream_subject_check"), DefaultStreamSubjectCheck)
+	viper.SetDefault(u.getConfigPath("bind_stream"), DefaultBindStream)
+	viper.SetDefault(u.getConfigPath("dedup_key"), DefaultDedupKey)
+	viper.SetDefault(u.getConfigPath("dedup_window"), DefaultDedupWindow)
+	viper.SetDefault(u.getConfigPath("consumer_pending_interval"), DefaultConsumerPendingInterval)
+	viper.SetDefault(u.getConfigPath("versioning"), DefaultVersioning)
+	viper.SetDefault(u.getConfigPath("max_versions"), DefaultMaxVersions)
+	viper.SetDefault(u.getConfigPath("checksum_extensions"), []string{})
+	viper.SetDefault(u.getConfigPath("flatten_depth"), DefaultFlattenDepth)
+	viper.SetDefault(u.getConfigPath("url_mode"), DefaultURLMode)
+	viper.SetDefault(u.getConfigPath("url_timeout"), DefaultURLTimeout)
+	viper.SetDefault(u.getConfigPath("url_nak_delay"), DefaultURLNakDelay)
+	viper.SetDefault(u.getConfigPath("url_index_dir"), DefaultURLIndexDir)
+	viper.SetDefault(u.getConfigPath("no_space_nak_delay"), DefaultNoSpaceNakDelay)
+	viper.SetDefault(u.getConfigPath("read_only"), DefaultReadOnly)
+	viper.SetDefault(u.getConfigPath("lowercase_archive_paths"), false)
+	viper.SetDefault(u.getConfigPath("partial_failure_mode"), DefaultPartialFailureMode)
+	viper.SetDefault(u.getConfigPath("grpc_address"), DefaultGRPCAddress)
+	viper.SetDefault(u.getConfigPath("create_stream_if_missing"), false)
+	viper.SetDefault(u.getConfigPath("stream_name"), "")
+	viper.SetDefault(u.getConfigPath("stream_subjects"), []string{})
+	viper.SetDefault(u.getConfigPath("stream_retention_policy"), DefaultStreamRetentionPolicy)
+	viper.SetDefault(u.getConfigPath("stream_max_age"), DefaultStreamMaxAge)
+	viper.SetDefault(u.getConfigPath("priority_header"), DefaultPriorityHeader)
+	viper.SetDefault(u.getConfigPath("priority_levels"), DefaultPriorityLevels)
+	viper.SetDefault(u.getConfigPath("priority_aging"), DefaultPriorityAging)
+	viper.SetDefault(u.getConfigPath("heartbeat_subject"), DefaultHeartbeatSubject)
+	viper.SetDefault(u.getConfigPath("shutdown_report_subject"), DefaultShutdownReportSubject)
+	viper.SetDefault(u.getConfigPath("index_checkpoint"), false)
+	viper.SetDefault(u.getConfigPath("record_deleted_markers"), false)
+	viper.SetDefault(u.getConfigPath("index_sync_every"), DefaultIndexSyncEvery)
+	viper.SetDefault(u.getConfigPath("index_sync_interval"), DefaultIndexSyncInterval)
+	viper.SetDefault(u.getConfigPath("heartbeat_interval"), DefaultHeartbeatInterval)
+	viper.SetDefault(u.getConfigPath("event_buffer_size"), DefaultEventBufferSize)
+	viper.SetDefault(u.getConfigPath("event_overflow"), DefaultEventOverflow)
+	viper.SetDefault(u.getConfigPath("index_write_timeout"), DefaultIndexWriteTimeout)
+	viper.SetDefault(u.getConfigPath("journal_dir"), DefaultJournalDir)
+	viper.SetDefault(u.getConfigPath("journal_reconcile_interval"), DefaultJournalReconcileInterval)
+	viper.SetDefault(u.getConfigPath("order_key"), DefaultOrderKey)
+	viper.SetDefault(u.getConfigPath("fix_dir_permissions"), false)
+	viper.SetDefault(u.getConfigPath("enforce_dir_mode"), false)
+	viper.SetDefault(u.getConfigPath("dir_umask"), DefaultDirUmask)
+	viper.SetDefault(u.getConfigPath("maintenance_interval"), DefaultMaintenanceInterval)
+	viper.SetDefault(u.getConfigPath("staging_max_age"), DefaultStagingMaxAge)
+	viper.SetDefault(u.getConfigPath("quarantine_dir"), DefaultQuarantineDir)
+	viper.SetDefault(u.getConfigPath("subject_archivestores"), []string{})
+	viper.SetDefault(u.getConfigPath("fallback_archivestore"), DefaultFallbackArchivestore)
+	viper.SetDefault(u.getConfigPath("archive_counter"), DefaultArchiveCounter)
+	viper.SetDefault(u.getConfigPath("counter_file"), "")
+	viper.SetDefault(u.getConfigPath("index_backend"), DefaultIndexBackend)
+	viper.SetDefault(u.getConfigPath("verify_interval"), DefaultVerifyInterval)
+	viper.SetDefault(u.getConfigPath("verify_batch_size"), DefaultVerifyBatchSize)
+	viper.SetDefault(u.getConfigPath("verify_rate"), DefaultVerifyRate)
+	viper.SetDefault(u.getConfigPath("verify_events"), DefaultVerifyEvents)
+	viper.SetDefault(u.getConfigPath("audit_interval"), DefaultAuditInterval)
+	viper.SetDefault(u.getConfigPath("audit_batch_size"), DefaultAuditBatchSize)
+	viper.SetDefault(u.getConfigPath("audit_rate"), DefaultAuditRate)
+	viper.SetDefault(u.getConfigPath("audit_file_mode"), DefaultAuditFileMode)
+	viper.SetDefault(u.getConfigPath("audit_uid"), DefaultAuditOwner)
+	viper.SetDefault(u.getConfigPath("audit_gid"), DefaultAuditOwner)
+	viper.SetDefault(u.getConfigPath("audit_fix"), DefaultAuditFix)
+	viper.SetDefault(u.getConfigPath("audit_events"), DefaultAuditEvents)
+	viper.SetDefault(u.getConfigPath("startup_delay"), DefaultStartupDelay)
+	viper.SetDefault(u.getConfigPath("startup_check_mounts"), DefaultStartupCheckMounts)
+	viper.SetDefault(u.getConfigPath("max_store_bytes"), DefaultMaxStoreBytes)
+	viper.SetDefault(u.getConfigPath("evict_min_age"), DefaultEvictMinAge)
+	viper.SetDefault(u.getConfigPath("evict_interval"), DefaultEvictInterval)
+	viper.SetDefault(u.getConfigPath("completion_subject"), DefaultCompletionSubject)
+	viper.SetDefault(u.getConfigPath("completion_retries"), DefaultCompletionRetries)
+	viper.SetDefault(u.getConfigPath("completion_retry_backoff"), DefaultCompletionRetryBackoff)
+	viper.SetDefault(u.getConfigPath("compress_events"), DefaultCompressEvents)
+	viper.SetDefault(u.getConfigPath("completion_ack"), DefaultCompletionAck)
+	viper.SetDefault(u.getConfigPath("completion_ack_wait"), DefaultCompletionAckWait)
+	viper.SetDefault(u.getConfigPath("completion_nak_delay"), DefaultCompletionNakDelay)
+	viper.SetDefault(u.getConfigPath("completion_batch_size"), DefaultCompletionBatchSize)
+	viper.SetDefault(u.getConfigPath("completion_batch_interval"), DefaultCompletionBatchInterval)
+	viper.SetDefault(u.getConfigPath("cold_archivestore"), DefaultColdArchivestore)
+	viper.SetDefault(u.getConfigPath("tier_after"), DefaultTierAfter)
+	viper.SetDefault(u.getConfigPath("tier_interval"), DefaultTierInterval)
 }
 
 func (u *Uploader) onStart(ctx context.Context) error {
 
 	u.logger.Info("Starting Uploader")
+	u.startedAt = time.Now()
 
-	u.domain = viper.GetString(u.getConfigPath("archive_domain"))
-	u.datastore = viper.GetString(u.getConfigPath("datastore"))
-	u.archivestore = viper.GetString(u.getConfigPath("archivestore"))
+	if err := u.loadConfig(); err != nil {
+		return err
+	}
 
 	//get hostname
 	hostname, err := os.Hostname()
@@ -93,15 +498,535 @@ func (u *Uploader) onStart(ctx context.Context) error {
 	}
 	u.hostname = hostname
 
+	if u.readOnly {
+		return u.startReplica()
+	}
+
+	if u.startupDelay > 0 {
+		u.startAfterDelay()
+		return nil
+	}
+
+	return u.delayedStart(ctx, u.start)
+}
+
+// start creates the directories, starts the background tasks and subscribes.
+// It runs after the stores were checked, so nothing is written below the mount
+// point of a store which isn't mounted yet.
+func (u *Uploader) start() error {
+
+	err := u.checkStagingDir()
+	if err != nil {
+		return err
+	}
+
+	err = u.checkSeqSource()
+	if err != nil {
+		return err
+	}
+
+	if viper.GetBool(u.getConfigPath("archive_counter")) {
+		u.archiveCounter, err = loadArchiveCounter(u.counterFilename())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = u.openIndexBackend()
+	if err != nil {
+		return err
+	}
+
+	if dir := viper.GetString(u.getConfigPath("journal_dir")); dir != "" {
+		u.journal, err = newJournal(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	// batch completion events
+	completionBatchSize := viper.GetInt(u.getConfigPath("completion_batch_size"))
+	if completionBatchSize > 0 && u.completionSubject != "" {
+		u.completionBatcher = newCompletionBatcher(
+			u,
+			completionBatchSize,
+			viper.GetDuration(u.getConfigPath("completion_batch_interval")),
+		)
+		u.completionBatcher.start()
+	}
+
+	// move old archive files to the cold tier
+	if u.coldArchivestore != "" {
+		u.startTask("tiering", u.tierInterval, u.promoteToColdTier)
+	}
+
+	// clean up staging files and orphans
+	if u.maintenanceInterval > 0 {
+		u.startTask("maintenance", u.maintenanceInterval, u.maintain)
+	}
+
+	// verify the archivestore against the index in the background
+	if u.verifyInterval > 0 {
+		u.startTask("verify", u.verifyInterval, u.verifyBatch)
+	}
+
+	// report archive paths whose owner or mode drifted
+	if u.auditInterval > 0 {
+		u.startTask("audit", u.auditInterval, u.auditBatch)
+	}
+
+	// keep the archivestore under the byte budget
+	if u.maxStoreBytes > 0 {
+		u.startTask("eviction", u.evictInterval, u.evict)
+	}
+
+	// leave the degraded mode once the archivestore is writable again
+	u.startTask("degraded", u.degradedProbeInterval, u.probeWritable)
+
+	// purge trashed sources past the retention
+	if u.sourceTrashDir != "" {
+		u.startTask("trash", u.trashPurgeInterval, u.purgeTrash)
+	}
+
+	// bound the time index entries stay unsynced
+	if u.indexSyncEvery > 0 && u.indexSyncInterval > 0 {
+		u.startTask("index_sync", u.indexSyncInterval, u.syncIndexes)
+	}
+
+	// move journaled entries into their indexes
+	if u.journal != nil {
+		u.startTask("journal", u.journalReconcileInterval, u.reconcileJournal)
+	}
+
+	// surface the server-side backlog of the consumer
+	if u.consumerPendingInterval > 0 {
+		u.startTask("consumer_pending", u.consumerPendingInterval, u.pollConsumerPending)
+	}
+
+	// tell monitoring the uploader is alive, even when idle
+	if u.heartbeatSubject != "" {
+		u.startTask("heartbeat", u.heartbeatInterval, u.publishHeartbeat)
+	}
+
+	// handle messages concurrently
+	switch {
+	case u.priorityHeader != "":
+		u.pool = newPriorityWorkerPool(u.workers, u.workerQueueSize, u.priorityLevels, u.priorityAging, u.coreHandler())
+	case u.workers > 1:
+		u.pool = newWorkerPool(u.workers, u.workerQueueSize, u.coreHandler())
+	}
+
 	err = u.startSubscriber()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = u.startControlSubscriber()
+	if err != nil {
+		return err
+	}
+
+	u.register()
+
+	return u.startGRPCServer()
+}
+
+// loadConfig reads the configs of the scope and validates them, reporting
+// every invalid config at once rather than stopping at the first one.
+func (u *Uploader) loadConfig() error {
+
+	var errs []error
+
+	u.domain = viper.GetString(u.getConfigPath("archive_domain"))
+	u.datastore = viper.GetString(u.getConfigPath("datastore"))
+	u.archivestore = viper.GetString(u.getConfigPath("archivestore"))
+	if path.Clean(u.datastore) == path.Clean(u.archivestore) {
+		errs = append(errs, fmt.Errorf("datastore and archivestore must differ: %s", u.datastore))
+	}
+	subjectArchivestores, err := parseSubjectArchivestores(viper.GetStringSlice(u.getConfigPath("subject_archivestores")), u.datastore)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.subjectArchivestores = subjectArchivestores
+	u.fallbackArchivestore = viper.GetString(u.getConfigPath("fallback_archivestore"))
+	if u.fallbackArchivestore != "" && path.Clean(u.fallbackArchivestore) == path.Clean(u.datastore) {
+		errs = append(errs, fmt.Errorf("fallback_archivestore must differ from the datastore: %s", u.fallbackArchivestore))
+	}
+	u.onCollision = viper.GetString(u.getConfigPath("on_collision"))
+	u.indexMaxSize = viper.GetInt64(u.getConfigPath("index_max_size"))
+	u.setXattrs = viper.GetBool(u.getConfigPath("set_xattrs"))
+	u.setImmutable = viper.GetBool(u.getConfigPath("set_immutable"))
+	u.rollupStats = viper.GetBool(u.getConfigPath("rollup_stats"))
+	u.xattrSeq = viper.GetString(u.getConfigPath("xattr_seq"))
+	u.xattrOrigin = viper.GetString(u.getConfigPath("xattr_origin"))
+	u.controlSubject = viper.GetString(u.getConfigPath("control_subject"))
+	u.controlReplyTimeout = viper.GetDuration(u.getConfigPath("control_reply_timeout"))
+	u.tempDir = viper.GetString(u.getConfigPath("temp_dir"))
+	u.checksum = viper.GetBool(u.getConfigPath("checksum"))
+	u.checksumExtensions = parseChecksumExtensions(viper.GetStringSlice(u.getConfigPath("checksum_extensions")))
+	u.ackMode = viper.GetString(u.getConfigPath("ack_mode"))
+	u.normalizeCase = viper.GetBool(u.getConfigPath("normalize_case"))
+	u.errorSubject = viper.GetString(u.getConfigPath("error_subject"))
+	recentArchives := viper.GetInt(u.getConfigPath("recent_archives"))
+	if recentArchives < 0 {
+		errs = append(errs, fmt.Errorf("invalid recent_archives: %d", recentArchives))
+		recentArchives = 0
+	}
+	u.recent = newRecentBuffer(recentArchives)
+	u.durable = viper.GetString(u.getConfigPath("durable"))
+	u.ackPolicy = viper.GetString(u.getConfigPath("ack_policy"))
+	u.numReplicas = viper.GetInt(u.getConfigPath("num_replicas"))
+	u.subjectToken = viper.GetInt(u.getConfigPath("subject_token"))
+	if u.subjectToken < subjectTokenDisabled {
+		errs = append(errs, fmt.Errorf("invalid subject_token: %d", u.subjectToken))
+	}
+	subjectTokenMap, err := parseSubjectTokenMap(viper.GetStringSlice(u.getConfigPath("subject_token_map")))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.subjectTokenMap = subjectTokenMap
+	subdirRegex, err := parseSubdirRegex(viper.GetString(u.getConfigPath("subdir_regex")))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.subdirRegex = subdirRegex
+
+	u.metadataSidecar = viper.GetBool(u.getConfigPath("write_metadata_sidecar"))
+	u.provenanceHeaders = viper.GetStringSlice(u.getConfigPath("provenance_headers"))
+	u.pauseNakDelay = viper.GetDuration(u.getConfigPath("pause_nak_delay"))
+	u.degradedNakDelay = viper.GetDuration(u.getConfigPath("degraded_nak_delay"))
+	u.degradedProbeInterval = viper.GetDuration(u.getConfigPath("degraded_probe_interval"))
+	if u.degradedProbeInterval <= 0 {
+		errs = append(errs, fmt.Errorf("degraded_probe_interval must be positive"))
+	}
+	u.partitionByHost = viper.GetBool(u.getConfigPath("partition_by_host"))
+	u.partitionByDate = viper.GetString(u.getConfigPath("partition_by_date"))
+	u.sizeBucketOverflow = viper.GetString(u.getConfigPath("size_bucket_overflow"))
+	sizeBuckets, err := parseSizeBuckets(viper.GetStringSlice(u.getConfigPath("size_buckets")), u.sizeBucketOverflow)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.sizeBuckets = sizeBuckets
+	u.seqSource = viper.GetString(u.getConfigPath("seq_source"))
+	seqFormat, err := parseSeqFormat(viper.GetString(u.getConfigPath("seq_format")))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.seqFormat = seqFormat
+	if err := validateSeqSource(u.seqSource); err != nil {
+		errs = append(errs, err)
+	}
+	u.sourceWait = viper.GetDuration(u.getConfigPath("source_wait"))
+	u.sourceWaitInterval = viper.GetDuration(u.getConfigPath("source_wait_interval"))
+	u.archivePrefix = viper.GetString(u.getConfigPath("archive_prefix"))
+	u.archiveSuffix = viper.GetString(u.getConfigPath("archive_suffix"))
+	u.compression = viper.GetString(u.getConfigPath("compression"))
+	if err := validateCompression(u.compression); err != nil {
+		errs = append(errs, err)
+	}
+
+	u.shutdownMode = viper.GetString(u.getConfigPath("shutdown_mode"))
+	if err := validateShutdownMode(u.shutdownMode); err != nil {
+		errs = append(errs, err)
+	}
+
+	u.recordFailures = viper.GetBool(u.getConfigPath("record_failures"))
+	u.missingSourceTermAfter = viper.GetInt(u.getConfigPath("missing_source_term_after"))
+	u.verifyMode = viper.GetString(u.getConfigPath("verify_mode"))
+	if err := validateVerifyMode(u.verifyMode); err != nil {
+		errs = append(errs, err)
+	}
+	u.postWriteCacheDrop = viper.GetBool(u.getConfigPath("post_write_cache_drop"))
+	u.verifySamples = viper.GetInt(u.getConfigPath("verify_samples"))
+	u.verifySampleSize = viper.GetInt(u.getConfigPath("verify_sample_size"))
+	if u.verifySamples < 1 || u.verifySampleSize < 1 {
+		errs = append(errs, fmt.Errorf("verify_samples and verify_sample_size must be positive"))
+	}
+
+	u.fsyncArchive = viper.GetBool(u.getConfigPath("fsync_archive"))
+	u.fsyncDir = viper.GetBool(u.getConfigPath("fsync_dir"))
+	u.renameRetries = viper.GetInt(u.getConfigPath("rename_retries"))
+	if u.renameRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid rename_retries: %d", u.renameRetries))
+	}
+	u.renameRetryBackoff = viper.GetDuration(u.getConfigPath("rename_retry_backoff"))
+	u.maxNameLength = viper.GetInt(u.getConfigPath("max_name_length"))
+	u.multilinePayload = viper.GetBool(u.getConfigPath("multiline_payload"))
+	if u.multilinePayload && u.seqSource == SeqSourceMetadata {
+		errs = append(errs, fmt.Errorf("multiline_payload is incompatible with seq_source %s", SeqSourceMetadata))
+	}
+
+	u.maxPayloadSize = viper.GetInt(u.getConfigPath("max_payload_size"))
+	u.streamRetentionCheck = viper.GetString(u.getConfigPath("stream_retention_check"))
+	if err := validateStreamRetentionCheck(u.streamRetentionCheck); err != nil {
+		errs = append(errs, err)
+	}
+	u.streamSubjectCheck = viper.GetString(u.getConfigPath("stream_subject_check"))
+	if err := validateStreamSubjectCheck(u.streamSubjectCheck); err != nil {
+		errs = append(errs, err)
+	}
+	u.bindStream = viper.GetString(u.getConfigPath("bind_stream"))
+	u.dedupKey = viper.GetString(u.getConfigPath("dedup_key"))
+	if err := validateDedupKey(u.dedupKey); err != nil {
+		errs = append(errs, err)
+	}
+	u.dedupWindow = viper.GetDuration(u.getConfigPath("dedup_window"))
+	u.consumerPendingInterval = viper.GetDuration(u.getConfigPath("consumer_pending_interval"))
+	u.versioning = viper.GetString(u.getConfigPath("versioning"))
+	if err := validateVersioning(u.versioning); err != nil {
+		errs = append(errs, err)
+	}
+	u.maxVersions = viper.GetInt(u.getConfigPath("max_versions"))
+	u.flattenDepth = viper.GetInt(u.getConfigPath("flatten_depth"))
+	u.urlMode = viper.GetBool(u.getConfigPath("url_mode"))
+	u.urlTimeout = viper.GetDuration(u.getConfigPath("url_timeout"))
+	u.urlNakDelay = viper.GetDuration(u.getConfigPath("url_nak_delay"))
+	u.urlIndexDir = viper.GetString(u.getConfigPath("url_index_dir"))
+	u.noSpaceNakDelay = viper.GetDuration(u.getConfigPath("no_space_nak_delay"))
+	u.readOnly = viper.GetBool(u.getConfigPath("read_only"))
+	u.lowercaseArchivePaths = viper.GetBool(u.getConfigPath("lowercase_archive_paths"))
+	u.partialFailureMode = viper.GetString(u.getConfigPath("partial_failure_mode"))
+	if err := validatePartialFailureMode(u.partialFailureMode); err != nil {
+		errs = append(errs, err)
+	}
+	u.grpcAddress = viper.GetString(u.getConfigPath("grpc_address"))
+	if err := u.validateURLMode(); err != nil {
+		errs = append(errs, err)
+	}
+	u.createStreamIfMissing = viper.GetBool(u.getConfigPath("create_stream_if_missing"))
+	u.streamName = viper.GetString(u.getConfigPath("stream_name"))
+	u.streamSubjects = viper.GetStringSlice(u.getConfigPath("stream_subjects"))
+	u.streamMaxAge = viper.GetDuration(u.getConfigPath("stream_max_age"))
+	streamRetentionPolicy, err := parseStreamRetentionPolicy(viper.GetString(u.getConfigPath("stream_retention_policy")))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.streamRetentionPolicy = streamRetentionPolicy
+	u.payloadCodec = viper.GetString(u.getConfigPath("payload_codec"))
+	if err := validatePayloadCodec(u.payloadCodec); err != nil {
+		errs = append(errs, err)
+	}
+
+	if u.multilinePayload && u.payloadCodec != PayloadCodecText {
+		errs = append(errs, fmt.Errorf("multiline_payload is incompatible with payload_codec %s", u.payloadCodec))
+	}
+
+	u.workers = viper.GetInt(u.getConfigPath("workers"))
+	if u.workers < 1 {
+		errs = append(errs, fmt.Errorf("invalid workers: %d", u.workers))
+	}
+	u.workerQueueSize = viper.GetInt(u.getConfigPath("worker_queue_size"))
+	u.orderKeySource = viper.GetString(u.getConfigPath("order_key"))
+	u.priorityHeader = viper.GetString(u.getConfigPath("priority_header"))
+	u.priorityLevels = viper.GetInt(u.getConfigPath("priority_levels"))
+	u.priorityAging = viper.GetDuration(u.getConfigPath("priority_aging"))
+	if u.priorityHeader != "" && u.orderKeySource != DefaultOrderKey {
+		errs = append(errs, fmt.Errorf("priority_header is incompatible with order_key %s", u.orderKeySource))
+	}
+	if u.priorityLevels < 1 {
+		errs = append(errs, fmt.Errorf("invalid priority_levels: %d", u.priorityLevels))
+	}
+	if err := validateOrderKey(u.orderKeySource); err != nil {
+		errs = append(errs, err)
+	}
+
+	u.fixDirPermissions = viper.GetBool(u.getConfigPath("fix_dir_permissions"))
+	u.enforceDirModes = viper.GetBool(u.getConfigPath("enforce_dir_mode"))
+	dirUmask, err := parseUmask(viper.GetString(u.getConfigPath("dir_umask")))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.dirUmask = dirUmask
+
+	u.maintenanceInterval = viper.GetDuration(u.getConfigPath("maintenance_interval"))
+	u.stagingMaxAge = viper.GetDuration(u.getConfigPath("staging_max_age"))
+	u.quarantineDir = viper.GetString(u.getConfigPath("quarantine_dir"))
+
+	u.verifyInterval = viper.GetDuration(u.getConfigPath("verify_interval"))
+	u.verifyBatchSize = viper.GetInt(u.getConfigPath("verify_batch_size"))
+	if u.verifyBatchSize < 1 {
+		errs = append(errs, fmt.Errorf("invalid verify_batch_size: %d", u.verifyBatchSize))
+	}
+	u.verifyRate = viper.GetInt(u.getConfigPath("verify_rate"))
+	u.verifyEvents = viper.GetBool(u.getConfigPath("verify_events"))
+
+	u.auditInterval = viper.GetDuration(u.getConfigPath("audit_interval"))
+	u.auditBatchSize = viper.GetInt(u.getConfigPath("audit_batch_size"))
+	if u.auditBatchSize < 1 {
+		errs = append(errs, fmt.Errorf("invalid audit_batch_size: %d", u.auditBatchSize))
+	}
+	u.auditRate = viper.GetInt(u.getConfigPath("audit_rate"))
+	auditFileMode, err := parseFileMode(viper.GetString(u.getConfigPath("audit_file_mode")))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.auditFileMode = auditFileMode
+	u.auditUID = viper.GetInt(u.getConfigPath("audit_uid"))
+	u.auditGID = viper.GetInt(u.getConfigPath("audit_gid"))
+	u.auditFix = viper.GetBool(u.getConfigPath("audit_fix"))
+	u.auditEvents = viper.GetBool(u.getConfigPath("audit_events"))
+
+	u.heartbeatSubject = viper.GetString(u.getConfigPath("heartbeat_subject"))
+	u.heartbeatInterval = viper.GetDuration(u.getConfigPath("heartbeat_interval"))
+	if u.heartbeatSubject != "" && u.heartbeatInterval <= 0 {
+		errs = append(errs, fmt.Errorf("heartbeat_interval must be positive"))
+	}
+	u.shutdownReportSubject = viper.GetString(u.getConfigPath("shutdown_report_subject"))
+	u.indexCheckpoint = viper.GetBool(u.getConfigPath("index_checkpoint"))
+	u.recordDeletedMarkers = viper.GetBool(u.getConfigPath("record_deleted_markers"))
+	u.indexSyncEvery = viper.GetInt(u.getConfigPath("index_sync_every"))
+	u.indexSyncInterval = viper.GetDuration(u.getConfigPath("index_sync_interval"))
+	if u.indexSyncEvery < 0 || u.indexSyncInterval < 0 {
+		errs = append(errs, fmt.Errorf("index_sync_every and index_sync_interval must not be negative"))
+	}
+
+	eventBufferSize := viper.GetInt(u.getConfigPath("event_buffer_size"))
+	eventOverflow := viper.GetString(u.getConfigPath("event_overflow"))
+	if err := validateEventOverflow(eventOverflow); err != nil {
+		errs = append(errs, err)
+	}
+	if eventBufferSize < 0 {
+		errs = append(errs, fmt.Errorf("invalid event_buffer_size: %d", eventBufferSize))
+	}
+	if eventBufferSize > 0 {
+		u.events = newEventStream(eventBufferSize, eventOverflow)
+	}
+
+	u.indexWriteTimeout = viper.GetDuration(u.getConfigPath("index_write_timeout"))
+	u.journalReconcileInterval = viper.GetDuration(u.getConfigPath("journal_reconcile_interval"))
+	if u.journalReconcileInterval <= 0 {
+		errs = append(errs, fmt.Errorf("journal_reconcile_interval must be positive"))
+	}
+
+	u.startupDelay = viper.GetDuration(u.getConfigPath("startup_delay"))
+	u.startupCheckMounts = viper.GetBool(u.getConfigPath("startup_check_mounts"))
+
+	u.maxStoreBytes = viper.GetInt64(u.getConfigPath("max_store_bytes"))
+	u.evictMinAge = viper.GetDuration(u.getConfigPath("evict_min_age"))
+	u.evictInterval = viper.GetDuration(u.getConfigPath("evict_interval"))
+	if u.maxStoreBytes > 0 && u.evictInterval <= 0 {
+		errs = append(errs, fmt.Errorf("evict_interval must be positive"))
+	}
+
+	u.completionSubject = viper.GetString(u.getConfigPath("completion_subject"))
+	u.completionRetries = viper.GetInt(u.getConfigPath("completion_retries"))
+	if u.completionRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid completion_retries: %d", u.completionRetries))
+	}
+	u.completionRetryBackoff = viper.GetDuration(u.getConfigPath("completion_retry_backoff"))
+	u.compressEvents = viper.GetBool(u.getConfigPath("compress_events"))
+	u.completionAck = viper.GetBool(u.getConfigPath("completion_ack"))
+	u.completionAckWait = viper.GetDuration(u.getConfigPath("completion_ack_wait"))
+	u.completionNakDelay = viper.GetDuration(u.getConfigPath("completion_nak_delay"))
+	if viper.GetInt(u.getConfigPath("completion_batch_size")) > 0 && viper.GetDuration(u.getConfigPath("completion_batch_interval")) <= 0 {
+		errs = append(errs, fmt.Errorf("completion_batch_interval must be positive"))
+	}
+	u.copyBufferSize = viper.GetInt(u.getConfigPath("copy_buffer_size"))
+	if u.copyBufferSize < MinCopyBufferSize {
+		errs = append(errs, fmt.Errorf("copy_buffer_size must be at least %d bytes: %d", MinCopyBufferSize, u.copyBufferSize))
+	}
+	u.parallelCopyThreshold = viper.GetInt64(u.getConfigPath("parallel_copy_threshold"))
+	u.parallelCopyChunkSize = viper.GetInt64(u.getConfigPath("parallel_copy_chunk_size"))
+	u.parallelCopyWorkers = viper.GetInt(u.getConfigPath("parallel_copy_workers"))
+	if u.parallelCopyThreshold > 0 && u.parallelCopyChunkSize < MinCopyBufferSize {
+		errs = append(errs, fmt.Errorf("parallel_copy_chunk_size must be at least %d bytes: %d", MinCopyBufferSize, u.parallelCopyChunkSize))
+	}
+	if u.parallelCopyThreshold > 0 && u.parallelCopyWorkers < 1 {
+		errs = append(errs, fmt.Errorf("parallel_copy_workers must be at least 1: %d", u.parallelCopyWorkers))
+	}
+	u.sourceTrashDir = viper.GetString(u.getConfigPath("source_trash_dir"))
+	u.trashRetention = viper.GetDuration(u.getConfigPath("trash_retention"))
+	u.trashPurgeInterval = viper.GetDuration(u.getConfigPath("trash_purge_interval"))
+	if u.sourceTrashDir != "" && u.trashRetention <= 0 {
+		errs = append(errs, fmt.Errorf("trash_retention must be positive"))
+	}
+	if u.sourceTrashDir != "" && u.trashPurgeInterval <= 0 {
+		errs = append(errs, fmt.Errorf("trash_purge_interval must be positive"))
+	}
+
+	u.reindexPolicy = viper.GetString(u.getConfigPath("reindex_policy"))
+	switch u.reindexPolicy {
+	case ReindexAppend, ReindexRejectIfChanged, ReindexReplaceAndRemoveOld:
+	default:
+		errs = append(errs, fmt.Errorf("invalid reindex_policy: %s", u.reindexPolicy))
+	}
+
+	u.deliverPolicy = viper.GetString(u.getConfigPath("deliver_policy"))
+	deliverStartTime, err := parseDeliverStartTime(viper.GetString(u.getConfigPath("deliver_start_time")))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	u.deliverStartTime = deliverStartTime
+
+	if err := u.validateConsumerConfig(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch u.ackMode {
+	case AckModeManual, AckModeAuto:
+	default:
+		errs = append(errs, fmt.Errorf("invalid ack_mode: %s", u.ackMode))
+	}
+	u.coldArchivestore = viper.GetString(u.getConfigPath("cold_archivestore"))
+	u.tierAfter = viper.GetDuration(u.getConfigPath("tier_after"))
+	u.tierInterval = viper.GetDuration(u.getConfigPath("tier_interval"))
+	if u.coldArchivestore != "" && u.tierInterval <= 0 {
+		errs = append(errs, fmt.Errorf("tier_interval must be positive"))
+	}
+
+	switch u.onCollision {
+	case CollisionSuffix, CollisionError, CollisionOverwrite:
+	default:
+		errs = append(errs, fmt.Errorf("invalid on_collision policy: %s", u.onCollision))
+	}
+
+	u.counterFile = viper.GetString(u.getConfigPath("counter_file"))
+	u.indexBackend = viper.GetString(u.getConfigPath("index_backend"))
+	if err := u.validateIndexBackend(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
 
 func (u *Uploader) onStop(ctx context.Context) error {
+
+	if u.readOnly {
+		u.stopGRPCServer(ctx)
+		u.logger.Info("Stopped read-only replica")
+		return nil
+	}
+
+	if !u.stopDelayedStart() {
+		u.logger.Info("Stopped Uploader before the delayed start")
+		return nil
+	}
+
+	var report ShutdownReport
+	report.InFlight, report.Queued = u.backlog()
+
+	u.deregister()
+	u.stopGRPCServer(ctx)
+
+	if err := u.shutdown(ctx); err != nil {
+		u.logger.Error(err.Error())
+	}
+
+	u.reportShutdown(report)
+	u.writeCheckpoints()
+	u.syncIndexesOnStop()
+
+	u.stopControlSubscriber()
+	u.stopTasks()
+
+	if u.events != nil {
+		u.events.close()
+	}
+
+	if u.completionBatcher != nil {
+		u.completionBatcher.stop()
+	}
+
 	u.logger.Info("Stopped Uploader")
 
 	return nil
@@ -110,73 +1035,560 @@ func (u *Uploader) onStop(ctx context.Context) error {
 func (u *Uploader) startSubscriber() error {
 	// nats stream pub a msg to cloud-uploader
 	js := u.params.NATSConnector.GetJetStreamContext()
-	subject := fmt.Sprintf(DefaultSubject, u.domain, u.hostname)
+	subject := u.subject()
+
+	if err := u.ensureStream(js, subject); err != nil {
+		return err
+	}
+
+	if err := u.checkStreamSubject(js, subject); err != nil {
+		return err
+	}
+
+	if err := u.checkStreamRetention(js, subject); err != nil {
+		return err
+	}
+
 	go func() {
 		//u.logger.Info(subject)
-		_, err := js.Subscribe(subject,
-			u.msgHandler,
-			nats.ManualAck(),
+		deliverOpts, err := u.deliverOptions(js, subject)
+		if err != nil {
+			u.logger.Fatal(err.Error())
+		}
+
+		sub, err := js.Subscribe(subject,
+			u.handler(),
+			append(u.subscribeOptions(), deliverOpts...)...,
 		)
 		if err != nil {
 			u.logger.Fatal(err.Error())
 		}
+
+		u.subMutex.Lock()
+		u.sub = sub
+		u.subMutex.Unlock()
 	}()
 	return nil
 }
 
+// subject returns the job subject the uploader subscribes to.
+func (u *Uploader) subject() string {
+	return fmt.Sprintf(DefaultSubject, u.domain, u.hostname)
+}
+
 func (u *Uploader) updateIndex(filename string, archiveName string, seq string) error {
+	return u.appendEntry(filename, Entry{
+		Seq:         seq,
+		ArchiveName: archiveName,
+	})
+}
+
+func (u *Uploader) appendEntry(filename string, entry Entry) error {
+
+	if u.sqlIndex != nil {
+		if err := u.sqlIndex.Writer(u.indexFilename(filename)).Append(entry); err != nil {
+			return err
+		}
+		u.updateRollup(u.indexFilename(filename), entry)
+		return nil
+	}
 
 	// prepare data
-	data := fmt.Sprintf("%s:%s\n", seq, archiveName)
+	data := entry.String() + "\n"
 
 	// opend index file
-	dstDir := path.Dir(filename)
-	indexFilename := path.Join(dstDir, "archive.index")
-	indexFile, err := os.OpenFile(indexFilename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	indexFilename := u.indexFilename(filename)
+
+	err := u.mkdirIndexDir(path.Dir(indexFilename))
+	if err != nil {
+		return err
+	}
+
+	// rotate index file
+	err = u.rotateIndexIfNeeded(indexFilename)
+	if err != nil {
+		return err
+	}
+
+	indexFile, err := os.OpenFile(indexFilename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return err
 	}
 	defer indexFile.Close()
 
+	size, err := u.dropPartialLine(indexFile, indexFilename)
+	if err != nil {
+		return err
+	}
+
 	// write index file
 	_, err = indexFile.WriteString(data)
 	if err != nil {
+		// drop a line cut off by a full filesystem
+		indexFile.Truncate(size)
 		return err
 	}
+
+	if u.indexSyncEvery > 0 {
+		if err := u.indexSync.wrote(indexFile, u.indexSyncEvery); err != nil {
+			return err
+		}
+	}
+
+	if u.indexCheckpoint {
+		u.checkpoints.add(indexFilename)
+	}
+
+	u.updateRollup(indexFilename, entry)
+
 	return nil
 }
 
+// dropPartialLine truncates a partial line left by a crash mid-append, so the
+// next entry doesn't continue it. It returns the size of the index.
+func (u *Uploader) dropPartialLine(indexFile *os.File, indexFilename string) (int64, error) {
+
+	fi, err := indexFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size := fi.Size()
+	if size == 0 {
+		return 0, nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := indexFile.ReadAt(last, size-1); err != nil {
+		return 0, err
+	}
+
+	if last[0] == '\n' {
+		return size, nil
+	}
+
+	end, err := completeLinesEnd(indexFile, size)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := indexFile.Truncate(end); err != nil {
+		return 0, err
+	}
+
+	u.logger.Warn("Dropped a partial line at the end of the index",
+		zap.String("index", indexFilename),
+		zap.Int64("bytes", size-end),
+	)
+
+	return end, nil
+}
+
+// writeIndex appends the entry, replacing the old entry of the sequence first when there is one.
+func (u *Uploader) writeIndex(filename string, oldEntry *Entry, entry Entry) error {
+
+	if oldEntry != nil {
+		if err := u.replaceEntry(filename, oldEntry, entry.ArchiveName); err != nil {
+			return err
+		}
+	}
+
+	return u.appendEntry(filename, entry)
+}
+
+func (u *Uploader) indexFilename(filename string) string {
+	return path.Join(path.Dir(filename), DefaultArchiveIndex)
+}
+
+// indexReader returns the reader of the index the file is recorded in.
+func (u *Uploader) indexReader(filename string) IndexReader {
+
+	if u.sqlIndex != nil {
+		return u.sqlIndex.Reader(u.indexFilename(filename))
+	}
+
+	return NewIndexReader(u.indexFilename(filename))
+}
+
 func (u *Uploader) msgHandler(m *nats.Msg) {
-	mdata := strings.SplitN(string(m.Data), ":", 2)
-	filename := mdata[1]
 
-	archiveName := strings.ReplaceAll(filename, path.Join(u.datastore), path.Join(u.archivestore))
+	u.inflight.Add(1)
+	defer u.inflight.Add(-1)
+
+	if u.requeueing.Load() {
+		u.counters.requeued.Add(1)
+		u.nak(m)
+		return
+	}
+
+	if u.paused.Load() {
+		u.nakWithDelay(m, u.pauseNakDelay)
+		return
+	}
+
+	if u.degraded.Load() {
+		u.nakWithDelay(m, u.degradedNakDelay)
+		return
+	}
+
+	ctx, span := u.startSpan(messageContext(m), SpanHandle, AttrSubject.String(m.Subject))
+	var err error
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	err = u.checkPayloadSize(m)
+	if err != nil {
+		u.logger.Error(err.Error())
+		u.term(m)
+		u.publishFailure(m, "", "", ActionTerm, err)
+		return
+	}
+
+	if u.multilinePayload {
+		err = u.handleBatch(ctx, m)
+		return
+	}
+
+	j, err := u.decodeJob(m)
+	if err != nil {
+		u.logger.Error(err.Error())
+		u.term(m)
+		u.publishFailure(m, "", string(m.Data), ActionTerm, err)
+		return
+	}
+	j.ctx = ctx
+
+	err = u.resolveSeq(j)
+	if err == nil {
+		err = u.resolveURL(j)
+	}
+	if err != nil {
+		u.logger.Error(err.Error())
+		u.term(m)
+		u.publishFailure(m, j.seq, j.filename, ActionTerm, err)
+		return
+	}
+
+	span.SetAttributes(AttrSeq.String(j.seq), AttrFilename.String(j.filename))
+
+	// a redelivery after a failed completion publish only publishes again
+	var archiveName string
+	if name, ok := u.duplicate(j); ok {
+		archiveName = name
+		u.counters.duplicates.Add(1)
+		u.emitSkipped(j, archiveName)
+	} else if entry, ok := u.archivedEntry(j); ok && u.completionSubject != "" {
+		archiveName = entry.ArchiveName
+		u.emitSkipped(j, archiveName)
+	} else {
+		// a source still missing is archived as a deleted marker
+		if u.sourceWait > 0 && j.url == "" && !u.waitForSource(cleanFilename(j.filename)) && !u.recordDeletedMarkers {
+			if u.requeueing.Load() {
+				u.counters.requeued.Add(1)
+				u.nak(m)
+				return
+			}
+
+			err = fmt.Errorf("source file is still missing after %s: %w (%s)", u.sourceWait, os.ErrNotExist, j.filename)
+			u.logger.Error(err.Error())
+			u.counters.failed.Add(1)
+
+			if u.sourceGone(m) {
+				err = fmt.Errorf("%w: %w", ErrSourceMissing, err)
+				u.term(m)
+				u.recordFailure(j, err)
+				u.publishFailure(m, j.seq, j.filename, ActionTerm, err)
+				return
+			}
+
+			u.nakWithDelay(m, u.sourceWait)
+			u.publishFailure(m, j.seq, j.filename, ActionNak, err)
+			return
+		}
+
+		if j.url != "" {
+			archiveName, err = u.archiveURL(j)
+		} else {
+			archiveName, err = u.archive(j)
+		}
+		u.emitArchive(j, archiveName, err)
+		if err != nil && isReadOnly(err) {
+			u.enterDegraded(err)
+			u.nakWithDelay(m, u.degradedNakDelay)
+			return
+		}
+		if errors.Is(err, ErrArchiveNoSpace) {
+			u.logger.Error(err.Error())
+			u.counters.failed.Add(1)
+			u.nakWithDelay(m, u.noSpaceNakDelay)
+			u.publishFailure(m, j.seq, j.filename, ActionNak, err)
+			return
+		}
+		if err != nil {
+			u.logger.Error(err.Error())
+			u.counters.failed.Add(1)
+
+			if errors.Is(err, os.ErrNotExist) && u.sourceGone(m) {
+				err = fmt.Errorf("%w: %w", ErrSourceMissing, err)
+			}
+
+			if isPermanent(err) {
+				u.term(m)
+				u.recordFailure(j, err)
+				u.publishFailure(m, j.seq, j.filename, ActionTerm, err)
+				return
+			}
 
-	err := os.MkdirAll(path.Dir(archiveName), 0750)
+			// give the remote server time to recover
+			if errors.Is(err, ErrURLStatus) {
+				u.nakWithDelay(m, u.urlNakDelay)
+				u.publishFailure(m, j.seq, j.filename, ActionNak, err)
+				return
+			}
+
+			u.nak(m)
+			u.publishFailure(m, j.seq, j.filename, ActionNak, err)
+			return
+		}
+
+		if u.dedupWindow > 0 {
+			u.seen.archived(u.jobKey(j), archiveName, u.dedupWindow)
+		}
+	}
+
+	span.SetAttributes(AttrArchiveName.String(archiveName))
+
+	// acked once the batch is published
+	if u.completionBatcher != nil {
+		u.completionBatcher.add(u.completionEvent(j.seq, j.filename, archiveName), m)
+		return
+	}
+
+	err = u.publishCompletionTraced(ctx, j.seq, j.filename, archiveName)
 	if err != nil {
-		m.Nak()
 		u.logger.Error(err.Error())
+
+		// a chain without a stream behind the subject would loop forever
+		if isPermanent(err) {
+			u.term(m)
+			u.recordFailure(j, err)
+			u.publishFailure(m, j.seq, j.filename, ActionTerm, err)
+			return
+		}
+
+		u.nakWithDelay(m, u.completionNakDelay)
+		u.publishFailure(m, j.seq, j.filename, ActionNak, err)
 		return
 	}
 
+	u.ack(m)
+}
+
+// Archive moves the datastore file into the archivestore and records it in the index.
+func (u *Uploader) Archive(seq string, filename string) (string, error) {
+
+	if err := u.checkWritable(); err != nil {
+		return "", err
+	}
+
+	j := &job{
+		seq:      u.seqFormat.normalize(seq),
+		filename: filename,
+	}
+
+	archiveName, err := u.archive(j)
+	u.emitArchive(j, archiveName, err)
+
+	return archiveName, err
+}
+
+// ArchivePathFor returns the path the datastore file would be archived to,
+// without touching the filesystem unless size_buckets needs the file size. A
+// collision suffix isn't predicted.
+func (u *Uploader) ArchivePathFor(filename string) (string, error) {
+	return u.archivePath(&job{
+		filename: filename,
+	})
+}
+
+// archivePath returns the archive name of the job, applying the translation,
+// the partitions, the prefix and suffix, and the compression extension.
+func (u *Uploader) archivePath(j *job) (string, error) {
+
+	filename := cleanFilename(j.filename)
+
+	root, err := u.archiveRoot(j)
+	if err != nil {
+		return "", err
+	}
+
+	archiveName := u.decorateArchiveName(u.flatten(u.translate(filename, root), root))
+	if archiveName == filename {
+		u.logger.Warn("Archive path equals the source, datastore and archivestore are probably misconfigured",
+			zap.String("fileName", filename),
+			zap.String("datastore", u.datastore),
+			zap.String("archivestore", u.archivestore),
+		)
+		return "", fmt.Errorf("%w (%s)", ErrSameArchivePath, filename)
+	}
+
+	if err := u.checkNameLength(archiveName); err != nil {
+		return "", err
+	}
+
+	return archiveName, nil
+}
+
+func (u *Uploader) archive(j *job) (string, error) {
+
+	seq := j.seq
+	rawFilename := j.filename
+
+	filename := cleanFilename(rawFilename)
+	normalized := u.normalizeFilename(rawFilename)
+
+	archiveName, err := u.archivePath(j)
+	if err != nil {
+		return "", err
+	}
+
+	// serialize mkdir, move and index write per archive directory
+	unlock := u.dirLocks.lock(path.Dir(archiveName))
+	defer unlock()
+
+	// serialize index writes with eviction rewriting the index
+	indexLock := u.dirLocks.lockHeld(u.indexFilename(filename))
+	defer indexLock.release()
+
+	err = u.mkdirArchiveDir(path.Dir(archiveName))
+	if err != nil {
+		return "", err
+	}
+
+	// versions are kept instead of resolving the collision
+	var logical string
+	if u.versioning != VersioningOff && u.versioning != "" {
+		logical = archiveName
+		archiveName, err = u.versionArchiveName(filename, logical)
+	} else {
+		archiveName, err = u.resolveCollision(j.context(), filename, archiveName, seq)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var oldEntry *Entry
+	if logical == "" {
+		oldEntry, err = u.checkReindex(filename, seq, archiveName)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	fi, err := os.Stat(filename)
+	if os.IsNotExist(err) && u.recordDeletedMarkers {
+		return u.archiveDeletedMarker(j, indexLock, archiveName, oldEntry)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	entry := Entry{
+		Seq:         seq,
+		ArchiveName: archiveName,
+		Size:        fi.Size(),
+		Filename:    normalized,
+		Time:        time.Now(),
+		Compression: u.compression,
+		Labels:      u.subjectLabels(j.subject()),
+		Logical:     logical,
+	}
+
+	if rawFilename != normalized {
+		entry.RawFilename = rawFilename
+	}
+
+	if u.archiveCounter != nil {
+		entry.Counter, err = u.archiveCounter.next()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	trace.SpanFromContext(j.context()).SetAttributes(AttrFileSize.Int64(entry.Size))
+
+	if u.checksumFile(filename) {
+		_, span := u.startSpan(j.context(), SpanChecksum)
+		entry.Checksum, err = u.fileChecksum(filename)
+		endSpan(span, err)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	u.logger.Debug("Archive file",
 		zap.String("fileName", filename),
 		zap.String("archiveName", archiveName),
 	)
 
-	if err := os.Rename(filename, archiveName); err != nil {
-		m.Nak()
-		u.logger.Error(err.Error())
-		return
+	// an archive replaced on collision is never rolled back
+	_, replaced := u.fileSystem().Stat(archiveName)
+
+	_, span := u.startSpan(j.context(), SpanMove, AttrFileSize.Int64(entry.Size))
+	if u.compression != CompressionNone {
+		err = u.compressFile(filename, archiveName)
+	} else {
+		err = u.moveFile(filename, archiveName)
+	}
+	if err == nil {
+		err = u.checkMoved(archiveName, entry.Size)
+	}
+	if err != nil {
+		err = u.rollbackMove(filename, archiveName, replaced == nil, err)
+	}
+	endSpan(span, err)
+	if err != nil {
+		return "", err
+	}
+	u.lowSpace.Store(false)
+
+	u.tagArchive(archiveName, filename, seq)
+	u.makeImmutable(archiveName)
+
+	err = u.writeMetadataSidecar(j, archiveName)
+	if err != nil {
+		return "", err
+	}
+
+	err = u.writeProvenanceSidecar(j, archiveName)
+	if err != nil {
+		return "", err
 	}
 
 	//update indexFile
-	err = u.updateIndex(filename, archiveName, mdata[0])
+	_, span = u.startSpan(j.context(), SpanIndex)
+	err = u.writeIndexBounded(indexLock, filename, oldEntry, entry)
+	endSpan(span, err)
 	if err != nil {
-		m.Nak()
-		u.logger.Error(err.Error())
-		return
+		return "", err
 	}
 
-	m.Ack()
+	if u.recent != nil {
+		u.recent.add(entry)
+	}
+
+	if logical != "" {
+		if err := u.pruneVersions(filename, logical); err != nil {
+			u.logger.Error("Failed to prune archive versions",
+				zap.String("archiveName", logical),
+				zap.Error(err),
+			)
+		}
+	}
+
+	u.counters.archived.Add(1)
+	u.counters.archivedBytes.Add(uint64(entry.Size))
+
+	return archiveName, nil
 }
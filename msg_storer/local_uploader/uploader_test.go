@@ -20,6 +20,7 @@ import (
 	"github.com/weedbox/common-modules/logger"
 	"github.com/weedbox/common-modules/nats_connector"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 )
 
 func runNatsServer() *server.Server {
@@ -74,6 +75,12 @@ func getUploader() *Uploader {
 			u.domain = DefaultDomain
 			u.datastore = "./datastore"
 			u.archivestore = "./archivestore"
+			u.onCollision = DefaultOnCollision
+			u.ackMode = DefaultAckMode
+			u.ackPolicy = DefaultAckPolicy
+			u.deliverPolicy = DefaultDeliverPolicy
+			u.subjectToken = DefaultSubjectToken
+			u.reindexPolicy = DefaultReindexPolicy
 
 			return u
 		}),
@@ -139,6 +146,46 @@ func getUploader() *Uploader {
 
 }
 
+// newTestUploader returns an uploader which works on temporary directories
+// without starting the fx application or connecting to NATS.
+func newTestUploader(t *testing.T) *Uploader {
+	root := t.TempDir()
+
+	u := &Uploader{
+		logger:        zap.NewNop(),
+		scope:         "uploader",
+		domain:        DefaultDomain,
+		datastore:     path.Join(root, "datastore"),
+		archivestore:  path.Join(root, "archivestore"),
+		hostname:      "test",
+		onCollision:   DefaultOnCollision,
+		ackMode:       DefaultAckMode,
+		ackPolicy:     DefaultAckPolicy,
+		deliverPolicy: DefaultDeliverPolicy,
+		subjectToken:  DefaultSubjectToken,
+		reindexPolicy: DefaultReindexPolicy,
+	}
+
+	return u
+}
+
+// createTestFile creates a file with content under the datastore of the uploader.
+func createTestFile(t *testing.T, u *Uploader, name string, content string) string {
+	filename := path.Join(u.datastore, name)
+
+	err := os.MkdirAll(path.Dir(filename), 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filename, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
 type TestSuite struct {
 	suite.Suite
 	uploader        *Uploader
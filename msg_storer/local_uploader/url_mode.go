@@ -0,0 +1,306 @@
+package uploader
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultURLMode     = false
+	DefaultURLTimeout  = 30 * time.Second
+	DefaultURLNakDelay = 10 * time.Second
+	DefaultURLIndexDir = "/url_index"
+
+	// mode of the archive files fetched from a URL
+	URLArchiveMode = 0640
+)
+
+var (
+	ErrURLStatus       = errors.New("URL responded with a retryable status.")
+	ErrURLClientStatus = errors.New("URL responded with a client error status.")
+)
+
+// validateURLMode validates url_mode against the other configs. Size buckets
+// need the size before the fetch, and versions aren't kept for URLs.
+func (u *Uploader) validateURLMode() error {
+
+	if !u.urlMode {
+		return nil
+	}
+
+	switch {
+	case len(u.sizeBuckets) > 0:
+		return errors.New("url_mode is incompatible with size_buckets")
+	case u.versioning != VersioningOff && u.versioning != "":
+		return fmt.Errorf("url_mode is incompatible with versioning %s", u.versioning)
+	case u.multilinePayload:
+		return errors.New("url_mode is incompatible with multiline_payload")
+	case u.urlTimeout <= 0:
+		return fmt.Errorf("invalid url_timeout: %s", u.urlTimeout)
+	case u.urlIndexDir == "":
+		return errors.New("url_mode requires url_index_dir")
+	case path.Clean(u.urlIndexDir) == path.Clean(u.datastore) || path.Clean(u.urlIndexDir) == path.Clean(u.archivestore):
+		return fmt.Errorf("url_index_dir must differ from the datastore and the archivestore: %s", u.urlIndexDir)
+	}
+
+	return nil
+}
+
+// resolveURL maps the URL of the job to a virtual source under url_index_dir,
+// <url_index_dir>/<host>/<path>, so the archive path and the index follow the
+// same layout as the files without touching the datastore. Payloads which
+// aren't http(s) URLs are left as datastore files.
+func (u *Uploader) resolveURL(j *job) error {
+
+	if !u.urlMode {
+		return nil
+	}
+
+	target, err := url.Parse(j.filename)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return nil
+	}
+
+	if target.Host == "" {
+		return fmt.Errorf("%w (%s)", ErrInvalidPayload, j.filename)
+	}
+
+	name := path.Clean("/" + target.Path)
+	if name == "/" {
+		name = "/index"
+	}
+
+	j.url = target.String()
+	j.filename = path.Join(u.urlIndexDir, target.Host, name)
+
+	return nil
+}
+
+// sourceRoot returns the root of the source, url_index_dir for the virtual
+// sources of URLs and the datastore otherwise.
+func (u *Uploader) sourceRoot(filename string) string {
+
+	if u.urlMode && strings.HasPrefix(path.Clean(filename), path.Clean(u.urlIndexDir)+"/") {
+		return u.urlIndexDir
+	}
+
+	return u.datastore
+}
+
+// archiveURL streams the URL of the job into the archive and records it in
+// the index under url_index_dir. The datastore isn't touched.
+func (u *Uploader) archiveURL(j *job) (string, error) {
+
+	filename := j.filename
+
+	archiveName, err := u.archivePath(j)
+	if err != nil {
+		return "", err
+	}
+
+	// serialize mkdir, fetch and index write per archive directory
+	unlock := u.dirLocks.lock(path.Dir(archiveName))
+	defer unlock()
+
+	indexLock := u.dirLocks.lockHeld(u.indexFilename(filename))
+	defer indexLock.release()
+
+	// the virtual source directory only holds the index
+	if err := os.MkdirAll(path.Dir(filename), 0750); err != nil {
+		return "", err
+	}
+
+	err = u.mkdirArchiveDir(path.Dir(archiveName))
+	if err != nil {
+		return "", err
+	}
+
+	archiveName, err = u.resolveCollision(j.context(), filename, archiveName, j.seq)
+	if err != nil {
+		return "", err
+	}
+
+	oldEntry, err := u.checkReindex(filename, j.seq, archiveName)
+	if err != nil {
+		return "", err
+	}
+
+	entry := Entry{
+		Seq:         j.seq,
+		ArchiveName: archiveName,
+		Filename:    filename,
+		URL:         j.url,
+		Compression: u.compression,
+		Labels:      u.subjectLabels(j.subject()),
+	}
+
+	if u.archiveCounter != nil {
+		entry.Counter, err = u.archiveCounter.next()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	u.logger.Debug("Archive URL",
+		zap.String("url", j.url),
+		zap.String("archiveName", archiveName),
+	)
+
+	_, span := u.startSpan(j.context(), SpanMove)
+	entry.Size, entry.Checksum, err = u.fetchURL(j, archiveName, u.checksumFile(filename))
+	endSpan(span, err)
+	if err != nil {
+		return "", err
+	}
+	entry.Time = time.Now()
+
+	u.makeImmutable(archiveName)
+
+	err = u.writeMetadataSidecar(j, archiveName)
+	if err != nil {
+		return "", err
+	}
+
+	err = u.writeProvenanceSidecar(j, archiveName)
+	if err != nil {
+		return "", err
+	}
+
+	_, span = u.startSpan(j.context(), SpanIndex)
+	err = u.writeIndexBounded(indexLock, filename, oldEntry, entry)
+	endSpan(span, err)
+	if err != nil {
+		return "", err
+	}
+
+	if u.recent != nil {
+		u.recent.add(entry)
+	}
+
+	u.counters.archived.Add(1)
+	u.counters.archivedBytes.Add(uint64(entry.Size))
+
+	return archiveName, nil
+}
+
+// fetchURL downloads the URL of the job into a staging file, compressing it
+// when compression is set, and renames it to the archive name. It returns the
+// size and, when checksum is set, the sha256 of the downloaded content.
+func (u *Uploader) fetchURL(j *job, archiveName string, checksum bool) (int64, string, error) {
+
+	ctx, cancel := context.WithTimeout(j.context(), u.urlTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w (%s)", ErrInvalidPayload, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkURLStatus(resp.StatusCode); err != nil {
+		return 0, "", fmt.Errorf("%w (%s: %s)", err, j.url, resp.Status)
+	}
+
+	fsys := u.fileSystem()
+
+	dst, err := fsys.CreateTemp(u.stagingDirFor(archiveName), StagingPattern)
+	if err != nil {
+		return 0, "", err
+	}
+	stagingName := dst.Name()
+
+	fail := func(err error) (int64, string, error) {
+		dst.Close()
+		fsys.Remove(stagingName)
+		return 0, "", err
+	}
+
+	var src io.Reader = resp.Body
+	var h hash.Hash
+	if checksum {
+		h = sha256.New()
+		src = io.TeeReader(src, h)
+	}
+
+	var size int64
+	if u.compression != CompressionNone {
+		zw := gzip.NewWriter(dst)
+		size, err = u.copyBuffer(zw, src)
+		if err == nil {
+			err = zw.Close()
+		}
+	} else {
+		size, err = u.copyBuffer(dst, src)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := dst.Chmod(URLArchiveMode); err != nil {
+		return fail(err)
+	}
+
+	// flush the data before the file shows up in the archive
+	if u.fsyncArchive {
+		if err := dst.Sync(); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := dst.Close(); err != nil {
+		fsys.Remove(stagingName)
+		return 0, "", err
+	}
+
+	if err := u.renameStaged(stagingName, archiveName); err != nil {
+		fsys.Remove(stagingName)
+		return 0, "", err
+	}
+
+	if err := u.syncArchiveDir(archiveName); err != nil {
+		return 0, "", err
+	}
+
+	var sum string
+	if h != nil {
+		sum = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return size, sum, nil
+}
+
+// checkURLStatus classifies the status of the response. Client errors are
+// permanent, except for timeouts and rate limiting which are retried along
+// with every other status outside of 2xx.
+func checkURLStatus(status int) error {
+
+	switch {
+	case status >= 200 && status < 300:
+		return nil
+	case status == http.StatusRequestTimeout || status == http.StatusTooManyRequests:
+		return ErrURLStatus
+	case status >= 400 && status < 500:
+		return ErrURLClientStatus
+	}
+
+	return ErrURLStatus
+}
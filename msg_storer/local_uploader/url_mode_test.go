@@ -0,0 +1,147 @@
+package uploader
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newURLTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/MSG_1.db", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "remote")
+	})
+	mux.HandleFunc("/broken/MSG_1.db", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newURLTestUploader(t *testing.T) *Uploader {
+	u := newTestUploader(t)
+	u.urlMode = true
+	u.urlTimeout = DefaultURLTimeout
+	u.urlIndexDir = path.Join(t.TempDir(), "url_index")
+	u.recordFailures = true
+
+	return u
+}
+
+func TestArchiveURL(t *testing.T) {
+	u := newURLTestUploader(t)
+	u.compression = CompressionGzip
+	u.checksum = true
+	server := newURLTestServer(t)
+
+	j := &job{seq: "1", filename: server.URL + "/files/MSG_1.db"}
+	assert.Nil(t, u.resolveURL(j))
+	assert.Equal(t, path.Join(u.urlIndexDir, server.Listener.Addr().String(), "files/MSG_1.db"), j.filename)
+
+	archiveName, err := u.archiveURL(j)
+	assert.Nil(t, err)
+
+	f, err := os.Open(archiveName)
+	if assert.Nil(t, err) {
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		assert.Nil(t, err)
+		data, err := io.ReadAll(zr)
+		assert.Nil(t, err)
+		assert.Equal(t, "remote", string(data))
+	}
+
+	entry, err := u.indexReader(j.filename).Lookup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, archiveName, entry.ArchiveName)
+	assert.Equal(t, server.URL+"/files/MSG_1.db", entry.URL)
+	assert.Equal(t, int64(len("remote")), entry.Size)
+	assert.NotEmpty(t, entry.Checksum)
+
+	// nothing but the index is written, and not to the datastore
+	names, err := os.ReadDir(path.Dir(j.filename))
+	assert.Nil(t, err)
+	if assert.Len(t, names, 1) {
+		assert.Equal(t, DefaultArchiveIndex, names[0].Name())
+	}
+	assert.NoDirExists(t, u.datastore)
+
+	// the index is validated along with the ones of the datastore
+	report, err := u.Validate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Checked)
+}
+
+func TestArchiveURLNotFound(t *testing.T) {
+	u := newURLTestUploader(t)
+	server := newURLTestServer(t)
+
+	// a client error is Term'd
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + server.URL + "/files/MSG_2.db"),
+	})
+
+	filename := path.Join(u.urlIndexDir, server.Listener.Addr().String(), "files/MSG_2.db")
+
+	records, err := ReadFailures(u.failureLogFilename(filename))
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Contains(t, records[0].Error, "404")
+	}
+
+	_, err = u.indexReader(filename).Lookup("1")
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+}
+
+func TestArchiveURLServerError(t *testing.T) {
+	u := newURLTestUploader(t)
+	server := newURLTestServer(t)
+
+	j := &job{seq: "1", filename: server.URL + "/broken/MSG_1.db"}
+	assert.Nil(t, u.resolveURL(j))
+
+	// a server error is retried after url_nak_delay
+	_, err := u.archiveURL(j)
+	assert.True(t, errors.Is(err, ErrURLStatus))
+	assert.False(t, isPermanent(err))
+
+	u.msgHandler(&nats.Msg{
+		Subject: "test",
+		Data:    []byte("1:" + server.URL + "/broken/MSG_1.db"),
+	})
+
+	records, err := ReadFailures(u.failureLogFilename(j.filename))
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+}
+
+func TestCheckURLStatus(t *testing.T) {
+	assert.Nil(t, checkURLStatus(http.StatusOK))
+	assert.ErrorIs(t, checkURLStatus(http.StatusNotFound), ErrURLClientStatus)
+	assert.ErrorIs(t, checkURLStatus(http.StatusTooManyRequests), ErrURLStatus)
+	assert.ErrorIs(t, checkURLStatus(http.StatusBadGateway), ErrURLStatus)
+}
+
+func TestValidateURLIndexDir(t *testing.T) {
+	u := newURLTestUploader(t)
+	assert.Nil(t, u.validateURLMode())
+
+	u.urlIndexDir = ""
+	assert.ErrorContains(t, u.validateURLMode(), "requires url_index_dir")
+
+	u.urlIndexDir = u.datastore
+	assert.ErrorContains(t, u.validateURLMode(), "must differ")
+}
@@ -0,0 +1,216 @@
+package uploader
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	ReasonMissing          = "missing"
+	ReasonSizeMismatch     = "size mismatch"
+	ReasonChecksumMismatch = "checksum mismatch"
+	ReasonUnreadable       = "unreadable"
+)
+
+// ValidateIssue describes an index entry whose archive file is missing or corrupt.
+type ValidateIssue struct {
+	IndexFile string
+	Entry     Entry
+	Reason    string
+}
+
+// ValidateReport is the result of Validate.
+type ValidateReport struct {
+	Checked int
+	Missing []ValidateIssue
+	Corrupt []ValidateIssue
+
+	// index files not ending with a clean stop checkpoint, when index_checkpoint is set
+	Unclean []string
+}
+
+// Validate checks every indexed archive file for existence and, when recorded,
+// for size and checksum. With index_checkpoint set it also reports the index
+// files which don't end with a clean stop checkpoint.
+func (u *Uploader) Validate(ctx context.Context) (report ValidateReport, err error) {
+
+	report = ValidateReport{
+		Missing: make([]ValidateIssue, 0),
+		Corrupt: make([]ValidateIssue, 0),
+		Unclean: make([]string, 0),
+	}
+
+	files, err := u.indexFiles(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, indexFilename := range files {
+
+		// checkpoints are only written to index files
+		if u.indexCheckpoint && u.sqlIndex == nil {
+			clean, _, err := StoppedCleanly(indexFilename)
+			if err != nil {
+				return report, err
+			}
+			if !clean {
+				report.Unclean = append(report.Unclean, indexFilename)
+			}
+		}
+
+		err = u.eachIndexEntry(ctx, indexFilename, func(entry Entry) error {
+
+			report.Checked++
+
+			reason, err := checkEntry(entry)
+			if err != nil {
+				return err
+			}
+
+			issue := ValidateIssue{
+				IndexFile: indexFilename,
+				Entry:     entry,
+				Reason:    reason,
+			}
+
+			switch reason {
+			case "":
+			case ReasonMissing:
+				report.Missing = append(report.Missing, issue)
+			default:
+				report.Corrupt = append(report.Corrupt, issue)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// checkEntry returns the reason why the archive file doesn't match the entry,
+// or an empty string if it does.
+func checkEntry(entry Entry) (string, error) {
+
+	// the archive file of a tombstone was removed on purpose
+	if !entry.Evicted.IsZero() {
+		return "", nil
+	}
+
+	fi, err := os.Stat(entry.ArchiveName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ReasonMissing, nil
+		}
+		return "", err
+	}
+
+	// size and checksum of a compressed archive refer to the uncompressed content
+	if entry.Compression != "" {
+		if entry.Size <= 0 && entry.Checksum == "" {
+			return "", nil
+		}
+
+		size, checksum, err := contentInfo(entry.ArchiveName, entry.Compression)
+		if err != nil {
+			return ReasonUnreadable, nil
+		}
+
+		if entry.Size > 0 && size != entry.Size {
+			return ReasonSizeMismatch, nil
+		}
+
+		if entry.Checksum != "" && checksum != entry.Checksum {
+			return ReasonChecksumMismatch, nil
+		}
+
+		return "", nil
+	}
+
+	if entry.Size > 0 && fi.Size() != entry.Size {
+		return ReasonSizeMismatch, nil
+	}
+
+	if entry.Checksum != "" {
+		checksum, err := fileChecksum(entry.ArchiveName)
+		if err != nil {
+			return "", err
+		}
+
+		if checksum != entry.Checksum {
+			return ReasonChecksumMismatch, nil
+		}
+	}
+
+	return "", nil
+}
+
+// eachIndexEntry calls fn for every entry of the index through the index
+// backend. Index files are streamed instead of listed.
+func (u *Uploader) eachIndexEntry(ctx context.Context, indexFilename string, fn func(Entry) error) error {
+
+	reader := u.indexReader(indexFilename)
+	if fr, ok := reader.(*FileIndexReader); ok {
+		return fr.each(ctx, fn)
+	}
+
+	entries, err := reader.List()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// eachIndexFile calls fn for every index file under the datastore, and under
+// url_index_dir in url_mode.
+func (u *Uploader) eachIndexFile(ctx context.Context, fn func(string) error) error {
+
+	if err := u.eachIndexFileUnder(ctx, u.datastore, fn); err != nil {
+		return err
+	}
+
+	if !u.urlMode {
+		return nil
+	}
+
+	return u.eachIndexFileUnder(ctx, u.urlIndexDir, fn)
+}
+
+func (u *Uploader) eachIndexFileUnder(ctx context.Context, root string, fn func(string) error) error {
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() || d.Name() != DefaultArchiveIndex {
+			return nil
+		}
+
+		return fn(p)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,72 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	u := newTestUploader(t)
+	u.checksum = true
+
+	good := createTestFile(t, u, "100/100/MSG_1.db", "good")
+	missing := createTestFile(t, u, "100/100/MSG_2.db", "missing")
+	corrupt := createTestFile(t, u, "100/100/MSG_3.db", "corrupt")
+
+	_, err := u.Archive("1", good)
+	assert.Nil(t, err)
+
+	missingArchive, err := u.Archive("2", missing)
+	assert.Nil(t, err)
+	err = os.Remove(missingArchive)
+	assert.Nil(t, err)
+
+	corruptArchive, err := u.Archive("3", corrupt)
+	assert.Nil(t, err)
+	err = os.WriteFile(corruptArchive, []byte("truncated-and-changed"), 0644)
+	assert.Nil(t, err)
+
+	report, err := u.Validate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.Checked)
+
+	assert.Len(t, report.Missing, 1)
+	assert.Equal(t, "2", report.Missing[0].Entry.Seq)
+
+	assert.Len(t, report.Corrupt, 1)
+	assert.Equal(t, "3", report.Corrupt[0].Entry.Seq)
+	assert.Equal(t, ReasonSizeMismatch, report.Corrupt[0].Reason)
+}
+
+func TestValidateCancelled(t *testing.T) {
+	u := newTestUploader(t)
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "good")
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = u.Validate(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestEntryFields(t *testing.T) {
+	entry := Entry{
+		Seq:         "1",
+		ArchiveName: "archivestore/100/100/MSG_1.db",
+		Size:        4,
+		Checksum:    "abcd",
+	}
+
+	line := entry.String()
+	assert.Equal(t, "1:archivestore/100/100/MSG_1.db\tsize=4\tsha256=abcd", line)
+
+	parsed, ok := parseEntry(line)
+	assert.True(t, ok)
+	assert.Equal(t, entry, parsed)
+}
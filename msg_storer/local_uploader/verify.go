@@ -0,0 +1,162 @@
+package uploader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	VerifyNone    = "none"
+	VerifyFull    = "full"
+	VerifySampled = "sampled"
+
+	DefaultVerifyMode       = VerifyNone
+	DefaultVerifySamples    = 3
+	DefaultVerifySampleSize = 4096
+)
+
+var (
+	ErrVerifyMismatch = errors.New("Archive copy doesn't match the source.")
+)
+
+func validateVerifyMode(mode string) error {
+
+	switch mode {
+	case VerifyNone, VerifyFull, VerifySampled:
+		return nil
+	}
+
+	return fmt.Errorf("invalid verify_mode: %s", mode)
+}
+
+// verifyCopy compares a copy against its source before the source is removed.
+// Compressed copies can't be sampled, so they are always fully verified.
+func (u *Uploader) verifyCopy(filename string, copyName string, compression string) error {
+
+	mode := u.verifyMode
+	if mode == VerifySampled && compression != CompressionNone {
+		mode = VerifyFull
+	}
+
+	if mode != VerifyNone {
+		u.dropCache(copyName)
+	}
+
+	var err error
+	switch mode {
+	case VerifyFull:
+		err = verifyFull(filename, copyName, compression)
+	case VerifySampled:
+		err = verifySampled(filename, copyName, u.verifySamples, u.verifySampleSize)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w (fileName: %s): %v", ErrVerifyMismatch, filename, err)
+	}
+
+	return nil
+}
+
+func verifyFull(filename string, copyName string, compression string) error {
+
+	size, checksum, err := contentInfo(filename, CompressionNone)
+	if err != nil {
+		return err
+	}
+
+	copySize, copyChecksum, err := contentInfo(copyName, compression)
+	if err != nil {
+		return err
+	}
+
+	if size != copySize {
+		return fmt.Errorf("size %d != %d", copySize, size)
+	}
+
+	if checksum != copyChecksum {
+		return fmt.Errorf("checksum %s != %s", copyChecksum, checksum)
+	}
+
+	return nil
+}
+
+// verifySampled compares the sizes and samples spread from head to tail.
+func verifySampled(filename string, copyName string, samples int, sampleSize int) error {
+
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Open(copyName)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstInfo, err := dst.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := srcInfo.Size()
+	if dstInfo.Size() != size {
+		return fmt.Errorf("size %d != %d", dstInfo.Size(), size)
+	}
+
+	for _, offset := range sampleOffsets(size, samples, int64(sampleSize)) {
+
+		a, err := readSample(src, offset, sampleSize)
+		if err != nil {
+			return err
+		}
+
+		b, err := readSample(dst, offset, sampleSize)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(a, b) {
+			return fmt.Errorf("sample at offset %d differs", offset)
+		}
+	}
+
+	return nil
+}
+
+// sampleOffsets returns the offsets of samples evenly spread from the head to the tail.
+func sampleOffsets(size int64, samples int, sampleSize int64) []int64 {
+
+	last := size - sampleSize
+	if last <= 0 || samples <= 1 {
+		return []int64{0}
+	}
+
+	offsets := make([]int64, 0, samples)
+	for i := 0; i < samples; i++ {
+		offsets = append(offsets, last*int64(i)/int64(samples-1))
+	}
+
+	return offsets
+}
+
+func readSample(f *os.File, offset int64, sampleSize int) ([]byte, error) {
+
+	buf := make([]byte, sampleSize)
+
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
@@ -0,0 +1,96 @@
+package uploader
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newVerifyUploader(t *testing.T, mode string) *Uploader {
+	u := newTestUploader(t)
+	u.verifyMode = mode
+	u.verifySamples = DefaultVerifySamples
+	u.verifySampleSize = 16
+
+	return u
+}
+
+func TestVerifySampledCopy(t *testing.T) {
+	u := newVerifyUploader(t, VerifySampled)
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", strings.Repeat("0123456789", 100))
+	archiveName := path.Join(u.archivestore, "MSG_1.db")
+
+	err := os.MkdirAll(u.archivestore, 0750)
+	assert.Nil(t, err)
+
+	err = u.copyFile(filename, archiveName)
+	assert.Nil(t, err)
+
+	_, err = os.Stat(archiveName)
+	assert.Nil(t, err)
+}
+
+func TestVerifySampledTruncated(t *testing.T) {
+	u := newVerifyUploader(t, VerifySampled)
+
+	content := strings.Repeat("0123456789", 100)
+	filename := createTestFile(t, u, "100/300/MSG_1.db", content)
+	truncated := createTestFile(t, u, "100/300/MSG_1.copy", content[:500])
+
+	err := u.verifyCopy(filename, truncated, CompressionNone)
+	assert.True(t, errors.Is(err, ErrVerifyMismatch))
+
+	// the message is Nak'd, not Term'd
+	assert.False(t, isPermanent(err))
+}
+
+func TestVerifySampledCorruptTail(t *testing.T) {
+	u := newVerifyUploader(t, VerifySampled)
+
+	content := strings.Repeat("0123456789", 100)
+	filename := createTestFile(t, u, "100/300/MSG_1.db", content)
+	corrupt := createTestFile(t, u, "100/300/MSG_1.copy", content[:len(content)-1]+"x")
+
+	err := u.verifyCopy(filename, corrupt, CompressionNone)
+	assert.True(t, errors.Is(err, ErrVerifyMismatch))
+}
+
+func TestVerifyFull(t *testing.T) {
+	u := newVerifyUploader(t, VerifyFull)
+
+	content := strings.Repeat("0123456789", 100)
+	filename := createTestFile(t, u, "100/300/MSG_1.db", content)
+	same := createTestFile(t, u, "100/300/MSG_1.same", content)
+	corrupt := createTestFile(t, u, "100/300/MSG_1.copy", "x"+content[1:])
+
+	assert.Nil(t, u.verifyCopy(filename, same, CompressionNone))
+	assert.True(t, errors.Is(u.verifyCopy(filename, corrupt, CompressionNone), ErrVerifyMismatch))
+}
+
+func TestVerifyCompressed(t *testing.T) {
+	u := newVerifyUploader(t, VerifySampled)
+	u.compression = CompressionGzip
+
+	filename := createTestFile(t, u, "100/300/MSG_1.db", strings.Repeat("0123456789", 100))
+
+	_, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+}
+
+func TestSampleOffsets(t *testing.T) {
+	assert.Equal(t, []int64{0}, sampleOffsets(10, 3, 16))
+	assert.Equal(t, []int64{0, 42, 84}, sampleOffsets(100, 3, 16))
+	assert.Equal(t, []int64{0}, sampleOffsets(100, 1, 16))
+}
+
+func TestValidateVerifyMode(t *testing.T) {
+	assert.Nil(t, validateVerifyMode(VerifyNone))
+	assert.Nil(t, validateVerifyMode(VerifyFull))
+	assert.Nil(t, validateVerifyMode(VerifySampled))
+	assert.NotNil(t, validateVerifyMode("deep"))
+}
@@ -0,0 +1,145 @@
+package uploader
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	VersioningOff       = "off"
+	VersioningCounter   = "counter"
+	VersioningTimestamp = "timestamp"
+
+	DefaultVersioning  = VersioningOff
+	DefaultMaxVersions = 0
+
+	versionTimeFormat = "20060102T150405"
+)
+
+func validateVersioning(versioning string) error {
+
+	switch versioning {
+	case VersioningOff, VersioningCounter, VersioningTimestamp:
+		return nil
+	}
+
+	return fmt.Errorf("invalid versioning: %s", versioning)
+}
+
+// versions returns the entries of the versions of the logical archive name,
+// oldest first, one per archive file.
+func (u *Uploader) versions(filename string, logical string) ([]Entry, error) {
+
+	entries, err := u.indexReader(filename).List()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]Entry, 0)
+	seen := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Logical != logical {
+			continue
+		}
+
+		// the latest entry of the archive file tells whether it was pruned
+		if i, ok := seen[entry.ArchiveName]; ok {
+			versions[i] = entry
+			continue
+		}
+
+		seen[entry.ArchiveName] = len(versions)
+		versions = append(versions, entry)
+	}
+
+	return versions, nil
+}
+
+// versionArchiveName returns the archive name of the next version of the
+// logical archive name, a .v<n> or .v<timestamp> suffix before the extension
+// once the logical name was archived.
+func (u *Uploader) versionArchiveName(filename string, logical string) (string, error) {
+
+	versions, err := u.versions(filename, logical)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = u.fileSystem().Stat(logical)
+	if os.IsNotExist(err) && len(versions) == 0 {
+		return logical, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	ext := path.Ext(logical)
+	base := strings.TrimSuffix(logical, ext)
+
+	if u.versioning == VersioningTimestamp {
+		t := time.Now().UTC()
+		candidate := fmt.Sprintf("%s.v%s%09d%s", base, t.Format(versionTimeFormat), t.Nanosecond(), ext)
+		if _, err := u.fileSystem().Stat(candidate); !os.IsNotExist(err) {
+			return "", fmt.Errorf("%w (archiveName: %s)", ErrArchiveCollision, candidate)
+		}
+		return candidate, nil
+	}
+
+	// number on from the versions ever recorded, so a pruned number isn't reused
+	next := len(versions)
+	if next == 0 {
+		next = 1
+	}
+
+	for i := next; i < next+maxCollisionSuffix; i++ {
+		candidate := fmt.Sprintf("%s.v%d%s", base, i, ext)
+		if _, err := u.fileSystem().Stat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w (archiveName: %s)", ErrArchiveCollision, logical)
+}
+
+// pruneVersions removes the oldest versions of the logical archive name
+// beyond max_versions. The caller holds the lock of the index.
+func (u *Uploader) pruneVersions(filename string, logical string) error {
+
+	if u.maxVersions <= 0 {
+		return nil
+	}
+
+	versions, err := u.versions(filename, logical)
+	if err != nil {
+		return err
+	}
+
+	live := make([]Entry, 0, len(versions))
+	for _, entry := range versions {
+		if entry.Evicted.IsZero() {
+			live = append(live, entry)
+		}
+	}
+
+	if len(live) <= u.maxVersions {
+		return nil
+	}
+
+	victims := live[:len(live)-u.maxVersions]
+	n, err := u.removeArchives(u.indexFilename(filename), victims)
+	if err != nil {
+		return err
+	}
+
+	u.logger.Info("Pruned archive versions",
+		zap.String("archiveName", logical),
+		zap.Int("count", n),
+	)
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package uploader
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersioning(t *testing.T) {
+	u := newTestUploader(t)
+	u.versioning = VersioningCounter
+	u.maxVersions = 2
+
+	logical := path.Join(u.archivestore, "100/100/MSG_1.db")
+
+	names := make([]string, 0)
+	for i, content := range []string{"first", "second", "third"} {
+		filename := createTestFile(t, u, "100/100/MSG_1.db", content)
+
+		archiveName, err := u.Archive(strings.Repeat("1", i+1), filename)
+		assert.Nil(t, err)
+		names = append(names, archiveName)
+	}
+
+	assert.Equal(t, []string{
+		logical,
+		path.Join(u.archivestore, "100/100/MSG_1.v1.db"),
+		path.Join(u.archivestore, "100/100/MSG_1.v2.db"),
+	}, names)
+
+	// the oldest version is pruned
+	_, err := os.Stat(names[0])
+	assert.True(t, os.IsNotExist(err))
+
+	for i, content := range []string{"second", "third"} {
+		data, err := os.ReadFile(names[i+1])
+		assert.Nil(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	filename := path.Join(u.datastore, "100/100/MSG_1.db")
+	versions, err := u.versions(filename, logical)
+	assert.Nil(t, err)
+	if assert.Len(t, versions, 3) {
+		assert.False(t, versions[0].Evicted.IsZero())
+		assert.True(t, versions[1].Evicted.IsZero())
+		assert.True(t, versions[2].Evicted.IsZero())
+	}
+
+	// a pruned name isn't reused
+	createTestFile(t, u, "100/100/MSG_1.db", "fourth")
+	archiveName, err := u.Archive("1111", filename)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(u.archivestore, "100/100/MSG_1.v3.db"), archiveName)
+}
+
+func TestVersioningTimestamp(t *testing.T) {
+	u := newTestUploader(t)
+	u.versioning = VersioningTimestamp
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "first")
+	first, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	createTestFile(t, u, "100/100/MSG_1.db", "second")
+	second, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	assert.Regexp(t, `/MSG_1\.v\d{8}T\d{15}\.db$`, second)
+	assert.FileExists(t, first)
+	assert.FileExists(t, second)
+
+	// both versions share the logical name
+	entry, err := u.indexReader(filename).LookupArchiveName(second)
+	assert.Nil(t, err)
+	assert.Equal(t, first, entry.Logical)
+}
+
+func TestVersioningEntry(t *testing.T) {
+	entry, ok := parseEntry("1:archivestore/MSG_1.v1.db\tlogical=archivestore%2FMSG_1.db")
+	assert.True(t, ok)
+	assert.Equal(t, "archivestore/MSG_1.db", entry.Logical)
+	assert.Equal(t, "1:archivestore/MSG_1.v1.db\tlogical=archivestore%2FMSG_1.db", entry.String())
+}
+
+func TestValidateVersioning(t *testing.T) {
+	assert.Nil(t, validateVersioning(VersioningCounter))
+	assert.NotNil(t, validateVersioning("git"))
+}
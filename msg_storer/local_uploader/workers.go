@@ -0,0 +1,184 @@
+package uploader
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultWorkers   = 1
+	DefaultQueueSize = 64
+	DefaultOrderKey  = ""
+
+	OrderKeyDir          = "dir"
+	OrderKeyHeaderPrefix = "header:"
+)
+
+// workerPool handles messages concurrently. Messages with the same key always
+// go to the same worker, so they are handled serially in arrival order.
+//
+// A priority pool instead shares a priority queue between the workers.
+type workerPool struct {
+	queues   []chan *nats.Msg
+	priority *priorityQueue
+	wg       sync.WaitGroup
+	next     atomic.Uint64
+}
+
+func newWorkerPool(workers int, queueSize int, handle func(*nats.Msg)) *workerPool {
+
+	p := &workerPool{
+		queues: make([]chan *nats.Msg, workers),
+	}
+
+	for i := range p.queues {
+		queue := make(chan *nats.Msg, queueSize)
+		p.queues[i] = queue
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for m := range queue {
+				handle(m)
+			}
+		}()
+	}
+
+	return p
+}
+
+func newPriorityWorkerPool(workers int, queueSize int, levels int, aging time.Duration, handle func(*nats.Msg)) *workerPool {
+
+	p := &workerPool{
+		priority: newPriorityQueue(levels, queueSize, aging),
+	}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for {
+				m, ok := p.priority.pop()
+				if !ok {
+					return
+				}
+				handle(m)
+			}
+		}()
+	}
+
+	return p
+}
+
+// dispatch queues the message on the worker of the key, or on the next worker
+// without a key. It blocks while the queue is full.
+func (p *workerPool) dispatch(key string, m *nats.Msg) {
+
+	var i uint64
+	if key == "" {
+		i = p.next.Add(1)
+	} else {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		i = h.Sum64()
+	}
+
+	p.queues[i%uint64(len(p.queues))] <- m
+}
+
+// dispatchPriority queues the message on the priority queue. It blocks while
+// the queue is full.
+func (p *workerPool) dispatchPriority(priority int, m *nats.Msg) {
+	p.priority.push(priority, m)
+}
+
+// queued returns the messages waiting for a worker.
+func (p *workerPool) queued() int {
+
+	if p.priority != nil {
+		return p.priority.len()
+	}
+
+	queued := 0
+	for _, queue := range p.queues {
+		queued += len(queue)
+	}
+
+	return queued
+}
+
+// stop waits for the queued messages to be handled.
+func (p *workerPool) stop() {
+
+	if p.priority != nil {
+		p.priority.close()
+	}
+
+	for _, queue := range p.queues {
+		close(queue)
+	}
+
+	p.wg.Wait()
+}
+
+func validateOrderKey(orderKey string) error {
+
+	switch {
+	case orderKey == DefaultOrderKey, orderKey == OrderKeyDir:
+		return nil
+	case strings.HasPrefix(orderKey, OrderKeyHeaderPrefix) && len(orderKey) > len(OrderKeyHeaderPrefix):
+		return nil
+	}
+
+	return fmt.Errorf("invalid order_key: %s", orderKey)
+}
+
+// orderKey returns the key of the message which is handled serially, either
+// the directory of the file or the value of a header.
+func (u *Uploader) orderKey(m *nats.Msg) string {
+
+	switch {
+	case u.orderKeySource == OrderKeyDir:
+		j, err := parseJob(m)
+		if err != nil {
+			return ""
+		}
+		return path.Dir(cleanFilename(j.filename))
+
+	case strings.HasPrefix(u.orderKeySource, OrderKeyHeaderPrefix):
+		if m.Header == nil {
+			return ""
+		}
+		return m.Header.Get(strings.TrimPrefix(u.orderKeySource, OrderKeyHeaderPrefix))
+	}
+
+	return ""
+}
+
+// dispatch is the subscription handler when messages are handled by workers.
+func (u *Uploader) dispatch(m *nats.Msg) {
+
+	if u.priorityHeader != "" {
+		u.pool.dispatchPriority(u.messagePriority(m), m)
+		return
+	}
+
+	u.pool.dispatch(u.orderKey(m), m)
+}
+
+// handler returns the subscription handler.
+func (u *Uploader) handler() nats.MsgHandler {
+
+	if u.pool != nil {
+		return u.dispatch
+	}
+
+	return u.coreHandler()
+}
@@ -0,0 +1,102 @@
+package uploader
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerPoolOrder(t *testing.T) {
+	var mutex sync.Mutex
+	handled := make(map[string][]string)
+	var active, maxActive atomic.Int32
+
+	pool := newWorkerPool(4, 16, func(m *nats.Msg) {
+		n := active.Add(1)
+		for {
+			max := maxActive.Load()
+			if n <= max || maxActive.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		active.Add(-1)
+
+		mutex.Lock()
+		handled[m.Subject] = append(handled[m.Subject], string(m.Data))
+		mutex.Unlock()
+	})
+
+	keys := []string{"a", "b", "c", "d"}
+	for i := 0; i < 10; i++ {
+		for _, key := range keys {
+			pool.dispatch(key, &nats.Msg{Subject: key, Data: []byte(fmt.Sprint(i))})
+		}
+	}
+	pool.stop()
+
+	// same key messages are handled in arrival order
+	for _, key := range keys {
+		expected := make([]string, 0)
+		for i := 0; i < 10; i++ {
+			expected = append(expected, fmt.Sprint(i))
+		}
+		assert.Equal(t, expected, handled[key], key)
+	}
+
+	// different keys overlap
+	assert.Greater(t, maxActive.Load(), int32(1))
+}
+
+func TestWorkerPoolArchiveOrder(t *testing.T) {
+	u := newTestUploader(t)
+	u.orderKeySource = OrderKeyDir
+	u.pool = newWorkerPool(4, 16, u.msgHandler)
+
+	for i := 0; i < 10; i++ {
+		for _, dir := range []string{"a", "b"} {
+			filename := createTestFile(t, u, fmt.Sprintf("100/%s/MSG_%d.db", dir, i), "data")
+			u.handler()(&nats.Msg{
+				Subject: "test",
+				Data:    []byte(fmt.Sprintf("%d:%s", i, filename)),
+			})
+		}
+	}
+	u.pool.stop()
+
+	for _, dir := range []string{"a", "b"} {
+		entries, err := NewIndexReader(u.indexFilename(u.datastore + "/100/" + dir + "/MSG_0.db")).List()
+		assert.Nil(t, err)
+		if assert.Len(t, entries, 10) {
+			for i, entry := range entries {
+				assert.Equal(t, fmt.Sprint(i), entry.Seq)
+			}
+		}
+	}
+}
+
+func TestOrderKey(t *testing.T) {
+	u := newTestUploader(t)
+	m := nats.NewMsg("test")
+	m.Data = []byte("1:datastore/100/300/MSG_1.db")
+	m.Header.Set("X-Job-Id", "job-1")
+
+	assert.Equal(t, "", u.orderKey(m))
+
+	u.orderKeySource = OrderKeyDir
+	assert.Equal(t, "datastore/100/300", u.orderKey(m))
+
+	u.orderKeySource = OrderKeyHeaderPrefix + "X-Job-Id"
+	assert.Equal(t, "job-1", u.orderKey(m))
+
+	assert.Nil(t, validateOrderKey(""))
+	assert.Nil(t, validateOrderKey(OrderKeyDir))
+	assert.Nil(t, validateOrderKey("header:X-Job-Id"))
+	assert.NotNil(t, validateOrderKey("header:"))
+	assert.NotNil(t, validateOrderKey("subject"))
+}
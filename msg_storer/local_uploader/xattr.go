@@ -0,0 +1,44 @@
+package uploader
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultXattrSeq    = "user.archive.seq"
+	DefaultXattrOrigin = "user.archive.origin"
+)
+
+var (
+	ErrXattrUnsupported = errors.New("Extended attributes are not supported on this platform.")
+)
+
+// tagArchive sets the extended attributes on the archive file. Not every
+// filesystem supports them, so failures are only logged.
+func (u *Uploader) tagArchive(archiveName string, filename string, seq string) {
+
+	if !u.setXattrs {
+		return
+	}
+
+	attrs := map[string]string{
+		u.xattrSeq:    seq,
+		u.xattrOrigin: filename,
+	}
+
+	for name, value := range attrs {
+		if name == "" {
+			continue
+		}
+
+		if err := setXattr(archiveName, name, value); err != nil {
+			u.logger.Warn("Failed to set extended attribute",
+				zap.String("archiveName", archiveName),
+				zap.String("attr", name),
+				zap.Error(err),
+			)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package uploader
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func setXattr(filename string, name string, value string) error {
+	return unix.Setxattr(filename, name, []byte(value), 0)
+}
+
+func getXattr(filename string, name string) (string, error) {
+
+	buf := make([]byte, 1024)
+	n, err := unix.Getxattr(filename, name, buf)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf[:n]), nil
+}
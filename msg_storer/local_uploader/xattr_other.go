@@ -0,0 +1,11 @@
+//go:build !linux
+
+package uploader
+
+func setXattr(filename string, name string, value string) error {
+	return ErrXattrUnsupported
+}
+
+func getXattr(filename string, name string) (string, error) {
+	return "", ErrXattrUnsupported
+}
@@ -0,0 +1,32 @@
+package uploader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetXattrs(t *testing.T) {
+	u := newTestUploader(t)
+	u.setXattrs = true
+	u.xattrSeq = DefaultXattrSeq
+	u.xattrOrigin = DefaultXattrOrigin
+
+	filename := createTestFile(t, u, "100/100/MSG_1.db", "data")
+
+	// probe filesystem support
+	if err := setXattr(filename, DefaultXattrSeq, "probe"); err != nil {
+		t.Skipf("extended attributes unsupported: %v", err)
+	}
+
+	archiveName, err := u.Archive("1", filename)
+	assert.Nil(t, err)
+
+	value, err := getXattr(archiveName, DefaultXattrSeq)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", value)
+
+	value, err = getXattr(archiveName, DefaultXattrOrigin)
+	assert.Nil(t, err)
+	assert.Equal(t, filename, value)
+}
@@ -2,11 +2,15 @@ package msg_storer
 
 import (
 	"bufio"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -152,21 +156,70 @@ func (sr *Storer) GetArchivedFileBySeq(dstPath string, seq uint64) (string, erro
 	}
 
 	// search archived url/path by seq
-	// read index file
+	// read the rotated shards of the index from the oldest, then the index
 	dstFile := path.Join(dstDir, DefaultArchiveIndex)
-	fr, err := os.Open(dstFile)
+	shards, err := filepath.Glob(dstFile + ".*.gz")
 	if err != nil {
 		return "", err
 	}
+	sort.Strings(shards)
+
+	afile := ""
+	for _, filename := range append(shards, dstFile) {
+		found, done, err := sr.scanIndex(filename, seq)
+		if err != nil {
+			// the index is only recreated by the next entry after a rotation
+			if os.IsNotExist(err) && filename == dstFile && len(shards) > 0 {
+				break
+			}
+			return "", err
+		}
+		if found != "" {
+			afile = found
+		}
+		if done {
+			break
+		}
+	}
+
+	if afile != "" {
+
+		return afile, nil
+	}
+
+	return "", ErrSeqNotFound
+
+}
+
+// scanIndex returns the archive name of the last entry of the index file at or
+// below seq, and whether a later entry ended the search.
+func (sr *Storer) scanIndex(filename string, seq uint64) (string, bool, error) {
+
+	fr, err := os.Open(filename)
+	if err != nil {
+		return "", false, err
+	}
 	defer fr.Close()
 
+	var r io.Reader = fr
+	if strings.HasSuffix(filename, ".gz") {
+		zr, err := gzip.NewReader(fr)
+		if err != nil {
+			return "", false, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
 	// new scanner
-	scanner := bufio.NewScanner(fr)
+	scanner := bufio.NewScanner(r)
 
 	// scan
 	afile := ""
 	for scanner.Scan() {
-		parseData := strings.SplitN(scanner.Text(), ":", 2)
+		// entries may carry tab separated fields after the archive name
+		line, _, _ := strings.Cut(scanner.Text(), "\t")
+		parseData := strings.SplitN(line, ":", 2)
 		archiveSeq, err := strconv.ParseUint(parseData[0], 10, 64)
 		if err != nil {
 			sr.logger.Error(err.Error())
@@ -175,17 +228,11 @@ func (sr *Storer) GetArchivedFileBySeq(dstPath string, seq uint64) (string, erro
 		if seq >= archiveSeq {
 			afile = parseData[1]
 		} else {
-			break
+			return afile, true, nil
 		}
 	}
 
-	if afile != "" {
-
-		return afile, nil
-	}
-
-	return "", ErrSeqNotFound
-
+	return afile, false, scanner.Err()
 }
 
 func (sr *Storer) MsgStore(dstPath string, seq uint64, rawData []byte) (string, error) {
@@ -2,6 +2,8 @@ package msg_storer
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"log"
@@ -14,12 +16,14 @@ import (
 
 	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"github.com/weedbox/common-modules/configs"
 	"github.com/weedbox/common-modules/daemon"
 	"github.com/weedbox/common-modules/logger"
 	"github.com/weedbox/common-modules/nats_connector"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 )
 
 func runNatsServer() *server.Server {
@@ -397,3 +401,47 @@ func BenchmarkUpdateIndex(b *testing.B) {
 	}
 	b.StopTimer()
 }
+
+func TestGetArchivedFileBySeqRotated(t *testing.T) {
+	sr := &Storer{
+		logger:    zap.NewNop(),
+		datastore: t.TempDir(),
+	}
+
+	dstDir := path.Join(sr.datastore, "100/100")
+	if err := os.MkdirAll(dstDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name string, data string) {
+		if err := os.WriteFile(path.Join(dstDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(DefaultCurrentDB, "5001:current\n")
+
+	// rotated shard holding the older entries
+	var shard bytes.Buffer
+	zw := gzip.NewWriter(&shard)
+	zw.Write([]byte("1001:MSG_1001.db\n2001:MSG_2001.db\n"))
+	zw.Close()
+	write(DefaultArchiveIndex+".1700000000000000000.gz", shard.String())
+
+	write(DefaultArchiveIndex, "3001:MSG_3001.db\n")
+
+	aFile, err := sr.GetArchivedFileBySeq("100/100", 1500)
+	assert.Nil(t, err)
+	assert.Equal(t, "MSG_1001.db", aFile)
+
+	aFile, err = sr.GetArchivedFileBySeq("100/100", 2500)
+	assert.Nil(t, err)
+	assert.Equal(t, "MSG_2001.db", aFile)
+
+	aFile, err = sr.GetArchivedFileBySeq("100/100", 4000)
+	assert.Nil(t, err)
+	assert.Equal(t, "MSG_3001.db", aFile)
+
+	_, err = sr.GetArchivedFileBySeq("100/100", 1)
+	assert.ErrorIs(t, err, ErrSeqNotFound)
+}